@@ -1,18 +1,61 @@
 package main
 
 import (
+	"context"
 	"log"
+	"os"
+	"os/signal"
+	"syscall"
 
+	"auto-lmk/pkg/api"
 	"auto-lmk/pkg/cli"
 	"auto-lmk/pkg/tools"
+	"auto-lmk/pkg/whatsapp"
 )
 
 func main() {
 	// Create WhatsApp manager with custom database directory
-	manager := tools.NewWhatsAppManager("./data")
+	manager := tools.NewWhatsAppManager(tools.EnvString("DATA_DIR", "./data"))
+
+	// Every client answers AI chats through its own service
+	services := whatsapp.NewClientServices(manager)
+
+	// Restore clients paired in earlier runs
+	if tools.EnvBool("RESTORE_CLIENTS", true) {
+		if _, err := manager.LoadExistingClients(tools.EnvBool("AUTO_CONNECT_ON_STARTUP", true)); err != nil {
+			log.Printf("Failed to restore some clients: %v", err)
+		}
+	}
+
+	// Disconnect all clients cleanly on Ctrl+C or SIGTERM
+	go func() {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+		<-sig
+
+		ctx, cancel := context.WithTimeout(context.Background(), cli.ShutdownTimeout)
+		defer cancel()
+		if err := manager.Shutdown(ctx); err != nil {
+			log.Printf("Shutdown incomplete: %v", err)
+		}
+		os.Exit(0)
+	}()
+
+	// Optional HTTP API for headless deployments
+	if addr := os.Getenv("API_ADDR"); addr != "" {
+		server := api.NewServer(addr, manager, services)
+		if os.Getenv("HEADLESS") == "true" {
+			log.Fatal(server.ListenAndServe())
+		}
+		go func() {
+			if err := server.ListenAndServe(); err != nil {
+				log.Printf("HTTP API stopped: %v", err)
+			}
+		}()
+	}
 
 	// Create and run CLI menu
-	menu := cli.NewMenu(manager)
+	menu := cli.NewMenu(manager, services)
 
 	log.Println("📱 WhatsApp Multi-Client Manager")
 	log.Println("================================")
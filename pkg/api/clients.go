@@ -0,0 +1,184 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"auto-lmk/pkg/tools"
+)
+
+type clientStatusResponse struct {
+	PhoneID   string `json:"phoneID"`
+	Connected bool   `json:"connected"`
+	Paused    bool   `json:"paused"` // the bot ignores or queues incoming messages
+	Database  string `json:"database"`
+}
+
+type addClientRequest struct {
+	PhoneID string `json:"phoneID"`
+}
+
+// connectEvent is one line of the newline-delimited JSON stream returned by the connect endpoint
+type connectEvent struct {
+	Event string `json:"event"`          // "qr", "connected" or "error"
+	QR    string `json:"qr,omitempty"`   // PNG data URL of the QR code to scan
+	Code  string `json:"code,omitempty"` // raw QR payload
+	Error string `json:"error,omitempty"`
+}
+
+// registerClientRoutes adds the client management endpoints
+func (s *Server) registerClientRoutes() {
+	s.mux.HandleFunc("GET /clients", s.handleListClients)
+	s.mux.HandleFunc("POST /clients", s.handleAddClient)
+	s.mux.HandleFunc("DELETE /clients/{phoneID}", s.handleRemoveClient)
+	s.mux.HandleFunc("POST /clients/{phoneID}/connect", s.handleConnectClient)
+	s.mux.HandleFunc("POST /clients/{phoneID}/logout", s.handleLogoutClient)
+	s.mux.HandleFunc("GET /clients/{phoneID}/status", s.handleClientStatus)
+}
+
+// managerErrorStatus maps manager errors to HTTP status codes
+func managerErrorStatus(err error) int {
+	if errors.Is(err, tools.ErrClientNotFound) {
+		return http.StatusNotFound
+	}
+	if errors.Is(err, tools.ErrClientNotConnected) || errors.Is(err, tools.ErrClientNotPaired) || strings.Contains(err.Error(), "already") {
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+func (s *Server) clientStatus(phoneID string) (clientStatusResponse, error) {
+	connected, database, err := s.manager.GetClientStatus(phoneID)
+	if err != nil {
+		return clientStatusResponse{}, err
+	}
+	return clientStatusResponse{PhoneID: phoneID, Connected: connected, Paused: s.isPaused(phoneID), Database: database}, nil
+}
+
+func (s *Server) handleListClients(w http.ResponseWriter, r *http.Request) {
+	clients := make([]clientStatusResponse, 0)
+	for _, phoneID := range s.manager.ListClients() {
+		status, err := s.clientStatus(phoneID)
+		if err != nil {
+			continue // removed while listing
+		}
+		clients = append(clients, status)
+	}
+	writeJSON(w, http.StatusOK, clients)
+}
+
+func (s *Server) handleAddClient(w http.ResponseWriter, r *http.Request) {
+	var req addClientRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+		return
+	}
+	if strings.TrimSpace(req.PhoneID) == "" {
+		writeError(w, http.StatusBadRequest, errors.New(`missing "phoneID" field`))
+		return
+	}
+
+	if _, err := s.manager.AddClient(req.PhoneID); err != nil {
+		writeError(w, managerErrorStatus(err), err)
+		return
+	}
+
+	status, err := s.clientStatus(req.PhoneID)
+	if err != nil {
+		writeError(w, managerErrorStatus(err), err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, status)
+}
+
+func (s *Server) handleRemoveClient(w http.ResponseWriter, r *http.Request) {
+	if err := s.manager.RemoveClient(r.PathValue("phoneID")); err != nil {
+		writeError(w, managerErrorStatus(err), err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleLogoutClient unpairs a client; connecting it again requires a new QR scan
+func (s *Server) handleLogoutClient(w http.ResponseWriter, r *http.Request) {
+	phoneID := r.PathValue("phoneID")
+	if err := s.manager.LogoutClient(phoneID); err != nil {
+		writeError(w, managerErrorStatus(err), err)
+		return
+	}
+
+	status, err := s.clientStatus(phoneID)
+	if err != nil {
+		writeError(w, managerErrorStatus(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+func (s *Server) handleClientStatus(w http.ResponseWriter, r *http.Request) {
+	status, err := s.clientStatus(r.PathValue("phoneID"))
+	if err != nil {
+		writeError(w, managerErrorStatus(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+// handleConnectClient connects a client and streams newline-delimited JSON events: a "qr"
+// event with a PNG data URL for every QR code, then "connected" or "error". Already
+// paired clients get a single "connected" event.
+func (s *Server) handleConnectClient(w http.ResponseWriter, r *http.Request) {
+	phoneID := r.PathValue("phoneID")
+	if _, err := s.manager.GetClient(phoneID); err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+
+	events := make(chan connectEvent, 8)
+	go func() {
+		defer close(events)
+		err := s.manager.ConnectClientWithQRCallback(phoneID, func(code string) {
+			evt := connectEvent{Event: "qr", Code: code}
+			if png, err := tools.QRCodePNG(code); err == nil {
+				evt.QR = "data:image/png;base64," + base64.StdEncoding.EncodeToString(png)
+			}
+			select {
+			case events <- evt:
+			default: // the HTTP client stopped reading; keep the login going
+			}
+		})
+		result := connectEvent{Event: "connected"}
+		if err != nil {
+			result = connectEvent{Event: "error", Error: err.Error()}
+		}
+		select {
+		case events <- result:
+		case <-r.Context().Done():
+		}
+	}()
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	encoder := json.NewEncoder(w)
+	for {
+		select {
+		case evt, ok := <-events:
+			if !ok {
+				return
+			}
+			if err := encoder.Encode(evt); err != nil {
+				return
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+)
+
+type pipelineResponse struct {
+	PhoneID  string `json:"phoneID"`
+	Paused   bool   `json:"paused"`
+	Replayed int    `json:"replayed,omitempty"` // queued messages replayed on resume
+}
+
+// registerPipelineRoutes adds the endpoints pausing and resuming a client's bot
+func (s *Server) registerPipelineRoutes() {
+	s.mux.HandleFunc("POST /clients/{phoneID}/pause", s.handlePause)
+	s.mux.HandleFunc("POST /clients/{phoneID}/resume", s.handleResume)
+}
+
+// getPipeline returns the pausable service of a client
+func (s *Server) getPipeline(phoneID string) (PipelineController, error) {
+	service, err := s.getAIService(phoneID)
+	if err != nil {
+		return nil, err
+	}
+	pipeline, ok := service.(PipelineController)
+	if !ok {
+		return nil, fmt.Errorf("client %s can't be paused", phoneID)
+	}
+	return pipeline, nil
+}
+
+// isPaused reports whether a client's bot is paused; clients without a pausable service never are
+func (s *Server) isPaused(phoneID string) bool {
+	pipeline, err := s.getPipeline(phoneID)
+	return err == nil && pipeline.IsPaused()
+}
+
+func (s *Server) handlePause(w http.ResponseWriter, r *http.Request) {
+	phoneID := r.PathValue("phoneID")
+	pipeline, err := s.getPipeline(phoneID)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+
+	pipeline.Pause()
+	writeJSON(w, http.StatusOK, pipelineResponse{PhoneID: phoneID, Paused: true})
+}
+
+func (s *Server) handleResume(w http.ResponseWriter, r *http.Request) {
+	phoneID := r.PathValue("phoneID")
+	pipeline, err := s.getPipeline(phoneID)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+
+	replayed := pipeline.Resume()
+	writeJSON(w, http.StatusOK, pipelineResponse{PhoneID: phoneID, Replayed: replayed})
+}
@@ -0,0 +1,202 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+
+	"auto-lmk/pkg/tools"
+	"auto-lmk/pkg/whatsapp"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+// ChatAIController is implemented by services that can toggle AI per chat
+type ChatAIController interface {
+	SetAIEnabled(chatJID string, enabled bool) error
+	IsAIEnabled(chatJID string) bool
+}
+
+// PipelineController is implemented by services whose message processing can be paused
+type PipelineController interface {
+	Pause()
+	Resume() int
+	IsPaused() bool
+}
+
+// Server exposes WhatsApp manager operations over HTTP
+type Server struct {
+	manager    *tools.WhatsAppManager
+	services   *whatsapp.ClientServices    // nil when clients have no AI services
+	aiServices map[string]ChatAIController // registered with RegisterAIService
+	mu         sync.RWMutex
+	addr       string
+	mux        *http.ServeMux
+}
+
+type aiStatusRequest struct {
+	Enabled *bool `json:"enabled"`
+}
+
+type aiStatusResponse struct {
+	PhoneID string `json:"phoneID"`
+	ChatJID string `json:"chatJID"`
+	Enabled bool   `json:"enabled"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// NewServer creates a new HTTP API server listening on addr. services, if not nil,
+// answers the per-chat AI and pause endpoints for clients without a registered service.
+func NewServer(addr string, manager *tools.WhatsAppManager, services *whatsapp.ClientServices) *Server {
+	if addr == "" {
+		addr = ":8080"
+	}
+
+	s := &Server{
+		manager:    manager,
+		services:   services,
+		aiServices: make(map[string]ChatAIController),
+		addr:       addr,
+		mux:        http.NewServeMux(),
+	}
+
+	s.mux.HandleFunc("GET /clients/{phoneID}/chats/{chatJID}/ai", s.handleGetChatAI)
+	s.mux.HandleFunc("POST /clients/{phoneID}/chats/{chatJID}/ai", s.handleSetChatAI)
+	s.registerClientRoutes()
+	s.registerPipelineRoutes()
+	s.mux.Handle("GET /metrics", manager.MetricsHandler())
+
+	return s
+}
+
+// RegisterAIService attaches the AI controller handling chats for a client
+func (s *Server) RegisterAIService(phoneID string, service ChatAIController) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.aiServices[phoneID] = service
+}
+
+// UnregisterAIService detaches a client's AI controller, closing it if it is an io.Closer
+func (s *Server) UnregisterAIService(phoneID string) {
+	s.mu.Lock()
+	service, exists := s.aiServices[phoneID]
+	delete(s.aiServices, phoneID)
+	s.mu.Unlock()
+
+	if closer, ok := service.(io.Closer); exists && ok {
+		if err := closer.Close(); err != nil {
+			log.Printf("Failed to close AI service of client %s: %v", phoneID, err)
+		}
+	}
+}
+
+// Handler returns the HTTP handler for the API
+func (s *Server) Handler() http.Handler {
+	return s.mux
+}
+
+// ListenAndServe starts serving the API
+func (s *Server) ListenAndServe() error {
+	log.Printf("HTTP API listening on %s", s.addr)
+	return http.ListenAndServe(s.addr, s.mux)
+}
+
+func (s *Server) getAIService(phoneID string) (ChatAIController, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if service, exists := s.aiServices[phoneID]; exists {
+		return service, nil
+	}
+	if s.services != nil {
+		if service, exists := s.services.Get(phoneID); exists {
+			return service, nil
+		}
+	}
+	return nil, fmt.Errorf("client with phoneID %s not found", phoneID)
+}
+
+// parseChatJID validates a chat JID taken from the request path
+func parseChatJID(raw string) (types.JID, error) {
+	jid, err := tools.NormalizeJID(raw)
+	if err != nil {
+		return types.EmptyJID, fmt.Errorf("invalid chat JID: %w", err)
+	}
+	return jid, nil
+}
+
+func (s *Server) handleGetChatAI(w http.ResponseWriter, r *http.Request) {
+	phoneID := r.PathValue("phoneID")
+	service, err := s.getAIService(phoneID)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+
+	chatJID, err := parseChatJID(r.PathValue("chatJID"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, aiStatusResponse{
+		PhoneID: phoneID,
+		ChatJID: chatJID.String(),
+		Enabled: service.IsAIEnabled(chatJID.String()),
+	})
+}
+
+func (s *Server) handleSetChatAI(w http.ResponseWriter, r *http.Request) {
+	phoneID := r.PathValue("phoneID")
+	service, err := s.getAIService(phoneID)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+
+	chatJID, err := parseChatJID(r.PathValue("chatJID"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	var req aiStatusRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+		return
+	}
+	if req.Enabled == nil {
+		writeError(w, http.StatusBadRequest, errors.New(`missing "enabled" field`))
+		return
+	}
+
+	if err := service.SetAIEnabled(chatJID.String(), *req.Enabled); err != nil {
+		writeError(w, http.StatusServiceUnavailable, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, aiStatusResponse{
+		PhoneID: phoneID,
+		ChatJID: chatJID.String(),
+		Enabled: service.IsAIEnabled(chatJID.String()),
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Failed to write API response: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, errorResponse{Error: err.Error()})
+}
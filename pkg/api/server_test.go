@@ -0,0 +1,110 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"auto-lmk/pkg/tools"
+	"auto-lmk/pkg/whatsapp"
+)
+
+// newTestServer serves the API for a manager with one client, "sales", in temp directories
+func newTestServer(t *testing.T) (*httptest.Server, *tools.WhatsAppManager) {
+	t.Helper()
+	t.Setenv("AI_DRY_RUN", "true")
+	oldDataDir := tools.DataDir()
+	tools.SetDataDir(t.TempDir())
+	t.Cleanup(func() { tools.SetDataDir(oldDataDir) })
+
+	manager := tools.NewWhatsAppManager(t.TempDir())
+	t.Cleanup(func() { manager.Shutdown(context.Background()) })
+	if _, err := manager.AddClient("sales"); err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(NewServer("", manager, whatsapp.NewClientServices(manager)).Handler())
+	t.Cleanup(server.Close)
+	return server, manager
+}
+
+func TestChatAIToggle(t *testing.T) {
+	server, manager := newTestServer(t)
+	url := server.URL + "/clients/sales/chats/628120000000/ai"
+
+	resp, err := http.Post(url, "application/json", strings.NewReader(`{"enabled":true}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("POST status = %d, want 200", resp.StatusCode)
+	}
+
+	resp, err = http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var status aiStatusResponse
+	err = json.NewDecoder(resp.Body).Decode(&status)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !status.Enabled || status.ChatJID != "628120000000@s.whatsapp.net" {
+		t.Errorf("GET = %+v, want AI enabled for the chat", status)
+	}
+
+	// Removed clients lose their AI controller
+	if err := manager.RemoveClient("sales"); err != nil {
+		t.Fatal(err)
+	}
+	resp, err = http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("GET after RemoveClient status = %d, want 404", resp.StatusCode)
+	}
+}
+
+func TestPauseResume(t *testing.T) {
+	server, _ := newTestServer(t)
+
+	paused := func() bool {
+		t.Helper()
+		resp, err := http.Get(server.URL + "/clients/sales/status")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var status clientStatusResponse
+		if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+			t.Fatal(err)
+		}
+		return status.Paused
+	}
+	post := func(action string) {
+		t.Helper()
+		resp, err := http.Post(server.URL+"/clients/sales/"+action, "application/json", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("POST %s status = %d, want 200", action, resp.StatusCode)
+		}
+	}
+
+	post("pause")
+	if !paused() {
+		t.Error("status not paused after pause")
+	}
+	post("resume")
+	if paused() {
+		t.Error("status still paused after resume")
+	}
+}
@@ -0,0 +1,47 @@
+package cli
+
+import (
+	"fmt"
+	"strconv"
+)
+
+func (m *Menu) manageEventHandlers() {
+	m.clearScreen()
+	fmt.Println("=== EVENT HANDLER CLIENT ===")
+
+	phoneID := m.getInput("Masukkan Phone ID: ")
+	instance, err := m.manager.GetClient(phoneID)
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+		m.pause()
+		return
+	}
+
+	handlers := instance.Handlers()
+	if len(handlers) == 0 {
+		fmt.Println("📭 Belum ada event handler terdaftar (client belum pernah connect).")
+		m.pause()
+		return
+	}
+
+	for _, h := range handlers {
+		fmt.Printf("🆔 %d - %s\n", h.ID, h.Name)
+	}
+	fmt.Println()
+
+	input := m.getInput("Masukkan ID handler yang ingin dihapus (kosongkan untuk kembali): ")
+	if input == "" {
+		return
+	}
+
+	id, err := strconv.ParseUint(input, 10, 32)
+	if err != nil {
+		fmt.Println("ID tidak valid!")
+	} else if instance.RemoveHandler(uint32(id)) {
+		fmt.Printf("✅ Handler %d dihapus.\n", id)
+	} else {
+		fmt.Printf("❌ Handler %d tidak ditemukan.\n", id)
+	}
+
+	m.pause()
+}
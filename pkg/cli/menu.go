@@ -2,23 +2,32 @@ package cli
 
 import (
 	"bufio"
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"auto-lmk/pkg/tools"
+	"auto-lmk/pkg/whatsapp"
 )
 
+// ShutdownTimeout bounds how long exiting waits for clients to disconnect
+const ShutdownTimeout = 15 * time.Second
+
 type Menu struct {
-	manager *tools.WhatsAppManager
-	reader  *bufio.Reader
+	manager  *tools.WhatsAppManager
+	services *whatsapp.ClientServices
+	reader   *bufio.Reader
 }
 
-func NewMenu(manager *tools.WhatsAppManager) *Menu {
+func NewMenu(manager *tools.WhatsAppManager, services *whatsapp.ClientServices) *Menu {
 	return &Menu{
-		manager: manager,
-		reader:  bufio.NewReader(os.Stdin),
+		manager:  manager,
+		services: services,
+		reader:   bufio.NewReader(os.Stdin),
 	}
 }
 
@@ -28,7 +37,7 @@ func (m *Menu) ShowMainMenu() {
 		m.printHeader()
 		m.printOptions()
 
-		choice := m.getInput("Pilih menu (1-9): ")
+		choice := m.getInput("Pilih menu (0-17): ")
 
 		switch choice {
 		case "1":
@@ -49,8 +58,25 @@ func (m *Menu) ShowMainMenu() {
 			m.showClientStatus()
 		case "9":
 			m.cleanupDatabases()
+		case "10":
+			m.vacuumDatabase()
+		case "11":
+			m.manageSchedules()
+		case "12":
+			m.manageEventHandlers()
+		case "13":
+			m.showDiskUsage()
+		case "14":
+			m.connectClientWithPairCode()
+		case "15":
+			m.sendTestMessage()
+		case "16":
+			m.logoutClient()
+		case "17":
+			m.pauseResumeClient()
 		case "0":
 			fmt.Println("Keluar dari program...")
+			m.shutdown()
 			return
 		default:
 			fmt.Println("Pilihan tidak valid. Silakan coba lagi.")
@@ -81,6 +107,14 @@ func (m *Menu) printOptions() {
 	fmt.Println("7. 🔌 Disconnect Semua Client")
 	fmt.Println("8. 📊 Lihat Status Client")
 	fmt.Println("9. 🧹 Cleanup Database")
+	fmt.Println("10. 🛠️  Periksa & Perbaiki Database Client")
+	fmt.Println("11. ⏰ Jadwal Pesan Berulang")
+	fmt.Println("12. 🎛️  Event Handler Client")
+	fmt.Println("13. 💽 Penggunaan Disk Gambar")
+	fmt.Println("14. 🔢 Connect Client (Kode Pairing)")
+	fmt.Println("15. ✉️  Kirim Pesan Tes")
+	fmt.Println("16. 🚫 Logout Client (Hapus Tautan Perangkat)")
+	fmt.Println("17. ⏸️  Jeda / Lanjutkan Bot Client")
 	fmt.Println("0. 🚪 Keluar")
 	fmt.Println()
 }
@@ -91,6 +125,16 @@ func (m *Menu) getInput(prompt string) string {
 	return strings.TrimSpace(input)
 }
 
+// shutdown disconnects all clients before the program exits
+func (m *Menu) shutdown() {
+	ctx, cancel := context.WithTimeout(context.Background(), ShutdownTimeout)
+	defer cancel()
+
+	if err := m.manager.Shutdown(ctx); err != nil {
+		fmt.Printf("❌ Tidak semua client tertutup dengan bersih: %v\n", err)
+	}
+}
+
 func (m *Menu) pause() {
 	fmt.Println("\nTekan Enter untuk melanjutkan...")
 	m.reader.ReadString('\n')
@@ -116,10 +160,18 @@ func (m *Menu) listClients() {
 			status := "🔴 Disconnected"
 			if connected {
 				status = "🟢 Connected"
+			} else if loggedOut, _ := m.manager.IsLoggedOut(clientName); loggedOut {
+				status = "⚠️ Logged out (perlu pairing ulang)"
+			}
+
+			number := "belum di-pairing"
+			if n, err := m.manager.GetClientPhoneNumber(clientName); err == nil {
+				number = "+" + n
 			}
 
 			fmt.Printf("%d. 📱 %s\n", i+1, clientName)
-			fmt.Printf("   Status: %s\n", status)
+			fmt.Printf("   Nomor: %s\n", number)
+			fmt.Printf("   Status: %s%s\n", status, m.pausedLabel(clientName))
 			fmt.Printf("   Database: %s\n", dbPath)
 			fmt.Println()
 		}
@@ -295,6 +347,62 @@ func (m *Menu) disconnectClient() {
 	m.pause()
 }
 
+// logoutClient unpairs a client; unlike disconnecting, reconnecting it needs a new QR scan
+func (m *Menu) logoutClient() {
+	m.clearScreen()
+	fmt.Println("=== LOGOUT CLIENT ===")
+
+	clients := m.manager.ListClients()
+	if len(clients) == 0 {
+		fmt.Println("Belum ada client yang terdaftar.")
+		m.pause()
+		return
+	}
+
+	fmt.Println("Pilih client yang akan di-logout:")
+	for i, phoneID := range clients {
+		connected, _, _ := m.manager.GetClientStatus(phoneID)
+		status := "Disconnected"
+		if connected {
+			status = "Connected"
+		}
+		fmt.Printf("%d. %s (%s)\n", i+1, phoneID, status)
+	}
+
+	choice := m.getInput("Pilih nomor (0 untuk batal): ")
+
+	if choice == "0" {
+		return
+	}
+
+	index, err := strconv.Atoi(choice)
+	if err != nil || index < 1 || index > len(clients) {
+		fmt.Println("Pilihan tidak valid!")
+		m.pause()
+		return
+	}
+
+	phoneID := clients[index-1]
+
+	confirm := m.getInput(fmt.Sprintf("Yakin ingin logout client %s? Connect berikutnya harus scan QR lagi. (y/N): ", phoneID))
+	if strings.ToLower(confirm) != "y" && strings.ToLower(confirm) != "yes" {
+		fmt.Println("Logout dibatalkan.")
+		m.pause()
+		return
+	}
+
+	switch err := m.manager.LogoutClient(phoneID); {
+	case errors.Is(err, tools.ErrClientNotPaired):
+		fmt.Printf("Client %s belum ditautkan ke WhatsApp.\n", phoneID)
+	case err != nil:
+		fmt.Printf("❌ Gagal logout client: %v\n", err)
+	default:
+		fmt.Printf("✅ Client %s berhasil logout. Scan QR lagi untuk menautkan.\n", phoneID)
+	}
+
+	m.pause()
+}
+
 func (m *Menu) connectAllClients() {
 	m.clearScreen()
 	fmt.Println("=== CONNECT SEMUA CLIENT ===")
@@ -346,7 +454,7 @@ func (m *Menu) showClientStatus() {
 			}
 
 			fmt.Printf("📱 %s\n", phoneID)
-			fmt.Printf("   Status: %s\n", status)
+			fmt.Printf("   Status: %s%s\n", status, m.pausedLabel(phoneID))
 			fmt.Printf("   Database: %s\n", dbPath)
 			fmt.Println()
 		}
@@ -361,10 +469,18 @@ func (m *Menu) cleanupDatabases() {
 
 	confirm := m.getInput("Yakin ingin menghapus semua database? (y/N): ")
 	if strings.ToLower(confirm) == "y" || strings.ToLower(confirm) == "yes" {
+		backupDir := m.manager.BackupDir()
+		backup := m.getInput(fmt.Sprintf("Backup database ke %s sebelum dihapus? (Y/n): ", backupDir))
+		if strings.ToLower(backup) == "n" || strings.ToLower(backup) == "no" {
+			backupDir = ""
+		}
+
 		fmt.Println("Menghapus database...")
-		err := m.manager.CleanupDatabases()
+		err := m.manager.CleanupDatabases(backupDir)
 		if err != nil {
 			fmt.Printf("Gagal cleanup database: %v\n", err)
+		} else if backupDir != "" {
+			fmt.Printf("Database berhasil dibersihkan! Backup tersimpan di %s\n", backupDir)
 		} else {
 			fmt.Println("Database berhasil dibersihkan!")
 		}
@@ -374,3 +490,162 @@ func (m *Menu) cleanupDatabases() {
 
 	m.pause()
 }
+
+func (m *Menu) vacuumDatabase() {
+	m.clearScreen()
+	fmt.Println("=== PERIKSA & PERBAIKI DATABASE ===")
+
+	phoneID := m.getInput("Masukkan Phone ID: ")
+	if phoneID == "" {
+		fmt.Println("Phone ID tidak boleh kosong!")
+		m.pause()
+		return
+	}
+
+	fmt.Println("Memeriksa integritas dan mengoptimalkan database...")
+	err := m.manager.VacuumDatabase(phoneID)
+	switch {
+	case errors.Is(err, tools.ErrDatabaseLocked):
+		fmt.Println("❌ Database sedang digunakan. Tutup proses lain yang membuka database lalu coba lagi.")
+	case err != nil:
+		fmt.Printf("❌ Gagal memeriksa database: %v\n", err)
+	default:
+		fmt.Println("✅ Database sehat dan berhasil dioptimalkan!")
+	}
+
+	m.pause()
+}
+
+func (m *Menu) showDiskUsage() {
+	m.clearScreen()
+	fmt.Println("=== PENGGUNAAN DISK GAMBAR ===")
+
+	usage, total, err := m.manager.DiskUsage()
+	if err != nil {
+		fmt.Printf("❌ Gagal memindai penyimpanan: %v\n", err)
+		m.pause()
+		return
+	}
+
+	fmt.Printf("💽 Total: %s\n\n", tools.FormatBytes(total))
+	for i, u := range usage {
+		fmt.Printf("%d. %s - %s (%d file)\n", i+1, u.Chat, tools.FormatBytes(u.Bytes), u.Files)
+	}
+	if len(usage) == 0 {
+		fmt.Println("📭 Belum ada gambar tersimpan.")
+	}
+
+	m.pause()
+}
+
+func (m *Menu) connectClientWithPairCode() {
+	m.clearScreen()
+	fmt.Println("=== CONNECT CLIENT DENGAN KODE PAIRING ===")
+
+	phoneID := m.getInput("Masukkan Phone ID: ")
+	phoneNumber := m.getInput("Masukkan nomor WhatsApp (format internasional, contoh: 628123456789): ")
+
+	code, err := m.manager.ConnectClientWithPairCode(phoneID, phoneNumber)
+	if err != nil {
+		fmt.Printf("❌ Gagal meminta kode pairing: %v\n", err)
+	} else {
+		fmt.Printf("\n🔢 Kode pairing: %s\n", code)
+		fmt.Println("📱 Di HP buka WhatsApp > Perangkat Tertaut > Tautkan dengan nomor telepon, lalu masukkan kode di atas.")
+	}
+
+	m.pause()
+}
+
+// defaultTestMessage is sent by "Kirim Pesan Tes" when no text is entered
+const defaultTestMessage = "✅ Pesan tes dari auto-lmk"
+
+// sendTestMessage sends a message from a connected client to check that sending works
+func (m *Menu) sendTestMessage() {
+	m.clearScreen()
+	fmt.Println("=== KIRIM PESAN TES ===")
+
+	phoneID := m.getInput("Masukkan Phone ID pengirim: ")
+	recipient := m.getInput("Masukkan nomor atau JID tujuan (contoh: 08123456789, +62 812-3456-789 atau 12345@g.us): ")
+	to, err := parseRecipient(recipient)
+	if err != nil {
+		fmt.Printf("❌ Tujuan tidak valid: %v\n", err)
+		m.pause()
+		return
+	}
+
+	text := m.getInput(fmt.Sprintf("Masukkan pesan (kosongkan untuk %q): ", defaultTestMessage))
+	if text == "" {
+		text = defaultTestMessage
+	}
+
+	switch err := m.manager.SendText(phoneID, to, text); {
+	case errors.Is(err, tools.ErrClientNotConnected):
+		fmt.Printf("❌ Client %s belum terhubung. Connect client terlebih dahulu.\n", phoneID)
+	case err != nil:
+		fmt.Printf("❌ Gagal mengirim pesan: %v\n", err)
+	default:
+		fmt.Printf("✅ Pesan tes terkirim ke %s\n", to)
+	}
+
+	m.pause()
+}
+
+// pausedLabel marks a client whose bot is paused in the status views
+func (m *Menu) pausedLabel(phoneID string) string {
+	if service, ok := m.services.Get(phoneID); ok && service.IsPaused() {
+		return " ⏸️ Bot dijeda"
+	}
+	return ""
+}
+
+// pauseResumeClient pauses a client's bot for maintenance or resumes it. Messages
+// received while paused are ignored or replayed on resume, see PAUSED_MESSAGES.
+func (m *Menu) pauseResumeClient() {
+	m.clearScreen()
+	fmt.Println("=== JEDA / LANJUTKAN BOT CLIENT ===")
+
+	clients := m.manager.ListClients()
+	if len(clients) == 0 {
+		fmt.Println("Belum ada client yang terdaftar.")
+		m.pause()
+		return
+	}
+
+	for i, phoneID := range clients {
+		status := "Berjalan"
+		if m.pausedLabel(phoneID) != "" {
+			status = "Dijeda"
+		}
+		fmt.Printf("%d. %s (%s)\n", i+1, phoneID, status)
+	}
+
+	choice := m.getInput("Pilih nomor (0 untuk batal): ")
+	if choice == "0" {
+		return
+	}
+
+	index, err := strconv.Atoi(choice)
+	if err != nil || index < 1 || index > len(clients) {
+		fmt.Println("Pilihan tidak valid!")
+		m.pause()
+		return
+	}
+
+	phoneID := clients[index-1]
+	service, ok := m.services.Get(phoneID)
+	if !ok {
+		fmt.Printf("Client %s tidak memiliki bot aktif.\n", phoneID)
+		m.pause()
+		return
+	}
+
+	if service.IsPaused() {
+		replayed := service.Resume()
+		fmt.Printf("▶️ Bot client %s dilanjutkan, %d pesan antrean diproses ulang.\n", phoneID, replayed)
+	} else {
+		service.Pause()
+		fmt.Printf("⏸️ Bot client %s dijeda.\n", phoneID)
+	}
+
+	m.pause()
+}
@@ -0,0 +1,107 @@
+package cli
+
+import (
+	"fmt"
+	"time"
+
+	"auto-lmk/pkg/tools"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+func (m *Menu) manageSchedules() {
+	for {
+		m.clearScreen()
+		fmt.Println("=== JADWAL PESAN BERULANG ===")
+		fmt.Println("1. 📋 Lihat Jadwal")
+		fmt.Println("2. ➕ Buat Jadwal Baru")
+		fmt.Println("3. 🗑️  Batalkan Jadwal")
+		fmt.Println("0. ↩️  Kembali")
+		fmt.Println()
+
+		switch m.getInput("Pilih menu (0-3): ") {
+		case "1":
+			m.listSchedules()
+		case "2":
+			m.createSchedule()
+		case "3":
+			m.cancelSchedule()
+		case "0":
+			return
+		default:
+			fmt.Println("Pilihan tidak valid. Silakan coba lagi.")
+			m.pause()
+		}
+	}
+}
+
+func (m *Menu) listSchedules() {
+	m.clearScreen()
+	fmt.Println("=== DAFTAR JADWAL ===")
+
+	schedules := m.manager.ListSchedules()
+	if len(schedules) == 0 {
+		fmt.Println("📭 Belum ada jadwal.")
+	}
+	for _, s := range schedules {
+		fmt.Printf("🆔 %s\n", s.ID)
+		fmt.Printf("   Client: %s -> %s\n", s.PhoneID, s.To)
+		fmt.Printf("   Jadwal: %s\n", s.Spec)
+		fmt.Printf("   Berikutnya: %s\n", s.NextRun.Format(time.RFC1123))
+		fmt.Printf("   Pesan: %s\n", s.Text)
+		fmt.Println()
+	}
+
+	m.pause()
+}
+
+func (m *Menu) createSchedule() {
+	m.clearScreen()
+	fmt.Println("=== BUAT JADWAL BARU ===")
+
+	phoneID := m.getInput("Masukkan Phone ID pengirim: ")
+	recipient := m.getInput("Masukkan nomor atau JID tujuan (contoh: 628123456789 atau 12345@g.us): ")
+	to, err := parseRecipient(recipient)
+	if err != nil {
+		fmt.Printf("Tujuan tidak valid: %v\n", err)
+		m.pause()
+		return
+	}
+
+	fmt.Println("Format jadwal: menit jam tanggal bulan hari (contoh: '0 9 * * 1-5' = Senin-Jumat 09:00, atau @daily)")
+	spec := m.getInput("Masukkan jadwal: ")
+	text := m.getInput("Masukkan pesan: ")
+	if text == "" {
+		fmt.Println("Pesan tidak boleh kosong!")
+		m.pause()
+		return
+	}
+
+	id, err := m.manager.ScheduleRecurring(phoneID, to, text, spec)
+	if err != nil {
+		fmt.Printf("Gagal membuat jadwal: %v\n", err)
+	} else {
+		fmt.Printf("✅ Jadwal '%s' berhasil dibuat!\n", id)
+	}
+
+	m.pause()
+}
+
+func (m *Menu) cancelSchedule() {
+	m.clearScreen()
+	fmt.Println("=== BATALKAN JADWAL ===")
+
+	id := m.getInput("Masukkan ID jadwal: ")
+	if err := m.manager.CancelSchedule(id); err != nil {
+		fmt.Printf("Gagal membatalkan jadwal: %v\n", err)
+	} else {
+		fmt.Printf("✅ Jadwal '%s' dibatalkan.\n", id)
+	}
+
+	m.pause()
+}
+
+// parseRecipient accepts a full JID or a phone number in any common format
+func parseRecipient(input string) (types.JID, error) {
+	return tools.NormalizeJID(input)
+}
@@ -0,0 +1,122 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+// RegisterAITools exposes the manager's client status to the model as the
+// "get_client_status" tool
+func (wm *WhatsAppManager) RegisterAITools(at *AITools) {
+	at.RegisterTool("get_client_status", ToolSchema{
+		Description: "List the WhatsApp clients managed by this server and whether each is connected.",
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"phone_id": map[string]any{
+					"type":        "string",
+					"description": "Only report this client. Omit to list all clients.",
+				},
+			},
+		},
+	}, func(ctx context.Context, chat string, args json.RawMessage) (string, error) {
+		var params struct {
+			PhoneID string `json:"phone_id"`
+		}
+		if err := json.Unmarshal(args, &params); err != nil {
+			return "", fmt.Errorf("invalid arguments: %w", err)
+		}
+
+		phoneIDs := wm.ListClients()
+		if params.PhoneID != "" {
+			phoneIDs = []string{params.PhoneID}
+		}
+		sort.Strings(phoneIDs)
+
+		type clientStatus struct {
+			PhoneID   string `json:"phone_id"`
+			Connected bool   `json:"connected"`
+			LoggedOut bool   `json:"logged_out,omitempty"`
+		}
+		statuses := make([]clientStatus, 0, len(phoneIDs))
+		for _, phoneID := range phoneIDs {
+			connected, _, err := wm.GetClientStatus(phoneID)
+			if err != nil {
+				return "", err
+			}
+			loggedOut, _ := wm.IsLoggedOut(phoneID)
+			statuses = append(statuses, clientStatus{PhoneID: phoneID, Connected: connected, LoggedOut: loggedOut})
+		}
+		return marshalToolResult(statuses)
+	})
+}
+
+// RegisterAITools exposes the chat's historical images to the model as the
+// "list_historical_images" tool; each chat only sees its own images
+func (wd *WhatsAppDownloader) RegisterAITools(at *AITools) {
+	at.RegisterTool("list_historical_images", ToolSchema{
+		Description: "List images sent earlier in this chat that were found in the WhatsApp history, newest first.",
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"limit": map[string]any{
+					"type":        "integer",
+					"description": "Maximum number of images to return (default 10).",
+				},
+			},
+		},
+	}, func(ctx context.Context, chat string, args json.RawMessage) (string, error) {
+		var params struct {
+			Limit int `json:"limit"`
+		}
+		if err := json.Unmarshal(args, &params); err != nil {
+			return "", fmt.Errorf("invalid arguments: %w", err)
+		}
+		if params.Limit <= 0 {
+			params.Limit = 10
+		}
+
+		type historicalImage struct {
+			MessageID  string    `json:"message_id"`
+			Sender     string    `json:"sender"`
+			Timestamp  time.Time `json:"timestamp"`
+			Caption    string    `json:"caption,omitempty"`
+			Downloaded bool      `json:"downloaded"`
+		}
+		var images []historicalImage
+		for _, info := range wd.ListHistoricalImages() {
+			if info.ChatJID.String() != chat {
+				continue
+			}
+			images = append(images, historicalImage{
+				MessageID:  string(info.MessageID),
+				Sender:     info.SenderJID.User,
+				Timestamp:  info.Timestamp,
+				Caption:    info.ImageMsg.GetCaption(),
+				Downloaded: fileExists(info.FileName),
+			})
+		}
+		sort.Slice(images, func(i, j int) bool { return images[i].Timestamp.After(images[j].Timestamp) })
+		if len(images) > params.Limit {
+			images = images[:params.Limit]
+		}
+		return marshalToolResult(images)
+	})
+}
+
+func marshalToolResult(v any) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode tool result: %w", err)
+	}
+	return string(data), nil
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,269 @@
+package tools
+
+import (
+	"cmp"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+	"github.com/openai/openai-go/shared"
+)
+
+// ErrProviderUnsupported is returned for features the configured AI provider doesn't offer
+var ErrProviderUnsupported = errors.New("not supported by the configured AI provider")
+
+// ImageInput is an image attached to a completion request
+type ImageInput struct {
+	MimeType string
+	Data     []byte
+}
+
+// CompletionRequest is a provider-neutral chat completion request. History uses the
+// OpenAI message format, which is how chat history is stored; providers translate it.
+type CompletionRequest struct {
+	Model       string
+	System      string
+	History     []openai.ChatCompletionMessageParamUnion
+	Text        string
+	Images      []ImageInput
+	MaxTokens   int
+	Temperature float64
+	// ReasoningEffort is sent to reasoning models only: minimal, low, medium or high; ""
+	// keeps the model's default
+	ReasoningEffort string
+	// ImageDetail is the OpenAI vision detail level for Images: low, high or auto
+	ImageDetail string
+	// OnUsage, when set, is called with the tokens each completion used
+	OnUsage func(TokenUsage)
+}
+
+// AIProvider is a chat completion backend. Implementations return "" when the model
+// produced no answer.
+type AIProvider interface {
+	CompleteText(ctx context.Context, req CompletionRequest) (string, error)
+	CompleteWithImage(ctx context.Context, req CompletionRequest, image ImageInput) (string, error)
+}
+
+// StreamingProvider is implemented by providers that can stream completions
+type StreamingProvider interface {
+	StreamText(ctx context.Context, req CompletionRequest, onChunk func(string)) (string, error)
+}
+
+// AIProviderKeyEnv returns the environment variable holding the API key of the provider
+// selected by AI_PROVIDER, or "" when the provider needs none
+func AIProviderKeyEnv() string {
+	switch strings.ToLower(EnvString("AI_PROVIDER", "openai")) {
+	case "openai":
+		return "OPENAI_API_KEY"
+	case "anthropic":
+		return "ANTHROPIC_API_KEY"
+	default:
+		return ""
+	}
+}
+
+// NewAIProviderFromEnv creates the provider selected by AI_PROVIDER (openai, llamacpp or
+// anthropic; default openai), or the DryRunProvider when AI_DRY_RUN is set, and reports
+// the default model for it
+func NewAIProviderFromEnv() (AIProvider, string, error) {
+	// AI_DRY_RUN answers with echoes and needs no API key
+	if EnvBool("AI_DRY_RUN", false) {
+		return DryRunProvider{}, "dry-run", nil
+	}
+
+	switch provider := strings.ToLower(EnvString("AI_PROVIDER", "openai")); provider {
+	case "openai":
+		apiKey := os.Getenv("OPENAI_API_KEY")
+		if apiKey == "" {
+			return nil, "", fmt.Errorf("OPENAI_API_KEY environment variable not set. AI functionality will be disabled")
+		}
+		// Retries are handled by AITools so AI_MAX_ATTEMPTS is the only retry setting
+		opts := []option.RequestOption{option.WithAPIKey(apiKey), option.WithMaxRetries(0)}
+		if baseURL := os.Getenv("OPENAI_BASE_URL"); baseURL != "" {
+			opts = append(opts, option.WithBaseURL(baseURL))
+		}
+		return NewOpenAIProvider(openai.NewClient(opts...)), os.Getenv("OPENAI_MODEL"), nil
+	case "llamacpp":
+		// llama.cpp's server speaks the OpenAI API; the key is only needed behind a proxy
+		opts := []option.RequestOption{
+			option.WithBaseURL(EnvString("LLAMACPP_BASE_URL", "http://localhost:8080/v1")),
+			option.WithAPIKey(EnvString("LLAMACPP_API_KEY", "no-key")),
+			option.WithMaxRetries(0),
+		}
+		return NewOpenAIProvider(openai.NewClient(opts...)), EnvString("LLAMACPP_MODEL", "local"), nil
+	case "anthropic":
+		apiKey := os.Getenv("ANTHROPIC_API_KEY")
+		if apiKey == "" {
+			return nil, "", fmt.Errorf("ANTHROPIC_API_KEY environment variable not set. AI functionality will be disabled")
+		}
+		return NewAnthropicProvider(apiKey, os.Getenv("ANTHROPIC_BASE_URL")), EnvString("ANTHROPIC_MODEL", defaultAnthropicModel), nil
+	default:
+		return nil, "", fmt.Errorf("unknown AI_PROVIDER %q (use openai, llamacpp or anthropic)", provider)
+	}
+}
+
+// OpenAIProvider sends completions to the OpenAI API or any OpenAI-compatible server
+type OpenAIProvider struct {
+	client openai.Client
+}
+
+// NewOpenAIProvider wraps an OpenAI client
+func NewOpenAIProvider(client openai.Client) *OpenAIProvider {
+	return &OpenAIProvider{client: client}
+}
+
+// Client returns the underlying OpenAI client, used for OpenAI-only features
+func (p *OpenAIProvider) Client() openai.Client {
+	return p.client
+}
+
+// CompleteText sends a chat completion request
+func (p *OpenAIProvider) CompleteText(ctx context.Context, req CompletionRequest) (string, error) {
+	resp, err := p.client.Chat.Completions.New(ctx, openAIParams(req))
+	if err != nil {
+		return "", err
+	}
+	req.reportUsage(openAIUsage(resp.Usage))
+	if len(resp.Choices) == 0 {
+		return "", nil
+	}
+	return resp.Choices[0].Message.Content, nil
+}
+
+// CompleteWithImage sends a chat completion request with one image attached
+func (p *OpenAIProvider) CompleteWithImage(ctx context.Context, req CompletionRequest, image ImageInput) (string, error) {
+	req.Images = append(append([]ImageInput(nil), req.Images...), image)
+	return p.CompleteText(ctx, req)
+}
+
+// StreamText streams a chat completion, calling onChunk with each content delta
+func (p *OpenAIProvider) StreamText(ctx context.Context, req CompletionRequest, onChunk func(string)) (string, error) {
+	params := openAIParams(req)
+	params.StreamOptions = openai.ChatCompletionStreamOptionsParam{IncludeUsage: openai.Bool(true)}
+	stream := p.client.Chat.Completions.NewStreaming(ctx, params)
+	defer stream.Close()
+
+	var sb strings.Builder
+	for stream.Next() {
+		chunk := stream.Current()
+		// With IncludeUsage the final chunk carries the usage and no choices
+		if chunk.Usage.TotalTokens > 0 {
+			req.reportUsage(openAIUsage(chunk.Usage))
+		}
+		if len(chunk.Choices) == 0 || chunk.Choices[0].Delta.Content == "" {
+			continue
+		}
+		delta := chunk.Choices[0].Delta.Content
+		sb.WriteString(delta)
+		if onChunk != nil {
+			onChunk(delta)
+		}
+	}
+	if err := stream.Err(); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+// openAIParams builds the chat completion parameters for a request. Reasoning models
+// reject temperature and max_tokens, so they get max_completion_tokens and the
+// reasoning effort instead.
+func openAIParams(req CompletionRequest) openai.ChatCompletionNewParams {
+	params := openai.ChatCompletionNewParams{
+		Model:    req.Model,
+		Messages: openAIMessages(req),
+	}
+	if !isReasoningModel(req.Model) {
+		params.MaxTokens = openai.Int(int64(req.MaxTokens))
+		params.Temperature = openai.Float(req.Temperature)
+		return params
+	}
+
+	params.MaxCompletionTokens = openai.Int(int64(req.MaxTokens))
+	if req.ReasoningEffort != "" {
+		params.ReasoningEffort = shared.ReasoningEffort(req.ReasoningEffort)
+	}
+	return params
+}
+
+// isReasoningModel reports whether a model is an OpenAI reasoning model: the o-series
+// (o1, o3-mini, o4-mini, ...) and gpt-5 except its chat variant. A provider prefix such
+// as "openai/" is ignored.
+func isReasoningModel(model string) bool {
+	model = strings.ToLower(model)
+	if i := strings.LastIndex(model, "/"); i >= 0 {
+		model = model[i+1:]
+	}
+	if len(model) >= 2 && model[0] == 'o' && model[1] >= '0' && model[1] <= '9' {
+		return true
+	}
+	return strings.HasPrefix(model, "gpt-5") && !strings.HasPrefix(model, "gpt-5-chat")
+}
+
+// reportUsage passes a completion's usage to OnUsage
+func (req CompletionRequest) reportUsage(u TokenUsage) {
+	if req.OnUsage != nil {
+		req.OnUsage(u)
+	}
+}
+
+func openAIUsage(u openai.CompletionUsage) TokenUsage {
+	return TokenUsage{PromptTokens: u.PromptTokens, CompletionTokens: u.CompletionTokens, TotalTokens: u.TotalTokens}
+}
+
+// openAIMessages converts a request into OpenAI chat messages, sending images inline as
+// base64 data URLs
+func openAIMessages(req CompletionRequest) []openai.ChatCompletionMessageParamUnion {
+	var contentParts []openai.ChatCompletionContentPartUnionParam
+	contentParts = append(contentParts, openai.TextContentPart(req.Text))
+	for _, img := range req.Images {
+		contentParts = append(contentParts, openai.ImageContentPart(openai.ChatCompletionContentPartImageImageURLParam{
+			URL:    fmt.Sprintf("data:%s;base64,%s", img.MimeType, base64.StdEncoding.EncodeToString(img.Data)),
+			Detail: cmp.Or(req.ImageDetail, DefaultImageDetail),
+		}))
+	}
+	return append(withSystemPrompt(req.System, req.History), openai.UserMessage(contentParts))
+}
+
+// messageText returns the role and text content of a stored chat message
+func messageText(msg openai.ChatCompletionMessageParamUnion) (string, string) {
+	switch {
+	case msg.OfSystem != nil:
+		if msg.OfSystem.Content.OfString.Valid() {
+			return "system", msg.OfSystem.Content.OfString.Value
+		}
+		var parts []string
+		for _, part := range msg.OfSystem.Content.OfArrayOfContentParts {
+			parts = append(parts, part.Text)
+		}
+		return "system", strings.Join(parts, "\n")
+	case msg.OfUser != nil:
+		if msg.OfUser.Content.OfString.Valid() {
+			return "user", msg.OfUser.Content.OfString.Value
+		}
+		var parts []string
+		for _, part := range msg.OfUser.Content.OfArrayOfContentParts {
+			if part.OfText != nil {
+				parts = append(parts, part.OfText.Text)
+			}
+		}
+		return "user", strings.Join(parts, "\n")
+	case msg.OfAssistant != nil:
+		if msg.OfAssistant.Content.OfString.Valid() {
+			return "assistant", msg.OfAssistant.Content.OfString.Value
+		}
+		var parts []string
+		for _, part := range msg.OfAssistant.Content.OfArrayOfContentParts {
+			if part.OfText != nil {
+				parts = append(parts, part.OfText.Text)
+			}
+		}
+		return "assistant", strings.Join(parts, "\n")
+	}
+	return "", ""
+}
@@ -0,0 +1,141 @@
+package tools
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/openai/openai-go"
+)
+
+func TestIsReasoningModel(t *testing.T) {
+	tests := []struct {
+		model string
+		want  bool
+	}{
+		{"o1", true},
+		{"o1-mini", true},
+		{"o3-mini-2025-01-31", true},
+		{"O4-MINI", true},
+		{"gpt-5", true},
+		{"gpt-5-mini", true},
+		{"openai/o3", true},
+		{"gpt-5-chat-latest", false},
+		{"gpt-4o", false},
+		{"gpt-4o-mini", false},
+		{"gpt-3.5-turbo", false},
+		{"omni-moderation-latest", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if got := isReasoningModel(tt.model); got != tt.want {
+			t.Errorf("isReasoningModel(%q) = %t, want %t", tt.model, got, tt.want)
+		}
+	}
+}
+
+func TestOpenAIParams(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     CompletionRequest
+		want    map[string]any
+		notSent []string
+	}{
+		{
+			name:    "chat model",
+			req:     CompletionRequest{Model: "gpt-4o", MaxTokens: 500, Temperature: 0.7, ReasoningEffort: "high"},
+			want:    map[string]any{"max_tokens": 500.0, "temperature": 0.7},
+			notSent: []string{"max_completion_tokens", "reasoning_effort"},
+		},
+		{
+			name:    "reasoning model",
+			req:     CompletionRequest{Model: "o3-mini", MaxTokens: 500, Temperature: 0.7, ReasoningEffort: "low"},
+			want:    map[string]any{"max_completion_tokens": 500.0, "reasoning_effort": "low"},
+			notSent: []string{"max_tokens", "temperature"},
+		},
+		{
+			name:    "reasoning model with default effort",
+			req:     CompletionRequest{Model: "gpt-5", MaxTokens: 200, Temperature: 0.7},
+			want:    map[string]any{"max_completion_tokens": 200.0},
+			notSent: []string{"max_tokens", "temperature", "reasoning_effort"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json.Marshal(openAIParams(tt.req))
+			if err != nil {
+				t.Fatalf("marshal params: %v", err)
+			}
+			var body map[string]any
+			if err := json.Unmarshal(data, &body); err != nil {
+				t.Fatalf("unmarshal params: %v", err)
+			}
+
+			for key, want := range tt.want {
+				if body[key] != want {
+					t.Errorf("%s = %v, want %v", key, body[key], want)
+				}
+			}
+			for _, key := range tt.notSent {
+				if value, ok := body[key]; ok {
+					t.Errorf("%s = %v sent, want it omitted", key, value)
+				}
+			}
+		})
+	}
+}
+
+func TestOpenAIMessagesSystemPrompt(t *testing.T) {
+	history := []openai.ChatCompletionMessageParamUnion{
+		openai.UserMessage("halo"),
+		openai.AssistantMessage("Halo juga!"),
+	}
+
+	messages := openAIMessages(CompletionRequest{System: "default prompt", History: history, Text: "apa kabar?"})
+	if len(messages) != 4 {
+		t.Fatalf("got %d messages, want system, history and the new message", len(messages))
+	}
+	if role, text := messageText(messages[0]); role != "system" || text != "default prompt" {
+		t.Errorf("first message = %s %q, want the system prompt", role, text)
+	}
+	if messages[3].OfUser == nil {
+		t.Error("last message isn't the user's")
+	}
+
+	// A history that already starts with a system message keeps it instead of getting a second one
+	withSystem := append([]openai.ChatCompletionMessageParamUnion{openai.SystemMessage("chat prompt")}, history...)
+	messages = openAIMessages(CompletionRequest{System: "default prompt", History: withSystem, Text: "apa kabar?"})
+	if len(messages) != 4 {
+		t.Fatalf("got %d messages, want the history's system message only once", len(messages))
+	}
+	for i, msg := range messages {
+		if role, text := messageText(msg); role == "system" && (i != 0 || text != "chat prompt") {
+			t.Errorf("message %d is system prompt %q", i, text)
+		}
+	}
+}
+
+func TestAnthropicRequestSystemPrompt(t *testing.T) {
+	history := []openai.ChatCompletionMessageParamUnion{
+		openai.UserMessage("halo"),
+		openai.AssistantMessage("Halo juga!"),
+	}
+
+	req := anthropicRequestFor(CompletionRequest{System: "default prompt", History: history, Text: "apa kabar?"})
+	if req.System != "default prompt" {
+		t.Errorf("system = %q, want the default prompt", req.System)
+	}
+	if len(req.Messages) != 3 || req.Messages[0].Role != "user" {
+		t.Errorf("messages = %+v, want the history and the new message starting with the user", req.Messages)
+	}
+
+	withSystem := append([]openai.ChatCompletionMessageParamUnion{openai.SystemMessage("chat prompt")}, history...)
+	req = anthropicRequestFor(CompletionRequest{System: "default prompt", History: withSystem, Text: "apa kabar?"})
+	if req.System != "chat prompt" {
+		t.Errorf("system = %q, want only the history's system message", req.System)
+	}
+	if len(req.Messages) != 3 {
+		t.Errorf("got %d messages, want 3", len(req.Messages))
+	}
+}
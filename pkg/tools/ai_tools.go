@@ -2,34 +2,345 @@ package tools
 
 import (
 	"context"
-	"encoding/base64"
+	"errors"
 	"fmt"
-	"os"
+	"path/filepath"
 	"strings"
+	"sync"
+	"sync/atomic"
 
 	"github.com/openai/openai-go"
 )
 
 // AITools handles AI tool integration for WhatsApp messages
 type AITools struct {
-	openaiClient openai.Client
-	model        string
+	provider           AIProvider
+	model              string
+	defaultModel       string
+	visionModel        string
+	transcriptionModel string
+	imageGenModel      string
+	systemPrompt       string
+	configMu           *sync.RWMutex // guards model and systemPrompt, changed while replies run; shared by copies
+	language           string
+	maxTokens          int
+	temperature        float64
+	reasoningEffort    string // sent to reasoning models only, see openAIParams
+	moderation         bool   // check image requests with moderator first, see AI_MODERATION
+	moderator          Moderator
+	imageMaxDimension  int // starting size images are resized to, see ProcessImageWithAI
+	imageQuality       int
+	imageFormat        ImageFormat
+	imageDetail        string
+	maxImages          int    // images per ProcessImageWithAI request
+	dataDir            string // directory image filenames are resolved in, DataDir() when empty
+	imageCache         *imageCache
+	visionSupported    *atomic.Bool // shared by per-chat copies, see VisionSupported
+	historyLimit       int
+	retry              RetryPolicy
+	usage              *usageStats
+	toolRegistry       *toolRegistry
+	chatKey            string // chat the tools act for, used for usage stats and tool calls; see ForChat
 }
 
-// NewAITools creates a new AI tools handler
-func NewAITools(openaiClient openai.Client, model string) *AITools {
+// AIOverrides are per-chat settings layered on top of an AITools configuration;
+// zero values keep the base setting
+type AIOverrides struct {
+	Model        string
+	SystemPrompt string
+	Language     string
+	MaxTokens    int
+}
+
+// Completion defaults, overridable with AI_MAX_TOKENS, AI_TEMPERATURE, AI_IMAGE_DETAIL
+// and AI_MAX_IMAGES
+const (
+	DefaultMaxTokens   = 500
+	DefaultTemperature = 0.7
+	DefaultImageDetail = "high"
+	DefaultMaxImages   = 4
+)
+
+// NewAITools creates a new AI tools handler using the given completion provider
+func NewAITools(provider AIProvider, model string) *AITools {
 	if model == "" {
 		model = "gpt-3.5-turbo"
 	}
 
-	return &AITools{
-		openaiClient: openaiClient,
-		model:        model,
+	at := &AITools{
+		provider:           provider,
+		configMu:           new(sync.RWMutex),
+		model:              model,
+		defaultModel:       model,
+		transcriptionModel: "whisper-1",
+		imageGenModel:      "dall-e-3",
+		maxTokens:          DefaultMaxTokens,
+		temperature:        DefaultTemperature,
+		imageMaxDimension:  EnvInt("AI_IMAGE_MAX_DIMENSION", LLMMaxWidth),
+		imageQuality:       EnvInt("AI_IMAGE_QUALITY", LLMQuality),
+		imageFormat:        ImageFormatJPEG,
+		imageDetail:        DefaultImageDetail,
+		maxImages:          max(EnvInt("AI_MAX_IMAGES", DefaultMaxImages), 1),
+		historyLimit:       EnvInt("AI_HISTORY_LIMIT", 20),
+		retry:              retryPolicyFromEnv(),
+		usage:              newUsageStats(),
+		toolRegistry:       newToolRegistry(),
+		imageCache:         newImageCache(EnvInt("AI_IMAGE_CACHE_SIZE", 100)),
+		visionSupported:    newVisionSupport(),
+	}
+	if err := at.SetMaxTokens(EnvInt("AI_MAX_TOKENS", DefaultMaxTokens)); err != nil {
+		fmt.Printf("Ignoring AI_MAX_TOKENS: %v\n", err)
+	}
+	if err := at.SetTemperature(EnvFloat("AI_TEMPERATURE", DefaultTemperature)); err != nil {
+		fmt.Printf("Ignoring AI_TEMPERATURE: %v\n", err)
+	}
+	if err := at.SetReasoningEffort(EnvString("AI_REASONING_EFFORT", "")); err != nil {
+		fmt.Printf("Ignoring AI_REASONING_EFFORT: %v\n", err)
+	}
+	if err := at.SetImageDetail(EnvString("AI_IMAGE_DETAIL", DefaultImageDetail)); err != nil {
+		fmt.Printf("Ignoring AI_IMAGE_DETAIL: %v\n", err)
+	}
+	at.moderation = EnvBool("AI_MODERATION", false)
+	if client, err := at.openAIClient("moderation"); err == nil {
+		at.moderator = NewOpenAIModerator(client, EnvString("AI_MODERATION_MODEL", DefaultModerationModel))
+	}
+	if format, err := ParseImageFormat(EnvString("AI_IMAGE_FORMAT", "")); err != nil {
+		fmt.Printf("Ignoring AI_IMAGE_FORMAT: %v\n", err)
+	} else {
+		at.imageFormat = format
+	}
+	return at
+}
+
+// openAIClient returns the OpenAI client for features only OpenAI offers, such as
+// transcription and image generation
+func (at *AITools) openAIClient(feature string) (openai.Client, error) {
+	if p, ok := at.provider.(*OpenAIProvider); ok {
+		return p.Client(), nil
+	}
+	return openai.Client{}, fmt.Errorf("%s is %w", feature, ErrProviderUnsupported)
+}
+
+// WithOverrides returns a copy of the AI tools with per-chat overrides applied
+func (at *AITools) WithOverrides(o AIOverrides) *AITools {
+	clone := at.copy()
+	if o.Model != "" {
+		clone.model = o.Model
+	}
+	if o.SystemPrompt != "" {
+		clone.systemPrompt = o.SystemPrompt
+	}
+	if o.Language != "" {
+		clone.language = o.Language
+	}
+	if o.MaxTokens > 0 {
+		clone.maxTokens = o.MaxTokens
+	}
+	return &clone
+}
+
+// textSystemPrompt returns the system prompt for text requests
+func (at *AITools) textSystemPrompt() string {
+	return at.withLanguage(TextProcessingSystemMessage)
+}
+
+// imageSystemPrompt returns the system prompt for image requests
+func (at *AITools) imageSystemPrompt() string {
+	return at.withLanguage(ImageProcessingSystemMessage)
+}
+
+// TrimHistory keeps a leading system message plus at most maxMessages of the most recent
+// messages. The kept window always starts at a user message so assistant replies and tool
+// responses are never separated from the turn that produced them. maxMessages <= 0 disables trimming.
+func TrimHistory(history []openai.ChatCompletionMessageParamUnion, maxMessages int) []openai.ChatCompletionMessageParamUnion {
+	var system []openai.ChatCompletionMessageParamUnion
+	rest := history
+	if len(rest) > 0 && rest[0].OfSystem != nil {
+		system, rest = rest[:1], rest[1:]
+	}
+	if maxMessages <= 0 || len(rest) <= maxMessages {
+		return history
+	}
+
+	cut := len(rest) - maxMessages
+	for cut < len(rest) && rest[cut].OfUser == nil {
+		cut++
+	}
+
+	trimmed := make([]openai.ChatCompletionMessageParamUnion, 0, len(system)+len(rest)-cut)
+	trimmed = append(trimmed, system...)
+	return append(trimmed, rest[cut:]...)
+}
+
+// withSystemPrompt returns a new message slice starting with the system prompt, unless
+// the history already starts with a system message
+func withSystemPrompt(prompt string, history []openai.ChatCompletionMessageParamUnion) []openai.ChatCompletionMessageParamUnion {
+	if len(history) > 0 && history[0].OfSystem != nil {
+		return append([]openai.ChatCompletionMessageParamUnion(nil), history...)
+	}
+
+	messages := make([]openai.ChatCompletionMessageParamUnion, 0, len(history)+2)
+	messages = append(messages, openai.SystemMessage(prompt))
+	return append(messages, history...)
+}
+
+// withLanguage applies the custom system prompt, if any, and the reply language instruction
+func (at *AITools) withLanguage(defaultPrompt string) string {
+	prompt := defaultPrompt
+	at.configMu.RLock()
+	if at.systemPrompt != "" {
+		prompt = at.systemPrompt
+	}
+	at.configMu.RUnlock()
+	if at.language != "" {
+		prompt += fmt.Sprintf(LanguageInstructionTemplate, at.language)
+	}
+	return prompt
+}
+
+// CacheKey returns the response cache key for a text message under the current
+// model and system prompt
+func (at *AITools) CacheKey(message string) string {
+	return ResponseCacheKey(at.Model(), at.textSystemPrompt(), message)
+}
+
+// SetSystemPrompt replaces the built-in system prompts for this client;
+// an empty prompt restores the defaults
+func (at *AITools) SetSystemPrompt(prompt string) {
+	at.configMu.Lock()
+	defer at.configMu.Unlock()
+	at.systemPrompt = strings.TrimSpace(prompt)
+}
+
+// SetModel sets the model used for text requests; an empty model restores the one the
+// tools were created with
+func (at *AITools) SetModel(model string) {
+	if model == "" {
+		model = at.defaultModel
+	}
+	at.configMu.Lock()
+	defer at.configMu.Unlock()
+	at.model = model
+}
+
+// Model returns the model used for text requests
+func (at *AITools) Model() string {
+	at.configMu.RLock()
+	defer at.configMu.RUnlock()
+	return at.model
+}
+
+// copy returns a shallow copy of the tools, taken while no setter runs
+func (at *AITools) copy() AITools {
+	at.configMu.RLock()
+	defer at.configMu.RUnlock()
+	return *at
+}
+
+// SetMaxTokens sets the maximum length of AI answers in tokens
+func (at *AITools) SetMaxTokens(maxTokens int) error {
+	if maxTokens <= 0 {
+		return fmt.Errorf("max tokens must be positive, got %d", maxTokens)
+	}
+	at.maxTokens = maxTokens
+	return nil
+}
+
+// SetTemperature sets the sampling temperature, from 0 (focused) to 2 (creative)
+func (at *AITools) SetTemperature(temperature float64) error {
+	if temperature < 0 || temperature > 2 {
+		return fmt.Errorf("temperature must be between 0 and 2, got %g", temperature)
+	}
+	at.temperature = temperature
+	return nil
+}
+
+// SetReasoningEffort sets how much reasoning models think before answering: minimal,
+// low, medium or high; "" keeps the model's default. Other models ignore it.
+func (at *AITools) SetReasoningEffort(effort string) error {
+	switch effort = strings.ToLower(effort); effort {
+	case "", "minimal", "low", "medium", "high":
+		at.reasoningEffort = effort
+		return nil
+	default:
+		return fmt.Errorf("reasoning effort must be minimal, low, medium or high, got %q", effort)
+	}
+}
+
+// SetDataDir sets the directory saved images are looked up in, e.g. a client's own
+// data/<phoneID> directory; empty uses the shared data directory
+func (at *AITools) SetDataDir(dir string) {
+	at.dataDir = dir
+}
+
+// ImagePath returns the path of a saved image file
+func (at *AITools) ImagePath(filename string) string {
+	if at.dataDir == "" {
+		return DataPath(filename)
+	}
+	return filepath.Join(at.dataDir, filename)
+}
+
+// SetImageDetail sets the vision detail level images are sent with: "low" is cheapest,
+// "high" reads fine print and "auto" lets the provider choose
+func (at *AITools) SetImageDetail(detail string) error {
+	switch detail {
+	case "low", "high", "auto":
+		at.imageDetail = detail
+		return nil
+	default:
+		return fmt.Errorf("image detail must be low, high or auto, got %q", detail)
+	}
+}
+
+// MaxImages returns how many images ProcessImageWithAI sends in one request
+func (at *AITools) MaxImages() int {
+	return at.maxImages
+}
+
+// SetVisionModel routes image requests to a separate vision-capable model;
+// an empty model uses the text model for images too
+func (at *AITools) SetVisionModel(model string) {
+	at.visionModel = model
+}
+
+// SetTranscriptionModel sets the model used to transcribe voice notes
+func (at *AITools) SetTranscriptionModel(model string) {
+	if model != "" {
+		at.transcriptionModel = model
+	}
+}
+
+// imageModel returns the model used for requests containing images
+func (at *AITools) imageModel() string {
+	if at.visionModel != "" {
+		return at.visionModel
+	}
+	return at.Model()
+}
+
+// isUnsupportedImageError reports whether an API error indicates the model can't accept images
+func isUnsupportedImageError(err error) bool {
+	var apiErr *openai.Error
+	if errors.As(err, &apiErr) && apiErr.StatusCode != 400 && apiErr.StatusCode != 404 && apiErr.StatusCode != 422 {
+		return false
 	}
+
+	msg := strings.ToLower(err.Error())
+	if !strings.Contains(msg, "image") && !strings.Contains(msg, "vision") && !strings.Contains(msg, "multimodal") {
+		return false
+	}
+	for _, hint := range []string{"not support", "unsupported", "does not support", "not enabled", "invalid content type", "only text"} {
+		if strings.Contains(msg, hint) {
+			return true
+		}
+	}
+	return false
 }
 
-// validateAndOptimizeImage checks image size and optimizes if necessary
-func (at *AITools) validateAndOptimizeImage(imageData []byte, filename string) ([]byte, string, error) {
+// validateAndOptimizeImage checks image size and resizes it to fit within maxDimension,
+// re-encoded in the configured image format (JPEG at quality by default)
+func (at *AITools) validateAndOptimizeImage(imageData []byte, filename string, maxDimension, quality int) ([]byte, string, error) {
 	// Validate image size
 	if err := ValidateImage(imageData); err != nil {
 		return nil, "", err
@@ -39,74 +350,136 @@ func (at *AITools) validateAndOptimizeImage(imageData []byte, filename string) (
 	mimeType := DetectImageType(filename, imageData)
 
 	// Resize image for LLM processing (always resize to optimize for LLM)
-	resizedData, err := ResizeImageForLLM(imageData, mimeType)
+	resizedData, resizedType, err := ResizeImageForLLMWithLimits(imageData, mimeType, maxDimension, quality, at.imageFormat)
 	if err != nil {
 		return nil, "", fmt.Errorf("failed to resize image for LLM: %w", err)
 	}
 
-	fmt.Printf("Image resized for LLM: %.2fMB -> %.2fMB (%s)\n",
-		float64(len(imageData))/1024/1024,
-		float64(len(resizedData))/1024/1024,
-		mimeType)
+	fmt.Printf("Image resized for LLM to %dpx at quality %d: %d -> %d bytes (%s -> %s)\n",
+		maxDimension, quality, len(imageData), len(resizedData), mimeType, resizedType)
 
-	return resizedData, "image/jpeg", nil // Always use JPEG for LLM processing
+	return resizedData, resizedType, nil
 }
 
-// ProcessImageWithAI handles image processing with multimodal AI
-func (at *AITools) ProcessImageWithAI(ctx context.Context, userMessage string, filename string, imageID string, history []openai.ChatCompletionMessageParamUnion, onStatus func(string)) (string, error) {
-	fmt.Printf("ProcessImageWithAI: Starting multimodal processing with message: %s, filename: %s, imageID: %s\n", userMessage, filename, imageID)
-
-	// Read image file
-	imagePath := fmt.Sprintf("data/%s", filename)
-	imageData, err := os.ReadFile(imagePath)
-	if err != nil {
-		return "", fmt.Errorf("failed to read image file: %w", err)
+// isPayloadTooLargeError reports whether the provider rejected a request for its size
+func isPayloadTooLargeError(err error) bool {
+	var openaiErr *openai.Error
+	if errors.As(err, &openaiErr) && openaiErr.StatusCode == 413 {
+		return true
 	}
-
-	// Validate and potentially optimize image
-	optimizedData, mimeType, err := at.validateAndOptimizeImage(imageData, filename)
-	if err != nil {
-		return "", err
+	var anthropicErr *AnthropicError
+	if errors.As(err, &anthropicErr) && (anthropicErr.StatusCode == 413 || anthropicErr.Type == "request_too_large") {
+		return true
 	}
 
-	// Convert image to base64
-	base64Image := base64.StdEncoding.EncodeToString(optimizedData)
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "too large") || strings.Contains(msg, "payload too large")
+}
+
+// ProcessImageWithAI handles image processing with multimodal AI. Several images, e.g. an
+// album, are sent together in one request, at most MaxImages of them; imageIDs holds the
+// message ID of each image.
+func (at *AITools) ProcessImageWithAI(ctx context.Context, userMessage string, filenames []string, imageIDs []string, history []openai.ChatCompletionMessageParamUnion, onStatus func(string)) (string, error) {
+	fmt.Printf("ProcessImageWithAI: Starting multimodal processing with message: %s, filenames: %v, imageIDs: %v\n", userMessage, filenames, imageIDs)
 
-	// Create enhanced message with image ID reference
+	if len(filenames) == 0 {
+		return "", fmt.Errorf("no images to process")
+	}
+	if !at.VisionSupported() {
+		return ErrorMessageVisionUnsupported, nil
+	}
+	if len(filenames) > at.maxImages {
+		fmt.Printf("ProcessImageWithAI: sending only the first %d of %d images\n", at.maxImages, len(filenames))
+		filenames = filenames[:at.maxImages]
+		imageIDs = imageIDs[:min(len(imageIDs), at.maxImages)]
+	}
+
+	// Create enhanced message with image ID references
 	enhancedMessage := userMessage
-	if imageID != "" {
-		enhancedMessage = fmt.Sprintf("%s\n\n[Image ID: %s]", userMessage, imageID)
+	if len(imageIDs) > 0 {
+		enhancedMessage += "\n"
+		for _, imageID := range imageIDs {
+			enhancedMessage += fmt.Sprintf("\n[Image ID: %s]", imageID)
+		}
 	}
 
-	// Add user message with image to history
-	updatedHistory := append(history, openai.UserMessage([]openai.ChatCompletionContentPartUnionParam{
-		openai.TextContentPart(enhancedMessage),
-		openai.ImageContentPart(openai.ChatCompletionContentPartImageImageURLParam{
-			URL:    fmt.Sprintf("data:%s;base64,%s", mimeType, base64Image),
-			Detail: "high",
-		}),
-	}))
+	model := at.imageModel()
+	req := CompletionRequest{
+		Model:           model,
+		System:          at.imageSystemPrompt(),
+		History:         TrimHistory(history, at.historyLimit),
+		Text:            enhancedMessage,
+		MaxTokens:       at.maxTokens,
+		Temperature:     at.temperature,
+		ReasoningEffort: at.reasoningEffort,
+		ImageDetail:     at.imageDetail,
+		OnUsage:         at.recordUsage,
+	}
 
-	// Create request with multimodal content using OpenAI Go library
-	req := openai.ChatCompletionNewParams{
-		Model:       at.model,
-		Messages:    updatedHistory,
-		MaxTokens:   openai.Int(500),
-		Temperature: openai.Float(0.7),
+	if at.moderation {
+		images := make([]ImageInput, 0, len(filenames))
+		for _, filename := range filenames {
+			data, mimeType, err := at.loadOptimizedImage(filename, at.imageMaxDimension, at.imageQuality)
+			if err != nil {
+				return "", err
+			}
+			images = append(images, ImageInput{MimeType: mimeType, Data: data})
+		}
+		if err := at.moderateImages(ctx, userMessage, images); err != nil {
+			fmt.Printf("ProcessImageWithAI: not sending images %v: %v\n", imageIDs, err)
+			return "", err
+		}
 	}
 
-	fmt.Printf("ProcessImageWithAI: Sending multimodal request to AI model: %s\n", at.model)
-	resp, err := at.openaiClient.Chat.Completions.New(ctx, req)
+	// Images rejected as too large are retried at half the size and lower quality
+	var response string
+	var err error
+	maxDimension, quality := at.imageMaxDimension, at.imageQuality
+	for {
+		images := make([]ImageInput, 0, len(filenames))
+		payloadSize := 0
+		for _, filename := range filenames {
+			optimizedData, mimeType, resizeErr := at.loadOptimizedImage(filename, maxDimension, quality)
+			if resizeErr != nil {
+				return "", resizeErr
+			}
+			images = append(images, ImageInput{MimeType: mimeType, Data: optimizedData})
+			payloadSize += len(optimizedData)
+		}
+
+		// Each image becomes its own content part; the last is passed separately
+		// because that's how providers take an attached image
+		req.Images = images[:len(images)-1]
+		fmt.Printf("ProcessImageWithAI: Sending multimodal request with %d images to AI model: %s\n", len(images), model)
+		err = at.retry.Do(ctx, "ProcessImageWithAI", func() (err error) {
+			countAIRequest()
+			response, err = at.provider.CompleteWithImage(ctx, req, images[len(images)-1])
+			return err
+		})
+		if err == nil || !isPayloadTooLargeError(err) || maxDimension <= LLMMinDimension {
+			break
+		}
+
+		fmt.Printf("ProcessImageWithAI: images of %d bytes rejected as too large, retrying smaller: %v\n", payloadSize, err)
+		maxDimension = max(maxDimension/2, LLMMinDimension)
+		quality = max(quality-15, LLMMinQuality)
+	}
 	if err != nil {
+		if isUnsupportedImageError(err) {
+			// Degrade gracefully instead of surfacing an opaque API error, and stop
+			// sending images to this model
+			fmt.Printf("ProcessImageWithAI: model %s does not accept images: %v\n", model, err)
+			at.SetVisionSupported(false)
+			return ErrorMessageVisionUnsupported, nil
+		}
 		return "", fmt.Errorf("multimodal AI API error: %w", err)
 	}
 
-	if len(resp.Choices) == 0 {
+	response = strings.TrimSpace(response)
+	if response == "" {
 		return "Maaf, saya tidak dapat merespons gambar tersebut saat ini.", nil
 	}
 
-	response := strings.TrimSpace(resp.Choices[0].Message.Content)
-
 	if onStatus != nil {
 		onStatus("⚡ Menyiapkan respons...")
 	}
@@ -114,10 +487,8 @@ func (at *AITools) ProcessImageWithAI(ctx context.Context, userMessage string, f
 	return response, nil
 }
 
-// ProcessTextWithAI handles text processing with optional referenced images
-func (at *AITools) ProcessTextWithAI(ctx context.Context, userMessage string, referencedImages []map[string]string, history []openai.ChatCompletionMessageParamUnion, onStatus func(string)) (string, error) {
-	fmt.Printf("ProcessTextWithAI: Starting processing with message: %s, referenced images: %d\n", userMessage, len(referencedImages))
-
+// textRequest assembles the completion request for a text message
+func (at *AITools) textRequest(userMessage string, referencedImages []map[string]string, history []openai.ChatCompletionMessageParamUnion) CompletionRequest {
 	// Create enhanced message with image references
 	enhancedMessage := userMessage
 	if len(referencedImages) > 0 {
@@ -127,55 +498,120 @@ func (at *AITools) ProcessTextWithAI(ctx context.Context, userMessage string, re
 		}
 	}
 
-	// Build message content parts
-	var contentParts []openai.ChatCompletionContentPartUnionParam
-	contentParts = append(contentParts, openai.TextContentPart(enhancedMessage))
+	req := CompletionRequest{
+		Model:           at.Model(),
+		System:          at.textSystemPrompt(),
+		History:         TrimHistory(history, at.historyLimit),
+		Text:            enhancedMessage,
+		MaxTokens:       at.maxTokens,
+		Temperature:     at.temperature,
+		ReasoningEffort: at.reasoningEffort,
+		ImageDetail:     at.imageDetail,
+		OnUsage:         at.recordUsage,
+	}
 
-	// Add referenced images
+	// Add referenced images; models without vision only get the image IDs
+	if !at.VisionSupported() {
+		return req
+	}
 	for _, img := range referencedImages {
-		imagePath := fmt.Sprintf("data/%s", img["filename"])
-		imageData, err := os.ReadFile(imagePath)
-		if err != nil {
-			fmt.Printf("Failed to read referenced image %s: %v\n", img["id"], err)
-			continue
-		}
-
-		// Validate and optimize image
-		optimizedData, mimeType, err := at.validateAndOptimizeImage(imageData, img["filename"])
+		// Validate and optimize image, reusing the result from an earlier reference
+		optimizedData, mimeType, err := at.loadOptimizedImage(img["filename"], at.imageMaxDimension, at.imageQuality)
 		if err != nil {
 			fmt.Printf("Failed to optimize referenced image %s: %v\n", img["id"], err)
 			continue
 		}
 
-		// Convert to base64
-		base64Image := base64.StdEncoding.EncodeToString(optimizedData)
-
-		contentParts = append(contentParts, openai.ImageContentPart(openai.ChatCompletionContentPartImageImageURLParam{
-			URL:    fmt.Sprintf("data:%s;base64,%s", mimeType, base64Image),
-			Detail: "high",
-		}))
+		req.Images = append(req.Images, ImageInput{MimeType: mimeType, Data: optimizedData})
 	}
 
-	// Add user message with content to history
-	updatedHistory := append(history, openai.UserMessage(contentParts))
+	return req
+}
 
-	// Create request with multimodal content
-	req := openai.ChatCompletionNewParams{
-		Model:       at.model,
-		Messages:    updatedHistory,
-		MaxTokens:   openai.Int(500),
-		Temperature: openai.Float(0.7),
+// PreviewTextPrompt renders the messages that ProcessTextWithAI would send, with image data redacted
+func (at *AITools) PreviewTextPrompt(userMessage string, referencedImages []map[string]string, history []openai.ChatCompletionMessageParamUnion) (string, error) {
+	rendered, err := RenderPrompt(openAIMessages(at.textRequest(userMessage, referencedImages, history)))
+	if err != nil {
+		return "", fmt.Errorf("failed to render prompt: %w", err)
 	}
+	return fmt.Sprintf("Model: %s\n\n%s", at.Model(), rendered), nil
+}
+
+// ProcessTextWithAI handles text processing with optional referenced images
+func (at *AITools) ProcessTextWithAI(ctx context.Context, userMessage string, referencedImages []map[string]string, history []openai.ChatCompletionMessageParamUnion, onStatus func(string)) (string, error) {
+	fmt.Printf("ProcessTextWithAI: Starting processing with message: %s, referenced images: %d\n", userMessage, len(referencedImages))
+
+	req := at.textRequest(userMessage, referencedImages, history)
 
-	resp, err := at.openaiClient.Chat.Completions.New(ctx, req)
+	var response string
+	err := at.retry.Do(ctx, "ProcessTextWithAI", func() (err error) {
+		countAIRequest()
+		response, err = at.provider.CompleteText(ctx, req)
+		return err
+	})
 	if err != nil {
 		return "", fmt.Errorf("text AI API error: %w", err)
 	}
 
-	if len(resp.Choices) == 0 {
+	response = strings.TrimSpace(response)
+	if response == "" {
 		return "Maaf, saya tidak dapat merespons pesan tersebut saat ini.", nil
 	}
+	return response, nil
+}
 
-	response := strings.TrimSpace(resp.Choices[0].Message.Content)
+// ProcessTextWithAIStream is like ProcessTextWithAI but streams the completion, calling
+// onChunk with each content delta as it arrives. It returns the full response.
+func (at *AITools) ProcessTextWithAIStream(ctx context.Context, userMessage string, referencedImages []map[string]string, history []openai.ChatCompletionMessageParamUnion, onChunk func(string)) (string, error) {
+	fmt.Printf("ProcessTextWithAIStream: Starting processing with message: %s, referenced images: %d\n", userMessage, len(referencedImages))
+
+	req := at.textRequest(userMessage, referencedImages, history)
+
+	// Providers without streaming deliver the whole answer as one chunk
+	streamer, ok := at.provider.(StreamingProvider)
+	if !ok {
+		var response string
+		err := at.retry.Do(ctx, "ProcessTextWithAIStream", func() (err error) {
+			countAIRequest()
+			response, err = at.provider.CompleteText(ctx, req)
+			return err
+		})
+		if err != nil {
+			return "", fmt.Errorf("text AI API error: %w", err)
+		}
+		if response = strings.TrimSpace(response); response != "" && onChunk != nil {
+			onChunk(response)
+		}
+		if response == "" {
+			return "Maaf, saya tidak dapat merespons pesan tersebut saat ini.", nil
+		}
+		return response, nil
+	}
+
+	// Once part of the answer has been delivered a retry would repeat it, so only
+	// failures before the first chunk are retried
+	var response string
+	err := at.retry.Do(ctx, "ProcessTextWithAIStream", func() (err error) {
+		countAIRequest()
+		started := false
+		response, err = streamer.StreamText(ctx, req, func(delta string) {
+			started = true
+			if onChunk != nil {
+				onChunk(delta)
+			}
+		})
+		if err != nil && started {
+			return &streamInterruptedError{err}
+		}
+		return err
+	})
+	if err != nil {
+		return "", fmt.Errorf("text AI streaming error: %w", err)
+	}
+
+	response = strings.TrimSpace(response)
+	if response == "" {
+		return "Maaf, saya tidak dapat merespons pesan tersebut saat ini.", nil
+	}
 	return response, nil
 }
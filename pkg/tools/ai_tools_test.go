@@ -0,0 +1,82 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"image"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// stubProvider records completion requests and answers them with response or err
+type stubProvider struct {
+	mu       sync.Mutex
+	requests []CompletionRequest
+	response string
+	err      error
+}
+
+func (p *stubProvider) CompleteText(ctx context.Context, req CompletionRequest) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.requests = append(p.requests, req)
+	return p.response, p.err
+}
+
+func (p *stubProvider) CompleteWithImage(ctx context.Context, req CompletionRequest, image ImageInput) (string, error) {
+	req.Images = append(req.Images, image)
+	return p.CompleteText(ctx, req)
+}
+
+func (p *stubProvider) models() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var models []string
+	for _, req := range p.requests {
+		models = append(models, req.Model)
+	}
+	return models
+}
+
+// writeTestImage saves a small JPEG in dir and returns its file name
+func writeTestImage(t *testing.T, dir string) string {
+	t.Helper()
+	data, err := encodeImage(image.NewRGBA(image.Rect(0, 0, 32, 32)), LLMQuality)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "IMG1.jpg"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return "IMG1.jpg"
+}
+
+func TestProcessImageWithAIVisionFallback(t *testing.T) {
+	dir := t.TempDir()
+	provider := &stubProvider{err: errors.New("400 Bad Request: this model does not support image input")}
+	at := NewAITools(provider, "text-only")
+	at.SetDataDir(dir)
+	at.SetVisionModel("vision-model")
+	filename := writeTestImage(t, dir)
+
+	response, err := at.ProcessImageWithAI(context.Background(), "what is this?", []string{filename}, []string{"IMG1"}, nil, nil)
+	if err != nil || response != ErrorMessageVisionUnsupported {
+		t.Fatalf("ProcessImageWithAI = %q, %v; want the vision fallback message", response, err)
+	}
+	if models := provider.models(); len(models) != 1 || models[0] != "vision-model" {
+		t.Errorf("requests went to %v, want one to VISION_MODEL", models)
+	}
+	if at.VisionSupported() {
+		t.Error("vision still marked as supported after the unsupported-modality error")
+	}
+
+	// Later images aren't sent to the model again
+	if _, err := at.ProcessImageWithAI(context.Background(), "and this?", []string{filename}, []string{"IMG1"}, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(provider.models()); n != 1 {
+		t.Errorf("%d requests after vision was disabled, want 1", n)
+	}
+}
@@ -0,0 +1,194 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	defaultAnthropicBaseURL = "https://api.anthropic.com"
+	defaultAnthropicModel   = "claude-3-5-sonnet-latest"
+	anthropicAPIVersion     = "2023-06-01"
+)
+
+// AnthropicError is an error response from the Anthropic Messages API
+type AnthropicError struct {
+	StatusCode int
+	Type       string
+	Message    string
+}
+
+func (e *AnthropicError) Error() string {
+	return fmt.Sprintf("anthropic API error %d (%s): %s", e.StatusCode, e.Type, e.Message)
+}
+
+// AnthropicProvider sends completions to the Anthropic Messages API
+type AnthropicProvider struct {
+	apiKey     string
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewAnthropicProvider creates an Anthropic provider; an empty baseURL uses the public API
+func NewAnthropicProvider(apiKey, baseURL string) *AnthropicProvider {
+	if baseURL == "" {
+		baseURL = defaultAnthropicBaseURL
+	}
+	return &AnthropicProvider{
+		apiKey:     apiKey,
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: 2 * time.Minute},
+	}
+}
+
+type anthropicContent struct {
+	Type   string                `json:"type"`
+	Text   string                `json:"text,omitempty"`
+	Source *anthropicImageSource `json:"source,omitempty"`
+}
+
+type anthropicImageSource struct {
+	Type      string `json:"type"`
+	MediaType string `json:"media_type"`
+	Data      string `json:"data"`
+}
+
+type anthropicMessage struct {
+	Role    string             `json:"role"`
+	Content []anthropicContent `json:"content"`
+}
+
+type anthropicRequest struct {
+	Model       string             `json:"model"`
+	MaxTokens   int                `json:"max_tokens"`
+	System      string             `json:"system,omitempty"`
+	Messages    []anthropicMessage `json:"messages"`
+	Temperature float64            `json:"temperature"`
+}
+
+type anthropicResponse struct {
+	Content []anthropicContent `json:"content"`
+	Usage   struct {
+		InputTokens  int64 `json:"input_tokens"`
+		OutputTokens int64 `json:"output_tokens"`
+	} `json:"usage"`
+	Error *struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// CompleteText sends a Messages API request
+func (p *AnthropicProvider) CompleteText(ctx context.Context, req CompletionRequest) (string, error) {
+	body, err := json.Marshal(anthropicRequestFor(req))
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal anthropic request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/v1/messages", bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("failed to create anthropic request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("x-api-key", p.apiKey)
+	httpReq.Header.Set("anthropic-version", anthropicAPIVersion)
+
+	resp, err := p.httpClient.Do(httpReq)
+	if err != nil {
+		return "", fmt.Errorf("anthropic request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read anthropic response: %w", err)
+	}
+
+	var parsed anthropicResponse
+	if err := json.Unmarshal(data, &parsed); err != nil && resp.StatusCode < 300 {
+		return "", fmt.Errorf("failed to parse anthropic response: %w", err)
+	}
+	if resp.StatusCode >= 300 {
+		apiErr := &AnthropicError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(data))}
+		if parsed.Error != nil {
+			apiErr.Type, apiErr.Message = parsed.Error.Type, parsed.Error.Message
+		}
+		return "", apiErr
+	}
+	req.reportUsage(TokenUsage{PromptTokens: parsed.Usage.InputTokens, CompletionTokens: parsed.Usage.OutputTokens})
+
+	var sb strings.Builder
+	for _, block := range parsed.Content {
+		if block.Type == "text" {
+			sb.WriteString(block.Text)
+		}
+	}
+	return sb.String(), nil
+}
+
+// CompleteWithImage sends a Messages API request with one image attached
+func (p *AnthropicProvider) CompleteWithImage(ctx context.Context, req CompletionRequest, image ImageInput) (string, error) {
+	req.Images = append(append([]ImageInput(nil), req.Images...), image)
+	return p.CompleteText(ctx, req)
+}
+
+// anthropicRequestFor converts a request to the Messages API format. System messages in
+// the history are folded into the system prompt and consecutive turns from the same
+// role are merged, since the API expects alternating user and assistant messages.
+func anthropicRequestFor(req CompletionRequest) anthropicRequest {
+	// As with OpenAI, a system message at the start of the history replaces req.System
+	var system []string
+	if req.System != "" && (len(req.History) == 0 || req.History[0].OfSystem == nil) {
+		system = append(system, req.System)
+	}
+
+	var messages []anthropicMessage
+	add := func(role string, content ...anthropicContent) {
+		if n := len(messages); n > 0 && messages[n-1].Role == role {
+			messages[n-1].Content = append(messages[n-1].Content, content...)
+			return
+		}
+		messages = append(messages, anthropicMessage{Role: role, Content: content})
+	}
+
+	for _, msg := range req.History {
+		role, text := messageText(msg)
+		switch {
+		case role == "system":
+			system = append(system, text)
+		case role != "" && text != "":
+			add(role, anthropicContent{Type: "text", Text: text})
+		}
+	}
+	if len(messages) > 0 && messages[0].Role != "user" {
+		messages = messages[1:] // the conversation must start with the user
+	}
+
+	content := []anthropicContent{{Type: "text", Text: req.Text}}
+	for _, img := range req.Images {
+		content = append(content, anthropicContent{
+			Type: "image",
+			Source: &anthropicImageSource{
+				Type:      "base64",
+				MediaType: img.MimeType,
+				Data:      base64.StdEncoding.EncodeToString(img.Data),
+			},
+		})
+	}
+	add("user", content...)
+
+	return anthropicRequest{
+		Model:       req.Model,
+		MaxTokens:   req.MaxTokens,
+		System:      strings.Join(system, "\n\n"),
+		Messages:    messages,
+		Temperature: req.Temperature,
+	}
+}
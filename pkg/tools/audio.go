@@ -0,0 +1,85 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/openai/openai-go"
+)
+
+// transcriptionFormats maps audio MIME types accepted by the transcription endpoint to file extensions
+var transcriptionFormats = map[string]string{
+	"audio/ogg":   "ogg",
+	"audio/mpeg":  "mp3",
+	"audio/mp3":   "mp3",
+	"audio/mp4":   "m4a",
+	"audio/m4a":   "m4a",
+	"audio/x-m4a": "m4a",
+	"audio/wav":   "wav",
+	"audio/x-wav": "wav",
+	"audio/webm":  "webm",
+	"audio/flac":  "flac",
+}
+
+// baseMimeType strips parameters such as "; codecs=opus" from a MIME type
+func baseMimeType(mimeType string) string {
+	base, _, _ := strings.Cut(mimeType, ";")
+	return strings.ToLower(strings.TrimSpace(base))
+}
+
+// ConvertAudioToMP3 converts audio of any format ffmpeg understands to MP3
+func ConvertAudioToMP3(ctx context.Context, data []byte) ([]byte, error) {
+	ffmpeg, err := exec.LookPath("ffmpeg")
+	if err != nil {
+		return nil, fmt.Errorf("ffmpeg not found, cannot convert audio: %w", err)
+	}
+
+	var out, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, ffmpeg, "-hide_banner", "-loglevel", "error", "-i", "pipe:0", "-f", "mp3", "pipe:1")
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Stdout = &out
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("ffmpeg conversion failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return out.Bytes(), nil
+}
+
+// TranscribeAudio converts speech to text using the OpenAI transcription endpoint.
+// Unsupported formats are converted with ffmpeg when it is available.
+func (at *AITools) TranscribeAudio(ctx context.Context, data []byte, mimeType string) (string, error) {
+	client, err := at.openAIClient("transcription")
+	if err != nil {
+		return "", err
+	}
+
+	ext, supported := transcriptionFormats[baseMimeType(mimeType)]
+	if !supported {
+		fmt.Printf("TranscribeAudio: converting unsupported audio type %s with ffmpeg\n", mimeType)
+		converted, err := ConvertAudioToMP3(ctx, data)
+		if err != nil {
+			return "", fmt.Errorf("unsupported audio type %s: %w", mimeType, err)
+		}
+		data, ext, mimeType = converted, "mp3", "audio/mpeg"
+	}
+
+	req := openai.AudioTranscriptionNewParams{
+		File:  openai.File(bytes.NewReader(data), "audio."+ext, baseMimeType(mimeType)),
+		Model: openai.AudioModel(at.transcriptionModel),
+	}
+
+	fmt.Printf("TranscribeAudio: Sending %.2fKB of %s audio to model: %s\n", float64(len(data))/1024, mimeType, at.transcriptionModel)
+	countAIRequest()
+	resp, err := client.Audio.Transcriptions.New(ctx, req)
+	if err != nil {
+		return "", fmt.Errorf("transcription API error: %w", err)
+	}
+	// Whisper bills by duration and reports no tokens; the request is still counted
+	at.recordUsage(TokenUsage{PromptTokens: resp.Usage.InputTokens, CompletionTokens: resp.Usage.OutputTokens, TotalTokens: resp.Usage.TotalTokens})
+
+	return strings.TrimSpace(resp.Text), nil
+}
@@ -0,0 +1,212 @@
+package tools
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// ChunkMode controls how a streamed AI response is split into WhatsApp messages
+type ChunkMode string
+
+const (
+	ChunkOnce      ChunkMode = "once"      // a single message once the response is complete
+	ChunkSentence  ChunkMode = "sentence"  // one message per sentence
+	ChunkParagraph ChunkMode = "paragraph" // one message per paragraph
+	ChunkChars     ChunkMode = "chars"     // messages of up to a character budget
+)
+
+// DefaultChunkChars is the character budget used by ChunkChars when none is configured
+const DefaultChunkChars = 500
+
+// ParseChunkMode validates a chunk mode name; empty means ChunkOnce
+func ParseChunkMode(s string) (ChunkMode, error) {
+	switch mode := ChunkMode(strings.ToLower(strings.TrimSpace(s))); mode {
+	case "":
+		return ChunkOnce, nil
+	case ChunkOnce, ChunkSentence, ChunkParagraph, ChunkChars:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown chunk mode %q (use once, sentence, paragraph or chars)", s)
+	}
+}
+
+// Chunker buffers streamed text and emits complete chunks according to its mode.
+// It never splits inside a ``` code block or between the items of a list.
+type Chunker struct {
+	mode     ChunkMode
+	maxChars int
+	emit     func(string)
+	buf      string
+}
+
+// NewChunker creates a chunker calling emit for every complete chunk
+func NewChunker(mode ChunkMode, maxChars int, emit func(string)) *Chunker {
+	if maxChars <= 0 {
+		maxChars = DefaultChunkChars
+	}
+	return &Chunker{mode: mode, maxChars: maxChars, emit: emit}
+}
+
+// Write adds streamed text and emits any chunks that are now complete
+func (c *Chunker) Write(delta string) {
+	c.buf += delta
+	if c.mode == ChunkOnce {
+		return
+	}
+
+	for {
+		cut := c.nextCut()
+		if cut <= 0 {
+			return
+		}
+		c.send(c.buf[:cut])
+		c.buf = c.buf[cut:]
+	}
+}
+
+// Flush emits whatever text is still buffered
+func (c *Chunker) Flush() {
+	c.send(c.buf)
+	c.buf = ""
+}
+
+func (c *Chunker) send(chunk string) {
+	if chunk = strings.TrimSpace(chunk); chunk != "" {
+		c.emit(chunk)
+	}
+}
+
+// nextCut returns the end of the next chunk in the buffer, or 0 if none is complete yet
+func (c *Chunker) nextCut() int {
+	switch c.mode {
+	case ChunkSentence:
+		return c.firstCut(sentenceBoundaries(c.buf))
+	case ChunkParagraph:
+		return c.firstCut(paragraphBoundaries(c.buf))
+	case ChunkChars:
+		if len(c.buf) < c.maxChars {
+			return 0
+		}
+		// Prefer the last allowed boundary within the budget, otherwise the first one after it
+		best := 0
+		for _, cut := range wordBoundaries(c.buf) {
+			if !c.canCut(cut) {
+				continue
+			}
+			if cut <= c.maxChars {
+				best = cut
+				continue
+			}
+			if best == 0 {
+				best = cut
+			}
+			break
+		}
+		return best
+	}
+	return 0
+}
+
+// firstCut returns the first boundary that is safe to split at
+func (c *Chunker) firstCut(boundaries []int) int {
+	for _, cut := range boundaries {
+		if c.canCut(cut) {
+			return cut
+		}
+	}
+	return 0
+}
+
+// canCut reports whether the buffer may be split at cut: outside code blocks, not
+// between list items, and only once the text following the cut has started to arrive
+func (c *Chunker) canCut(cut int) bool {
+	before, after := c.buf[:cut], c.buf[cut:]
+	if strings.Count(before, "```")%2 == 1 {
+		return false
+	}
+
+	next := strings.TrimLeftFunc(after, unicode.IsSpace)
+	if next == "" {
+		return false // we don't know yet what follows
+	}
+
+	prevText := strings.TrimRight(before, " \n")
+	prevLine := prevText[strings.LastIndex(prevText, "\n")+1:]
+	nextLine, _, complete := strings.Cut(next, "\n")
+	if !complete && len(nextLine) < 3 {
+		return false // not enough text to tell whether a list starts or continues
+	}
+
+	if !strings.Contains(before[len(prevText):], "\n") {
+		// Cutting mid-line: never inside a list item, judged by the whole line
+		rest, _, _ := strings.Cut(after, "\n")
+		if isListLine(before[strings.LastIndex(before, "\n")+1:] + rest) {
+			return false
+		}
+	}
+	if isListLine(prevLine) {
+		// Inside a list only a line break ending the whole list is allowed
+		return strings.Contains(before[len(prevText):], "\n") && !isListLine(nextLine)
+	}
+	if strings.HasSuffix(prevLine, ":") && isListLine(nextLine) {
+		return false // keep a list together with the line introducing it
+	}
+	return true
+}
+
+// isListLine reports whether a line is a bulleted or numbered list item
+func isListLine(line string) bool {
+	line = strings.TrimSpace(line)
+	for _, bullet := range []string{"- ", "* ", "• "} {
+		if strings.HasPrefix(line, bullet) {
+			return true
+		}
+	}
+
+	digits := 0
+	for digits < len(line) && line[digits] >= '0' && line[digits] <= '9' {
+		digits++
+	}
+	return digits > 0 && digits+1 < len(line) && (line[digits] == '.' || line[digits] == ')') && line[digits+1] == ' '
+}
+
+// sentenceBoundaries returns positions just after sentence-ending punctuation or paragraph breaks
+func sentenceBoundaries(s string) []int {
+	var cuts []int
+	for i := 0; i < len(s)-1; i++ {
+		switch s[i] {
+		case '.', '!', '?':
+			if s[i+1] == ' ' || s[i+1] == '\n' {
+				cuts = append(cuts, i+1)
+			}
+		case '\n':
+			if s[i+1] == '\n' {
+				cuts = append(cuts, i+2)
+			}
+		}
+	}
+	return cuts
+}
+
+// paragraphBoundaries returns positions just after blank lines
+func paragraphBoundaries(s string) []int {
+	var cuts []int
+	for i := 0; i < len(s)-1; i++ {
+		if s[i] == '\n' && s[i+1] == '\n' {
+			cuts = append(cuts, i+2)
+		}
+	}
+	return cuts
+}
+
+// wordBoundaries returns positions just after whitespace
+func wordBoundaries(s string) []int {
+	var cuts []int
+	for i := 0; i < len(s); i++ {
+		if s[i] == ' ' || s[i] == '\n' {
+			cuts = append(cuts, i+1)
+		}
+	}
+	return cuts
+}
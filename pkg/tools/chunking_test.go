@@ -0,0 +1,82 @@
+package tools
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// chunkStream feeds text to a chunker a few bytes at a time, like a streamed response
+func chunkStream(mode ChunkMode, maxChars int, text string) []string {
+	var chunks []string
+	c := NewChunker(mode, maxChars, func(s string) { chunks = append(chunks, s) })
+	for i := 0; i < len(text); i += 3 {
+		c.Write(text[i:min(i+3, len(text))])
+	}
+	c.Flush()
+	return chunks
+}
+
+func TestChunker(t *testing.T) {
+	const sample = "Halo! Ini jawaban pertama. Berikut langkahnya:\n1. Buka aplikasi.\n2. Pilih menu.\n\n" +
+		"Contoh kode:\n```go\nfmt.Println(\"a. b\")\n\nx := 1\n```\nSelesai. Semoga membantu?"
+
+	tests := []struct {
+		mode ChunkMode
+		want []string
+	}{
+		{ChunkOnce, []string{sample}},
+		{ChunkSentence, []string{
+			"Halo!",
+			"Ini jawaban pertama.",
+			"Berikut langkahnya:\n1. Buka aplikasi.\n2. Pilih menu.",
+			"Contoh kode:\n```go\nfmt.Println(\"a. b\")\n\nx := 1\n```\nSelesai.",
+			"Semoga membantu?",
+		}},
+		{ChunkParagraph, []string{
+			"Halo! Ini jawaban pertama. Berikut langkahnya:\n1. Buka aplikasi.\n2. Pilih menu.",
+			"Contoh kode:\n```go\nfmt.Println(\"a. b\")\n\nx := 1\n```\nSelesai. Semoga membantu?",
+		}},
+		{ChunkChars, []string{
+			"Halo! Ini jawaban pertama. Berikut",
+			"langkahnya:\n1. Buka aplikasi.\n2. Pilih menu.",
+			"Contoh kode:",
+			"```go\nfmt.Println(\"a. b\")\n\nx := 1\n```",
+			"Selesai. Semoga membantu?",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.mode), func(t *testing.T) {
+			got := chunkStream(tt.mode, 40, sample)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("chunks = %#v\nwant %#v", got, tt.want)
+			}
+			for _, chunk := range got {
+				if strings.Count(chunk, "```")%2 != 0 {
+					t.Errorf("chunk splits a code block: %q", chunk)
+				}
+			}
+		})
+	}
+}
+
+func TestChunkerKeepsListItemsTogether(t *testing.T) {
+	const list = "Pilihan:\n- Paket A. Murah.\n- Paket B. Cepat!\n- Paket C\n\nHubungi kami."
+	for _, mode := range []ChunkMode{ChunkSentence, ChunkParagraph, ChunkChars} {
+		chunks := chunkStream(mode, 10, list)
+		if len(chunks) < 2 || chunks[0] != "Pilihan:\n- Paket A. Murah.\n- Paket B. Cepat!\n- Paket C" {
+			t.Errorf("%s: chunks = %#v, want the list in one chunk", mode, chunks)
+		}
+	}
+}
+
+func TestParseChunkMode(t *testing.T) {
+	for input, want := range map[string]ChunkMode{"": ChunkOnce, " Sentence ": ChunkSentence, "CHARS": ChunkChars} {
+		if got, err := ParseChunkMode(input); err != nil || got != want {
+			t.Errorf("ParseChunkMode(%q) = %q, %v; want %q", input, got, err, want)
+		}
+	}
+	if _, err := ParseChunkMode("words"); err == nil {
+		t.Error("ParseChunkMode accepted an unknown mode")
+	}
+}
@@ -0,0 +1,58 @@
+package tools
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ClientConfig holds optional per-client settings stored next to the client databases
+type ClientConfig struct {
+	WebhookURL string `json:"webhookURL,omitempty"`
+	Model      string `json:"model,omitempty"` // AI model, overriding OPENAI_MODEL for this client
+}
+
+// LoadClientConfig reads a client config file; a missing file yields an empty config
+func LoadClientConfig(path string) (ClientConfig, error) {
+	var cfg ClientConfig
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, fmt.Errorf("failed to read client config %s: %w", path, err)
+	}
+
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("failed to parse client config %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// LoadSystemPrompt reads a system prompt file; a missing file yields an empty prompt
+func LoadSystemPrompt(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read system prompt %s: %w", path, err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// SaveClientConfig writes a client config file
+func SaveClientConfig(path string, cfg ClientConfig) error {
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal client config: %w", err)
+	}
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to save client config to %s: %w", path, err)
+	}
+	return nil
+}
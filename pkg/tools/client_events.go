@@ -0,0 +1,51 @@
+package tools
+
+// OnClientAdded registers a callback fired when a client is added, restored by
+// LoadExistingClients, or connected again after LogoutClient. Clients already registered
+// are passed to it right away. Callbacks run synchronously, possibly with the client
+// locked, so they must not call back into the manager for the same client.
+func (wm *WhatsAppManager) OnClientAdded(fn func(instance *WhatsAppInstance)) {
+	wm.handlerMu.Lock()
+	wm.clientAddedHandler = fn
+	wm.handlerMu.Unlock()
+
+	wm.mu.RLock()
+	instances := make([]*WhatsAppInstance, 0, len(wm.instances))
+	for _, instance := range wm.instances {
+		instances = append(instances, instance)
+	}
+	wm.mu.RUnlock()
+
+	for _, instance := range instances {
+		wm.clientAdded(instance)
+	}
+}
+
+// OnClientRemoved registers a callback fired when a client is removed or logged out,
+// before its WhatsApp client is discarded. The same restrictions as for OnClientAdded
+// apply.
+func (wm *WhatsAppManager) OnClientRemoved(fn func(phoneID string)) {
+	wm.handlerMu.Lock()
+	defer wm.handlerMu.Unlock()
+	wm.clientRemovedHandler = fn
+}
+
+// clientAdded notifies the OnClientAdded callback about a client once
+func (wm *WhatsAppManager) clientAdded(instance *WhatsAppInstance) {
+	wm.handlerMu.RLock()
+	handler := wm.clientAddedHandler
+	wm.handlerMu.RUnlock()
+	if handler != nil && instance.announced.CompareAndSwap(false, true) {
+		handler(instance)
+	}
+}
+
+// clientRemoved notifies the OnClientRemoved callback about a client announced earlier
+func (wm *WhatsAppManager) clientRemoved(instance *WhatsAppInstance) {
+	wm.handlerMu.RLock()
+	handler := wm.clientRemovedHandler
+	wm.handlerMu.RUnlock()
+	if handler != nil && instance.announced.Swap(false) {
+		handler(instance.PhoneID)
+	}
+}
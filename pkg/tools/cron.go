@@ -0,0 +1,171 @@
+package tools
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronShortcuts are the supported @-prefixed schedule aliases
+var cronShortcuts = map[string]string{
+	"@hourly":   "0 * * * *",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@weekly":   "0 0 * * 0",
+	"@monthly":  "0 0 1 * *",
+	"@yearly":   "0 0 1 1 *",
+}
+
+// CronSchedule is a parsed five-field cron spec: minute hour day-of-month month day-of-week
+type CronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	domStar, dowStar              bool
+	loc                           *time.Location
+}
+
+// ParseCronSpec parses a cron spec such as "30 8 * * 1-5" (08:30 on weekdays) or an alias
+// like "@daily". Fields support *, lists (1,3), ranges (1-5) and steps (*/15, 0-30/10).
+// Times are evaluated in loc; nil means the local timezone.
+func ParseCronSpec(spec string, loc *time.Location) (*CronSchedule, error) {
+	if loc == nil {
+		loc = time.Local
+	}
+
+	spec = strings.TrimSpace(spec)
+	if alias, ok := cronShortcuts[strings.ToLower(spec)]; ok {
+		spec = alias
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid cron spec %q: expected 5 fields (minute hour day month weekday)", spec)
+	}
+
+	bounds := [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+	names := [5]string{"minute", "hour", "day of month", "month", "day of week"}
+	var sets [5]uint64
+	for i, field := range fields {
+		set, err := parseCronField(field, bounds[i][0], bounds[i][1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s field %q: %w", names[i], field, err)
+		}
+		sets[i] = set
+	}
+
+	// Sunday may be written as 0 or 7
+	if sets[4]&(1<<7) != 0 {
+		sets[4] |= 1
+	}
+
+	return &CronSchedule{
+		minute:  sets[0],
+		hour:    sets[1],
+		dom:     sets[2],
+		month:   sets[3],
+		dow:     sets[4],
+		domStar: fields[2] == "*",
+		dowStar: fields[4] == "*",
+		loc:     loc,
+	}, nil
+}
+
+// parseCronField returns a bitset of the values matched by a single cron field
+func parseCronField(field string, min, max int) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepPart)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step %q", stepPart)
+			}
+			step = n
+		}
+
+		lo, hi := min, max
+		if rangePart != "*" {
+			loStr, hiStr, isRange := strings.Cut(rangePart, "-")
+			var err error
+			if lo, err = strconv.Atoi(loStr); err != nil {
+				return 0, fmt.Errorf("invalid value %q", loStr)
+			}
+			hi = lo
+			if isRange {
+				if hi, err = strconv.Atoi(hiStr); err != nil {
+					return 0, fmt.Errorf("invalid value %q", hiStr)
+				}
+			} else if hasStep {
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("value out of range %d-%d", min, max)
+		}
+
+		for v := lo; v <= hi; v += step {
+			set |= 1 << uint(v)
+		}
+	}
+	return set, nil
+}
+
+// matchesDay applies cron's day rule: when both day-of-month and day-of-week are
+// restricted, a day matching either one fires
+func (cs *CronSchedule) matchesDay(t time.Time) bool {
+	if cs.month&(1<<uint(t.Month())) == 0 {
+		return false
+	}
+
+	domMatch := cs.dom&(1<<uint(t.Day())) != 0
+	dowMatch := cs.dow&(1<<uint(t.Weekday())) != 0
+	switch {
+	case cs.domStar && cs.dowStar:
+		return true
+	case cs.domStar:
+		return dowMatch
+	case cs.dowStar:
+		return domMatch
+	default:
+		return domMatch || dowMatch
+	}
+}
+
+// Next returns the first fire time strictly after the given time, or the zero time if the
+// spec never fires (e.g. "0 0 31 2 *"). Wall-clock times skipped by a DST change fire at
+// the equivalent instant after the shift; repeated wall-clock times fire once.
+func (cs *CronSchedule) Next(after time.Time) time.Time {
+	after = after.In(cs.loc)
+	day := time.Date(after.Year(), after.Month(), after.Day(), 0, 0, 0, 0, cs.loc)
+
+	// Five years covers every valid day/month/weekday combination, including Feb 29
+	for i := 0; i < 5*366; i++ {
+		date := day.AddDate(0, 0, i)
+		if !cs.matchesDay(date) {
+			continue
+		}
+		for h := 0; h < 24; h++ {
+			if cs.hour&(1<<uint(h)) == 0 {
+				continue
+			}
+			for m := 0; m < 60; m++ {
+				if cs.minute&(1<<uint(m)) == 0 {
+					continue
+				}
+				t := time.Date(date.Year(), date.Month(), date.Day(), h, m, 0, 0, cs.loc)
+				if t.Hour() != h || t.Minute() != m {
+					// Wall time falls in a DST gap; Go normalizes it to before the
+					// transition, so move it forward by the size of the gap
+					_, offsetBefore := t.Zone()
+					_, offsetAfter := t.Add(3 * time.Hour).Zone()
+					t = t.Add(time.Duration(offsetAfter-offsetBefore) * time.Second)
+				}
+				if t.After(after) {
+					return t
+				}
+			}
+		}
+	}
+	return time.Time{}
+}
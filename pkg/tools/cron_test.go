@@ -0,0 +1,90 @@
+package tools
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronScheduleNext(t *testing.T) {
+	jakarta, err := time.LoadLocation("Asia/Jakarta")
+	if err != nil {
+		t.Skipf("timezone data not available: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		spec  string
+		after time.Time
+		want  time.Time
+	}{
+		{"weekday morning skips the weekend", "30 8 * * 1-5",
+			time.Date(2026, 10, 16, 9, 0, 0, 0, jakarta), time.Date(2026, 10, 19, 8, 30, 0, 0, jakarta)},
+		{"step", "*/15 * * * *",
+			time.Date(2026, 10, 16, 10, 7, 0, 0, jakarta), time.Date(2026, 10, 16, 10, 15, 0, 0, jakarta)},
+		{"strictly after", "*/15 * * * *",
+			time.Date(2026, 10, 16, 10, 15, 0, 0, jakarta), time.Date(2026, 10, 16, 10, 30, 0, 0, jakarta)},
+		{"alias", "@monthly",
+			time.Date(2026, 10, 16, 10, 0, 0, 0, jakarta), time.Date(2026, 11, 1, 0, 0, 0, 0, jakarta)},
+		{"sunday as 7", "0 9 * * 7",
+			time.Date(2026, 10, 16, 10, 0, 0, 0, jakarta), time.Date(2026, 10, 18, 9, 0, 0, 0, jakarta)},
+		{"day of month or day of week", "0 0 13 * 5",
+			time.Date(2026, 10, 1, 12, 0, 0, 0, jakarta), time.Date(2026, 10, 2, 0, 0, 0, 0, jakarta)},
+		{"leap day", "0 0 29 2 *",
+			time.Date(2026, 10, 16, 0, 0, 0, 0, jakarta), time.Date(2028, 2, 29, 0, 0, 0, 0, jakarta)},
+		{"never fires", "0 0 31 2 *",
+			time.Date(2026, 10, 16, 0, 0, 0, 0, jakarta), time.Time{}},
+		// The schedule's timezone decides the wall clock, not the zone of after
+		{"other timezone", "0 8 * * *",
+			time.Date(2026, 10, 16, 0, 30, 0, 0, time.UTC), time.Date(2026, 10, 16, 8, 0, 0, 0, jakarta)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := ParseCronSpec(tt.spec, jakarta)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := schedule.Next(tt.after); !got.Equal(tt.want) {
+				t.Errorf("Next(%s) = %s, want %s", tt.after, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCronScheduleNextDST(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("timezone data not available: %v", err)
+	}
+
+	// 02:30 doesn't exist on 2026-03-08; it fires at the same instant as 03:30 EDT
+	schedule, err := ParseCronSpec("30 2 * * *", newYork)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := schedule.Next(time.Date(2026, 3, 8, 0, 0, 0, 0, newYork))
+	if want := time.Date(2026, 3, 8, 7, 30, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("Next across the spring gap = %s, want %s", got, want)
+	}
+
+	// 01:30 happens twice on 2026-11-01 but fires only once
+	schedule, err = ParseCronSpec("30 1 * * *", newYork)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := schedule.Next(time.Date(2026, 11, 1, 0, 0, 0, 0, newYork))
+	if first.Hour() != 1 || first.Minute() != 30 || first.Day() != 1 {
+		t.Fatalf("Next on the fall-back day = %s, want 01:30", first)
+	}
+	second := schedule.Next(first)
+	if want := time.Date(2026, 11, 2, 1, 30, 0, 0, newYork); !second.Equal(want) {
+		t.Errorf("Next after %s = %s, want %s", first, second, want)
+	}
+}
+
+func TestParseCronSpecErrors(t *testing.T) {
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "0 0 0 * *", "*/0 * * * *", "5-1 * * * *", "a * * * *"} {
+		if _, err := ParseCronSpec(spec, time.UTC); err == nil {
+			t.Errorf("ParseCronSpec(%q) succeeded", spec)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package tools
+
+import (
+	"path/filepath"
+	"sync"
+)
+
+var (
+	dataDirMu sync.RWMutex
+	dataDir   = "data"
+)
+
+// SetDataDir sets the directory saved images and other runtime files are stored in.
+// NewWhatsAppManager points it at the manager's database directory.
+func SetDataDir(dir string) {
+	if dir == "" {
+		dir = "data"
+	}
+	dataDirMu.Lock()
+	defer dataDirMu.Unlock()
+	dataDir = dir
+}
+
+// DataDir returns the directory saved images and other runtime files are stored in
+func DataDir() string {
+	dataDirMu.RLock()
+	defer dataDirMu.RUnlock()
+	return dataDir
+}
+
+// DataPath joins path elements onto the data directory
+func DataPath(elem ...string) string {
+	return filepath.Join(append([]string{DataDir()}, elem...)...)
+}
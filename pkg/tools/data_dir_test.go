@@ -0,0 +1,61 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDataDirSaveAndRead(t *testing.T) {
+	previous := DataDir()
+	t.Cleanup(func() { SetDataDir(previous) })
+
+	SetDataDir("")
+	if got := DataPath("IMG1.jpg"); got != filepath.Join("data", "IMG1.jpg") {
+		t.Errorf("default DataPath = %q, want data/IMG1.jpg", got)
+	}
+
+	dir := filepath.Join(t.TempDir(), "wa")
+	SetDataDir(dir)
+	if got := DataPath("profiles", "store.json"); got != filepath.Join(dir, "profiles", "store.json") {
+		t.Errorf("DataPath = %q, want it under %s", got, dir)
+	}
+
+	data, err := encodeImage(image.NewRGBA(image.Rect(0, 0, 16, 16)), LLMQuality)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path, err := SaveImageToFile(data, "IMG1", "image/jpeg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != filepath.Join(dir, "IMG1.jpg") {
+		t.Errorf("saved to %q, want %s", path, filepath.Join(dir, "IMG1.jpg"))
+	}
+	if saved, err := os.ReadFile(path); err != nil || !bytes.Equal(saved, data) {
+		t.Errorf("reading the saved image: %v", err)
+	}
+
+	// AI tools read images from the data directory unless given their own
+	provider := &stubProvider{response: "a black square"}
+	at := NewAITools(provider, "vision")
+	if got := at.ImagePath("IMG1.jpg"); got != path {
+		t.Errorf("ImagePath = %q, want %q", got, path)
+	}
+	response, err := at.ProcessImageWithAI(context.Background(), "what is this?", []string{"IMG1.jpg"}, []string{"IMG1"}, nil, nil)
+	if err != nil || response != "a black square" {
+		t.Fatalf("ProcessImageWithAI = %q, %v", response, err)
+	}
+	if len(provider.requests) != 1 || len(provider.requests[0].Images) != 1 {
+		t.Errorf("provider got %d requests, want one with the saved image", len(provider.requests))
+	}
+
+	clientDir := t.TempDir()
+	at.SetDataDir(clientDir)
+	if got := at.ImagePath("IMG1.jpg"); got != filepath.Join(clientDir, "IMG1.jpg") {
+		t.Errorf("ImagePath with a client data dir = %q, want it under %s", got, clientDir)
+	}
+}
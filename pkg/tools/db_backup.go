@@ -0,0 +1,106 @@
+package tools
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// databaseFiles returns the client databases in the database directory together with
+// their SQLite sidecar files and history image indexes
+func (wm *WhatsAppManager) databaseFiles() ([]string, error) {
+	var files []string
+	for _, pattern := range []string{"whatsapp_*.db", "whatsapp_*.db-wal", "whatsapp_*.db-shm", "whatsapp_*_history.json"} {
+		matches, err := filepath.Glob(filepath.Join(wm.dbDir, pattern))
+		if err != nil {
+			return nil, fmt.Errorf("failed to glob %s: %w", pattern, err)
+		}
+		files = append(files, matches...)
+	}
+	return files, nil
+}
+
+// BackupDatabases copies every client database into a new timestamped directory under
+// destDir, e.g. data/backups/20251116_122441, and verifies each copy
+func (wm *WhatsAppManager) BackupDatabases(destDir string) error {
+	_, err := wm.backupDatabases(destDir)
+	return err
+}
+
+// backupDatabases is BackupDatabases returning the files it backed up
+func (wm *WhatsAppManager) backupDatabases(destDir string) ([]string, error) {
+	files, err := wm.databaseFiles()
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, nil
+	}
+
+	backupDir := filepath.Join(destDir, time.Now().Format("20060102_150405"))
+	if err := os.MkdirAll(backupDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	for _, file := range files {
+		if err := copyVerified(file, filepath.Join(backupDir, filepath.Base(file))); err != nil {
+			return nil, err
+		}
+	}
+	log.Printf("Backed up %d database files to %s", len(files), backupDir)
+	return files, nil
+}
+
+// copyVerified copies src to dst and checks that the copy matches the original
+func copyVerified(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", src, err)
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", dst, err)
+	}
+	srcHash := sha256.New()
+	if _, err := io.Copy(out, io.TeeReader(in, srcHash)); err != nil {
+		out.Close()
+		return fmt.Errorf("failed to copy %s: %w", src, err)
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return fmt.Errorf("failed to flush %s: %w", dst, err)
+	}
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("failed to close %s: %w", dst, err)
+	}
+
+	dstHash, err := fileHash(dst)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(srcHash.Sum(nil), dstHash) {
+		return fmt.Errorf("backup of %s is incomplete", src)
+	}
+	return nil
+}
+
+func fileHash(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return h.Sum(nil), nil
+}
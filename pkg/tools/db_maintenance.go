@@ -0,0 +1,104 @@
+package tools
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// ErrDatabaseLocked is returned when a client database is in use by another connection
+var ErrDatabaseLocked = errors.New("database is locked by another process or connection")
+
+// ErrDeviceConflict is returned when another client initialized the same device store concurrently
+var ErrDeviceConflict = errors.New("device store was initialized concurrently by another client")
+
+// VacuumDatabase checks a disconnected client's database with PRAGMA integrity_check and,
+// if it is healthy, compacts it with VACUUM, logging how much space was reclaimed
+func (wm *WhatsAppManager) VacuumDatabase(phoneID string) error {
+	instance, err := wm.GetClient(phoneID)
+	if err != nil {
+		return err
+	}
+
+	// Hold the instance lock so the client can't connect while maintenance runs
+	instance.mu.Lock()
+	defer instance.mu.Unlock()
+
+	if instance.Connected {
+		return fmt.Errorf("client %s must be disconnected before database maintenance", phoneID)
+	}
+
+	sizeBefore, err := fileSize(instance.Database)
+	if err != nil {
+		return fmt.Errorf("failed to stat database %s: %w", instance.Database, err)
+	}
+
+	db, err := sql.Open("sqlite3", "file:"+instance.Database+"?_busy_timeout=5000")
+	if err != nil {
+		return fmt.Errorf("failed to open database %s: %w", instance.Database, err)
+	}
+	defer db.Close()
+
+	rows, err := db.Query("PRAGMA integrity_check")
+	if err != nil {
+		return wrapSQLiteError("integrity check failed", err)
+	}
+	var problems []string
+	for rows.Next() {
+		var line string
+		if err := rows.Scan(&line); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to read integrity check result: %w", err)
+		}
+		if line != "ok" {
+			problems = append(problems, line)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return wrapSQLiteError("integrity check failed", err)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("database %s is corrupted (%d problems): %s", instance.Database, len(problems), strings.Join(problems, "; "))
+	}
+	log.Printf("Integrity check passed for %s", instance.Database)
+
+	if _, err := db.Exec("VACUUM"); err != nil {
+		return wrapSQLiteError("vacuum failed", err)
+	}
+
+	sizeAfter, err := fileSize(instance.Database)
+	if err != nil {
+		return fmt.Errorf("failed to stat database %s: %w", instance.Database, err)
+	}
+
+	log.Printf("Vacuumed %s: %.2fMB -> %.2fMB (reclaimed %.2fMB)", instance.Database,
+		float64(sizeBefore)/1024/1024, float64(sizeAfter)/1024/1024, float64(sizeBefore-sizeAfter)/1024/1024)
+	return nil
+}
+
+// wrapSQLiteError turns busy/locked SQLite errors into ErrDatabaseLocked and constraint
+// violations during device setup into ErrDeviceConflict
+func wrapSQLiteError(action string, err error) error {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) && (sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked) {
+		return fmt.Errorf("%s: %w", action, ErrDatabaseLocked)
+	}
+	if errors.As(err, &sqliteErr) && sqliteErr.Code == sqlite3.ErrConstraint {
+		return fmt.Errorf("%s: %w: %v", action, ErrDeviceConflict, err)
+	}
+	return fmt.Errorf("%s: %w", action, err)
+}
+
+func fileSize(path string) (int64, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
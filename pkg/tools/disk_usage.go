@@ -0,0 +1,93 @@
+package tools
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// UnknownChat groups stored files that have no chat metadata
+const UnknownChat = "(unknown)"
+
+// ChatDiskUsage is the disk space used by one chat's stored images
+type ChatDiskUsage struct {
+	Chat  string
+	Files int
+	Bytes int64
+}
+
+var imageExtensions = map[string]bool{".jpg": true, ".jpeg": true, ".png": true, ".webp": true, ".gif": true}
+
+// ImageFiles lists stored images under dataDir (recursively) plus historical images,
+// which are saved in the working directory
+func ImageFiles(dataDir string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dataDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && imageExtensions[strings.ToLower(filepath.Ext(path))] {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to scan %s: %w", dataDir, err)
+	}
+
+	historical, err := filepath.Glob("historical_*.jpg")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list historical images: %w", err)
+	}
+	return append(files, historical...), nil
+}
+
+// AggregateDiskUsage sums file sizes per chat, largest first. owners maps a file's base
+// name to its chat; files without an owner are reported under UnknownChat.
+func AggregateDiskUsage(files []string, owners map[string]string) ([]ChatDiskUsage, int64) {
+	byChat := make(map[string]*ChatDiskUsage)
+	var total int64
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			continue
+		}
+
+		chat, ok := owners[filepath.Base(file)]
+		if !ok {
+			chat = UnknownChat
+		}
+		usage, ok := byChat[chat]
+		if !ok {
+			usage = &ChatDiskUsage{Chat: chat}
+			byChat[chat] = usage
+		}
+		usage.Files++
+		usage.Bytes += info.Size()
+		total += info.Size()
+	}
+
+	result := make([]ChatDiskUsage, 0, len(byChat))
+	for _, usage := range byChat {
+		result = append(result, *usage)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Bytes > result[j].Bytes })
+	return result, total
+}
+
+// FormatBytes renders a size in human-readable units
+func FormatBytes(n int64) string {
+	switch {
+	case n >= 1024*1024*1024:
+		return fmt.Sprintf("%.2fGB", float64(n)/1024/1024/1024)
+	case n >= 1024*1024:
+		return fmt.Sprintf("%.2fMB", float64(n)/1024/1024)
+	case n >= 1024:
+		return fmt.Sprintf("%.1fKB", float64(n)/1024)
+	default:
+		return fmt.Sprintf("%dB", n)
+	}
+}
@@ -0,0 +1,105 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// DefaultDocumentMaxChars is the default character budget for document text sent to the AI
+const DefaultDocumentMaxChars = 20000
+
+// ErrUnsupportedDocument is returned for document types whose text can't be extracted
+var ErrUnsupportedDocument = errors.New("unsupported document type")
+
+// textDocumentTypes are non-text/* MIME types that are plain text
+var textDocumentTypes = map[string]bool{
+	"application/json":   true,
+	"application/xml":    true,
+	"application/csv":    true,
+	"application/x-yaml": true,
+}
+
+// documentTypesByExt resolves documents sent with a generic MIME type
+var documentTypesByExt = map[string]string{
+	".pdf":  "application/pdf",
+	".txt":  "text/plain",
+	".md":   "text/markdown",
+	".csv":  "text/csv",
+	".json": "application/json",
+	".xml":  "application/xml",
+	".yaml": "application/x-yaml",
+	".yml":  "application/x-yaml",
+	".log":  "text/plain",
+}
+
+// ExtractDocumentText returns the text of a PDF or plain-text document. PDFs need the
+// pdftotext tool from poppler-utils.
+func ExtractDocumentText(ctx context.Context, data []byte, mimeType string, filename string) (string, error) {
+	mimeType = baseMimeType(mimeType)
+	if mimeType == "" || mimeType == "application/octet-stream" {
+		mimeType = documentTypesByExt[strings.ToLower(filepath.Ext(filename))]
+	}
+
+	switch {
+	case mimeType == "application/pdf":
+		return extractPDFText(ctx, data)
+	case strings.HasPrefix(mimeType, "text/") || textDocumentTypes[mimeType]:
+		if !utf8.Valid(data) {
+			data = bytes.ToValidUTF8(data, []byte("�"))
+		}
+		return strings.TrimSpace(string(data)), nil
+	default:
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedDocument, mimeType)
+	}
+}
+
+// extractPDFText converts a PDF to text using pdftotext
+func extractPDFText(ctx context.Context, data []byte) (string, error) {
+	pdftotext, err := exec.LookPath("pdftotext")
+	if err != nil {
+		return "", fmt.Errorf("%w: pdftotext not found, cannot read PDF: %v", ErrUnsupportedDocument, err)
+	}
+
+	// pdftotext can't read every PDF from a pipe, so go through a temporary file
+	tmp, err := os.CreateTemp("", "document-*.pdf")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary PDF file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("failed to write temporary PDF file: %w", err)
+	}
+	tmp.Close()
+
+	var out, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, pdftotext, "-layout", "-enc", "UTF-8", tmp.Name(), "-")
+	cmd.Stdout = &out
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("pdftotext failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(out.String()), nil
+}
+
+// TruncateText shortens text to at most maxChars characters, cutting at a line break
+// when one is close to the limit. It reports whether the text was truncated.
+func TruncateText(text string, maxChars int) (string, bool) {
+	if maxChars <= 0 || utf8.RuneCountInString(text) <= maxChars {
+		return text, false
+	}
+
+	runes := []rune(text)
+	cut := string(runes[:maxChars])
+	if i := strings.LastIndex(cut, "\n"); i > len(cut)*9/10 {
+		cut = cut[:i]
+	}
+	return strings.TrimSpace(cut), true
+}
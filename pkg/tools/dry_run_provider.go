@@ -0,0 +1,21 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+)
+
+// DryRunProvider answers every request with an echo of its input instead of calling an
+// AI API, for testing the message flow locally without spending credits
+type DryRunProvider struct{}
+
+// CompleteText echoes the message and the number of attached images
+func (DryRunProvider) CompleteText(ctx context.Context, req CompletionRequest) (string, error) {
+	return fmt.Sprintf("[dry run] %s\n\n(gambar dirujuk: %d, riwayat: %d pesan)", req.Text, len(req.Images), len(req.History)), nil
+}
+
+// CompleteWithImage echoes the message and the number of images including the attached one
+func (p DryRunProvider) CompleteWithImage(ctx context.Context, req CompletionRequest, image ImageInput) (string, error) {
+	req.Images = append(append([]ImageInput(nil), req.Images...), image)
+	return p.CompleteText(ctx, req)
+}
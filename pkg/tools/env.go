@@ -0,0 +1,76 @@
+package tools
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Helpers for reading optional configuration from environment variables.
+// Invalid values are logged and the default is used instead.
+
+// EnvString returns the value of key, or def if it is unset or empty
+func EnvString(key, def string) string {
+	if v := strings.TrimSpace(os.Getenv(key)); v != "" {
+		return v
+	}
+	return def
+}
+
+// EnvInt returns the integer value of key, or def if it is unset or invalid
+func EnvInt(key string, def int) int {
+	v := strings.TrimSpace(os.Getenv(key))
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		log.Printf("Invalid value for %s (%q), using default %d", key, v, def)
+		return def
+	}
+	return n
+}
+
+// EnvFloat returns the float value of key, or def if it is unset or invalid
+func EnvFloat(key string, def float64) float64 {
+	v := strings.TrimSpace(os.Getenv(key))
+	if v == "" {
+		return def
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		log.Printf("Invalid value for %s (%q), using default %g", key, v, def)
+		return def
+	}
+	return f
+}
+
+// EnvBool returns the boolean value of key, or def if it is unset or invalid
+func EnvBool(key string, def bool) bool {
+	v := strings.TrimSpace(os.Getenv(key))
+	if v == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		log.Printf("Invalid value for %s (%q), using default %t", key, v, def)
+		return def
+	}
+	return b
+}
+
+// EnvDuration returns the duration value of key (e.g. "5m"), or def if it is unset or invalid
+func EnvDuration(key string, def time.Duration) time.Duration {
+	v := strings.TrimSpace(os.Getenv(key))
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		log.Printf("Invalid value for %s (%q), using default %s", key, v, def)
+		return def
+	}
+	return d
+}
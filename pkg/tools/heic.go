@@ -0,0 +1,41 @@
+package tools
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+
+	"github.com/gen2brain/heic"
+)
+
+// ErrImageFormatUnsupported is returned for images whose format can't be decoded, e.g. a
+// HEIC photo the decoder fails on
+var ErrImageFormatUnsupported = errors.New("image format not supported")
+
+// heicBrands are the ftyp brands of HEIC/HEIF still images, as sent by iPhones
+var heicBrands = map[string]bool{
+	"heic": true, "heix": true, "hevc": true, "hevx": true,
+	"heim": true, "heis": true, "mif1": true, "msf1": true,
+}
+
+// isHEIC reports whether data starts like an HEIC/HEIF file
+func isHEIC(data []byte) bool {
+	return len(data) >= 12 && string(data[4:8]) == "ftyp" && heicBrands[string(data[8:12])]
+}
+
+// decodeHEIC decodes an HEIC/HEIF image using libheif compiled to WebAssembly, so no cgo
+// or system library is needed
+func decodeHEIC(data []byte) (img image.Image, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			img, err = nil, fmt.Errorf("%w: HEIC decoder unavailable: %v", ErrImageFormatUnsupported, r)
+		}
+	}()
+
+	img, err = heic.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to decode HEIC image: %v", ErrImageFormatUnsupported, err)
+	}
+	return img, nil
+}
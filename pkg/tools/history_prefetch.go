@@ -0,0 +1,119 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultPrefetchConcurrency is how many historical images PrefetchRecent downloads at
+// once when HISTORY_PREFETCH_CONCURRENCY is unset
+const DefaultPrefetchConcurrency = 3
+
+// SetPrefetch configures the download of recent historical images after every history
+// sync: images younger than age (0 disables it), at most maxImages per sync (0 = all),
+// concurrency at a time
+func (wd *WhatsAppDownloader) SetPrefetch(age time.Duration, maxImages, concurrency int) {
+	wd.prefetchMutex.Lock()
+	defer wd.prefetchMutex.Unlock()
+	wd.prefetchAge = age
+	wd.prefetchMax = max(maxImages, 0)
+	wd.prefetchConcurrency = max(concurrency, 1)
+}
+
+// PrefetchRecent downloads the historical images sent after since that aren't on disk
+// yet, newest first and at most maxImages of them (0 = all), so they needn't be fetched
+// when referenced. It returns how many images were downloaded.
+func (wd *WhatsAppDownloader) PrefetchRecent(ctx context.Context, since time.Time, maxImages int) (int, error) {
+	var pending []HistoryImageInfo
+	for _, info := range wd.ListHistoricalImages() {
+		if info.Timestamp.Before(since) {
+			continue
+		}
+		if _, err := os.Stat(info.FileName); err == nil {
+			continue
+		}
+		pending = append(pending, info)
+	}
+	sort.Slice(pending, func(i, j int) bool {
+		return pending[i].Timestamp.After(pending[j].Timestamp)
+	})
+	if maxImages > 0 && len(pending) > maxImages {
+		pending = pending[:maxImages]
+	}
+	if len(pending) == 0 {
+		return 0, nil
+	}
+
+	wd.prefetchMutex.Lock()
+	concurrency := max(wd.prefetchConcurrency, 1)
+	wd.prefetchMutex.Unlock()
+
+	var wg sync.WaitGroup
+	var downloaded atomic.Int32
+	errChan := make(chan error, len(pending))
+	sem := make(chan struct{}, concurrency)
+
+	for _, info := range pending {
+		wg.Add(1)
+		go func(info HistoryImageInfo) {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			defer func() { <-sem }()
+
+			if _, err := wd.DownloadHistoricalImage(ctx, info); err != nil {
+				errChan <- err
+				return
+			}
+			downloaded.Add(1)
+		}(info)
+	}
+
+	wg.Wait()
+	close(errChan)
+
+	n := int(downloaded.Load())
+	if err := ctx.Err(); err != nil {
+		return n, fmt.Errorf("prefetch of historical images interrupted: %w", err)
+	}
+	var errs []error
+	for err := range errChan {
+		errs = append(errs, err)
+	}
+	if len(errs) > 0 {
+		return n, fmt.Errorf("failed to prefetch %d of %d historical images: %w", len(errs), len(pending), errors.Join(errs...))
+	}
+	return n, nil
+}
+
+// prefetchAfterSync downloads recent historical images in the background when
+// prefetching is enabled; a sync arriving while a prefetch runs doesn't start another
+func (wd *WhatsAppDownloader) prefetchAfterSync(ctx context.Context) {
+	wd.prefetchMutex.Lock()
+	age, maxImages := wd.prefetchAge, wd.prefetchMax
+	wd.prefetchMutex.Unlock()
+	if age <= 0 || !wd.prefetching.CompareAndSwap(false, true) {
+		return
+	}
+
+	go func() {
+		defer wd.prefetching.Store(false)
+		n, err := wd.PrefetchRecent(ctx, time.Now().Add(-age), maxImages)
+		if err != nil {
+			log.Printf("Failed to prefetch recent historical images: %v", err)
+		}
+		if n > 0 {
+			fmt.Printf("Prefetched %d recent historical images\n", n)
+		}
+	}()
+}
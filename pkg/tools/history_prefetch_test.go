@@ -0,0 +1,77 @@
+package tools_test
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"auto-lmk/pkg/tools"
+	"auto-lmk/pkg/whatsapptest"
+
+	waProto "go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestPrefetchRecent(t *testing.T) {
+	dir := t.TempDir()
+	oldDataDir := tools.DataDir()
+	tools.SetDataDir(dir) // keeps the dedup index out of the package directory
+	t.Cleanup(func() { tools.SetDataDir(oldDataDir) })
+	now := time.Now()
+	chat := types.NewJID("628120000000", types.DefaultUserServer)
+	client := whatsapptest.NewFakeClient()
+
+	images := make(map[string]tools.HistoryImageInfo)
+	for i, age := range []time.Duration{time.Hour, 2 * time.Hour, 3 * time.Hour, 48 * time.Hour} {
+		id := types.MessageID([]string{"NEWEST", "ON_DISK", "OLDER", "TOO_OLD"}[i])
+		path := "/" + string(id)
+		client.Downloads[path] = []byte(id)
+		images[string(id)] = tools.HistoryImageInfo{
+			MessageID: id,
+			ChatJID:   chat,
+			SenderJID: chat,
+			Timestamp: now.Add(-age),
+			ImageMsg:  &waProto.ImageMessage{DirectPath: proto.String(path)},
+			FileName:  filepath.Join(dir, string(id)+".jpg"),
+		}
+	}
+	if err := os.WriteFile(images["ON_DISK"].FileName, []byte("saved"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	metadata, err := json.Marshal(images)
+	if err != nil {
+		t.Fatal(err)
+	}
+	metadataPath := filepath.Join(dir, "history.json")
+	if err := os.WriteFile(metadataPath, metadata, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	wd := tools.NewWhatsAppDownloader(client)
+	if err := wd.LoadHistoryMetadata(metadataPath); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the newest of the two recent images that aren't on disk fits the limit
+	n, err := wd.PrefetchRecent(context.Background(), now.Add(-24*time.Hour), 1)
+	if err != nil || n != 1 {
+		t.Fatalf("PrefetchRecent = %d, %v; want 1 download", n, err)
+	}
+	for id, want := range map[string]bool{"NEWEST": true, "ON_DISK": true, "OLDER": false, "TOO_OLD": false} {
+		if _, err := os.Stat(images[id].FileName); (err == nil) != want {
+			t.Errorf("%s on disk = %t, want %t", id, err == nil, want)
+		}
+	}
+
+	n, err = wd.PrefetchRecent(context.Background(), now.Add(-24*time.Hour), 0)
+	if err != nil || n != 1 {
+		t.Fatalf("second PrefetchRecent = %d, %v; want 1 download", n, err)
+	}
+	if _, err := os.Stat(images["OLDER"].FileName); err != nil {
+		t.Errorf("OLDER not prefetched: %v", err)
+	}
+}
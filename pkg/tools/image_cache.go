@@ -0,0 +1,103 @@
+package tools
+
+import (
+	"container/list"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// imageCache keeps recently optimized images in memory so a chat referencing the same
+// image again doesn't re-read and re-encode it. Entries are dropped when the file changes.
+type imageCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	entries    map[string]*list.Element
+	order      *list.List // most recently used first
+}
+
+type imageCacheEntry struct {
+	key      string
+	modTime  time.Time
+	size     int64
+	data     []byte
+	mimeType string
+}
+
+// newImageCache creates a cache holding up to maxEntries images; 0 disables caching
+func newImageCache(maxEntries int) *imageCache {
+	return &imageCache{
+		maxEntries: maxEntries,
+		entries:    make(map[string]*list.Element),
+		order:      list.New(),
+	}
+}
+
+// get returns the cached image for key if it was made from the file as it is now
+func (c *imageCache) get(key string, info os.FileInfo) ([]byte, string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, "", false
+	}
+	entry := el.Value.(*imageCacheEntry)
+	if !entry.modTime.Equal(info.ModTime()) || entry.size != info.Size() {
+		c.order.Remove(el)
+		delete(c.entries, key)
+		return nil, "", false
+	}
+	c.order.MoveToFront(el)
+	return entry.data, entry.mimeType, true
+}
+
+func (c *imageCache) put(key string, info os.FileInfo, data []byte, mimeType string) {
+	if c.maxEntries <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := &imageCacheEntry{key: key, modTime: info.ModTime(), size: info.Size(), data: data, mimeType: mimeType}
+	if el, ok := c.entries[key]; ok {
+		el.Value = entry
+		c.order.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.order.PushFront(entry)
+	for c.order.Len() > c.maxEntries {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*imageCacheEntry).key)
+	}
+}
+
+// loadOptimizedImage reads a saved image and optimizes it for the AI like
+// validateAndOptimizeImage, reusing the result while the file is unchanged
+func (at *AITools) loadOptimizedImage(filename string, maxDimension, quality int) ([]byte, string, error) {
+	path := at.ImagePath(filename)
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read image file: %w", err)
+	}
+
+	// The same file optimized with other settings is a different entry
+	key := fmt.Sprintf("%s|%d|%d|%s", path, maxDimension, quality, at.imageFormat)
+	if data, mimeType, ok := at.imageCache.get(key, info); ok {
+		return data, mimeType, nil
+	}
+
+	imageData, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read image file: %w", err)
+	}
+	data, mimeType, err := at.validateAndOptimizeImage(imageData, filename, maxDimension, quality)
+	if err != nil {
+		return nil, "", err
+	}
+	at.imageCache.put(key, info, data, mimeType)
+	return data, mimeType, nil
+}
@@ -0,0 +1,98 @@
+package tools
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// imageIndex maps the SHA-256 of saved image contents to the file holding them, so
+// identical images are stored once. It is persisted as JSON next to the images.
+type imageIndex struct {
+	mu      sync.Mutex
+	path    string
+	entries map[string]string // nil until loaded
+}
+
+var savedImages = &imageIndex{}
+
+// load reads the index from the current data directory unless already loaded; must be
+// called with mu held
+func (ix *imageIndex) load() {
+	path := DataPath("image_index.json")
+	if ix.entries != nil && ix.path == path {
+		return
+	}
+	ix.path = path
+	ix.entries = make(map[string]string)
+
+	data, err := os.ReadFile(ix.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return
+	}
+	if err == nil {
+		err = json.Unmarshal(data, &ix.entries)
+	}
+	if err != nil {
+		log.Printf("Ignoring unreadable image index %s: %v", ix.path, err)
+		ix.entries = make(map[string]string)
+	}
+}
+
+// save writes the index to disk; must be called with mu held
+func (ix *imageIndex) save() error {
+	data, err := json.MarshalIndent(ix.entries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal image index: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(ix.path), 0755); err != nil {
+		return fmt.Errorf("failed to create image index directory: %w", err)
+	}
+
+	tmp := ix.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to save image index: %w", err)
+	}
+	if err := os.Rename(tmp, ix.path); err != nil {
+		return fmt.Errorf("failed to save image index: %w", err)
+	}
+	return nil
+}
+
+// writeImageDeduplicated writes data to path unless identical content was saved before,
+// and returns the path of the file holding the content
+func writeImageDeduplicated(data []byte, path string) (string, error) {
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+
+	ix := savedImages
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.load()
+
+	// Only reuse files in the same directory so clients' data directories stay separate
+	if existing, ok := ix.entries[hash]; ok && filepath.Dir(existing) == filepath.Dir(path) {
+		if _, err := os.Stat(existing); err == nil {
+			fmt.Printf("Image already saved as %s, skipping duplicate %s\n", existing, path)
+			return existing, nil
+		}
+		delete(ix.entries, hash) // the file was cleaned up since
+	}
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return "", fmt.Errorf("failed to save image file: %w", err)
+	}
+
+	ix.entries[hash] = path
+	if err := ix.save(); err != nil {
+		// The image itself is saved; at worst a later duplicate is written again
+		log.Printf("Failed to update image index: %v", err)
+	}
+	return path, nil
+}
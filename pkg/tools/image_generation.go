@@ -0,0 +1,125 @@
+package tools
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/shared"
+)
+
+const generateImageToolName = "generate_image"
+
+// ErrImageContentRejected is returned when the provider refuses an image prompt on policy grounds
+var ErrImageContentRejected = errors.New("image prompt rejected by content policy")
+
+// SetImageGenerationModel sets the model used by GenerateImage
+func (at *AITools) SetImageGenerationModel(model string) {
+	if model != "" {
+		at.imageGenModel = model
+	}
+}
+
+// DetectImageGenerationIntent asks the model, via function calling, whether the user wants an
+// image generated. It returns the image prompt when the model chose to call the tool.
+func (at *AITools) DetectImageGenerationIntent(ctx context.Context, userMessage string) (string, bool, error) {
+	req := openai.ChatCompletionNewParams{
+		Model: at.Model(),
+		Messages: []openai.ChatCompletionMessageParamUnion{
+			openai.SystemMessage(ImageGenerationIntentSystemMessage),
+			openai.UserMessage(userMessage),
+		},
+		Tools: []openai.ChatCompletionToolParam{{
+			Function: shared.FunctionDefinitionParam{
+				Name:        generateImageToolName,
+				Description: openai.String("Generate a new image from a text description when the user explicitly asks for one to be created or drawn."),
+				Parameters: shared.FunctionParameters{
+					"type": "object",
+					"properties": map[string]any{
+						"prompt": map[string]any{
+							"type":        "string",
+							"description": "Detailed description of the image to generate, in English.",
+						},
+					},
+					"required": []string{"prompt"},
+				},
+			},
+		}},
+		ToolChoice: openai.ChatCompletionToolChoiceOptionUnionParam{OfAuto: openai.String("auto")},
+	}
+
+	client, err := at.openAIClient("image intent detection")
+	if err != nil {
+		return "", false, err
+	}
+	countAIRequest()
+	resp, err := client.Chat.Completions.New(ctx, req)
+	if err != nil {
+		return "", false, fmt.Errorf("image intent detection error: %w", err)
+	}
+	at.recordUsage(openAIUsage(resp.Usage))
+	if len(resp.Choices) == 0 {
+		return "", false, nil
+	}
+
+	for _, call := range resp.Choices[0].Message.ToolCalls {
+		if call.Function.Name != generateImageToolName {
+			continue
+		}
+		var args struct {
+			Prompt string `json:"prompt"`
+		}
+		if err := json.Unmarshal([]byte(call.Function.Arguments), &args); err != nil {
+			return "", false, fmt.Errorf("invalid %s arguments: %w", generateImageToolName, err)
+		}
+		if strings.TrimSpace(args.Prompt) == "" {
+			args.Prompt = userMessage
+		}
+		return args.Prompt, true, nil
+	}
+
+	return "", false, nil
+}
+
+// GenerateImage creates an image from a prompt and returns the encoded image bytes
+func (at *AITools) GenerateImage(ctx context.Context, prompt string) ([]byte, error) {
+	req := openai.ImageGenerateParams{
+		Prompt: prompt,
+		Model:  openai.ImageModel(at.imageGenModel),
+		N:      openai.Int(1),
+		Size:   openai.ImageGenerateParamsSize1024x1024,
+	}
+	// gpt-image models always return base64; DALL·E models must be asked for it
+	if strings.HasPrefix(at.imageGenModel, "dall-e") {
+		req.ResponseFormat = openai.ImageGenerateParamsResponseFormatB64JSON
+	}
+
+	fmt.Printf("GenerateImage: Sending image generation request to model: %s\n", at.imageGenModel)
+	client, err := at.openAIClient("image generation")
+	if err != nil {
+		return nil, err
+	}
+	countAIRequest()
+	resp, err := client.Images.Generate(ctx, req)
+	if err != nil {
+		var apiErr *openai.Error
+		if errors.As(err, &apiErr) && (apiErr.Code == "content_policy_violation" || apiErr.Code == "moderation_blocked") {
+			return nil, fmt.Errorf("%w: %s", ErrImageContentRejected, apiErr.Message)
+		}
+		return nil, fmt.Errorf("image generation API error: %w", err)
+	}
+
+	if len(resp.Data) == 0 || resp.Data[0].B64JSON == "" {
+		return nil, fmt.Errorf("image generation returned no image data")
+	}
+
+	data, err := base64.StdEncoding.DecodeString(resp.Data[0].B64JSON)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode generated image: %w", err)
+	}
+	return data, nil
+}
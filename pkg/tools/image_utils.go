@@ -2,13 +2,20 @@ package tools
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"image"
+	"image/color"
+	"image/gif"
 	"image/jpeg"
 	"image/png"
+	"math"
+	"math/bits"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"golang.org/x/image/draw"
 	"golang.org/x/image/webp"
@@ -19,9 +26,14 @@ const (
 	MaxImageWidth    = 2048             // Max width for optimization
 	MaxImageHeight   = 2048             // Max height for optimization
 	OptimizedQuality = 85               // JPEG quality for optimization
+	StrippedQuality  = 95               // JPEG quality when re-encoding to drop metadata
 	LLMMaxWidth      = 250              // Max width for LLM processing
 	LLMMaxHeight     = 250              // Max height for LLM processing
 	LLMQuality       = 75               // JPEG quality for LLM processing
+	LLMMinDimension  = 64               // Smallest size retried when a provider rejects an image as too large
+	LLMMinQuality    = 40               // Lowest JPEG quality retried when a provider rejects an image as too large
+	ThumbnailSize    = 100              // Max width/height of message preview thumbnails
+	ThumbnailQuality = 60               // JPEG quality for message preview thumbnails
 )
 
 // DetectImageType detects the image type from file extension and magic bytes
@@ -37,6 +49,8 @@ func DetectImageType(filename string, data []byte) string {
 		return "image/webp"
 	case ".gif":
 		return "image/gif"
+	case ".heic", ".heif":
+		return "image/heic"
 	}
 
 	// Fallback to magic bytes detection
@@ -58,6 +72,10 @@ func DetectImageType(filename string, data []byte) string {
 		if bytes.HasPrefix(data, []byte("GIF87a")) || bytes.HasPrefix(data, []byte("GIF89a")) {
 			return "image/gif"
 		}
+		// HEIC/HEIF, e.g. iPhone photos
+		if isHEIC(data) {
+			return "image/heic"
+		}
 	}
 
 	// Default to JPEG if we can't detect
@@ -73,12 +91,42 @@ func decodeImage(data []byte, mimeType string) (image.Image, error) {
 		return png.Decode(bytes.NewReader(data))
 	case "image/webp":
 		return webp.Decode(bytes.NewReader(data))
+	case "image/gif":
+		return firstGIFFrame(data)
+	case "image/heic", "image/heif":
+		return decodeHEIC(data)
 	default:
+		if isHEIC(data) {
+			return decodeHEIC(data)
+		}
 		// Try JPEG as fallback
 		return jpeg.Decode(bytes.NewReader(data))
 	}
 }
 
+// firstGIFFrame decodes the first frame of a (possibly animated) GIF, drawn at its
+// position on the GIF's logical screen over a white background since the result is
+// re-encoded as JPEG
+func firstGIFFrame(data []byte) (image.Image, error) {
+	g, err := gif.DecodeAll(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if len(g.Image) == 0 {
+		return nil, fmt.Errorf("gif has no frames")
+	}
+
+	frame := g.Image[0]
+	bounds := image.Rect(0, 0, g.Config.Width, g.Config.Height)
+	if bounds.Empty() {
+		bounds = frame.Bounds()
+	}
+	canvas := image.NewRGBA(bounds)
+	draw.Draw(canvas, bounds, image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+	return canvas, nil
+}
+
 // resizeImage resizes an image to fit within the specified dimensions while maintaining aspect ratio
 func resizeImage(img image.Image, maxWidth, maxHeight int) image.Image {
 	// Get original dimensions
@@ -99,9 +147,10 @@ func resizeImage(img image.Image, maxWidth, maxHeight int) image.Image {
 		return img
 	}
 
-	// Calculate new dimensions
-	newWidth := int(float64(originalWidth) * scale)
-	newHeight := int(float64(originalHeight) * scale)
+	// Calculate new dimensions; extreme aspect ratios such as long screenshots would
+	// otherwise round a side down to zero
+	newWidth := max(1, int(math.Round(float64(originalWidth)*scale)))
+	newHeight := max(1, int(math.Round(float64(originalHeight)*scale)))
 
 	// Create new image
 	newImg := image.NewRGBA(image.Rect(0, 0, newWidth, newHeight))
@@ -122,19 +171,86 @@ func encodeImage(img image.Image, quality int) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
+// ImageFormat selects how resized images are encoded for the LLM. WebP isn't offered:
+// golang.org/x/image only decodes it.
+type ImageFormat string
+
+const (
+	ImageFormatJPEG ImageFormat = "jpeg" // always JPEG, the default
+	ImageFormatPNG  ImageFormat = "png"  // always PNG, lossless
+	ImageFormatAuto ImageFormat = "auto" // PNG for images with transparency, JPEG otherwise
+)
+
+// ParseImageFormat parses an AI_IMAGE_FORMAT value; empty means JPEG
+func ParseImageFormat(s string) (ImageFormat, error) {
+	switch f := ImageFormat(strings.ToLower(strings.TrimSpace(s))); f {
+	case "", "jpg", ImageFormatJPEG:
+		return ImageFormatJPEG, nil
+	case ImageFormatPNG, ImageFormatAuto:
+		return f, nil
+	default:
+		return "", fmt.Errorf("unsupported image format %q (want jpeg, png or auto)", s)
+	}
+}
+
+// hasTransparency reports whether any pixel of img isn't fully opaque
+func hasTransparency(img image.Image) bool {
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		return !o.Opaque()
+	}
+	return false
+}
+
+// encodeImageAs encodes img in format, returning the data and its MIME type; quality
+// only applies to JPEG
+func encodeImageAs(img image.Image, format ImageFormat, quality int) ([]byte, string, error) {
+	if format == ImageFormatPNG || (format == ImageFormatAuto && hasTransparency(img)) {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			return nil, "", fmt.Errorf("failed to encode image as PNG: %w", err)
+		}
+		return buf.Bytes(), "image/png", nil
+	}
+
+	data, err := encodeImage(img, quality)
+	return data, "image/jpeg", err
+}
+
 // ResizeImageForLLM resizes an image specifically for LLM processing
 func ResizeImageForLLM(data []byte, mimeType string) ([]byte, error) {
+	resized, _, err := ResizeImageForLLMWithLimits(data, mimeType, LLMMaxWidth, LLMQuality, ImageFormatJPEG)
+	return resized, err
+}
+
+// ResizeImageForLLMWithLimits resizes an image to fit within maxDimension on both sides
+// and encodes it in format, returning the data and its MIME type
+func ResizeImageForLLMWithLimits(data []byte, mimeType string, maxDimension, quality int, format ImageFormat) ([]byte, string, error) {
 	// Decode the image
 	img, err := decodeImage(data, mimeType)
 	if err != nil {
-		return nil, fmt.Errorf("failed to decode image: %w", err)
+		return nil, "", fmt.Errorf("failed to decode image: %w", err)
 	}
 
 	// Resize for LLM processing
-	resizedImg := resizeImage(img, LLMMaxWidth, LLMMaxHeight)
+	resizedImg := resizeImage(img, maxDimension, maxDimension)
 
-	// Encode as JPEG with appropriate quality
-	return encodeImage(resizedImg, LLMQuality)
+	return encodeImageAs(resizedImg, format, quality)
+}
+
+// ImageThumbnail returns the image's dimensions and a small JPEG preview, as shown by
+// WhatsApp while the full image downloads
+func ImageThumbnail(data []byte) (thumbnail []byte, width, height int, err error) {
+	img, err := decodeImage(data, DetectImageType("", data))
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	bounds := img.Bounds()
+	thumbnail, err = encodeImage(resizeImage(img, ThumbnailSize, ThumbnailSize), ThumbnailQuality)
+	if err != nil {
+		return nil, 0, 0, err
+	}
+	return thumbnail, bounds.Dx(), bounds.Dy(), nil
 }
 
 // OptimizeImage optimizes an image if it's too large
@@ -157,6 +273,64 @@ func OptimizeImage(data []byte, mimeType string) ([]byte, error) {
 	return encodeImage(resizedImg, OptimizedQuality)
 }
 
+// PerceptualHash computes a 64-bit average hash (aHash) of an image. The image is scaled
+// down to 8x8 grayscale and each bit records whether a pixel is brighter than the mean,
+// so near-duplicate images (re-encoded, resized, recompressed) produce similar hashes.
+func PerceptualHash(data []byte) (uint64, error) {
+	img, err := decodeImage(data, DetectImageType("", data))
+	if err != nil {
+		return 0, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	small := image.NewGray(image.Rect(0, 0, 8, 8))
+	draw.CatmullRom.Scale(small, small.Bounds(), img, img.Bounds(), draw.Src, nil)
+
+	var sum int
+	for _, p := range small.Pix {
+		sum += int(p)
+	}
+	mean := sum / len(small.Pix)
+
+	var hash uint64
+	for i, p := range small.Pix {
+		if int(p) > mean {
+			hash |= 1 << uint(i)
+		}
+	}
+	return hash, nil
+}
+
+// HammingDistance returns the number of differing bits between two perceptual hashes
+func HammingDistance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// ExtractVideoThumbnail extracts the first frame of a video as a JPEG using ffmpeg
+func ExtractVideoThumbnail(data []byte) ([]byte, error) {
+	ffmpeg, err := exec.LookPath("ffmpeg")
+	if err != nil {
+		return nil, fmt.Errorf("ffmpeg not found, cannot extract video frame: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var out, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, ffmpeg, "-hide_banner", "-loglevel", "error", "-i", "pipe:0",
+		"-frames:v", "1", "-f", "image2", "-c:v", "mjpeg", "pipe:1")
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Stdout = &out
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("ffmpeg frame extraction failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	if out.Len() == 0 {
+		return nil, fmt.Errorf("ffmpeg produced no frame")
+	}
+	return out.Bytes(), nil
+}
+
 // ValidateImage checks if an image meets size requirements
 func ValidateImage(data []byte) error {
 	if len(data) > MaxImageSize {
@@ -166,8 +340,15 @@ func ValidateImage(data []byte) error {
 	return nil
 }
 
-// SaveImageToFile saves image data to a file with the appropriate extension
+// SaveImageToFile saves image data to a file with the appropriate extension. If the same
+// image was saved before, the existing file's path is returned instead.
 func SaveImageToFile(data []byte, filename string, mimeType string) (string, error) {
+	return SaveImageToDir(data, DataDir(), filename, mimeType)
+}
+
+// SaveImageToDir is like SaveImageToFile but saves into dir, e.g. a client's own data
+// directory, instead of the shared data directory
+func SaveImageToDir(data []byte, dir string, filename string, mimeType string) (string, error) {
 	// Determine appropriate file extension
 	ext := ".jpg"
 	switch mimeType {
@@ -177,6 +358,8 @@ func SaveImageToFile(data []byte, filename string, mimeType string) (string, err
 		ext = ".webp"
 	case "image/gif":
 		ext = ".gif"
+	case "image/heic", "image/heif":
+		ext = ".heic"
 	}
 
 	// Ensure filename has the correct extension
@@ -185,15 +368,48 @@ func SaveImageToFile(data []byte, filename string, mimeType string) (string, err
 	}
 
 	// Create data directory if it doesn't exist
-	if err := os.MkdirAll("data", 0755); err != nil {
+	if err := os.MkdirAll(dir, 0755); err != nil {
 		return "", fmt.Errorf("failed to create data directory: %w", err)
 	}
 
-	// Save the file
-	filePath := filepath.Join("data", filename)
-	if err := os.WriteFile(filePath, data, 0644); err != nil {
-		return "", fmt.Errorf("failed to save image file: %w", err)
+	// Save the file, reusing an existing file with identical content
+	return writeImageDeduplicated(data, filepath.Join(dir, filename))
+}
+
+// SaveImageToFileStripped is like SaveImageToFile but first re-encodes JPEG and PNG
+// images so EXIF data such as GPS position and device details is not written to disk.
+// Other formats are saved unchanged.
+func SaveImageToFileStripped(data []byte, filename string, mimeType string) (string, error) {
+	return SaveImageToDirStripped(data, DataDir(), filename, mimeType)
+}
+
+// SaveImageToDirStripped is like SaveImageToFileStripped but saves into dir
+func SaveImageToDirStripped(data []byte, dir string, filename string, mimeType string) (string, error) {
+	stripped, err := StripImageMetadata(data, mimeType)
+	if err != nil {
+		return "", err
 	}
+	return SaveImageToDir(stripped, dir, filename, mimeType)
+}
 
-	return filePath, nil
+// StripImageMetadata decodes and re-encodes a JPEG or PNG image, dropping any metadata
+// it carries. The EXIF orientation is dropped too, so rotated photos are stored as shot.
+func StripImageMetadata(data []byte, mimeType string) ([]byte, error) {
+	if mimeType != "image/jpeg" && mimeType != "image/png" {
+		return data, nil
+	}
+
+	img, err := decodeImage(data, mimeType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	if mimeType == "image/png" {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			return nil, fmt.Errorf("failed to encode image as PNG: %w", err)
+		}
+		return buf.Bytes(), nil
+	}
+	return encodeImage(img, StrippedQuality)
 }
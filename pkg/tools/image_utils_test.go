@@ -0,0 +1,187 @@
+package tools
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"testing"
+)
+
+func TestResizeImage(t *testing.T) {
+	tests := []struct {
+		name                string
+		width, height       int
+		maxWidth, maxHeight int
+		wantWidth           int
+		wantHeight          int
+	}{
+		{"wide panorama", 4000, 10, 250, 250, 250, 1},
+		{"tall screenshot", 10, 4000, 250, 250, 1, 250},
+		{"one pixel high", 10000, 1, 250, 250, 250, 1},
+		{"landscape", 1000, 500, 250, 250, 250, 125},
+		{"rounds instead of truncating", 999, 333, 250, 250, 250, 83},
+		{"non-square limits", 800, 800, 400, 200, 200, 200},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := image.NewRGBA(image.Rect(0, 0, tt.width, tt.height))
+
+			got := resizeImage(img, tt.maxWidth, tt.maxHeight).Bounds()
+			if got.Dx() != tt.wantWidth || got.Dy() != tt.wantHeight {
+				t.Errorf("resizeImage(%dx%d) = %dx%d, want %dx%d",
+					tt.width, tt.height, got.Dx(), got.Dy(), tt.wantWidth, tt.wantHeight)
+			}
+		})
+	}
+}
+
+func TestResizeImageKeepsSmallImages(t *testing.T) {
+	for _, size := range []image.Point{{250, 250}, {100, 40}, {1, 1}} {
+		img := image.NewRGBA(image.Rect(0, 0, size.X, size.Y))
+
+		if got := resizeImage(img, 250, 250); got != image.Image(img) {
+			t.Errorf("resizeImage(%dx%d) returned a new image, want the original", size.X, size.Y)
+		}
+	}
+}
+
+func TestResizeImageForLLMExtremeAspectRatio(t *testing.T) {
+	data, err := encodeImage(image.NewRGBA(image.Rect(0, 0, 4000, 10)), LLMQuality)
+	if err != nil {
+		t.Fatalf("encodeImage: %v", err)
+	}
+
+	resized, err := ResizeImageForLLM(data, "image/jpeg")
+	if err != nil {
+		t.Fatalf("ResizeImageForLLM: %v", err)
+	}
+
+	img, err := decodeImage(resized, "image/jpeg")
+	if err != nil {
+		t.Fatalf("decodeImage: %v", err)
+	}
+	if b := img.Bounds(); b.Dx() != LLMMaxWidth || b.Dy() != 1 {
+		t.Errorf("resized to %dx%d, want %dx1", b.Dx(), b.Dy(), LLMMaxWidth)
+	}
+}
+
+// testPattern draws a diagonal gradient with a bright block in one corner; flipped moves
+// the block to the opposite corner and reverses the gradient
+func testPattern(size int, flipped bool) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	for y := range size {
+		for x := range size {
+			v := uint8((x + y) * 127 / size)
+			inBlock := x < size/3 && y < size/3
+			if flipped {
+				v = 254 - v
+				inBlock = x >= size*2/3 && y >= size*2/3
+			}
+			if inBlock {
+				v = 255
+			}
+			img.Set(x, y, color.RGBA{v, v, v, 255})
+		}
+	}
+	return img
+}
+
+func TestPerceptualHash(t *testing.T) {
+	hashOf := func(img image.Image, quality int) uint64 {
+		t.Helper()
+		data, err := encodeImage(img, quality)
+		if err != nil {
+			t.Fatalf("encodeImage: %v", err)
+		}
+		hash, err := PerceptualHash(data)
+		if err != nil {
+			t.Fatalf("PerceptualHash: %v", err)
+		}
+		return hash
+	}
+
+	original := hashOf(testPattern(256, false), 95)
+	// Resized and recompressed like a forwarded photo
+	nearDuplicate := hashOf(resizeImage(testPattern(256, false), 100, 100), 30)
+	distinct := hashOf(testPattern(256, true), 95)
+
+	if d := HammingDistance(original, nearDuplicate); d > 4 {
+		t.Errorf("near-duplicate distance = %d, want at most 4", d)
+	}
+	if d := HammingDistance(original, distinct); d < 20 {
+		t.Errorf("distinct image distance = %d, want at least 20", d)
+	}
+}
+
+func TestFindSimilarImages(t *testing.T) {
+	data, err := encodeImage(testPattern(64, false), 90)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, err := PerceptualHash(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wd := NewWhatsAppDownloader(nil)
+	wd.historyImages = map[string]HistoryImageInfo{
+		"CLOSE":    {MessageID: "CLOSE", PHash: hash ^ 0b11, HasPHash: true},
+		"SAME":     {MessageID: "SAME", PHash: hash, HasPHash: true},
+		"FAR":      {MessageID: "FAR", PHash: ^hash, HasPHash: true},
+		"UNHASHED": {MessageID: "UNHASHED"},
+	}
+
+	matches := wd.FindSimilarImages(data, 5)
+	if len(matches) != 2 || matches[0].MessageID != "SAME" || matches[1].MessageID != "CLOSE" {
+		t.Errorf("FindSimilarImages = %v, want SAME then CLOSE", matches)
+	}
+}
+
+func TestResizeImageForLLMAnimatedGIF(t *testing.T) {
+	palette := color.Palette{color.White, color.RGBA{255, 0, 0, 255}, color.RGBA{0, 0, 255, 255}}
+
+	// The first frame only covers the left half of the 500x250 screen
+	first := image.NewPaletted(image.Rect(0, 0, 250, 250), palette)
+	second := image.NewPaletted(image.Rect(0, 0, 500, 250), palette)
+	for y := range 250 {
+		for x := range 500 {
+			if x < 250 {
+				first.SetColorIndex(x, y, 1)
+			}
+			second.SetColorIndex(x, y, 2)
+		}
+	}
+
+	var buf bytes.Buffer
+	err := gif.EncodeAll(&buf, &gif.GIF{
+		Image:  []*image.Paletted{first, second},
+		Delay:  []int{10, 10},
+		Config: image.Config{ColorModel: palette, Width: 500, Height: 250},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resized, err := ResizeImageForLLM(buf.Bytes(), DetectImageType("", buf.Bytes()))
+	if err != nil {
+		t.Fatalf("ResizeImageForLLM: %v", err)
+	}
+	img, err := jpeg.Decode(bytes.NewReader(resized))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := img.Bounds(); got.Dx() != 250 || got.Dy() != 125 {
+		t.Errorf("resized to %dx%d, want 250x125", got.Dx(), got.Dy())
+	}
+
+	// The first frame is used, over a white background where it doesn't reach
+	if r, g, b, _ := img.At(60, 60).RGBA(); r>>8 < 200 || g>>8 > 60 || b>>8 > 60 {
+		t.Errorf("left half = %d,%d,%d, want the first frame's red", r>>8, g>>8, b>>8)
+	}
+	if r, g, b, _ := img.At(190, 60).RGBA(); r>>8 < 200 || g>>8 < 200 || b>>8 < 200 {
+		t.Errorf("right half = %d,%d,%d, want white", r>>8, g>>8, b>>8)
+	}
+}
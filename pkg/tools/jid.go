@@ -0,0 +1,92 @@
+package tools
+
+import (
+	"fmt"
+	"strings"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+// Phone numbers are at most 15 digits (E.164); shorter than 8 can't be a mobile number
+const (
+	minPhoneDigits = 8
+	maxPhoneDigits = 15
+)
+
+// DefaultCountryCode replaces the leading 0 of numbers in national format, from
+// DEFAULT_COUNTRY_CODE (default 62)
+func DefaultCountryCode() string {
+	return NormalizePhoneNumber(EnvString("DEFAULT_COUNTRY_CODE", "62"))
+}
+
+// NormalizeJID turns a user-supplied phone number or JID into a JID. Numbers may be
+// formatted, e.g. "+62 812-3456-7890", "0812 3456 7890" (national format, see
+// DefaultCountryCode) or "0062812...", and get the @s.whatsapp.net server. Full JIDs
+// are accepted for users, LIDs and groups, without the device part.
+func NormalizeJID(input string) (types.JID, error) {
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return types.EmptyJID, fmt.Errorf("phone number or JID is empty")
+	}
+
+	if strings.Contains(input, "@") {
+		jid, err := types.ParseJID(input)
+		if err != nil {
+			return types.EmptyJID, fmt.Errorf("invalid JID %q: %w", input, err)
+		}
+		switch jid.Server {
+		case types.DefaultUserServer:
+			if _, err := phoneDigits(jid.User); err != nil {
+				return types.EmptyJID, fmt.Errorf("invalid JID %q: %w", input, err)
+			}
+		case types.GroupServer, types.HiddenUserServer:
+			if jid.User == "" {
+				return types.EmptyJID, fmt.Errorf("invalid JID %q: missing user", input)
+			}
+		default:
+			return types.EmptyJID, fmt.Errorf("invalid JID %q: unsupported server %q", input, jid.Server)
+		}
+		return jid.ToNonAD(), nil
+	}
+
+	number, err := phoneDigits(input)
+	if err != nil {
+		return types.EmptyJID, fmt.Errorf("invalid phone number %q: %w", input, err)
+	}
+	return types.NewJID(number, types.DefaultUserServer), nil
+}
+
+// phoneDigits validates a phone number and returns it in international format, digits only
+func phoneDigits(number string) (string, error) {
+	international := false
+	if rest, ok := strings.CutPrefix(number, "+"); ok {
+		number, international = rest, true
+	}
+
+	var sb strings.Builder
+	for _, r := range number {
+		switch {
+		case r >= '0' && r <= '9':
+			sb.WriteRune(r)
+		case strings.ContainsRune(" -.()", r):
+		default:
+			return "", fmt.Errorf("unexpected character %q", r)
+		}
+	}
+
+	digits := sb.String()
+	if !international {
+		if rest, ok := strings.CutPrefix(digits, "00"); ok {
+			digits = rest
+		} else if rest, ok := strings.CutPrefix(digits, "0"); ok {
+			digits = DefaultCountryCode() + rest
+		}
+	}
+	if strings.HasPrefix(digits, "0") {
+		return "", fmt.Errorf("country code can't start with 0")
+	}
+	if len(digits) < minPhoneDigits || len(digits) > maxPhoneDigits {
+		return "", fmt.Errorf("expected %d to %d digits, got %d", minPhoneDigits, maxPhoneDigits, len(digits))
+	}
+	return digits, nil
+}
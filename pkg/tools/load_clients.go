@@ -0,0 +1,121 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+
+	"go.mau.fi/whatsmeow/store/sqlstore"
+	waLog "go.mau.fi/whatsmeow/util/log"
+)
+
+// databaseNamePattern matches the names generateDatabaseName produces, e.g.
+// whatsapp_628123_20251116_122441_a1b2c3.db (older names lack the random suffix)
+var databaseNamePattern = regexp.MustCompile(`^whatsapp_(.+)_(\d{8}_\d{6})(?:_[0-9a-f]{6})?\.db$`)
+
+// parseDatabaseName returns the phone ID and creation timestamp encoded in a client
+// database file name
+func parseDatabaseName(name string) (phoneID, timestamp string, ok bool) {
+	m := databaseNamePattern.FindStringSubmatch(name)
+	if m == nil {
+		return "", "", false
+	}
+	return m[1], m[2], true
+}
+
+// LoadExistingClients registers a client for every phone ID with a database in the data
+// directory, so sessions survive a restart. When a phone ID has several databases the
+// newest paired one is used. With connect set, clients that are already paired are
+// connected too. It returns the phone IDs that were loaded.
+func (wm *WhatsAppManager) LoadExistingClients(connect bool) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(wm.dbDir, "whatsapp_*.db"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to glob database files: %w", err)
+	}
+
+	type candidate struct {
+		path      string
+		timestamp string
+	}
+	byPhone := make(map[string][]candidate)
+	for _, file := range files {
+		phoneID, timestamp, ok := parseDatabaseName(filepath.Base(file))
+		if !ok {
+			log.Printf("Skipping database with unrecognized name: %s", file)
+			continue
+		}
+		byPhone[phoneID] = append(byPhone[phoneID], candidate{file, timestamp})
+	}
+
+	phoneIDs := make([]string, 0, len(byPhone))
+	for phoneID := range byPhone {
+		phoneIDs = append(phoneIDs, phoneID)
+	}
+	sort.Strings(phoneIDs)
+
+	var loaded []string
+	var errs []error
+	for _, phoneID := range phoneIDs {
+		candidates := byPhone[phoneID]
+		sort.Slice(candidates, func(i, j int) bool { return candidates[i].timestamp > candidates[j].timestamp })
+
+		dbPath := candidates[0].path
+		for _, c := range candidates {
+			if isPairedDatabase(c.path) {
+				dbPath = c.path
+				break
+			}
+		}
+
+		wm.mu.Lock()
+		if _, exists := wm.instances[phoneID]; exists {
+			wm.mu.Unlock()
+			continue
+		}
+		instance, err := wm.openClientLocked(phoneID, dbPath)
+		wm.mu.Unlock()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to load client %s: %w", phoneID, err))
+			continue
+		}
+		wm.clientAdded(instance)
+		loaded = append(loaded, phoneID)
+	}
+
+	if connect {
+		for _, phoneID := range loaded {
+			instance, err := wm.GetClient(phoneID)
+			if err != nil || instance.Client.Store.ID == nil {
+				continue // not paired yet; needs a QR scan or pairing code
+			}
+			if err := wm.ConnectClient(phoneID); err != nil {
+				errs = append(errs, fmt.Errorf("failed to connect client %s: %w", phoneID, err))
+			}
+		}
+	}
+
+	log.Printf("Loaded %d existing WhatsApp clients from %s", len(loaded), wm.dbDir)
+	return loaded, errors.Join(errs...)
+}
+
+// isPairedDatabase reports whether a client database holds a logged-in device
+func isPairedDatabase(dbPath string) bool {
+	if info, err := os.Stat(dbPath); err != nil || info.Size() == 0 {
+		return false
+	}
+
+	ctx := context.Background()
+	container, err := sqlstore.New(ctx, "sqlite3", dbPath+"?_foreign_keys=on&_busy_timeout=5000", waLog.Noop)
+	if err != nil {
+		return false
+	}
+	defer container.Close()
+
+	device, err := container.GetFirstDevice(ctx)
+	return err == nil && device.ID != nil
+}
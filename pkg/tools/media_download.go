@@ -0,0 +1,177 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/proto/waMmsRetry"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// ErrMediaExpired is returned when media is gone from WhatsApp's servers and the phone
+// that sent it didn't upload it again
+var ErrMediaExpired = errors.New("media expired and was not re-uploaded by the phone")
+
+// downloadPolicyFromEnv reads MEDIA_DOWNLOAD_MAX_ATTEMPTS (default 3),
+// MEDIA_DOWNLOAD_RETRY_DELAY (default 2s) and MEDIA_DOWNLOAD_TIMEOUT (default 2m)
+func downloadPolicyFromEnv() (RetryPolicy, time.Duration) {
+	retry := RetryPolicy{
+		MaxAttempts: max(EnvInt("MEDIA_DOWNLOAD_MAX_ATTEMPTS", 3), 1),
+		BaseDelay:   EnvDuration("MEDIA_DOWNLOAD_RETRY_DELAY", 2*time.Second),
+		MaxDelay:    30 * time.Second,
+	}
+	return retry, EnvDuration("MEDIA_DOWNLOAD_TIMEOUT", 2*time.Minute)
+}
+
+// SetDownloadPolicy sets how failed media downloads are retried and how long a download,
+// including retries and a re-upload request, may take in total (0 = no limit)
+func (wd *WhatsAppDownloader) SetDownloadPolicy(retry RetryPolicy, timeout time.Duration) {
+	retry.MaxAttempts = max(retry.MaxAttempts, 1)
+	wd.downloadRetry = retry
+	wd.downloadTimeout = timeout
+}
+
+// isRetryableDownloadError reports whether a failed download may succeed when retried:
+// network errors and 408/429/5xx responses, but not missing or corrupt media
+func isRetryableDownloadError(err error) bool {
+	var httpErr whatsmeow.DownloadHTTPError
+	switch {
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return false
+	case errors.As(err, &httpErr):
+		return isRetryableStatus(httpErr.StatusCode)
+	case errors.Is(err, whatsmeow.ErrNoURLPresent),
+		errors.Is(err, whatsmeow.ErrUnknownMediaType),
+		errors.Is(err, whatsmeow.ErrFileLengthMismatch),
+		errors.Is(err, whatsmeow.ErrTooShortFile),
+		errors.Is(err, whatsmeow.ErrInvalidMediaHMAC),
+		errors.Is(err, whatsmeow.ErrInvalidMediaEncSHA256),
+		errors.Is(err, whatsmeow.ErrInvalidMediaSHA256):
+		return false
+	}
+	return true
+}
+
+// download fetches a message's media, retrying transient failures with backoff. Media
+// expired on the server (HTTP 410) is requested from the sender's phone again once.
+func (wd *WhatsAppDownloader) download(ctx context.Context, kind string, msgInfo types.MessageInfo, msg whatsmeow.DownloadableMessage) ([]byte, error) {
+	if wd.client == nil {
+		return nil, fmt.Errorf("WhatsApp client not initialized")
+	}
+	if wd.downloadTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, wd.downloadTimeout)
+		defer cancel()
+	}
+
+	attempts := max(wd.downloadRetry.MaxAttempts, 1)
+	for attempt := 1; ; attempt++ {
+		data, err := wd.client.Download(ctx, msg)
+		if err == nil {
+			return data, nil
+		}
+
+		if errors.Is(err, whatsmeow.ErrMediaDownloadFailedWith410) {
+			if data, err = wd.downloadReuploaded(ctx, msgInfo, msg); err == nil {
+				return data, nil
+			}
+			countDownloadFailure()
+			return nil, fmt.Errorf("failed to download %s %s: %w", kind, msgInfo.ID, err)
+		}
+		if attempt >= attempts || !isRetryableDownloadError(err) {
+			countDownloadFailure()
+			return nil, fmt.Errorf("failed to download %s %s (attempt %d/%d): %w", kind, msgInfo.ID, attempt, attempts, err)
+		}
+
+		delay := wd.downloadRetry.backoff(attempt)
+		fmt.Printf("Download of %s %s failed (attempt %d/%d), retrying in %s: %v\n", kind, msgInfo.ID, attempt, attempts, delay.Round(time.Millisecond), err)
+		select {
+		case <-ctx.Done():
+			countDownloadFailure()
+			return nil, fmt.Errorf("failed to download %s %s: %w (gave up retrying: %w)", kind, msgInfo.ID, err, ctx.Err())
+		case <-time.After(delay):
+		}
+	}
+}
+
+// downloadReuploaded asks the sender's phone to upload expired media again and downloads
+// it from the new path. The answer arrives as an *events.MediaRetry, see
+// AddHistorySyncHandlers.
+func (wd *WhatsAppDownloader) downloadReuploaded(ctx context.Context, msgInfo types.MessageInfo, msg whatsmeow.DownloadableMessage) ([]byte, error) {
+	if msgInfo.ID == "" || msgInfo.Chat.IsEmpty() {
+		return nil, fmt.Errorf("%w: the chat of message %q is unknown, can't request a re-upload", ErrMediaExpired, msgInfo.ID)
+	}
+
+	ch := make(chan *events.MediaRetry, 1)
+	wd.mediaRetryMutex.Lock()
+	if wd.mediaRetries == nil {
+		wd.mediaRetries = make(map[types.MessageID]chan *events.MediaRetry)
+	}
+	wd.mediaRetries[msgInfo.ID] = ch
+	wd.mediaRetryMutex.Unlock()
+	defer func() {
+		wd.mediaRetryMutex.Lock()
+		delete(wd.mediaRetries, msgInfo.ID)
+		wd.mediaRetryMutex.Unlock()
+	}()
+
+	if err := wd.client.SendMediaRetryReceipt(ctx, &msgInfo, msg.GetMediaKey()); err != nil {
+		return nil, fmt.Errorf("%w: failed to request a re-upload: %w", ErrMediaExpired, err)
+	}
+	fmt.Printf("Media of message %s expired, asked the phone to upload it again\n", msgInfo.ID)
+
+	var evt *events.MediaRetry
+	select {
+	case evt = <-ch:
+	case <-ctx.Done():
+		return nil, fmt.Errorf("%w: no answer from the phone: %w", ErrMediaExpired, ctx.Err())
+	}
+
+	notif, err := whatsmeow.DecryptMediaRetryNotification(evt, msg.GetMediaKey())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrMediaExpired, err)
+	}
+	if notif.GetResult() != waMmsRetry.MediaRetryNotification_SUCCESS || notif.GetDirectPath() == "" {
+		return nil, fmt.Errorf("%w: the phone answered %s", ErrMediaExpired, notif.GetResult())
+	}
+
+	data, err := wd.client.Download(ctx, reuploadedMedia{
+		DownloadableMessage: msg,
+		directPath:          notif.GetDirectPath(),
+		mediaType:           whatsmeow.GetMediaType(msg),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to download re-uploaded media: %w", err)
+	}
+	return data, nil
+}
+
+// handleMediaRetry passes a re-upload answer to the download waiting for it
+func (wd *WhatsAppDownloader) handleMediaRetry(evt *events.MediaRetry) {
+	wd.mediaRetryMutex.Lock()
+	ch, ok := wd.mediaRetries[evt.MessageID]
+	wd.mediaRetryMutex.Unlock()
+	if !ok {
+		return
+	}
+
+	select {
+	case ch <- evt:
+	default: // already answered
+	}
+}
+
+// reuploadedMedia is a media message pointing at the path its re-upload was stored at.
+// It hides the message's original URL, which Download would otherwise prefer.
+type reuploadedMedia struct {
+	whatsmeow.DownloadableMessage
+	directPath string
+	mediaType  whatsmeow.MediaType
+}
+
+func (m reuploadedMedia) GetDirectPath() string             { return m.directPath }
+func (m reuploadedMedia) GetMediaType() whatsmeow.MediaType { return m.mediaType }
@@ -0,0 +1,138 @@
+package tools_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"auto-lmk/pkg/tools"
+	"auto-lmk/pkg/whatsapptest"
+
+	"go.mau.fi/whatsmeow"
+	waProto "go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/proto/waMmsRetry"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+	"go.mau.fi/whatsmeow/util/gcmutil"
+	"go.mau.fi/whatsmeow/util/hkdfutil"
+	"google.golang.org/protobuf/proto"
+)
+
+// flakyClient fails the first downloads with the queued errors
+type flakyClient struct {
+	*whatsapptest.FakeClient
+	mu        sync.Mutex
+	errs      []error
+	downloads int
+}
+
+func (c *flakyClient) Download(ctx context.Context, msg whatsmeow.DownloadableMessage) ([]byte, error) {
+	c.mu.Lock()
+	c.downloads++
+	if len(c.errs) > 0 {
+		err := c.errs[0]
+		c.errs = c.errs[1:]
+		c.mu.Unlock()
+		return nil, err
+	}
+	c.mu.Unlock()
+	return c.FakeClient.Download(ctx, msg)
+}
+
+func newFlakyDownloader(errs ...error) (*tools.WhatsAppDownloader, *flakyClient) {
+	client := &flakyClient{FakeClient: whatsapptest.NewFakeClient(), errs: errs}
+	client.Downloads["/old"] = []byte("image")
+	wd := tools.NewWhatsAppDownloader(client)
+	wd.SetDownloadPolicy(tools.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}, time.Second)
+	return wd, client
+}
+
+var testImageInfo = types.MessageInfo{
+	MessageSource: types.MessageSource{Chat: types.NewJID("628120000000", types.DefaultUserServer)},
+	ID:            "IMG1",
+}
+
+func testImage() *waProto.ImageMessage {
+	return &waProto.ImageMessage{DirectPath: proto.String("/old"), MediaKey: make([]byte, 32)}
+}
+
+func TestDownloadImageRetriesTransientErrors(t *testing.T) {
+	wd, client := newFlakyDownloader(errors.New("connection reset"), whatsmeow.DownloadHTTPError{Response: &http.Response{StatusCode: 503}})
+
+	data, err := wd.DownloadImage(context.Background(), testImageInfo, testImage())
+	if err != nil || string(data) != "image" {
+		t.Fatalf("DownloadImage = %q, %v", data, err)
+	}
+	if client.downloads != 3 {
+		t.Errorf("downloads = %d, want 3", client.downloads)
+	}
+}
+
+func TestDownloadImageGivesUpOnPermanentErrors(t *testing.T) {
+	wd, client := newFlakyDownloader(whatsmeow.ErrMediaDownloadFailedWith404)
+
+	if _, err := wd.DownloadImage(context.Background(), testImageInfo, testImage()); !errors.Is(err, whatsmeow.ErrMediaDownloadFailedWith404) {
+		t.Fatalf("err = %v, want 404", err)
+	}
+	if client.downloads != 1 {
+		t.Errorf("downloads = %d, want 1", client.downloads)
+	}
+}
+
+func TestDownloadImageRequestsExpiredMediaAgain(t *testing.T) {
+	tests := []struct {
+		name   string
+		result waMmsRetry.MediaRetryNotification_ResultType
+		want   error
+	}{
+		{"reuploaded", waMmsRetry.MediaRetryNotification_SUCCESS, nil},
+		{"gone from phone", waMmsRetry.MediaRetryNotification_NOT_FOUND, tools.ErrMediaExpired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wd, client := newFlakyDownloader(whatsmeow.ErrMediaDownloadFailedWith410)
+			client.Downloads["/new"] = []byte("reuploaded")
+			wd.AddHistorySyncHandlers(context.Background())
+			img := testImage()
+			answer := mediaRetryEvent(t, testImageInfo.ID, img.GetMediaKey(), tt.result, "/new")
+
+			go func() {
+				for len(client.MediaRetries()) == 0 {
+					time.Sleep(time.Millisecond)
+				}
+				client.Dispatch(answer)
+			}()
+
+			data, err := wd.DownloadImage(context.Background(), testImageInfo, img)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("err = %v, want %v", err, tt.want)
+			}
+			if tt.want == nil && string(data) != "reuploaded" {
+				t.Errorf("data = %q, want the re-uploaded media", data)
+			}
+		})
+	}
+}
+
+// mediaRetryEvent encrypts a re-upload answer the way the phone does
+func mediaRetryEvent(t *testing.T, id types.MessageID, mediaKey []byte, result waMmsRetry.MediaRetryNotification_ResultType, path string) *events.MediaRetry {
+	plaintext, err := proto.Marshal(&waMmsRetry.MediaRetryNotification{
+		StanzaID:   proto.String(id),
+		DirectPath: proto.String(path),
+		Result:     result.Enum(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := hkdfutil.SHA256(mediaKey, nil, []byte("WhatsApp Media Retry Notification"), 32)
+	iv := make([]byte, 12)
+	ciphertext, err := gcmutil.Encrypt(key, iv, plaintext, []byte(id))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &events.MediaRetry{MessageID: id, Ciphertext: ciphertext, IV: iv}
+}
@@ -0,0 +1,70 @@
+package tools
+
+import (
+	"expvar"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+)
+
+// Process-wide counters, also published through expvar
+var (
+	metricMessagesReceived = expvar.NewInt("whatsapp_messages_received_total")
+	metricAIRequests       = expvar.NewInt("ai_requests_total")
+	metricDownloadFailures = expvar.NewInt("whatsapp_download_failures_total")
+)
+
+// CountMessageReceived records an incoming message
+func CountMessageReceived() {
+	metricMessagesReceived.Add(1)
+}
+
+// countAIRequest records a request sent to the AI provider, including retries
+func countAIRequest() {
+	metricAIRequests.Add(1)
+}
+
+// countDownloadFailure records a media download that failed
+func countDownloadFailure() {
+	metricDownloadFailures.Add(1)
+}
+
+// MetricsHandler serves the manager's counters in the Prometheus text format
+func (wm *WhatsAppManager) MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		wm.WriteMetrics(w)
+	})
+}
+
+// WriteMetrics writes the client gauges and message, AI and download counters in the
+// Prometheus text format
+func (wm *WhatsAppManager) WriteMetrics(w io.Writer) {
+	phoneIDs := wm.ListClients()
+	sort.Strings(phoneIDs)
+
+	connected := 0
+	perClient := make([]int, len(phoneIDs))
+	for i, phoneID := range phoneIDs {
+		if ok, _, err := wm.GetClientStatus(phoneID); err == nil && ok {
+			connected++
+			perClient[i] = 1
+		}
+	}
+
+	writeMetric(w, "whatsapp_clients", "gauge", "Number of registered WhatsApp clients.", int64(len(phoneIDs)))
+	writeMetric(w, "whatsapp_clients_connected", "gauge", "Number of connected WhatsApp clients.", int64(connected))
+	fmt.Fprintln(w, "# HELP whatsapp_client_connected Whether a WhatsApp client is connected (1) or not (0).")
+	fmt.Fprintln(w, "# TYPE whatsapp_client_connected gauge")
+	for i, phoneID := range phoneIDs {
+		fmt.Fprintf(w, "whatsapp_client_connected{phone_id=%q} %d\n", phoneID, perClient[i])
+	}
+	writeMetric(w, "whatsapp_messages_received_total", "counter", "Incoming WhatsApp messages.", metricMessagesReceived.Value())
+	writeMetric(w, "ai_requests_total", "counter", "Requests sent to the AI provider, including retries.", metricAIRequests.Value())
+	writeMetric(w, "whatsapp_download_failures_total", "counter", "Failed media downloads.", metricDownloadFailures.Value())
+}
+
+func writeMetric(w io.Writer, name, kind, help string, value int64) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %d\n", name, help, name, kind, name, value)
+}
@@ -0,0 +1,130 @@
+package tools
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/openai/openai-go"
+)
+
+// ErrContentFlagged is returned by ProcessImageWithAI when moderation flags the caption
+// or images; the request is not sent to the model
+var ErrContentFlagged = errors.New("content flagged by moderation")
+
+// DefaultModerationModel is the OpenAI moderation model, overridable with AI_MODERATION_MODEL
+const DefaultModerationModel = "omni-moderation-latest"
+
+// ModerationResult is a moderation verdict
+type ModerationResult struct {
+	Flagged    bool
+	Categories []string // flagged categories, e.g. "violence"
+}
+
+// Moderator classifies content before it reaches the AI. OpenAIModerator is used by
+// default; SetModerator plugs in another classifier.
+type Moderator interface {
+	Moderate(ctx context.Context, text string, images []ImageInput) (ModerationResult, error)
+}
+
+// OpenAIModerator checks content with the OpenAI moderation endpoint
+type OpenAIModerator struct {
+	client openai.Client
+	model  string
+}
+
+// NewOpenAIModerator creates a moderator using the given moderation model
+func NewOpenAIModerator(client openai.Client, model string) *OpenAIModerator {
+	if model == "" {
+		model = DefaultModerationModel
+	}
+	return &OpenAIModerator{client: client, model: model}
+}
+
+// Moderate sends the text and images in one multi-modal moderation request
+func (m *OpenAIModerator) Moderate(ctx context.Context, text string, images []ImageInput) (ModerationResult, error) {
+	var inputs []openai.ModerationMultiModalInputUnionParam
+	if text != "" {
+		inputs = append(inputs, openai.ModerationMultiModalInputParamOfText(text))
+	}
+	for _, img := range images {
+		inputs = append(inputs, openai.ModerationMultiModalInputParamOfImageURL(openai.ModerationImageURLInputImageURLParam{
+			URL: fmt.Sprintf("data:%s;base64,%s", img.MimeType, base64.StdEncoding.EncodeToString(img.Data)),
+		}))
+	}
+	if len(inputs) == 0 {
+		return ModerationResult{}, nil
+	}
+
+	resp, err := m.client.Moderations.New(ctx, openai.ModerationNewParams{
+		Model: m.model,
+		Input: openai.ModerationNewParamsInputUnion{OfModerationMultiModalArray: inputs},
+	})
+	if err != nil {
+		return ModerationResult{}, fmt.Errorf("failed to moderate content: %w", err)
+	}
+
+	var result ModerationResult
+	for _, r := range resp.Results {
+		if !r.Flagged {
+			continue
+		}
+		result.Flagged = true
+		result.Categories = append(result.Categories, flaggedCategories(r.Categories.RawJSON())...)
+	}
+	return result, nil
+}
+
+// flaggedCategories returns the names of the true categories in a moderation result
+func flaggedCategories(raw string) []string {
+	var categories map[string]bool
+	if err := json.Unmarshal([]byte(raw), &categories); err != nil {
+		return nil
+	}
+	var flagged []string
+	for name, hit := range categories {
+		if hit {
+			flagged = append(flagged, name)
+		}
+	}
+	sort.Strings(flagged)
+	return flagged
+}
+
+// SetModerator sets the classifier images and captions are checked with when
+// moderation is enabled
+func (at *AITools) SetModerator(m Moderator) {
+	at.moderator = m
+}
+
+// SetModeration turns moderation of image requests on or off
+func (at *AITools) SetModeration(enabled bool) {
+	at.moderation = enabled
+}
+
+// moderateImages checks a caption and its images when moderation is enabled, returning
+// ErrContentFlagged if they must not be sent. Moderation failures are logged and let
+// the request through so an outage of the classifier doesn't stop the bot.
+func (at *AITools) moderateImages(ctx context.Context, text string, images []ImageInput) error {
+	if !at.moderation {
+		return nil
+	}
+
+	if at.moderator == nil {
+		fmt.Println("Skipping moderation: no moderator set and the provider isn't OpenAI")
+		return nil
+	}
+
+	result, err := at.moderator.Moderate(ctx, text, images)
+	if err != nil {
+		fmt.Printf("Moderation failed, sending the request unchecked: %v\n", err)
+		return nil
+	}
+	if result.Flagged {
+		return fmt.Errorf("%w: %v", ErrContentFlagged, result.Categories)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/openai/openai-go"
+)
+
+// RedactedImagePlaceholder replaces inline base64 image data in rendered or exported prompts
+const RedactedImagePlaceholder = "[base64 image redacted]"
+
+// RedactMessages converts chat messages into plain JSON values with inline image data
+// replaced by a placeholder, so they can be displayed or stored in a readable form
+func RedactMessages(messages []openai.ChatCompletionMessageParamUnion) ([]any, error) {
+	data, err := json.Marshal(messages)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal messages: %w", err)
+	}
+
+	var values []any
+	if err := json.Unmarshal(data, &values); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal messages: %w", err)
+	}
+
+	for _, v := range values {
+		redactImageURLs(v)
+	}
+	return values, nil
+}
+
+// redactImageURLs walks a decoded JSON value and replaces data URLs in-place
+func redactImageURLs(v any) {
+	switch val := v.(type) {
+	case map[string]any:
+		for key, child := range val {
+			if s, ok := child.(string); ok && key == "url" && strings.HasPrefix(s, "data:") {
+				mimeType := strings.SplitN(strings.TrimPrefix(s, "data:"), ";", 2)[0]
+				val[key] = fmt.Sprintf("%s (%s, %d chars)", RedactedImagePlaceholder, mimeType, len(s))
+				continue
+			}
+			redactImageURLs(child)
+		}
+	case []any:
+		for _, child := range val {
+			redactImageURLs(child)
+		}
+	}
+}
+
+// RenderPrompt formats chat messages as human-readable text for prompt debugging
+func RenderPrompt(messages []openai.ChatCompletionMessageParamUnion) (string, error) {
+	values, err := RedactMessages(messages)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	for i, v := range values {
+		msg, _ := v.(map[string]any)
+		role, _ := msg["role"].(string)
+		fmt.Fprintf(&sb, "#%d [%s]\n", i+1, role)
+
+		switch content := msg["content"].(type) {
+		case string:
+			sb.WriteString(content)
+			sb.WriteString("\n")
+		case []any:
+			for _, p := range content {
+				part, _ := p.(map[string]any)
+				switch part["type"] {
+				case "text":
+					fmt.Fprintf(&sb, "%v\n", part["text"])
+				case "image_url":
+					img, _ := part["image_url"].(map[string]any)
+					fmt.Fprintf(&sb, "<image detail=%v> %v\n", img["detail"], img["url"])
+				default:
+					fmt.Fprintf(&sb, "<%v part>\n", part["type"])
+				}
+			}
+		}
+		sb.WriteString("\n")
+	}
+
+	return strings.TrimSpace(sb.String()), nil
+}
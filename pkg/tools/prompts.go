@@ -11,21 +11,64 @@ Ketika pengguna merujuk ke gambar sebelumnya (dengan kata seperti "gambar tadi",
 	// SystemMessage for text processing
 	TextProcessingSystemMessage = `Kamu adalah asisten AI WhatsApp yang membantu dan ramah. Berikan respons yang relevan, membantu, dan ringkas dalam Bahasa Indonesia.`
 
+	// Appended to the system prompt when a chat has a reply language configured
+	LanguageInstructionTemplate = "\n\nSelalu jawab dalam bahasa: %s."
+
+	// SystemMessage for detecting image generation requests via function calling
+	ImageGenerationIntentSystemMessage = `Tentukan apakah pengguna secara eksplisit meminta dibuatkan atau digambarkan sebuah gambar baru (misalnya "buatkan gambar kucing"). Jika ya, panggil fungsi generate_image dengan deskripsi gambar yang detail. Jika tidak, jawab singkat tanpa memanggil fungsi.`
+
 	// Default image prompt when no caption is provided
 	DefaultImagePrompt = "Apa yang kamu lihat dalam gambar ini?"
 
+	// Prompt for comparing several stored images in one request
+	AnalyzeImagesPrompt = "Bandingkan dan analisis gambar-gambar berikut secara bersamaan. Jelaskan persamaan, perbedaan, dan hal penting dari masing-masing gambar."
+
+	// Document prompt: file name, truncation note, document text, user request
+	DocumentTemplate      = "[Pengguna mengirim dokumen \"%s\"%s]\n\nIsi dokumen:\n%s\n\n%s"
+	DocumentTruncatedNote = ", hanya %d karakter pertama yang disertakan"
+	DefaultDocumentPrompt = "Ringkas isi dokumen ini."
+
 	// Quoted message templates
 	QuotedImageWithIDAndCaptionTemplate = "> [Gambar ID: %s dengan caption: %s]"
 	QuotedImageWithIDTemplate           = "> [Gambar ID: %s]"
 	QuotedTextTemplate                  = "> %s"
 
+	// Sent to the AI in place of a message type the bot can't read yet
+	UnsupportedMessageTemplate = "[Pengguna mengirim pesan jenis '%s' yang belum didukung. Beri tahu pengguna dengan sopan bahwa kamu belum bisa membaca jenis pesan ini.]"
+
+	// Interactive reply template (display text, selected button/row ID)
+	InteractiveReplyTemplate = "%s\n\n[Pilihan ID: %s]"
+
+	// Video frame and sticker prompts: caption or default image prompt
+	VideoFrameTemplate = "[Gambar ini adalah cuplikan dari video yang dikirim pengguna]\n%s"
+	StickerTemplate    = "[Gambar ini adalah stiker yang dikirim pengguna]\n%s"
+
+	// Shared location (latitude, longitude, optional details) and contact (name, numbers)
+	LocationTemplate     = "[Pengguna membagikan lokasi %.6f,%.6f%s]"
+	LiveLocationTemplate = "[Pengguna membagikan lokasi terkini (live) %.6f,%.6f]"
+	ContactTemplate      = "[Pengguna membagikan kontak: %s, %s]"
+
 	// Error messages
-	ErrorMessageImageProcessing   = "❌ Error processing image with AI"
-	ErrorMessageImageValidation   = "❌ %s. Silakan kirim gambar yang lebih kecil."
-	ErrorMessageImageSave         = "❌ Maaf, terjadi kesalahan saat menyimpan gambar. Silakan coba lagi."
-	ErrorMessageAIToolsNotInit    = "❌ AI tools not initialized"
-	ErrorMessageSendingResponse   = "❌ Maaf, terjadi kesalahan saat mengirim respons. Silakan coba lagi."
-	ErrorMessageProcessingMessage = "❌ Maaf, terjadi kesalahan saat memproses pesan. Silakan coba lagi."
+	ErrorMessageImageProcessing    = "❌ Error processing image with AI"
+	ErrorMessageImageValidation    = "❌ %s. Silakan kirim gambar yang lebih kecil."
+	ErrorMessageImageFormat        = "🖼️ Maaf, format gambar ini (misalnya HEIC dari iPhone) tidak dapat dibaca. Silakan kirim ulang sebagai JPG atau PNG."
+	ErrorMessageImageSave          = "❌ Maaf, terjadi kesalahan saat menyimpan gambar. Silakan coba lagi."
+	ErrorMessageAIToolsNotInit     = "❌ AI tools not initialized"
+	ErrorMessageSendingResponse    = "❌ Maaf, terjadi kesalahan saat mengirim respons. Silakan coba lagi."
+	ErrorMessageProcessingMessage  = "❌ Maaf, terjadi kesalahan saat memproses pesan. Silakan coba lagi."
+	ErrorMessageAudioProcessing    = "❌ Maaf, pesan suara tidak dapat diproses. Silakan kirim pesan teks."
+	ErrorMessageAudioEmpty         = "🎙️ Maaf, saya tidak dapat mendengar apa pun dalam pesan suara tersebut."
+	ErrorMessageImageGeneration    = "❌ Maaf, gambar tidak dapat dibuat saat ini. Silakan coba lagi nanti."
+	ErrorMessageImageRejected      = "🚫 Maaf, permintaan gambar tersebut tidak dapat dibuat karena melanggar kebijakan konten."
+	ErrorMessageImageGenLimit      = "⏳ Batas pembuatan gambar untuk chat ini hari ini sudah tercapai. Silakan coba lagi besok."
+	ErrorMessageVideoProcessing    = "❌ Maaf, video tidak dapat diproses. Silakan coba lagi."
+	ErrorMessageStickerProcessing  = "❌ Maaf, stiker tidak dapat diproses. Silakan coba lagi."
+	ErrorMessageRateLimited        = "⏳ Terlalu banyak pesan dalam waktu singkat. Mohon tunggu sebentar sebelum mengirim pesan lagi."
+	ErrorMessageDocumentType       = "📄 Maaf, saya belum bisa membaca jenis file ini. Silakan kirim PDF atau file teks."
+	ErrorMessageDocumentEmpty      = "📄 Maaf, saya tidak menemukan teks apa pun dalam dokumen tersebut."
+	ErrorMessageDocumentProcessing = "❌ Maaf, dokumen tidak dapat diproses. Silakan coba lagi."
+	ErrorMessageContentFlagged     = "🚫 Maaf, gambar atau keterangan ini tidak dapat saya proses karena tidak sesuai dengan kebijakan konten. Silakan kirim gambar lain."
+	ErrorMessageVisionUnsupported  = "🖼️ Maaf, model AI yang digunakan saat ini tidak dapat menganalisis gambar. Silakan jelaskan isi gambar dengan teks."
 
 	// Success messages
 	SuccessMessageTypingIndicator = "🤔"
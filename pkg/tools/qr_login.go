@@ -0,0 +1,132 @@
+package tools
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mdp/qrterminal"
+	"go.mau.fi/whatsmeow"
+	"rsc.io/qr"
+)
+
+// ErrQRExpired is returned when every QR code expired without being scanned
+var ErrQRExpired = errors.New("QR code expired without being scanned")
+
+// QRExpiredEventType is the webhook event type sent when a client's QR login times out
+const QRExpiredEventType = "qr_expired"
+
+// OnQRExpired registers a callback fired once whenever a client's QR login expires
+// without being scanned. The client is already disconnected and ready for another
+// attempt when the callback runs.
+func (wm *WhatsAppManager) OnQRExpired(fn func(phoneID string)) {
+	wm.handlerMu.Lock()
+	defer wm.handlerMu.Unlock()
+	wm.qrExpiredHandler = fn
+}
+
+// waitForQRLogin renders QR codes until the login finishes
+func (wm *WhatsAppManager) waitForQRLogin(instance *WhatsAppInstance, phoneID string, qrChan <-chan whatsmeow.QRChannelItem, render func(code string)) error {
+	for evt := range qrChan {
+		switch evt.Event {
+		case whatsmeow.QRChannelEventCode:
+			render(evt.Code)
+		case whatsmeow.QRChannelSuccess.Event:
+			return nil
+		case whatsmeow.QRChannelTimeout.Event:
+			wm.handleQRExpired(instance, phoneID)
+			return fmt.Errorf("client %s: %w", phoneID, ErrQRExpired)
+		case whatsmeow.QRChannelEventError:
+			instance.Client.Disconnect()
+			return fmt.Errorf("QR login for client %s failed: %w", phoneID, evt.Error)
+		default:
+			instance.Client.Disconnect()
+			return fmt.Errorf("QR login for client %s failed: %s", phoneID, evt.Event)
+		}
+	}
+	return nil
+}
+
+// handleQRExpired resets a client after its QR codes ran out and notifies observers
+func (wm *WhatsAppManager) handleQRExpired(instance *WhatsAppInstance, phoneID string) {
+	log.Printf("QR code for client %s expired without being scanned", phoneID)
+
+	instance.mu.Lock()
+	instance.Client.Disconnect()
+	instance.removeHandlersNamed(historySyncHandlerName)
+	instance.removeHandlersNamed(managerHandlerName)
+	instance.Connected = false
+	instance.mu.Unlock()
+
+	wm.webhooks.Dispatch(WebhookEvent{
+		PhoneID:   phoneID,
+		Type:      QRExpiredEventType,
+		Timestamp: time.Now(),
+	})
+
+	wm.handlerMu.RLock()
+	handler := wm.qrExpiredHandler
+	wm.handlerMu.RUnlock()
+	if handler != nil {
+		// Run asynchronously so a retry started from the callback doesn't nest inside this login
+		go handler(phoneID)
+	}
+}
+
+// qrLevel returns the QR error-correction level from QR_ERROR_CORRECTION (L, M, Q or H;
+// default L). Higher levels make bigger codes that scan more reliably.
+func qrLevel() qr.Level {
+	switch level := strings.ToUpper(EnvString("QR_ERROR_CORRECTION", "L")); level {
+	case "L":
+		return qr.L
+	case "M":
+		return qr.M
+	case "Q":
+		return qr.Q
+	case "H":
+		return qr.H
+	default:
+		log.Printf("Ignoring QR_ERROR_CORRECTION %q, expected L, M, Q or H", level)
+		return qr.L
+	}
+}
+
+// PrintQRCode renders a login QR code in the terminal. Half blocks (the default) keep it
+// small; QR_HALF_BLOCKS=false draws full blocks for terminals where it's too dense to scan.
+func PrintQRCode(code string, w io.Writer) {
+	if EnvBool("QR_HALF_BLOCKS", true) {
+		qrterminal.GenerateHalfBlock(code, qrLevel(), w)
+		return
+	}
+	qrterminal.Generate(code, qrLevel(), w)
+}
+
+// QRCodePNG renders a login QR code as a PNG image
+func QRCodePNG(code string) ([]byte, error) {
+	qrCode, err := qr.Encode(code, qrLevel())
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode QR code: %w", err)
+	}
+	return qrCode.PNG(), nil
+}
+
+// WriteQRCodePNG writes a login QR code to a PNG file, creating parent directories as needed
+func WriteQRCodePNG(code, path string) error {
+	data, err := QRCodePNG(code)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create QR output directory: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write QR code to %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// ErrClientLoggedOut is returned for clients that were logged out from the phone and
+// must be paired again
+var ErrClientLoggedOut = errors.New("client logged out, pair it again")
+
+// Default reconnect backoff, overridable with RECONNECT_INTERVAL and RECONNECT_MAX_INTERVAL
+const (
+	DefaultReconnectInterval    = 5 * time.Second
+	DefaultReconnectMaxInterval = 5 * time.Minute
+)
+
+// EnableAutoReconnect starts a watchdog for the client that reconnects it with backoff
+// whenever it drops unexpectedly. Clients that are logged out stop retrying and report
+// ErrClientLoggedOut until they are paired again.
+func (wm *WhatsAppManager) EnableAutoReconnect(phoneID string) error {
+	instance, err := wm.GetClient(phoneID)
+	if err != nil {
+		return err
+	}
+
+	instance.mu.Lock()
+	defer instance.mu.Unlock()
+
+	if instance.LoggedOut {
+		return fmt.Errorf("%w: %s", ErrClientLoggedOut, phoneID)
+	}
+	// The watchdog replaces whatsmeow's built-in reconnect so there is one retry loop
+	instance.autoReconnect = true
+	instance.Client.EnableAutoReconnect = false
+	return nil
+}
+
+// DisableAutoReconnect stops reconnecting the client, cancelling a retry in progress
+func (wm *WhatsAppManager) DisableAutoReconnect(phoneID string) error {
+	instance, err := wm.GetClient(phoneID)
+	if err != nil {
+		return err
+	}
+
+	instance.mu.Lock()
+	defer instance.mu.Unlock()
+
+	instance.autoReconnect = false
+	instance.stopReconnecting()
+	return nil
+}
+
+// SetReconnectInterval sets the delay before the first reconnect attempt and the cap the
+// delay doubles up to after each failure
+func (wm *WhatsAppManager) SetReconnectInterval(interval, maxInterval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultReconnectInterval
+	}
+	if maxInterval < interval {
+		maxInterval = interval
+	}
+
+	wm.handlerMu.Lock()
+	defer wm.handlerMu.Unlock()
+	wm.reconnectInterval, wm.reconnectMaxInterval = interval, maxInterval
+}
+
+func (wm *WhatsAppManager) reconnectBackoff() (time.Duration, time.Duration) {
+	wm.handlerMu.RLock()
+	defer wm.handlerMu.RUnlock()
+	return wm.reconnectInterval, wm.reconnectMaxInterval
+}
+
+// IsLoggedOut reports whether the client was logged out and needs to be paired again
+func (wm *WhatsAppManager) IsLoggedOut(phoneID string) (bool, error) {
+	instance, err := wm.GetClient(phoneID)
+	if err != nil {
+		return false, err
+	}
+
+	instance.mu.RLock()
+	defer instance.mu.RUnlock()
+	return instance.LoggedOut, nil
+}
+
+// startReconnecting launches the reconnect loop after an unexpected disconnect; callers
+// must hold instance.mu
+func (wm *WhatsAppManager) startReconnecting(instance *WhatsAppInstance) {
+	if !instance.autoReconnect || instance.LoggedOut || instance.reconnectCancel != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	instance.reconnectCancel = cancel
+	go wm.reconnectLoop(ctx, instance)
+}
+
+// stopReconnecting cancels a running reconnect loop; callers must hold instance.mu
+func (wi *WhatsAppInstance) stopReconnecting() {
+	if wi.reconnectCancel != nil {
+		wi.reconnectCancel()
+		wi.reconnectCancel = nil
+	}
+}
+
+// reconnectLoop retries connecting until it succeeds, the client is logged out or the
+// loop is cancelled by a manual disconnect
+func (wm *WhatsAppManager) reconnectLoop(ctx context.Context, instance *WhatsAppInstance) {
+	delay, maxDelay := wm.reconnectBackoff()
+	for attempt := 1; ; attempt++ {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+
+		instance.mu.Lock()
+		if ctx.Err() != nil {
+			instance.mu.Unlock()
+			return
+		}
+		if instance.Connected || instance.LoggedOut || instance.Client.Store.ID == nil {
+			instance.stopReconnecting()
+			instance.mu.Unlock()
+			return
+		}
+
+		log.Printf("Reconnecting WhatsApp client %s (attempt %d)", instance.PhoneID, attempt)
+		err := instance.Client.Connect()
+		if err == nil {
+			// The Connected or LoggedOut event reports how the login went
+			instance.stopReconnecting()
+			instance.mu.Unlock()
+			return
+		}
+		instance.mu.Unlock()
+
+		log.Printf("Failed to reconnect WhatsApp client %s: %v", instance.PhoneID, err)
+		delay = min(delay*2, maxDelay)
+	}
+}
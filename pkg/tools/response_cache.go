@@ -0,0 +1,136 @@
+package tools
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CacheStats reports response cache activity for a chat
+type CacheStats struct {
+	Hits    int
+	Misses  int
+	Entries int
+}
+
+type cacheEntry struct {
+	chatKey   string
+	response  string
+	createdAt time.Time
+}
+
+// ResponseCache stores AI replies per chat so repeated questions can be answered
+// without another API call
+type ResponseCache struct {
+	entries    map[string]cacheEntry
+	stats      map[string]*CacheStats
+	ttl        time.Duration
+	maxEntries int
+	mu         sync.Mutex
+}
+
+// NewResponseCache creates a cache whose entries expire after ttl
+func NewResponseCache(ttl time.Duration, maxEntries int) *ResponseCache {
+	if maxEntries <= 0 {
+		maxEntries = 500
+	}
+
+	return &ResponseCache{
+		entries:    make(map[string]cacheEntry),
+		stats:      make(map[string]*CacheStats),
+		ttl:        ttl,
+		maxEntries: maxEntries,
+	}
+}
+
+// ResponseCacheKey derives a cache key from everything that shapes the answer, so a
+// change of model or system prompt never serves a reply produced under another configuration
+func ResponseCacheKey(model, systemPrompt, message string) string {
+	normalized := strings.ToLower(strings.Join(strings.Fields(message), " "))
+	sum := sha256.Sum256([]byte(model + "\x00" + systemPrompt + "\x00" + normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+func (rc *ResponseCache) chatStats(chatKey string) *CacheStats {
+	stats, ok := rc.stats[chatKey]
+	if !ok {
+		stats = &CacheStats{}
+		rc.stats[chatKey] = stats
+	}
+	return stats
+}
+
+// Get returns a cached response for the chat and records a hit or miss
+func (rc *ResponseCache) Get(chatKey, key string) (string, bool) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	stats := rc.chatStats(chatKey)
+	entry, ok := rc.entries[chatKey+"|"+key]
+	if ok && rc.ttl > 0 && time.Since(entry.createdAt) > rc.ttl {
+		delete(rc.entries, chatKey+"|"+key)
+		ok = false
+	}
+
+	if !ok {
+		stats.Misses++
+		return "", false
+	}
+	stats.Hits++
+	return entry.response, true
+}
+
+// Put stores a response for the chat, evicting the oldest entry when the cache is full
+func (rc *ResponseCache) Put(chatKey, key, response string) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	if len(rc.entries) >= rc.maxEntries {
+		var oldestKey string
+		var oldest time.Time
+		for k, entry := range rc.entries {
+			if oldestKey == "" || entry.createdAt.Before(oldest) {
+				oldestKey, oldest = k, entry.createdAt
+			}
+		}
+		delete(rc.entries, oldestKey)
+	}
+
+	rc.entries[chatKey+"|"+key] = cacheEntry{
+		chatKey:   chatKey,
+		response:  response,
+		createdAt: time.Now(),
+	}
+}
+
+// ClearChat removes all cached responses for a chat and returns how many were removed
+func (rc *ResponseCache) ClearChat(chatKey string) int {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	removed := 0
+	for k, entry := range rc.entries {
+		if entry.chatKey == chatKey {
+			delete(rc.entries, k)
+			removed++
+		}
+	}
+	delete(rc.stats, chatKey)
+	return removed
+}
+
+// Stats returns hit/miss counters and the number of cached entries for a chat
+func (rc *ResponseCache) Stats(chatKey string) CacheStats {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	stats := *rc.chatStats(chatKey)
+	for _, entry := range rc.entries {
+		if entry.chatKey == chatKey {
+			stats.Entries++
+		}
+	}
+	return stats
+}
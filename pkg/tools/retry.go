@@ -0,0 +1,88 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"time"
+
+	"github.com/openai/openai-go"
+)
+
+// RetryPolicy controls how transient AI API errors are retried
+type RetryPolicy struct {
+	MaxAttempts int           // total attempts, including the first
+	BaseDelay   time.Duration // delay before the first retry; doubles on every retry
+	MaxDelay    time.Duration
+}
+
+// retryPolicyFromEnv reads AI_MAX_ATTEMPTS (default 3) and AI_RETRY_BASE_DELAY (default 1s)
+func retryPolicyFromEnv() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: EnvInt("AI_MAX_ATTEMPTS", 3),
+		BaseDelay:   EnvDuration("AI_RETRY_BASE_DELAY", time.Second),
+		MaxDelay:    30 * time.Second,
+	}
+}
+
+// backoff returns the delay before retry n (1-based), with up to 20% jitter
+func (p RetryPolicy) backoff(n int) time.Duration {
+	delay := p.BaseDelay << (n - 1)
+	if delay <= 0 || (p.MaxDelay > 0 && delay > p.MaxDelay) {
+		delay = p.MaxDelay
+	}
+	return delay + time.Duration(rand.Int63n(int64(delay)/5+1))
+}
+
+// Do runs op until it succeeds, fails with a non-retryable error, the attempts run out
+// or ctx is done
+func (p RetryPolicy) Do(ctx context.Context, name string, op func() error) error {
+	attempts := max(p.MaxAttempts, 1)
+	for attempt := 1; ; attempt++ {
+		err := op()
+		if err == nil || attempt >= attempts || !IsRetryableAIError(err) {
+			return err
+		}
+
+		delay := p.backoff(attempt)
+		fmt.Printf("%s: attempt %d/%d failed, retrying in %s: %v\n", name, attempt, attempts, delay.Round(time.Millisecond), err)
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w (gave up retrying: %w)", err, ctx.Err())
+		case <-time.After(delay):
+		}
+	}
+}
+
+// streamInterruptedError marks a stream that failed after delivering output; it is never retried
+type streamInterruptedError struct{ err error }
+
+func (e *streamInterruptedError) Error() string { return e.err.Error() }
+func (e *streamInterruptedError) Unwrap() error { return e.err }
+
+// IsRetryableAIError reports whether an AI API error is transient: rate limiting, server
+// errors and network failures. Client errors and cancellation are not retried.
+func IsRetryableAIError(err error) bool {
+	var interrupted *streamInterruptedError
+	if errors.As(err, &interrupted) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var openaiErr *openai.Error
+	if errors.As(err, &openaiErr) {
+		return isRetryableStatus(openaiErr.StatusCode)
+	}
+	var anthropicErr *AnthropicError
+	if errors.As(err, &anthropicErr) {
+		return isRetryableStatus(anthropicErr.StatusCode)
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+func isRetryableStatus(code int) bool {
+	return code == 408 || code == 429 || code >= 500
+}
@@ -0,0 +1,84 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyDo(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+	overloaded := &AnthropicError{StatusCode: 529, Type: "overloaded_error"}
+	badRequest := &AnthropicError{StatusCode: 400, Type: "invalid_request_error"}
+
+	t.Run("succeeds after transient failures", func(t *testing.T) {
+		calls := 0
+		err := policy.Do(context.Background(), "test", func() error {
+			if calls++; calls <= 2 {
+				return overloaded
+			}
+			return nil
+		})
+		if err != nil || calls != 3 {
+			t.Errorf("Do = %v after %d calls, want success on the third", err, calls)
+		}
+	})
+
+	t.Run("client error surfaces immediately", func(t *testing.T) {
+		calls := 0
+		err := policy.Do(context.Background(), "test", func() error {
+			calls++
+			return badRequest
+		})
+		if !errors.Is(err, badRequest) || calls != 1 {
+			t.Errorf("Do = %v after %d calls, want the 400 after one call", err, calls)
+		}
+	})
+
+	t.Run("gives up after the last attempt", func(t *testing.T) {
+		calls := 0
+		err := policy.Do(context.Background(), "test", func() error {
+			calls++
+			return overloaded
+		})
+		if !errors.Is(err, overloaded) || calls != 3 {
+			t.Errorf("Do = %v after %d calls, want the error after 3", err, calls)
+		}
+	})
+
+	t.Run("cancelled context stops retrying", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		slow := RetryPolicy{MaxAttempts: 5, BaseDelay: time.Hour, MaxDelay: time.Hour}
+		calls := 0
+		err := slow.Do(ctx, "test", func() error {
+			calls++
+			cancel()
+			return overloaded
+		})
+		if !errors.Is(err, context.Canceled) || !errors.Is(err, overloaded) || calls != 1 {
+			t.Errorf("Do = %v after %d calls, want the error and the cancellation after one call", err, calls)
+		}
+	})
+}
+
+func TestIsRetryableAIError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&AnthropicError{StatusCode: 429}, true},
+		{&AnthropicError{StatusCode: 500}, true},
+		{&AnthropicError{StatusCode: 408}, true},
+		{&AnthropicError{StatusCode: 400}, false},
+		{&AnthropicError{StatusCode: 401}, false},
+		{context.Canceled, false},
+		{&streamInterruptedError{err: &AnthropicError{StatusCode: 500}}, false},
+		{errors.New("something else"), false},
+	}
+	for _, tt := range tests {
+		if got := IsRetryableAIError(tt.err); got != tt.want {
+			t.Errorf("IsRetryableAIError(%v) = %t, want %t", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package tools
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+// ScheduledMessage is a recurring message persisted by the Scheduler
+type ScheduledMessage struct {
+	ID        string    `json:"id"`
+	PhoneID   string    `json:"phoneID"`
+	To        string    `json:"to"`
+	Text      string    `json:"text"`
+	Spec      string    `json:"spec"`
+	NextRun   time.Time `json:"nextRun"`
+	LastRun   time.Time `json:"lastRun,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// SendFunc delivers a text message from a client
+type SendFunc func(phoneID string, to types.JID, text string) error
+
+// Scheduler fires recurring messages and persists them to a JSON file so they
+// survive restarts
+type Scheduler struct {
+	path      string
+	loc       *time.Location
+	send      SendFunc
+	jobs      map[string]*ScheduledMessage
+	schedules map[string]*CronSchedule
+	mu        sync.Mutex
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+// NewScheduler creates a scheduler storing its jobs at path and evaluating specs in loc
+func NewScheduler(path string, loc *time.Location, send SendFunc) *Scheduler {
+	if loc == nil {
+		loc = time.Local
+	}
+
+	return &Scheduler{
+		path:      path,
+		loc:       loc,
+		send:      send,
+		jobs:      make(map[string]*ScheduledMessage),
+		schedules: make(map[string]*CronSchedule),
+	}
+}
+
+// Load reads persisted jobs; a missing file is not an error. Runs missed while the
+// process was down are skipped and rescheduled from now.
+func (s *Scheduler) Load() error {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read schedules %s: %w", s.path, err)
+	}
+
+	var jobs []*ScheduledMessage
+	if err := json.Unmarshal(data, &jobs); err != nil {
+		return fmt.Errorf("failed to parse schedules %s: %w", s.path, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for _, job := range jobs {
+		schedule, err := ParseCronSpec(job.Spec, s.loc)
+		if err != nil {
+			log.Printf("Dropping schedule %s: %v", job.ID, err)
+			continue
+		}
+		if job.NextRun.Before(now) {
+			log.Printf("Schedule %s missed a run at %s, rescheduling", job.ID, job.NextRun.Format(time.RFC3339))
+			job.NextRun = schedule.Next(now)
+		}
+		s.jobs[job.ID] = job
+		s.schedules[job.ID] = schedule
+	}
+	return nil
+}
+
+// save writes all jobs to disk; callers must hold s.mu
+func (s *Scheduler) save() error {
+	data, err := json.MarshalIndent(s.sortedJobs(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal schedules: %w", err)
+	}
+
+	if err := os.WriteFile(s.path, data, 0644); err != nil {
+		return fmt.Errorf("failed to save schedules to %s: %w", s.path, err)
+	}
+	return nil
+}
+
+// sortedJobs returns copies of all jobs ordered by next run; callers must hold s.mu
+func (s *Scheduler) sortedJobs() []ScheduledMessage {
+	jobs := make([]ScheduledMessage, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		jobs = append(jobs, *job)
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].NextRun.Before(jobs[j].NextRun) })
+	return jobs
+}
+
+// Add schedules a recurring message and returns its ID
+func (s *Scheduler) Add(phoneID string, to types.JID, text, spec string) (string, error) {
+	schedule, err := ParseCronSpec(spec, s.loc)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	next := schedule.Next(now)
+	if next.IsZero() {
+		return "", fmt.Errorf("cron spec %q never fires", spec)
+	}
+
+	idBytes := make([]byte, 4)
+	if _, err := rand.Read(idBytes); err != nil {
+		return "", fmt.Errorf("failed to generate schedule ID: %w", err)
+	}
+
+	job := &ScheduledMessage{
+		ID:        hex.EncodeToString(idBytes),
+		PhoneID:   phoneID,
+		To:        to.String(),
+		Text:      text,
+		Spec:      spec,
+		NextRun:   next,
+		CreatedAt: now,
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.jobs[job.ID] = job
+	s.schedules[job.ID] = schedule
+	if err := s.save(); err != nil {
+		delete(s.jobs, job.ID)
+		delete(s.schedules, job.ID)
+		return "", err
+	}
+	return job.ID, nil
+}
+
+// Cancel removes a scheduled message
+func (s *Scheduler) Cancel(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.jobs[id]; !exists {
+		return fmt.Errorf("schedule %s not found", id)
+	}
+	delete(s.jobs, id)
+	delete(s.schedules, id)
+	return s.save()
+}
+
+// List returns all scheduled messages ordered by next run
+func (s *Scheduler) List() []ScheduledMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sortedJobs()
+}
+
+// Start runs due jobs every interval until Stop is called
+func (s *Scheduler) Start(interval time.Duration) {
+	s.mu.Lock()
+	if s.stop != nil {
+		s.mu.Unlock()
+		return
+	}
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+	stop, done := s.stop, s.done
+	s.mu.Unlock()
+
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				s.runDue(time.Now())
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop halts the background loop started by Start, waiting for sends in progress
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	if s.stop == nil {
+		s.mu.Unlock()
+		return
+	}
+	close(s.stop)
+	done := s.done
+	s.stop, s.done = nil, nil
+	s.mu.Unlock()
+
+	<-done
+}
+
+// runDue sends every job whose next run has passed and advances it to its next fire time
+func (s *Scheduler) runDue(now time.Time) {
+	s.mu.Lock()
+	var due []ScheduledMessage
+	for id, job := range s.jobs {
+		if job.NextRun.After(now) {
+			continue
+		}
+		due = append(due, *job)
+		job.LastRun = now
+		job.NextRun = s.schedules[id].Next(now)
+	}
+	if len(due) > 0 {
+		if err := s.save(); err != nil {
+			log.Printf("Failed to persist schedules: %v", err)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, job := range due {
+		to, err := types.ParseJID(job.To)
+		if err != nil {
+			log.Printf("Schedule %s has invalid recipient %s: %v", job.ID, job.To, err)
+			continue
+		}
+		if err := s.send(job.PhoneID, to, job.Text); err != nil {
+			log.Printf("Failed to send scheduled message %s: %v", job.ID, err)
+			continue
+		}
+		log.Printf("Sent scheduled message %s from %s to %s", job.ID, job.PhoneID, job.To)
+	}
+}
@@ -0,0 +1,135 @@
+package tools
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/webp"
+)
+
+// WebP VP8X feature flags
+const (
+	webpFlagAlpha     = 0x10
+	webpFlagAnimation = 0x02
+)
+
+// webpChunk is a RIFF chunk inside a WebP file
+type webpChunk struct {
+	fourCC  string
+	payload []byte
+}
+
+// parseWebPChunks splits RIFF chunk data into chunks
+func parseWebPChunks(data []byte) ([]webpChunk, error) {
+	var chunks []webpChunk
+	for len(data) >= 8 {
+		size := int(binary.LittleEndian.Uint32(data[4:8]))
+		if size > len(data)-8 {
+			return nil, fmt.Errorf("truncated webp chunk %q", data[:4])
+		}
+		chunks = append(chunks, webpChunk{fourCC: string(data[:4]), payload: data[8 : 8+size]})
+		data = data[8+size+size%2:] // chunks are padded to an even size
+	}
+	return chunks, nil
+}
+
+// webpChunks returns the top-level chunks of a WebP file
+func webpChunks(data []byte) ([]webpChunk, error) {
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, fmt.Errorf("not a webp image")
+	}
+	return parseWebPChunks(data[12:])
+}
+
+// IsAnimatedWebP reports whether data is an animated WebP image
+func IsAnimatedWebP(data []byte) bool {
+	chunks, err := webpChunks(data)
+	if err != nil || len(chunks) == 0 || chunks[0].fourCC != "VP8X" || len(chunks[0].payload) < 1 {
+		return false
+	}
+	return chunks[0].payload[0]&webpFlagAnimation != 0
+}
+
+// FirstWebPFrame extracts the first frame of an animated WebP as a still WebP image
+func FirstWebPFrame(data []byte) ([]byte, error) {
+	chunks, err := webpChunks(data)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, chunk := range chunks {
+		if chunk.fourCC != "ANMF" || len(chunk.payload) < 16 {
+			continue
+		}
+		// Frame header: X, Y, width-1, height-1, duration (24 bits each) and flags
+		header := chunk.payload[:16]
+		frameChunks, err := parseWebPChunks(chunk.payload[16:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid webp frame: %w", err)
+		}
+
+		var out bytes.Buffer
+		hasAlpha := false
+		for _, fc := range frameChunks {
+			if fc.fourCC == "ALPH" {
+				hasAlpha = true
+			}
+		}
+		if hasAlpha {
+			// Lossy frames with alpha need an extended header declaring the canvas
+			vp8x := make([]byte, 10)
+			vp8x[0] = webpFlagAlpha
+			copy(vp8x[4:10], header[6:12])
+			writeWebPChunk(&out, "VP8X", vp8x)
+		}
+		for _, fc := range frameChunks {
+			if fc.fourCC == "ALPH" || fc.fourCC == "VP8 " || fc.fourCC == "VP8L" {
+				writeWebPChunk(&out, fc.fourCC, fc.payload)
+			}
+		}
+
+		var file bytes.Buffer
+		file.WriteString("RIFF")
+		binary.Write(&file, binary.LittleEndian, uint32(4+out.Len()))
+		file.WriteString("WEBP")
+		file.Write(out.Bytes())
+		return file.Bytes(), nil
+	}
+	return nil, fmt.Errorf("animated webp has no frames")
+}
+
+func writeWebPChunk(buf *bytes.Buffer, fourCC string, payload []byte) {
+	buf.WriteString(fourCC)
+	binary.Write(buf, binary.LittleEndian, uint32(len(payload)))
+	buf.Write(payload)
+	if len(payload)%2 == 1 {
+		buf.WriteByte(0)
+	}
+}
+
+// StickerToJPEG converts a WebP sticker to a JPEG on a white background so the AI can
+// see it; animated stickers use their first frame
+func StickerToJPEG(data []byte) ([]byte, error) {
+	if IsAnimatedWebP(data) {
+		frame, err := FirstWebPFrame(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to extract sticker frame: %w", err)
+		}
+		data = frame
+	}
+
+	img, err := webp.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode sticker: %w", err)
+	}
+
+	// JPEG has no alpha channel; transparent areas would otherwise turn black
+	canvas := image.NewRGBA(img.Bounds())
+	draw.Draw(canvas, canvas.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(canvas, canvas.Bounds(), img, img.Bounds().Min, draw.Over)
+	return encodeImage(canvas, OptimizedQuality)
+}
@@ -0,0 +1,154 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/shared"
+)
+
+// MaxToolRounds limits how many rounds of tool calls ProcessWithTools runs before giving up
+const MaxToolRounds = 5
+
+// ToolSchema describes a tool to the model; Parameters is a JSON schema object
+type ToolSchema struct {
+	Description string
+	Parameters  map[string]any
+}
+
+// ToolHandler runs a tool call for a chat with the model's JSON arguments and returns the
+// result passed back to the model
+type ToolHandler func(ctx context.Context, chat string, args json.RawMessage) (string, error)
+
+type registeredTool struct {
+	schema  ToolSchema
+	handler ToolHandler
+}
+
+// toolRegistry holds the registered tools; it is shared by all copies of an AITools
+type toolRegistry struct {
+	mu    sync.RWMutex
+	tools map[string]registeredTool
+}
+
+func newToolRegistry() *toolRegistry {
+	return &toolRegistry{tools: make(map[string]registeredTool)}
+}
+
+// RegisterTool makes a Go function available to the model in ProcessWithTools,
+// replacing any tool registered under the same name
+func (at *AITools) RegisterTool(name string, schema ToolSchema, handler ToolHandler) {
+	at.toolRegistry.mu.Lock()
+	defer at.toolRegistry.mu.Unlock()
+	at.toolRegistry.tools[name] = registeredTool{schema: schema, handler: handler}
+}
+
+// ToolNames lists the registered tools
+func (at *AITools) ToolNames() []string {
+	at.toolRegistry.mu.RLock()
+	defer at.toolRegistry.mu.RUnlock()
+
+	names := make([]string, 0, len(at.toolRegistry.tools))
+	for name := range at.toolRegistry.tools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// toolParams returns the registered tools in the OpenAI request format
+func (at *AITools) toolParams() []openai.ChatCompletionToolParam {
+	at.toolRegistry.mu.RLock()
+	defer at.toolRegistry.mu.RUnlock()
+
+	params := make([]openai.ChatCompletionToolParam, 0, len(at.toolRegistry.tools))
+	for name, tool := range at.toolRegistry.tools {
+		parameters := tool.schema.Parameters
+		if parameters == nil {
+			parameters = map[string]any{"type": "object", "properties": map[string]any{}}
+		}
+		params = append(params, openai.ChatCompletionToolParam{
+			Function: shared.FunctionDefinitionParam{
+				Name:        name,
+				Description: openai.String(tool.schema.Description),
+				Parameters:  shared.FunctionParameters(parameters),
+			},
+		})
+	}
+	sort.Slice(params, func(i, j int) bool { return params[i].Function.Name < params[j].Function.Name })
+	return params
+}
+
+// callTool runs a tool call. Failures are returned as the result so the model can
+// explain them or try something else.
+func (at *AITools) callTool(ctx context.Context, name, arguments string) string {
+	at.toolRegistry.mu.RLock()
+	tool, ok := at.toolRegistry.tools[name]
+	at.toolRegistry.mu.RUnlock()
+	if !ok {
+		return fmt.Sprintf("error: unknown tool %q", name)
+	}
+
+	if strings.TrimSpace(arguments) == "" {
+		arguments = "{}"
+	}
+	result, err := tool.handler(ctx, at.chatKey, json.RawMessage(arguments))
+	if err != nil {
+		fmt.Printf("Tool %s failed: %v\n", name, err)
+		return fmt.Sprintf("error: %v", err)
+	}
+	return result
+}
+
+// ProcessWithTools answers a text message like ProcessTextWithAI but lets the model call
+// the registered tools, feeding their results back until it answers in text. Tool calling
+// requires the OpenAI API.
+func (at *AITools) ProcessWithTools(ctx context.Context, userMessage string, referencedImages []map[string]string, history []openai.ChatCompletionMessageParamUnion) (string, error) {
+	client, err := at.openAIClient("tool calling")
+	if err != nil {
+		return "", err
+	}
+
+	req := at.textRequest(userMessage, referencedImages, history)
+	params := openAIParams(req)
+	params.Tools = at.toolParams()
+
+	for round := 0; round < MaxToolRounds; round++ {
+		var resp *openai.ChatCompletion
+		err := at.retry.Do(ctx, "ProcessWithTools", func() (err error) {
+			countAIRequest()
+			resp, err = client.Chat.Completions.New(ctx, params)
+			return err
+		})
+		if err != nil {
+			return "", fmt.Errorf("tool calling AI API error: %w", err)
+		}
+		at.recordUsage(openAIUsage(resp.Usage))
+		if len(resp.Choices) == 0 {
+			break
+		}
+
+		message := resp.Choices[0].Message
+		if len(message.ToolCalls) == 0 {
+			if response := strings.TrimSpace(message.Content); response != "" {
+				return response, nil
+			}
+			break
+		}
+
+		params.Messages = append(params.Messages, message.ToParam())
+		for _, call := range message.ToolCalls {
+			fmt.Printf("ProcessWithTools: calling %s(%s)\n", call.Function.Name, call.Function.Arguments)
+			result := at.callTool(ctx, call.Function.Name, call.Function.Arguments)
+			params.Messages = append(params.Messages, openai.ToolMessage(result, call.ID))
+		}
+	}
+
+	fmt.Printf("ProcessWithTools: no text answer after %d rounds of tool calls\n", MaxToolRounds)
+	return "Maaf, saya tidak dapat merespons pesan tersebut saat ini.", nil
+}
@@ -0,0 +1,70 @@
+package tools
+
+import "sync"
+
+// TokenUsage is the number of tokens spent on AI completions
+type TokenUsage struct {
+	PromptTokens     int64
+	CompletionTokens int64
+	TotalTokens      int64
+	Requests         int64
+}
+
+// Add returns the sum of two usages
+func (u TokenUsage) Add(o TokenUsage) TokenUsage {
+	return TokenUsage{
+		PromptTokens:     u.PromptTokens + o.PromptTokens,
+		CompletionTokens: u.CompletionTokens + o.CompletionTokens,
+		TotalTokens:      u.TotalTokens + o.TotalTokens,
+		Requests:         u.Requests + o.Requests,
+	}
+}
+
+// usageStats accumulates token usage per chat; it is shared by all copies of an AITools
+type usageStats struct {
+	mu     sync.Mutex
+	byChat map[string]TokenUsage
+}
+
+func newUsageStats() *usageStats {
+	return &usageStats{byChat: make(map[string]TokenUsage)}
+}
+
+func (s *usageStats) add(chatKey string, u TokenUsage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.byChat[chatKey] = s.byChat[chatKey].Add(u)
+}
+
+func (s *usageStats) snapshot() map[string]TokenUsage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats := make(map[string]TokenUsage, len(s.byChat))
+	for chatKey, u := range s.byChat {
+		stats[chatKey] = u
+	}
+	return stats
+}
+
+// ForChat returns a copy of the AI tools acting for chatKey, recording token usage and
+// scoping tool calls to that chat
+func (at *AITools) ForChat(chatKey string) *AITools {
+	clone := at.copy()
+	clone.chatKey = chatKey
+	return &clone
+}
+
+// GetUsageStats returns the tokens spent so far, keyed by chat JID. Requests made
+// without a chat are counted under "".
+func (at *AITools) GetUsageStats() map[string]TokenUsage {
+	return at.usage.snapshot()
+}
+
+// recordUsage adds one completion's usage to the current chat's totals
+func (at *AITools) recordUsage(u TokenUsage) {
+	u.Requests = 1
+	if u.TotalTokens == 0 {
+		u.TotalTokens = u.PromptTokens + u.CompletionTokens
+	}
+	at.usage.add(at.chatKey, u)
+}
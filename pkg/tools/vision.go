@@ -0,0 +1,80 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// visionProbeTimeout bounds the startup request checking whether the model accepts images
+const visionProbeTimeout = 30 * time.Second
+
+// visionModeFromEnv reads AI_VISION_SUPPORTED: true (default), false, or probe to ask
+// the model at startup, useful for self-hosted OpenAI-compatible servers
+func visionModeFromEnv() string {
+	mode := strings.ToLower(EnvString("AI_VISION_SUPPORTED", "true"))
+	switch mode {
+	case "true", "false", "probe":
+		return mode
+	default:
+		fmt.Printf("Ignoring AI_VISION_SUPPORTED %q, use true, false or probe\n", mode)
+		return "true"
+	}
+}
+
+// newVisionSupport returns the shared flag recording whether the model accepts images
+func newVisionSupport() *atomic.Bool {
+	supported := new(atomic.Bool)
+	supported.Store(visionModeFromEnv() != "false")
+	return supported
+}
+
+// VisionSupported reports whether images are sent to the model. When they aren't, image
+// messages are answered with ErrorMessageVisionUnsupported and referenced images are left out.
+func (at *AITools) VisionSupported() bool {
+	return at.visionSupported.Load()
+}
+
+// SetVisionSupported records whether the model accepts images, for all chats
+func (at *AITools) SetVisionSupported(supported bool) {
+	at.visionSupported.Store(supported)
+}
+
+// DetectVisionSupport sends a tiny image to the model when AI_VISION_SUPPORTED=probe and
+// records whether it was accepted. Errors other than the model rejecting images leave
+// vision enabled.
+func (at *AITools) DetectVisionSupport(ctx context.Context) error {
+	if visionModeFromEnv() != "probe" {
+		return nil
+	}
+
+	probe, err := encodeImage(image.NewRGBA(image.Rect(0, 0, 8, 8)), LLMQuality)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, visionProbeTimeout)
+	defer cancel()
+	countAIRequest()
+	_, err = at.provider.CompleteWithImage(ctx, CompletionRequest{
+		Model:       at.imageModel(),
+		Text:        "Reply with OK.",
+		MaxTokens:   1,
+		Temperature: at.temperature,
+		ImageDetail: "low",
+		OnUsage:     at.recordUsage,
+	}, ImageInput{MimeType: "image/jpeg", Data: probe})
+	switch {
+	case err == nil:
+		at.SetVisionSupported(true)
+		return nil
+	case isUnsupportedImageError(err):
+		at.SetVisionSupported(false)
+		return nil
+	default:
+		return fmt.Errorf("failed to probe image support: %w", err)
+	}
+}
@@ -0,0 +1,201 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	waProto "go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+)
+
+// WebhookEvent is the JSON payload posted to webhooks for every incoming message
+type WebhookEvent struct {
+	PhoneID   string    `json:"phoneID,omitempty"`
+	MessageID string    `json:"messageID"`
+	Sender    string    `json:"sender"`
+	Chat      string    `json:"chat"`
+	Text      string    `json:"text,omitempty"`
+	Type      string    `json:"type"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// WebhookDispatcher posts incoming message events to per-client webhook URLs,
+// falling back to a global default URL
+type WebhookDispatcher struct {
+	defaultURL string
+	clientURLs map[string]string
+	httpClient *http.Client
+	retry      RetryPolicy
+	mu         sync.RWMutex
+}
+
+// webhookStatusError is a webhook answering with a non-2xx status
+type webhookStatusError struct {
+	url    string
+	status int
+}
+
+func (e *webhookStatusError) Error() string {
+	return fmt.Sprintf("webhook %s returned status %d", e.url, e.status)
+}
+
+// isRetryableWebhookError reports whether a failed delivery may succeed when retried:
+// network errors, timeouts and 408/429/5xx responses, but not other 4xx
+func isRetryableWebhookError(err error) bool {
+	var statusErr *webhookStatusError
+	if errors.As(err, &statusErr) {
+		return isRetryableStatus(statusErr.status)
+	}
+	return true
+}
+
+// NewWebhookDispatcher creates a dispatcher with a global default URL (may be empty).
+// Failed deliveries are retried per WEBHOOK_MAX_ATTEMPTS (default 3) and
+// WEBHOOK_RETRY_DELAY (default 1s, doubling per attempt).
+func NewWebhookDispatcher(defaultURL string, timeout time.Duration) *WebhookDispatcher {
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+
+	return &WebhookDispatcher{
+		defaultURL: defaultURL,
+		clientURLs: make(map[string]string),
+		httpClient: &http.Client{Timeout: timeout},
+		retry: RetryPolicy{
+			MaxAttempts: max(EnvInt("WEBHOOK_MAX_ATTEMPTS", 3), 1),
+			BaseDelay:   EnvDuration("WEBHOOK_RETRY_DELAY", time.Second),
+			MaxDelay:    30 * time.Second,
+		},
+	}
+}
+
+// SetClientURL sets the webhook URL for a client; an empty URL restores the default
+func (wd *WebhookDispatcher) SetClientURL(phoneID, url string) {
+	wd.mu.Lock()
+	defer wd.mu.Unlock()
+
+	if url == "" {
+		delete(wd.clientURLs, phoneID)
+		return
+	}
+	wd.clientURLs[phoneID] = url
+}
+
+// URLFor returns the webhook URL events from a client are sent to
+func (wd *WebhookDispatcher) URLFor(phoneID string) string {
+	wd.mu.RLock()
+	defer wd.mu.RUnlock()
+
+	if url, ok := wd.clientURLs[phoneID]; ok {
+		return url
+	}
+	return wd.defaultURL
+}
+
+// Dispatch posts the event asynchronously so a slow webhook never blocks message handling
+func (wd *WebhookDispatcher) Dispatch(evt WebhookEvent) {
+	url := wd.URLFor(evt.PhoneID)
+	if url == "" {
+		return
+	}
+
+	go func() {
+		if err := wd.deliver(url, evt); err != nil {
+			log.Printf("Failed to deliver webhook for message %s: %v", evt.MessageID, err)
+		}
+	}()
+}
+
+// deliver posts an event, retrying transient failures with backoff
+func (wd *WebhookDispatcher) deliver(url string, evt WebhookEvent) error {
+	for attempt := 1; ; attempt++ {
+		err := wd.post(context.Background(), url, evt)
+		if err == nil || attempt >= wd.retry.MaxAttempts || !isRetryableWebhookError(err) {
+			return err
+		}
+		delay := wd.retry.backoff(attempt)
+		log.Printf("Webhook for message %s failed (attempt %d/%d), retrying in %s: %v", evt.MessageID, attempt, wd.retry.MaxAttempts, delay, err)
+		time.Sleep(delay)
+	}
+}
+
+func (wd *WebhookDispatcher) post(ctx context.Context, url string, evt WebhookEvent) error {
+	body, err := json.Marshal(evt)
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook payload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := wd.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook request to %s failed: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return &webhookStatusError{url: url, status: resp.StatusCode}
+	}
+	return nil
+}
+
+// NewWebhookEvent builds the webhook payload for an incoming message
+func NewWebhookEvent(phoneID string, info types.MessageInfo, msg *waProto.Message) WebhookEvent {
+	msgType, text := MessageSummary(msg)
+	return WebhookEvent{
+		PhoneID:   phoneID,
+		MessageID: info.ID,
+		Sender:    info.Sender.String(),
+		Chat:      info.Chat.String(),
+		Text:      text,
+		Type:      msgType,
+		Timestamp: info.Timestamp,
+	}
+}
+
+// MessageSummary returns a short type name and the text (or caption) of a message
+func MessageSummary(msg *waProto.Message) (string, string) {
+	switch {
+	case msg == nil:
+		return "unknown", ""
+	case msg.GetConversation() != "":
+		return "text", msg.GetConversation()
+	case msg.GetExtendedTextMessage() != nil:
+		return "text", msg.GetExtendedTextMessage().GetText()
+	case msg.GetImageMessage() != nil:
+		return "image", msg.GetImageMessage().GetCaption()
+	case msg.GetVideoMessage() != nil:
+		return "video", msg.GetVideoMessage().GetCaption()
+	case msg.GetAudioMessage() != nil:
+		return "audio", ""
+	case msg.GetDocumentMessage() != nil:
+		return "document", msg.GetDocumentMessage().GetTitle()
+	case msg.GetStickerMessage() != nil:
+		return "sticker", ""
+	case msg.GetContactMessage() != nil:
+		return "contact", msg.GetContactMessage().GetDisplayName()
+	case msg.GetLocationMessage() != nil:
+		return "location", msg.GetLocationMessage().GetName()
+	case msg.GetReactionMessage() != nil:
+		return "reaction", msg.GetReactionMessage().GetText()
+	case msg.GetButtonsResponseMessage() != nil:
+		return "button_reply", msg.GetButtonsResponseMessage().GetSelectedDisplayText()
+	case msg.GetListResponseMessage() != nil:
+		return "list_reply", msg.GetListResponseMessage().GetTitle()
+	case msg.GetTemplateButtonReplyMessage() != nil:
+		return "template_reply", msg.GetTemplateButtonReplyMessage().GetSelectedDisplayText()
+	default:
+		return "other", ""
+	}
+}
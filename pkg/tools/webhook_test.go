@@ -0,0 +1,85 @@
+package tools
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	waProto "go.mau.fi/whatsmeow/proto/waE2E"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestWebhookDispatcherRouting(t *testing.T) {
+	received := make(chan [2]string, 3) // server name and phone ID of each event
+	newServer := func(name string) *httptest.Server {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var evt WebhookEvent
+			if err := json.NewDecoder(r.Body).Decode(&evt); err != nil {
+				t.Errorf("%s: invalid event: %v", name, err)
+			}
+			received <- [2]string{name, evt.PhoneID}
+		}))
+		t.Cleanup(server.Close)
+		return server
+	}
+	defaultServer, salesServer, supportServer := newServer("default"), newServer("sales"), newServer("support")
+
+	wd := NewWebhookDispatcher(defaultServer.URL, time.Second)
+	wd.SetClientURL("sales", salesServer.URL)
+	wd.SetClientURL("support", supportServer.URL)
+
+	want := map[string]string{"sales": "sales", "support": "support", "other": "default"}
+	for phoneID := range want {
+		wd.Dispatch(WebhookEvent{PhoneID: phoneID, MessageID: "MSG_" + phoneID})
+	}
+
+	for range want {
+		select {
+		case got := <-received:
+			if server, phoneID := got[0], got[1]; want[phoneID] != server {
+				t.Errorf("event from %s went to the %s webhook, want %s", phoneID, server, want[phoneID])
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("timed out waiting for webhook deliveries")
+		}
+	}
+}
+
+func TestMessageSummary(t *testing.T) {
+	tests := []struct {
+		name     string
+		message  *waProto.Message
+		wantType string
+		wantText string
+	}{
+		{"nil", nil, "unknown", ""},
+		{"text", &waProto.Message{Conversation: proto.String("hello")}, "text", "hello"},
+		{"extended text", &waProto.Message{ExtendedTextMessage: &waProto.ExtendedTextMessage{Text: proto.String("quoted")}}, "text", "quoted"},
+		{"image", &waProto.Message{ImageMessage: &waProto.ImageMessage{Caption: proto.String("a car")}}, "image", "a car"},
+		{
+			"button reply",
+			&waProto.Message{ButtonsResponseMessage: &waProto.ButtonsResponseMessage{
+				Response: &waProto.ButtonsResponseMessage_SelectedDisplayText{SelectedDisplayText: "Yes"},
+			}},
+			"button_reply", "Yes",
+		},
+		{"list reply", &waProto.Message{ListResponseMessage: &waProto.ListResponseMessage{Title: proto.String("Avanza")}}, "list_reply", "Avanza"},
+		{
+			"template reply",
+			&waProto.Message{TemplateButtonReplyMessage: &waProto.TemplateButtonReplyMessage{SelectedDisplayText: proto.String("Book")}},
+			"template_reply", "Book",
+		},
+		{"other", &waProto.Message{PollUpdateMessage: &waProto.PollUpdateMessage{}}, "other", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotType, gotText := MessageSummary(tt.message)
+			if gotType != tt.wantType || gotText != tt.wantText {
+				t.Errorf("MessageSummary = %q, %q; want %q, %q", gotType, gotText, tt.wantType, tt.wantText)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package tools
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"go.mau.fi/whatsmeow"
 	waProto "go.mau.fi/whatsmeow/proto/waE2E"
@@ -11,36 +12,74 @@ import (
 	"go.mau.fi/whatsmeow/types/events"
 	"log"
 	"os"
+	"path/filepath"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 type WhatsAppDownloader struct {
-	client            *whatsmeow.Client
-	historyImages     map[string]HistoryImageInfo
-	historyImagesMutex sync.RWMutex
+	client              WhatsAppClient
+	historyImages       map[string]HistoryImageInfo
+	historyImagesMutex  sync.RWMutex
+	metadataPath        string      // file the history image index is saved to, see SetHistoryMetadataPath
+	dataDir             string      // directory historical images are downloaded to, see SetDataDir
+	syncWaiters         []chan int  // receive the number of images added by the next on-demand sync
+	downloadRetry       RetryPolicy // see SetDownloadPolicy
+	downloadTimeout     time.Duration
+	mediaRetryMutex     sync.Mutex
+	mediaRetries        map[types.MessageID]chan *events.MediaRetry // downloads waiting for a re-upload
+	prefetchMutex       sync.Mutex
+	prefetchAge         time.Duration // see SetPrefetch
+	prefetchMax         int
+	prefetchConcurrency int
+	prefetching         atomic.Bool
 }
 
-func NewWhatsAppDownloader(client *whatsmeow.Client) *WhatsAppDownloader {
+func NewWhatsAppDownloader(client WhatsAppClient) *WhatsAppDownloader {
+	retry, timeout := downloadPolicyFromEnv()
 	return &WhatsAppDownloader{
-		client:        client,
-		historyImages: make(map[string]HistoryImageInfo),
+		client:              client,
+		historyImages:       make(map[string]HistoryImageInfo),
+		downloadRetry:       retry,
+		downloadTimeout:     timeout,
+		prefetchAge:         EnvDuration("HISTORY_PREFETCH_AGE", 0),
+		prefetchMax:         EnvInt("HISTORY_PREFETCH_MAX", 50),
+		prefetchConcurrency: max(EnvInt("HISTORY_PREFETCH_CONCURRENCY", DefaultPrefetchConcurrency), 1),
 	}
 }
 
+// SetClient replaces the client media is downloaded with, e.g. after a logout
+func (wd *WhatsAppDownloader) SetClient(client WhatsAppClient) {
+	wd.client = client
+}
+
 func (wd *WhatsAppDownloader) DownloadImage(ctx context.Context, msgInfo types.MessageInfo, imgMsg *waProto.ImageMessage) ([]byte, error) {
-	if wd.client == nil {
-		return nil, fmt.Errorf("WhatsApp client not initialized")
-	}
+	return wd.download(ctx, "image", msgInfo, imgMsg)
+}
 
-	// Download the image data
-	data, err := wd.client.Download(ctx, imgMsg)
-	if err != nil {
-		return nil, fmt.Errorf("failed to download image: %w", err)
-	}
+func (wd *WhatsAppDownloader) DownloadAudio(ctx context.Context, msgInfo types.MessageInfo, audioMsg *waProto.AudioMessage) ([]byte, error) {
+	return wd.download(ctx, "audio", msgInfo, audioMsg)
+}
+
+func (wd *WhatsAppDownloader) DownloadVideo(ctx context.Context, msgInfo types.MessageInfo, videoMsg *waProto.VideoMessage) ([]byte, error) {
+	return wd.download(ctx, "video", msgInfo, videoMsg)
+}
+
+func (wd *WhatsAppDownloader) DownloadSticker(ctx context.Context, msgInfo types.MessageInfo, stickerMsg *waProto.StickerMessage) ([]byte, error) {
+	return wd.download(ctx, "sticker", msgInfo, stickerMsg)
+}
+
+func (wd *WhatsAppDownloader) DownloadDocument(ctx context.Context, msgInfo types.MessageInfo, docMsg *waProto.DocumentMessage) ([]byte, error) {
+	return wd.download(ctx, "document", msgInfo, docMsg)
+}
 
-	// data is already []byte, return directly
-	return data, nil
+func (wd *WhatsAppDownloader) GetAudioType(audioMsg *waProto.AudioMessage) string {
+	if audioMsg.Mimetype != nil {
+		return *audioMsg.Mimetype
+	}
+	return "audio/ogg; codecs=opus" // WhatsApp voice note default
 }
 
 func (wd *WhatsAppDownloader) GetImageCaption(imgMsg *waProto.ImageMessage) string {
@@ -78,46 +117,124 @@ func (wd *WhatsAppDownloader) RequestHistorySync(ctx context.Context, lastKnownM
 	return nil
 }
 
+// RequestHistorySyncAndWait requests up to count messages sent before lastKnownMessageInfo
+// and waits for the phone's answer, returning how many new images were indexed. It must
+// not be called from an event handler, which would block the answer from being received.
+func (wd *WhatsAppDownloader) RequestHistorySyncAndWait(ctx context.Context, lastKnownMessageInfo *types.MessageInfo, count int) (int, error) {
+	ch := make(chan int, 1)
+	wd.historyImagesMutex.Lock()
+	wd.syncWaiters = append(wd.syncWaiters, ch)
+	wd.historyImagesMutex.Unlock()
+	defer wd.removeSyncWaiter(ch)
+
+	if err := wd.RequestHistorySync(ctx, lastKnownMessageInfo, count); err != nil {
+		return 0, err
+	}
+
+	select {
+	case added := <-ch:
+		return added, nil
+	case <-ctx.Done():
+		return 0, fmt.Errorf("no history sync received from the phone: %w", ctx.Err())
+	}
+}
+
+// notifySyncWaiters passes the result of an on-demand history sync to waiting requests
+func (wd *WhatsAppDownloader) notifySyncWaiters(added int) {
+	wd.historyImagesMutex.Lock()
+	defer wd.historyImagesMutex.Unlock()
+	for _, ch := range wd.syncWaiters {
+		ch <- added
+	}
+	wd.syncWaiters = nil
+}
+
+func (wd *WhatsAppDownloader) removeSyncWaiter(ch chan int) {
+	wd.historyImagesMutex.Lock()
+	defer wd.historyImagesMutex.Unlock()
+	for i, w := range wd.syncWaiters {
+		if w == ch {
+			wd.syncWaiters = append(wd.syncWaiters[:i], wd.syncWaiters[i+1:]...)
+			return
+		}
+	}
+}
+
+// OldestHistoricalImage returns the earliest indexed image in a chat, the point to
+// request older history from
+func (wd *WhatsAppDownloader) OldestHistoricalImage(chat types.JID) (HistoryImageInfo, bool) {
+	wd.historyImagesMutex.RLock()
+	defer wd.historyImagesMutex.RUnlock()
+
+	var oldest HistoryImageInfo
+	found := false
+	for _, info := range wd.historyImages {
+		if info.ChatJID.ToNonAD() != chat.ToNonAD() {
+			continue
+		}
+		if !found || info.Timestamp.Before(oldest.Timestamp) {
+			oldest, found = info, true
+		}
+	}
+	return oldest, found
+}
+
 // AddHistorySyncHandlers adds event handlers for history sync notifications.
 // This now processes history sync data lazily - it only stores metadata about historical images
 // without downloading them. Images are downloaded on-demand using DownloadHistoricalImageByMessageID().
-func (wd *WhatsAppDownloader) AddHistorySyncHandlers(ctx context.Context) {
+// The handler also passes media re-upload answers to the downloads waiting for them.
+// It returns the handler ID, which can be passed to the client's RemoveEventHandler.
+func (wd *WhatsAppDownloader) AddHistorySyncHandlers(ctx context.Context) uint32 {
 	if wd.client == nil {
 		log.Printf("WhatsApp client not initialized, cannot add history sync handlers")
-		return
+		return 0
 	}
 
-		wd.client.AddEventHandler(func(evt any) {
+	return wd.client.AddEventHandler(func(evt any) {
+		if v, ok := evt.(*events.MediaRetry); ok {
+			wd.handleMediaRetry(v)
+			return
+		}
 		if v, ok := evt.(*events.HistorySync); ok {
 			// The event fires after the history sync blob has been downloaded and decrypted.
 			fmt.Printf("History sync event received. Processing %d conversations for image metadata...\n", len(v.Data.Conversations))
-			_, err := wd.processHistorySyncData(ctx, v.Data)
+			_, added, err := wd.processHistorySyncData(ctx, v.Data)
 			if err != nil {
 				log.Printf("Failed to process history sync data: %v", err)
 				return
 			}
-			fmt.Printf("Successfully processed history sync. Images will be downloaded on-demand.\n")
+			fmt.Printf("Successfully processed history sync (%d new images). Images will be downloaded on-demand.\n", added)
+			wd.persistHistoryMetadata()
+			if v.Data.GetSyncType() == waHistorySync.HistorySync_ON_DEMAND {
+				wd.notifySyncWaiters(added)
+			}
+			wd.prefetchAfterSync(ctx)
 		}
 	})
 }
 
 // HistoryImageInfo stores metadata about historical images without downloading them
 type HistoryImageInfo struct {
-	MessageID  types.MessageID
-	ChatJID    types.JID
-	SenderJID  types.JID
-	Timestamp  time.Time
-	ImageMsg   *waProto.ImageMessage
-	FileName   string
+	MessageID types.MessageID
+	ChatJID   types.JID
+	SenderJID types.JID
+	Timestamp time.Time
+	FromMe    bool // sent by this account, needed to request expired media again
+	ImageMsg  *waProto.ImageMessage
+	FileName  string
+	PHash     uint64 // Perceptual hash, computed from the thumbnail or downloaded image
+	HasPHash  bool
 }
 
-// processHistorySyncData processes the parsed history sync data and stores image metadata for lazy loading
-func (wd *WhatsAppDownloader) processHistorySyncData(ctx context.Context, historySync *waHistorySync.HistorySync) ([]string, error) {
+// processHistorySyncData processes the parsed history sync data and stores image metadata
+// for lazy loading, returning how many images weren't indexed before
+func (wd *WhatsAppDownloader) processHistorySyncData(ctx context.Context, historySync *waHistorySync.HistorySync) ([]string, int, error) {
 	if wd.client == nil {
-		return nil, fmt.Errorf("WhatsApp client not initialized")
+		return nil, 0, fmt.Errorf("WhatsApp client not initialized")
 	}
 
 	var downloadedFiles []string
+	added := 0
 
 	// Process conversations in the history sync
 	for _, conversation := range historySync.Conversations {
@@ -153,21 +270,33 @@ func (wd *WhatsAppDownloader) processHistorySyncData(ctx context.Context, histor
 
 				// Store image metadata for lazy loading instead of downloading immediately
 				timestamp := time.Unix(int64(webMsg.GetMessageTimestamp()), 0)
-				filename := fmt.Sprintf("historical_%s_%s.jpg",
+				filename := wd.imagePath(fmt.Sprintf("historical_%s_%s.jpg",
 					timestamp.Format("20060102_150405"),
-					webMsg.GetKey().GetID())
+					webMsg.GetKey().GetID()))
 
 				imageInfo := HistoryImageInfo{
 					MessageID: msgInfo.ID,
 					ChatJID:   jid,
 					SenderJID: jid,
+					FromMe:    webMsg.GetKey().GetFromMe(),
 					Timestamp: timestamp,
 					ImageMsg:  imgMsg,
 					FileName:  filename,
 				}
 
+				// Index the embedded thumbnail so similarity search works before download
+				if thumb := imgMsg.GetJPEGThumbnail(); len(thumb) > 0 {
+					if hash, err := PerceptualHash(thumb); err == nil {
+						imageInfo.PHash = hash
+						imageInfo.HasPHash = true
+					}
+				}
+
 				// Store the image metadata for later lazy loading
 				wd.historyImagesMutex.Lock()
+				if _, known := wd.historyImages[string(msgInfo.ID)]; !known {
+					added++
+				}
 				wd.historyImages[string(msgInfo.ID)] = imageInfo
 				wd.historyImagesMutex.Unlock()
 
@@ -176,14 +305,14 @@ func (wd *WhatsAppDownloader) processHistorySyncData(ctx context.Context, histor
 		}
 	}
 
-	return downloadedFiles, nil
+	return downloadedFiles, added, nil
 }
 
 // GetHistoricalImageInfo retrieves metadata for a historical image by message ID
 func (wd *WhatsAppDownloader) GetHistoricalImageInfo(messageID types.MessageID) (HistoryImageInfo, bool) {
 	wd.historyImagesMutex.RLock()
 	defer wd.historyImagesMutex.RUnlock()
-	
+
 	imageInfo, exists := wd.historyImages[string(messageID)]
 	return imageInfo, exists
 }
@@ -192,7 +321,7 @@ func (wd *WhatsAppDownloader) GetHistoricalImageInfo(messageID types.MessageID)
 func (wd *WhatsAppDownloader) ListHistoricalImages() []HistoryImageInfo {
 	wd.historyImagesMutex.RLock()
 	defer wd.historyImagesMutex.RUnlock()
-	
+
 	images := make([]HistoryImageInfo, 0, len(wd.historyImages))
 	for _, imageInfo := range wd.historyImages {
 		images = append(images, imageInfo)
@@ -204,17 +333,17 @@ func (wd *WhatsAppDownloader) ListHistoricalImages() []HistoryImageInfo {
 func (wd *WhatsAppDownloader) SaveHistoryMetadata(filename string) error {
 	wd.historyImagesMutex.RLock()
 	defer wd.historyImagesMutex.RUnlock()
-	
+
 	data, err := json.MarshalIndent(wd.historyImages, "", "  ")
 	if err != nil {
 		return fmt.Errorf("failed to marshal history metadata: %w", err)
 	}
-	
+
 	err = os.WriteFile(filename, data, 0644)
 	if err != nil {
 		return fmt.Errorf("failed to save history metadata to %s: %w", filename, err)
 	}
-	
+
 	return nil
 }
 
@@ -224,27 +353,84 @@ func (wd *WhatsAppDownloader) LoadHistoryMetadata(filename string) error {
 	if err != nil {
 		return fmt.Errorf("failed to read history metadata from %s: %w", filename, err)
 	}
-	
+
 	var loadedImages map[string]HistoryImageInfo
 	err = json.Unmarshal(data, &loadedImages)
 	if err != nil {
 		return fmt.Errorf("failed to unmarshal history metadata: %w", err)
 	}
-	
+	if loadedImages == nil {
+		loadedImages = make(map[string]HistoryImageInfo)
+	}
+
 	wd.historyImagesMutex.Lock()
 	wd.historyImages = loadedImages
 	wd.historyImagesMutex.Unlock()
-	
+
+	return nil
+}
+
+// SetDataDir sets the directory historical images are downloaded to, e.g. a client's
+// own data/<phoneID> directory; images indexed earlier keep their path
+func (wd *WhatsAppDownloader) SetDataDir(dir string) {
+	wd.historyImagesMutex.Lock()
+	defer wd.historyImagesMutex.Unlock()
+	wd.dataDir = dir
+}
+
+// DataDir returns the directory historical images are downloaded to
+func (wd *WhatsAppDownloader) DataDir() string {
+	wd.historyImagesMutex.RLock()
+	defer wd.historyImagesMutex.RUnlock()
+	return wd.dataDir
+}
+
+// imagePath returns where an image file is stored, relative to the working directory
+// when no data directory is set
+func (wd *WhatsAppDownloader) imagePath(filename string) string {
+	wd.historyImagesMutex.RLock()
+	defer wd.historyImagesMutex.RUnlock()
+	return filepath.Join(wd.dataDir, filename)
+}
+
+// SetHistoryMetadataPath loads the history image index saved at path, if any, and saves
+// the index there after every history sync and on-demand download from now on
+func (wd *WhatsAppDownloader) SetHistoryMetadataPath(path string) error {
+	wd.historyImagesMutex.Lock()
+	wd.metadataPath = path
+	wd.historyImagesMutex.Unlock()
+
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return nil // first run, nothing saved yet
+	}
+	if err := wd.LoadHistoryMetadata(path); err != nil {
+		return err
+	}
+	fmt.Printf("Loaded %d historical images from %s\n", len(wd.ListHistoricalImages()), path)
 	return nil
 }
 
+// persistHistoryMetadata saves the history image index when a metadata path is set
+func (wd *WhatsAppDownloader) persistHistoryMetadata() {
+	wd.historyImagesMutex.RLock()
+	path := wd.metadataPath
+	wd.historyImagesMutex.RUnlock()
+	if path == "" {
+		return
+	}
+
+	if err := wd.SaveHistoryMetadata(path); err != nil {
+		log.Printf("Failed to persist history metadata: %v", err)
+	}
+}
+
 // DownloadHistoricalImageByMessageID downloads a historical image by its message ID
 func (wd *WhatsAppDownloader) DownloadHistoricalImageByMessageID(ctx context.Context, messageID types.MessageID) (string, error) {
 	imageInfo, exists := wd.GetHistoricalImageInfo(messageID)
 	if !exists {
 		return "", fmt.Errorf("historical image with message ID %s not found", messageID)
 	}
-	
+
 	return wd.DownloadHistoricalImage(ctx, imageInfo)
 }
 
@@ -267,6 +453,8 @@ func (wd *WhatsAppDownloader) DownloadHistoricalImage(ctx context.Context, image
 	}
 	msgInfo.Chat = imageInfo.ChatJID
 	msgInfo.Sender = imageInfo.SenderJID
+	msgInfo.IsFromMe = imageInfo.FromMe
+	msgInfo.IsGroup = imageInfo.ChatJID.Server == types.GroupServer
 
 	// Download the image
 	imageData, err := wd.DownloadImage(ctx, msgInfo, imageInfo.ImageMsg)
@@ -274,16 +462,70 @@ func (wd *WhatsAppDownloader) DownloadHistoricalImage(ctx context.Context, image
 		return "", fmt.Errorf("failed to download historical image %s: %w", imageInfo.MessageID, err)
 	}
 
-	// Save the image to a file
-	err = os.WriteFile(imageInfo.FileName, imageData, 0644)
+	// Save the image to a file, reusing an identical image saved earlier
+	if dir := filepath.Dir(imageInfo.FileName); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return "", fmt.Errorf("failed to create image directory: %w", err)
+		}
+	}
+	savedPath, err := writeImageDeduplicated(imageData, imageInfo.FileName)
 	if err != nil {
 		return "", fmt.Errorf("failed to save historical image %s: %w", imageInfo.FileName, err)
 	}
+	imageInfo.FileName = savedPath
+
+	// Refine the similarity index with the full-resolution image
+	if hash, err := PerceptualHash(imageData); err == nil {
+		imageInfo.PHash = hash
+		imageInfo.HasPHash = true
+	}
+	wd.historyImagesMutex.Lock()
+	wd.historyImages[string(imageInfo.MessageID)] = imageInfo
+	wd.historyImagesMutex.Unlock()
+
+	wd.persistHistoryMetadata()
 
 	fmt.Printf("Downloaded historical image on demand: %s\n", imageInfo.FileName)
 	return imageInfo.FileName, nil
 }
 
+// ImageOwners maps historical image file names to the chat they came from
+func (wd *WhatsAppDownloader) ImageOwners() map[string]string {
+	wd.historyImagesMutex.RLock()
+	defer wd.historyImagesMutex.RUnlock()
+
+	owners := make(map[string]string, len(wd.historyImages))
+	for _, info := range wd.historyImages {
+		owners[filepath.Base(info.FileName)] = info.ChatJID.String()
+	}
+	return owners
+}
+
+// FindSimilarImages returns historical images whose perceptual hash is within threshold
+// bits (Hamming distance) of the given image, closest matches first
+func (wd *WhatsAppDownloader) FindSimilarImages(data []byte, threshold int) []HistoryImageInfo {
+	hash, err := PerceptualHash(data)
+	if err != nil {
+		log.Printf("Failed to hash image for similarity search: %v", err)
+		return nil
+	}
+
+	wd.historyImagesMutex.RLock()
+	defer wd.historyImagesMutex.RUnlock()
+
+	var matches []HistoryImageInfo
+	for _, imageInfo := range wd.historyImages {
+		if imageInfo.HasPHash && HammingDistance(hash, imageInfo.PHash) <= threshold {
+			matches = append(matches, imageInfo)
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		return HammingDistance(hash, matches[i].PHash) < HammingDistance(hash, matches[j].PHash)
+	})
+	return matches
+}
+
 // ProcessHistorySync processes a history sync notification and stores historical image metadata
 func (wd *WhatsAppDownloader) ProcessHistorySync(ctx context.Context, notif *waProto.HistorySyncNotification) ([]string, error) {
 	if wd.client == nil {
@@ -296,5 +538,6 @@ func (wd *WhatsAppDownloader) ProcessHistorySync(ctx context.Context, notif *waP
 		return nil, fmt.Errorf("failed to download history sync: %w", err)
 	}
 
-	return wd.processHistorySyncData(ctx, historySync)
+	files, _, err := wd.processHistorySyncData(ctx, historySync)
+	return files, err
 }
@@ -2,59 +2,251 @@ package tools
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"go.mau.fi/whatsmeow"
+	waProto "go.mau.fi/whatsmeow/proto/waE2E"
 	"go.mau.fi/whatsmeow/store/sqlstore"
+	"go.mau.fi/whatsmeow/types"
 	"go.mau.fi/whatsmeow/types/events"
 	waLog "go.mau.fi/whatsmeow/util/log"
 
 	_ "github.com/mattn/go-sqlite3"
-	"github.com/mdp/qrterminal"
+	"google.golang.org/protobuf/proto"
 )
 
+// ErrClientNotFound is returned when no client is registered under a phone ID
+var ErrClientNotFound = errors.New("client not found")
+
+// ErrClientNotConnected is returned when sending through a client that is not logged in
+var ErrClientNotConnected = errors.New("client not connected")
+
+// ErrClientNotPaired is returned for clients that have never been linked to a WhatsApp account
+var ErrClientNotPaired = errors.New("client not paired yet")
+
 type WhatsAppInstance struct {
-	Client     *whatsmeow.Client
-	Downloader *WhatsAppDownloader
-	Database   string
-	PhoneID    string
-	Connected  bool
-	mu         sync.RWMutex
+	Client       *whatsmeow.Client
+	Downloader   *WhatsAppDownloader
+	AITools      *AITools // nil when no AI provider is configured
+	Database     string
+	PhoneID      string
+	Connected    bool
+	LoggedOut    bool // logged out from the phone; the client must be paired again
+	Config       ClientConfig
+	SystemPrompt string // optional persona, applied with AITools.SetSystemPrompt
+	mu           sync.RWMutex
+	store        *sqlstore.Container
+
+	autoReconnect   bool               // reconnect after unexpected disconnects, see EnableAutoReconnect
+	reconnectCancel context.CancelFunc // stops the running reconnect loop
+
+	handlers   map[uint32]string // registered event handler IDs and their names
+	handlersMu sync.Mutex
+
+	announced atomic.Bool // passed to the OnClientAdded callback and not removed since
+}
+
+// EventHandlerInfo describes an event handler registered on a client
+type EventHandlerInfo struct {
+	ID   uint32
+	Name string
+}
+
+// AddHandler registers an event handler on the client and records it under name
+func (wi *WhatsAppInstance) AddHandler(name string, handler whatsmeow.EventHandler) uint32 {
+	id := wi.Client.AddEventHandler(handler)
+	wi.trackHandler(id, name)
+	return id
 }
 
+// trackHandler records a handler that was registered directly on the client
+func (wi *WhatsAppInstance) trackHandler(id uint32, name string) {
+	if id == 0 {
+		return
+	}
+
+	wi.handlersMu.Lock()
+	defer wi.handlersMu.Unlock()
+
+	if wi.handlers == nil {
+		wi.handlers = make(map[uint32]string)
+	}
+	wi.handlers[id] = name
+}
+
+// RemoveHandler unregisters an event handler, returning false if it wasn't registered
+func (wi *WhatsAppInstance) RemoveHandler(id uint32) bool {
+	wi.handlersMu.Lock()
+	_, tracked := wi.handlers[id]
+	delete(wi.handlers, id)
+	wi.handlersMu.Unlock()
+
+	return wi.Client.RemoveEventHandler(id) || tracked
+}
+
+// removeHandlersNamed unregisters all handlers recorded under name
+func (wi *WhatsAppInstance) removeHandlersNamed(name string) {
+	for _, h := range wi.Handlers() {
+		if h.Name == name {
+			wi.RemoveHandler(h.ID)
+		}
+	}
+}
+
+// Handlers lists the event handlers registered through the instance, ordered by ID
+func (wi *WhatsAppInstance) Handlers() []EventHandlerInfo {
+	wi.handlersMu.Lock()
+	defer wi.handlersMu.Unlock()
+
+	handlers := make([]EventHandlerInfo, 0, len(wi.handlers))
+	for id, name := range wi.handlers {
+		handlers = append(handlers, EventHandlerInfo{ID: id, Name: name})
+	}
+	sort.Slice(handlers, func(i, j int) bool { return handlers[i].ID < handlers[j].ID })
+	return handlers
+}
+
+// Names of the event handlers the manager registers on every connect
+const (
+	historySyncHandlerName = "history-sync"
+	managerHandlerName     = "manager-events"
+)
+
 type WhatsAppManager struct {
 	instances map[string]*WhatsAppInstance
 	mu        sync.RWMutex
 	dbDir     string
+	webhooks  *WebhookDispatcher
+	scheduler *Scheduler
+
+	qrExpiredHandler     func(phoneID string)
+	clientAddedHandler   func(instance *WhatsAppInstance)
+	clientRemovedHandler func(phoneID string)
+	handlerMu            sync.RWMutex
+
+	reconnectInterval    time.Duration
+	reconnectMaxInterval time.Duration
+
+	maxConcurrent int        // clients ConnectAllClients connects at once, guarded by handlerMu
+	qrMu          sync.Mutex // serializes terminal QR prompts
 }
 
+// DefaultMaxConcurrentConnections is how many clients ConnectAllClients connects at once
+const DefaultMaxConcurrentConnections = 4
+
 func NewWhatsAppManager(dbDir string) *WhatsAppManager {
 	if dbDir == "" {
 		dbDir = "./data"
 	}
+	// Saved images live next to the client databases
+	SetDataDir(dbDir)
 
 	// Create database directory if it doesn't exist
 	if err := os.MkdirAll(dbDir, 0755); err != nil {
 		log.Printf("Failed to create database directory: %v", err)
 	}
 
-	return &WhatsAppManager{
+	wm := &WhatsAppManager{
 		instances: make(map[string]*WhatsAppInstance),
 		dbDir:     dbDir,
+		webhooks:  NewWebhookDispatcher(os.Getenv("WEBHOOK_URL"), EnvDuration("WEBHOOK_TIMEOUT", 10*time.Second)),
+	}
+
+	// Recurring messages are evaluated in SCHEDULE_TIMEZONE (e.g. Asia/Jakarta)
+	loc := time.Local
+	if tz := os.Getenv("SCHEDULE_TIMEZONE"); tz != "" {
+		if l, err := time.LoadLocation(tz); err != nil {
+			log.Printf("Invalid SCHEDULE_TIMEZONE %q, using local time: %v", tz, err)
+		} else {
+			loc = l
+		}
+	}
+	wm.SetReconnectInterval(EnvDuration("RECONNECT_INTERVAL", DefaultReconnectInterval), EnvDuration("RECONNECT_MAX_INTERVAL", DefaultReconnectMaxInterval))
+	wm.SetMaxConcurrentConnections(EnvInt("CONNECT_MAX_CONCURRENT", DefaultMaxConcurrentConnections))
+
+	wm.scheduler = NewScheduler(filepath.Join(dbDir, "schedules.json"), loc, wm.SendText)
+	if err := wm.scheduler.Load(); err != nil {
+		log.Printf("Failed to load schedules: %v", err)
 	}
+	wm.scheduler.Start(30 * time.Second)
+
+	return wm
+}
+
+func (wm *WhatsAppManager) clientConfigPath(phoneID string) string {
+	return filepath.Join(wm.dbDir, fmt.Sprintf("config_%s.json", phoneID))
+}
+
+// systemPromptPath returns the per-client system prompt file, e.g. data/system_prompt_628123.txt
+func (wm *WhatsAppManager) systemPromptPath(phoneID string) string {
+	return filepath.Join(wm.dbDir, fmt.Sprintf("system_prompt_%s.txt", phoneID))
+}
+
+// ClientDataDir is where a client's images are stored, e.g. data/<phoneID>, so clients
+// receiving images with the same filename don't overwrite each other
+func (wm *WhatsAppManager) ClientDataDir(phoneID string) string {
+	return filepath.Join(wm.dbDir, phoneID)
+}
+
+// BackupDir is where CleanupDatabases backs up databases by default
+func (wm *WhatsAppManager) BackupDir() string {
+	return filepath.Join(wm.dbDir, "backups")
+}
+
+// historyMetadataPath returns the file a client's history image index is saved to,
+// e.g. data/whatsapp_628123_20251116_122441_a1b2c3_history.json
+func historyMetadataPath(dbPath string) string {
+	return strings.TrimSuffix(dbPath, ".db") + "_history.json"
 }
 
 func (wm *WhatsAppManager) generateDatabaseName(phoneID string) string {
 	timestamp := time.Now().Format("20060102_150405")
-	return fmt.Sprintf("%s/whatsapp_%s_%s.db", wm.dbDir, phoneID, timestamp)
+	suffix := make([]byte, 3)
+	if _, err := rand.Read(suffix); err != nil {
+		return fmt.Sprintf("%s/whatsapp_%s_%s.db", wm.dbDir, phoneID, timestamp)
+	}
+	return fmt.Sprintf("%s/whatsapp_%s_%s_%s.db", wm.dbDir, phoneID, timestamp, hex.EncodeToString(suffix))
+}
+
+// reserveDatabasePath atomically creates an empty database file so no other client or
+// process can initialize a device store at the same path
+func (wm *WhatsAppManager) reserveDatabasePath(phoneID string) (string, error) {
+	for attempt := 0; attempt < 5; attempt++ {
+		dbPath := wm.generateDatabaseName(phoneID)
+		f, err := os.OpenFile(dbPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if errors.Is(err, os.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to create database file %s: %w", dbPath, err)
+		}
+		f.Close()
+		return dbPath, nil
+	}
+	return "", fmt.Errorf("failed to find a free database name for %s", phoneID)
 }
 
 func (wm *WhatsAppManager) AddClient(phoneID string) (*WhatsAppInstance, error) {
+	instance, err := wm.addClient(phoneID)
+	if err != nil {
+		return nil, err
+	}
+	wm.clientAdded(instance)
+	return instance, nil
+}
+
+func (wm *WhatsAppManager) addClient(phoneID string) (*WhatsAppInstance, error) {
 	wm.mu.Lock()
 	defer wm.mu.Unlock()
 
@@ -62,37 +254,75 @@ func (wm *WhatsAppManager) AddClient(phoneID string) (*WhatsAppInstance, error)
 		return nil, fmt.Errorf("client with phoneID %s already exists", phoneID)
 	}
 
-	// Generate unique database name
-	dbPath := wm.generateDatabaseName(phoneID)
+	// Reserve a unique database file
+	dbPath, err := wm.reserveDatabasePath(phoneID)
+	if err != nil {
+		return nil, err
+	}
+
+	instance, err := wm.openClientLocked(phoneID, dbPath)
+	if err != nil {
+		os.Remove(dbPath)
+		return nil, err
+	}
+	return instance, nil
+}
 
+// openClientLocked registers a client backed by the database at dbPath, which may be new
+// or hold an existing session; callers must hold wm.mu
+func (wm *WhatsAppManager) openClientLocked(phoneID, dbPath string) (*WhatsAppInstance, error) {
 	// Create device store with unique database
 	dbLog := waLog.Stdout("DB", "INFO", true)
-	deviceStore, err := sqlstore.New(context.Background(), "sqlite3", dbPath+"?_foreign_keys=on", dbLog)
+	deviceStore, err := sqlstore.New(context.Background(), "sqlite3", dbPath+"?_foreign_keys=on&_busy_timeout=5000", dbLog)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create device store for %s: %w", phoneID, err)
+		return nil, wrapSQLiteError(fmt.Sprintf("failed to create device store for %s", phoneID), err)
 	}
 
 	// Get or create device
 	device, err := deviceStore.GetFirstDevice(context.Background())
 	if err != nil {
-		return nil, fmt.Errorf("failed to get device for %s: %w", phoneID, err)
+		deviceStore.Close()
+		return nil, wrapSQLiteError(fmt.Sprintf("failed to get device for %s", phoneID), err)
 	}
 
 	// Create WhatsApp client
 	client := whatsmeow.NewClient(device, waLog.Noop)
 
-	// Create downloader
+	// Create downloader, restoring the history image index saved next to the database
 	downloader := NewWhatsAppDownloader(client)
+	downloader.SetDataDir(wm.ClientDataDir(phoneID))
+	if err := downloader.SetHistoryMetadataPath(historyMetadataPath(dbPath)); err != nil {
+		log.Printf("Failed to load history metadata for %s: %v", phoneID, err)
+	}
+
+	// Load optional per-client settings
+	config, err := LoadClientConfig(wm.clientConfigPath(phoneID))
+	if err != nil {
+		log.Printf("Using default settings for %s: %v", phoneID, err)
+	}
+	systemPrompt, err := LoadSystemPrompt(wm.systemPromptPath(phoneID))
+	if err != nil {
+		log.Printf("Using default system prompt for %s: %v", phoneID, err)
+	}
 
 	instance := &WhatsAppInstance{
-		Client:     client,
-		Downloader: downloader,
-		Database:   dbPath,
-		PhoneID:    phoneID,
-		Connected:  false,
+		Client:       client,
+		Downloader:   downloader,
+		AITools:      newClientAITools(phoneID, config, systemPrompt, downloader),
+		Database:     dbPath,
+		PhoneID:      phoneID,
+		Connected:    false,
+		Config:       config,
+		SystemPrompt: systemPrompt,
+		store:        deviceStore,
+	}
+	if EnvBool("AUTO_RECONNECT", false) {
+		instance.autoReconnect = true
+		client.EnableAutoReconnect = false
 	}
 
 	wm.instances[phoneID] = instance
+	wm.webhooks.SetClientURL(phoneID, config.WebhookURL)
 
 	log.Printf("Added WhatsApp client for phoneID: %s with database: %s", phoneID, dbPath)
 	return instance, nil
@@ -104,25 +334,32 @@ func (wm *WhatsAppManager) GetClient(phoneID string) (*WhatsAppInstance, error)
 
 	instance, exists := wm.instances[phoneID]
 	if !exists {
-		return nil, fmt.Errorf("client with phoneID %s not found", phoneID)
+		return nil, fmt.Errorf("%w: %s", ErrClientNotFound, phoneID)
 	}
 
 	return instance, nil
 }
 
 func (wm *WhatsAppManager) RemoveClient(phoneID string) error {
+	instance, err := wm.GetClient(phoneID)
+	if err != nil {
+		return err
+	}
+	wm.clientRemoved(instance)
+
 	wm.mu.Lock()
 	defer wm.mu.Unlock()
-
-	instance, exists := wm.instances[phoneID]
-	if !exists {
-		return fmt.Errorf("client with phoneID %s not found", phoneID)
+	if wm.instances[phoneID] != instance {
+		return fmt.Errorf("%w: %s", ErrClientNotFound, phoneID) // removed meanwhile
 	}
 
 	// Disconnect if connected
+	instance.mu.Lock()
+	instance.stopReconnecting()
 	if instance.Connected {
 		instance.Client.Disconnect()
 	}
+	instance.mu.Unlock()
 
 	delete(wm.instances, phoneID)
 	log.Printf("Removed WhatsApp client for phoneID: %s", phoneID)
@@ -135,68 +372,365 @@ func (wm *WhatsAppManager) ConnectClient(phoneID string) error {
 		return err
 	}
 
+	// Only one client shows a QR code at a time so concurrent logins don't interleave on the terminal
+	instance.mu.Lock()
+	needsQR := instance.Client.Store.ID == nil
+	instance.mu.Unlock()
+	if needsQR {
+		wm.qrMu.Lock()
+		defer wm.qrMu.Unlock()
+	}
+
+	return wm.connectClient(phoneID, func(code string) {
+		fmt.Println("Scan this QR code with WhatsApp:")
+		PrintQRCode(code, os.Stdout)
+		fmt.Printf("Client: %s", phoneID)
+		fmt.Println("=====================================")
+	})
+}
+
+// ConnectClientWithQRImage connects a client like ConnectClient but writes each QR code
+// to a PNG file at outputPath instead of the terminal, for headless deployments. The
+// file is removed once the login finishes.
+func (wm *WhatsAppManager) ConnectClientWithQRImage(phoneID, outputPath string) error {
+	defer os.Remove(outputPath)
+
+	return wm.connectClient(phoneID, func(code string) {
+		if err := WriteQRCodePNG(code, outputPath); err != nil {
+			log.Printf("Failed to write QR code for %s: %v", phoneID, err)
+			return
+		}
+		log.Printf("QR code for client %s written to %s", phoneID, outputPath)
+	})
+}
+
+// connectClient connects a client, rendering QR codes with renderQR when a new login is needed
+func (wm *WhatsAppManager) connectClient(phoneID string, renderQR func(code string)) error {
+	instance, err := wm.GetClient(phoneID)
+	if err != nil {
+		return err
+	}
+	wm.clientAdded(instance) // again after LogoutClient
+
 	instance.mu.Lock()
-	defer instance.mu.Unlock()
 
 	if instance.Connected {
+		instance.mu.Unlock()
 		return fmt.Errorf("client %s is already connected", phoneID)
 	}
 
+	wm.registerHandlers(instance, phoneID)
+
+	// Connect to WhatsApp with QR code handling
+	if instance.Client.Store.ID == nil {
+		// No ID stored, new login required
+		qrChan, _ := instance.Client.GetQRChannel(context.Background())
+		err = instance.Client.Connect()
+		instance.mu.Unlock()
+		if err != nil {
+			return fmt.Errorf("failed to connect client %s for QR login: %w", phoneID, err)
+		}
+
+		// Display QR code; the instance lock is released so status queries and the
+		// connection event handlers aren't blocked while waiting for the scan
+		fmt.Printf("\n=== SCAN QR CODE FOR CLIENT: %s ===\n", phoneID)
+		return wm.waitForQRLogin(instance, phoneID, qrChan, renderQR)
+	}
+
+	// Already logged in, just connect
+	defer instance.mu.Unlock()
+	err = instance.Client.Connect()
+	if err != nil {
+		return fmt.Errorf("failed to connect existing client %s: %w", phoneID, err)
+	}
+	return nil
+}
+
+// ConnectClientWithQRCallback connects a client like ConnectClient but hands each QR code
+// to onQR instead of rendering it. It blocks until the login succeeds, fails or expires.
+func (wm *WhatsAppManager) ConnectClientWithQRCallback(phoneID string, onQR func(code string)) error {
+	return wm.connectClient(phoneID, onQR)
+}
+
+// registerHandlers (re)installs the manager's event handlers on a client; callers must hold instance.mu
+func (wm *WhatsAppManager) registerHandlers(instance *WhatsAppInstance, phoneID string) {
+	// Replace handlers from a previous connection so reconnecting doesn't duplicate them
+	instance.removeHandlersNamed(historySyncHandlerName)
+	instance.removeHandlersNamed(managerHandlerName)
+
 	// Add history sync handlers before connecting
 	ctx := context.Background()
-	instance.Downloader.AddHistorySyncHandlers(ctx)
+	instance.trackHandler(instance.Downloader.AddHistorySyncHandlers(ctx), historySyncHandlerName)
 
 	// Add event handlers
-	instance.Client.AddEventHandler(func(evt any) {
-		switch evt.(type) {
+	instance.AddHandler(managerHandlerName, func(evt any) {
+		switch v := evt.(type) {
+		case *events.Message:
+			if !v.Info.IsFromMe {
+				CountMessageReceived()
+				wm.webhooks.Dispatch(NewWebhookEvent(phoneID, v.Info, v.Message))
+			}
 		case *events.Connected:
 			instance.mu.Lock()
 			instance.Connected = true
+			instance.LoggedOut = false
 			instance.mu.Unlock()
 			log.Printf("WhatsApp client %s connected successfully!", phoneID)
 		case *events.Disconnected:
+			// Only unexpected drops are reported; Disconnect() doesn't emit this event
 			instance.mu.Lock()
 			instance.Connected = false
+			wm.startReconnecting(instance)
 			instance.mu.Unlock()
 			log.Printf("WhatsApp client %s disconnected", phoneID)
 		case *events.LoggedOut:
 			instance.mu.Lock()
 			instance.Connected = false
+			instance.LoggedOut = true
+			instance.stopReconnecting()
 			instance.mu.Unlock()
-			log.Printf("WhatsApp client %s was logged out", phoneID)
+			log.Printf("WhatsApp client %s was logged out and must be paired again", phoneID)
 		}
 	})
+}
 
-	// Connect to WhatsApp with QR code handling
-	if instance.Client.Store.ID == nil {
-		// No ID stored, new login required
-		qrChan, _ := instance.Client.GetQRChannel(context.Background())
-		err = instance.Client.Connect()
-		if err != nil {
-			return fmt.Errorf("failed to connect client %s for QR login: %w", phoneID, err)
+// ConnectClientWithPairCode logs in a new client by phone number instead of QR code. It
+// returns the 8-character code to enter in WhatsApp under Linked devices > Link with phone
+// number. The code must be entered within about two minutes.
+func (wm *WhatsAppManager) ConnectClientWithPairCode(phoneID, phoneNumber string) (string, error) {
+	instance, err := wm.GetClient(phoneID)
+	if err != nil {
+		return "", err
+	}
+
+	jid, err := NormalizeJID(phoneNumber)
+	if err != nil {
+		return "", fmt.Errorf("failed to pair client %s: %w", phoneID, err)
+	}
+	if jid.Server != types.DefaultUserServer {
+		return "", fmt.Errorf("failed to pair client %s: %q is not a phone number", phoneID, phoneNumber)
+	}
+	phoneNumber = jid.User
+
+	instance.mu.Lock()
+	defer instance.mu.Unlock()
+
+	if instance.Connected {
+		return "", fmt.Errorf("client %s is already connected", phoneID)
+	}
+	if instance.Client.Store.ID != nil {
+		return "", fmt.Errorf("client %s is already paired, use ConnectClient instead", phoneID)
+	}
+
+	wm.registerHandlers(instance, phoneID)
+
+	code, err := PairWithPhone(context.Background(), instance.Client, phoneNumber)
+	if err != nil {
+		return "", fmt.Errorf("failed to pair client %s: %w", phoneID, err)
+	}
+	return code, nil
+}
+
+// NormalizePhoneNumber strips everything but digits, e.g. "+62 812-3456" -> "628123456"
+func NormalizePhoneNumber(phone string) string {
+	var sb strings.Builder
+	for _, r := range phone {
+		if r >= '0' && r <= '9' {
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
+
+// PairWithPhone connects an unpaired client and requests a pairing code for phoneNumber
+// (international format, digits only). The login websocket stays open in the background
+// until the code is entered or the pairing window expires.
+func PairWithPhone(ctx context.Context, client WhatsAppClient, phoneNumber string) (string, error) {
+	qrChan, err := client.GetQRChannel(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get login channel: %w", err)
+	}
+	if err := client.Connect(); err != nil {
+		return "", fmt.Errorf("failed to connect for pairing: %w", err)
+	}
+
+	// The first QR event means the login websocket is ready to accept a pairing request
+	select {
+	case evt, ok := <-qrChan:
+		if !ok || evt.Event != "code" {
+			client.Disconnect()
+			return "", fmt.Errorf("login channel closed before pairing could start")
 		}
+	case <-time.After(30 * time.Second):
+		client.Disconnect()
+		return "", fmt.Errorf("timed out waiting for login websocket")
+	}
 
-		// Display QR code
-		fmt.Printf("\n=== SCAN QR CODE FOR CLIENT: %s ===\n", phoneID)
+	code, err := client.PairPhone(ctx, phoneNumber, true, whatsmeow.PairClientChrome, "Chrome (Linux)")
+	if err != nil {
+		client.Disconnect()
+		return "", err
+	}
+
+	go func() {
 		for evt := range qrChan {
-			if evt.Event == "code" {
-				fmt.Println("Scan this QR code with WhatsApp:")
-				qrterminal.GenerateHalfBlock(evt.Code, qrterminal.L, os.Stdout)
-				fmt.Printf("Client: %s", phoneID)
-				fmt.Println("=====================================")
+			if evt.Event != "code" {
+				log.Printf("Pairing for %s finished: %s", phoneNumber, evt.Event)
 			}
 		}
-	} else {
-		// Already logged in, just connect
-		err = instance.Client.Connect()
-		if err != nil {
-			return fmt.Errorf("failed to connect existing client %s: %w", phoneID, err)
+	}()
+	return code, nil
+}
+
+// SetClientWebhookURL routes a client's incoming-message webhooks to url and persists
+// it in the client's config; an empty url falls back to the global WEBHOOK_URL
+func (wm *WhatsAppManager) SetClientWebhookURL(phoneID, url string) error {
+	instance, err := wm.GetClient(phoneID)
+	if err != nil {
+		return err
+	}
+
+	instance.mu.Lock()
+	instance.Config.WebhookURL = url
+	config := instance.Config
+	instance.mu.Unlock()
+
+	wm.webhooks.SetClientURL(phoneID, url)
+	return SaveClientConfig(wm.clientConfigPath(phoneID), config)
+}
+
+// SetClientModel sets the AI model a client answers with and persists it in the client's
+// config; an empty model falls back to the global OPENAI_MODEL
+func (wm *WhatsAppManager) SetClientModel(phoneID, model string) error {
+	instance, err := wm.GetClient(phoneID)
+	if err != nil {
+		return err
+	}
+
+	model = strings.TrimSpace(model)
+	instance.mu.Lock()
+	instance.Config.Model = model
+	config := instance.Config
+	if instance.AITools != nil {
+		instance.AITools.SetModel(model)
+	}
+	instance.mu.Unlock()
+
+	return SaveClientConfig(wm.clientConfigPath(phoneID), config)
+}
+
+// newClientAITools creates a client's AI tools using its configured model and persona,
+// or returns nil when no AI provider is configured
+func newClientAITools(phoneID string, config ClientConfig, systemPrompt string, downloader *WhatsAppDownloader) *AITools {
+	provider, model, err := NewAIProviderFromEnv()
+	if err != nil {
+		log.Printf("AI disabled for client %s: %v", phoneID, err)
+		return nil
+	}
+
+	aiTools := NewAITools(provider, model)
+	aiTools.SetModel(config.Model)
+	aiTools.SetVisionModel(os.Getenv("VISION_MODEL"))
+	aiTools.SetTranscriptionModel(os.Getenv("TRANSCRIPTION_MODEL"))
+	aiTools.SetImageGenerationModel(os.Getenv("IMAGE_GEN_MODEL"))
+	if err := aiTools.DetectVisionSupport(context.Background()); err != nil {
+		log.Printf("Client %s: %v", phoneID, err)
+	}
+	aiTools.SetSystemPrompt(systemPrompt)
+	aiTools.SetDataDir(downloader.DataDir())
+	downloader.RegisterAITools(aiTools)
+	return aiTools
+}
+
+// SetClientSystemPrompt sets a client's persona and persists it to the client's
+// system prompt file; an empty prompt removes the file and restores the defaults
+func (wm *WhatsAppManager) SetClientSystemPrompt(phoneID, prompt string) error {
+	instance, err := wm.GetClient(phoneID)
+	if err != nil {
+		return err
+	}
+
+	prompt = strings.TrimSpace(prompt)
+	instance.mu.Lock()
+	instance.SystemPrompt = prompt
+	if instance.AITools != nil {
+		instance.AITools.SetSystemPrompt(prompt)
+	}
+	instance.mu.Unlock()
+
+	path := wm.systemPromptPath(phoneID)
+	if prompt == "" {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove system prompt %s: %w", path, err)
 		}
+		return nil
+	}
+	if err := os.WriteFile(path, []byte(prompt+"\n"), 0644); err != nil {
+		return fmt.Errorf("failed to save system prompt to %s: %w", path, err)
+	}
+	return nil
+}
+
+// SendText sends a plain text message from the given client, which must be connected
+func (wm *WhatsAppManager) SendText(phoneID string, to types.JID, text string) error {
+	instance, err := wm.GetClient(phoneID)
+	if err != nil {
+		return err
 	}
 
+	instance.mu.RLock()
+	connected := instance.Connected
+	instance.mu.RUnlock()
+	if !connected {
+		return fmt.Errorf("%w: %s", ErrClientNotConnected, phoneID)
+	}
+
+	_, err = instance.Client.SendMessage(context.Background(), to, &waProto.Message{Conversation: proto.String(text)})
+	if err != nil {
+		return fmt.Errorf("failed to send message from %s to %s: %w", phoneID, to, err)
+	}
 	return nil
 }
 
+// ScheduleRecurring sends text from a client to a chat whenever the cron spec fires,
+// e.g. "0 9 * * 1-5" for weekdays at 09:00 in SCHEDULE_TIMEZONE
+func (wm *WhatsAppManager) ScheduleRecurring(phoneID string, to types.JID, text string, spec string) (string, error) {
+	if _, err := wm.GetClient(phoneID); err != nil {
+		return "", err
+	}
+	return wm.scheduler.Add(phoneID, to, text, spec)
+}
+
+// ListSchedules returns all recurring messages ordered by next run
+func (wm *WhatsAppManager) ListSchedules() []ScheduledMessage {
+	return wm.scheduler.List()
+}
+
+// CancelSchedule removes a recurring message
+func (wm *WhatsAppManager) CancelSchedule(id string) error {
+	return wm.scheduler.Cancel(id)
+}
+
+// DiskUsage reports how much disk stored images use per chat across all clients
+func (wm *WhatsAppManager) DiskUsage() ([]ChatDiskUsage, int64, error) {
+	files, err := ImageFiles(wm.dbDir)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	owners := make(map[string]string)
+	wm.mu.RLock()
+	for _, instance := range wm.instances {
+		for file, chat := range instance.Downloader.ImageOwners() {
+			owners[file] = chat
+		}
+	}
+	wm.mu.RUnlock()
+
+	usage, total := AggregateDiskUsage(files, owners)
+	return usage, total, nil
+}
+
 func (wm *WhatsAppManager) DisconnectClient(phoneID string) error {
 	instance, err := wm.GetClient(phoneID)
 	if err != nil {
@@ -206,8 +740,9 @@ func (wm *WhatsAppManager) DisconnectClient(phoneID string) error {
 	instance.mu.Lock()
 	defer instance.mu.Unlock()
 
+	instance.stopReconnecting()
 	if !instance.Connected {
-		return fmt.Errorf("client %s is not connected", phoneID)
+		return fmt.Errorf("%w: %s", ErrClientNotConnected, phoneID)
 	}
 
 	instance.Client.Disconnect()
@@ -217,21 +752,96 @@ func (wm *WhatsAppManager) DisconnectClient(phoneID string) error {
 	return nil
 }
 
+// LogoutClient unpairs a client from its WhatsApp account, unlike DisconnectClient which
+// keeps the session. A connected client is removed from the phone's linked devices; for
+// a disconnected one only the local session is deleted and the phone keeps listing the
+// device until it's removed there. The next connect requires a fresh QR scan or pairing
+// code.
+func (wm *WhatsAppManager) LogoutClient(phoneID string) error {
+	instance, err := wm.GetClient(phoneID)
+	if err != nil {
+		return err
+	}
+
+	instance.mu.Lock()
+	defer instance.mu.Unlock()
+
+	if instance.Client.Store.ID == nil {
+		return fmt.Errorf("%w: %s", ErrClientNotPaired, phoneID)
+	}
+	instance.stopReconnecting()
+
+	ctx := context.Background()
+	if instance.Connected {
+		// Logout disconnects and deletes the session from the store
+		if err := instance.Client.Logout(ctx); err != nil {
+			return fmt.Errorf("failed to log out client %s: %w", phoneID, err)
+		}
+	} else {
+		if err := instance.Client.Store.Delete(ctx); err != nil {
+			return wrapSQLiteError(fmt.Sprintf("failed to delete session of client %s", phoneID), err)
+		}
+		log.Printf("Client %s was offline; remove it from the phone's linked devices too", phoneID)
+	}
+	wm.clientRemoved(instance)
+
+	// A logged out device can't be paired again, the next login needs fresh keys
+	client := whatsmeow.NewClient(instance.store.NewDevice(), waLog.Noop)
+	if instance.autoReconnect {
+		client.EnableAutoReconnect = false
+	}
+	instance.handlersMu.Lock()
+	instance.handlers = nil // registered on the old client
+	instance.handlersMu.Unlock()
+	instance.Client = client
+	instance.Downloader.SetClient(client)
+	instance.Connected = false
+	instance.LoggedOut = true
+
+	log.Printf("WhatsApp client %s logged out", phoneID)
+	return nil
+}
+
+// SetMaxConcurrentConnections sets how many clients ConnectAllClients connects at once
+func (wm *WhatsAppManager) SetMaxConcurrentConnections(n int) {
+	if n <= 0 {
+		n = DefaultMaxConcurrentConnections
+	}
+	wm.handlerMu.Lock()
+	wm.maxConcurrent = n
+	wm.handlerMu.Unlock()
+}
+
+// ConnectAllClients connects every client that isn't connected yet, at most maxConcurrent at a time
 func (wm *WhatsAppManager) ConnectAllClients() error {
 	wm.mu.RLock()
 	phoneIDs := make([]string, 0, len(wm.instances))
-	for phoneID := range wm.instances {
+	for phoneID, instance := range wm.instances {
+		instance.mu.Lock()
+		connected := instance.Connected
+		instance.mu.Unlock()
+		if connected {
+			continue
+		}
 		phoneIDs = append(phoneIDs, phoneID)
 	}
 	wm.mu.RUnlock()
 
+	wm.handlerMu.RLock()
+	maxConcurrent := wm.maxConcurrent
+	wm.handlerMu.RUnlock()
+
 	var wg sync.WaitGroup
 	errChan := make(chan error, len(phoneIDs))
+	sem := make(chan struct{}, maxConcurrent)
 
 	for _, phoneID := range phoneIDs {
 		wg.Add(1)
 		go func(pid string) {
 			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
 			if err := wm.ConnectClient(pid); err != nil {
 				errChan <- fmt.Errorf("failed to connect client %s: %w", pid, err)
 			}
@@ -289,6 +899,82 @@ func (wm *WhatsAppManager) DisconnectAllClients() error {
 	return nil
 }
 
+// Shutdown stops the scheduler, disconnects every client and closes their databases.
+// It returns once everything is closed or ctx is done; clients that haven't finished
+// by then are reported in the returned error.
+func (wm *WhatsAppManager) Shutdown(ctx context.Context) error {
+	schedulerStopped := make(chan struct{})
+	go func() {
+		wm.scheduler.Stop()
+		close(schedulerStopped)
+	}()
+
+	wm.mu.RLock()
+	instances := make([]*WhatsAppInstance, 0, len(wm.instances))
+	for _, instance := range wm.instances {
+		instances = append(instances, instance)
+	}
+	wm.mu.RUnlock()
+
+	type closeResult struct {
+		phoneID string
+		err     error
+	}
+	results := make(chan closeResult, len(instances))
+	pending := make(map[string]bool, len(instances))
+	for _, instance := range instances {
+		pending[instance.PhoneID] = true
+		go func(instance *WhatsAppInstance) {
+			results <- closeResult{instance.PhoneID, instance.close()}
+		}(instance)
+	}
+
+	var errs []error
+	for len(pending) > 0 {
+		select {
+		case res := <-results:
+			delete(pending, res.phoneID)
+			if res.err != nil {
+				errs = append(errs, res.err)
+			}
+		case <-ctx.Done():
+			for phoneID := range pending {
+				errs = append(errs, fmt.Errorf("client %s did not shut down: %w", phoneID, ctx.Err()))
+			}
+			pending = nil
+		}
+	}
+
+	select {
+	case <-schedulerStopped:
+	case <-ctx.Done():
+		errs = append(errs, fmt.Errorf("scheduler did not stop: %w", ctx.Err()))
+	}
+
+	log.Printf("WhatsApp manager shut down (%d clients, %d errors)", len(instances), len(errs))
+	return errors.Join(errs...)
+}
+
+// close disconnects the client, including one that is still logging in, and closes its
+// device store
+func (wi *WhatsAppInstance) close() error {
+	wi.mu.Lock()
+	defer wi.mu.Unlock()
+
+	wi.autoReconnect = false
+	wi.stopReconnecting()
+	wi.Client.Disconnect()
+	wi.Connected = false
+	if wi.store == nil {
+		return nil
+	}
+	if err := wi.store.Close(); err != nil {
+		return fmt.Errorf("failed to close database for client %s: %w", wi.PhoneID, err)
+	}
+	wi.store = nil
+	return nil
+}
+
 func (wm *WhatsAppManager) ListClients() []string {
 	wm.mu.RLock()
 	defer wm.mu.RUnlock()
@@ -315,10 +1001,52 @@ func (wm *WhatsAppManager) GetClientStatus(phoneID string) (bool, string, error)
 	return connected, database, nil
 }
 
-func (wm *WhatsAppManager) CleanupDatabases() error {
-	files, err := filepath.Glob(filepath.Join(wm.dbDir, "whatsapp_*.db"))
+// GetClientPhoneNumber returns the WhatsApp number a client is paired with
+func (wm *WhatsAppManager) GetClientPhoneNumber(phoneID string) (string, error) {
+	instance, err := wm.GetClient(phoneID)
 	if err != nil {
-		return fmt.Errorf("failed to glob database files: %w", err)
+		return "", err
+	}
+
+	instance.mu.RLock()
+	defer instance.mu.RUnlock()
+	if instance.Client.Store.ID == nil {
+		return "", fmt.Errorf("%w: %s", ErrClientNotPaired, phoneID)
+	}
+	return instance.Client.Store.ID.User, nil
+}
+
+// Stats returns how many clients are managed and how many of them are connected
+func (wm *WhatsAppManager) Stats() (total, connected int) {
+	wm.mu.RLock()
+	defer wm.mu.RUnlock()
+
+	for _, instance := range wm.instances {
+		instance.mu.RLock()
+		if instance.Connected {
+			connected++
+		}
+		instance.mu.RUnlock()
+	}
+	return len(wm.instances), connected
+}
+
+// CleanupDatabases deletes every client database along with its history image index.
+// With a backupDir the files are first copied there, see BackupDatabases, and nothing is
+// deleted unless every copy is complete.
+func (wm *WhatsAppManager) CleanupDatabases(backupDir string) error {
+	var files []string
+	var err error
+	if backupDir != "" {
+		files, err = wm.backupDatabases(backupDir)
+		if err != nil {
+			return fmt.Errorf("failed to back up databases, nothing was deleted: %w", err)
+		}
+	} else {
+		files, err = wm.databaseFiles()
+		if err != nil {
+			return err
+		}
 	}
 
 	var errors []error
@@ -0,0 +1,220 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mau.fi/whatsmeow"
+)
+
+func TestAddClientConcurrent(t *testing.T) {
+	t.Setenv("AI_DRY_RUN", "true")
+	previous := DataDir()
+	t.Cleanup(func() { SetDataDir(previous) })
+
+	manager := NewWhatsAppManager(t.TempDir())
+	t.Cleanup(func() { manager.Shutdown(context.Background()) })
+
+	// Distinct clients plus several callers racing to add the same one
+	var phoneIDs []string
+	for i := range 8 {
+		phoneIDs = append(phoneIDs, fmt.Sprintf("client%d", i))
+	}
+	for range 4 {
+		phoneIDs = append(phoneIDs, "shared")
+	}
+
+	instances := make([]*WhatsAppInstance, len(phoneIDs))
+	errs := make([]error, len(phoneIDs))
+	var wg sync.WaitGroup
+	for i, phoneID := range phoneIDs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			instances[i], errs[i] = manager.AddClient(phoneID)
+		}()
+	}
+	wg.Wait()
+
+	databases := make(map[string]string)
+	sharedAdded := 0
+	for i, phoneID := range phoneIDs {
+		if err := errs[i]; err != nil {
+			if phoneID != "shared" || !strings.Contains(err.Error(), "already exists") {
+				t.Errorf("AddClient(%s) failed: %v", phoneID, err)
+			}
+			continue
+		}
+		if phoneID == "shared" {
+			sharedAdded++
+		}
+
+		db := instances[i].Database
+		if other, ok := databases[db]; ok {
+			t.Errorf("%s and %s share database %s", phoneID, other, db)
+		}
+		databases[db] = phoneID
+		if _, err := os.Stat(db); err != nil {
+			t.Errorf("database of %s: %v", phoneID, err)
+		}
+	}
+	if sharedAdded != 1 {
+		t.Errorf("shared client added %d times, want once", sharedAdded)
+	}
+	if n := len(manager.ListClients()); n != 9 {
+		t.Errorf("manager has %d clients, want 9", n)
+	}
+}
+
+func TestRegisterHandlersOnReconnect(t *testing.T) {
+	t.Setenv("AI_DRY_RUN", "true")
+	previous := DataDir()
+	t.Cleanup(func() { SetDataDir(previous) })
+
+	manager := NewWhatsAppManager(t.TempDir())
+	t.Cleanup(func() { manager.Shutdown(context.Background()) })
+	instance, err := manager.AddClient("sales")
+	if err != nil {
+		t.Fatal(err)
+	}
+	custom := instance.AddHandler("custom", func(evt any) {})
+
+	// Every connect registers the manager's handlers again
+	instance.mu.Lock()
+	manager.registerHandlers(instance, "sales")
+	first := instance.Handlers()
+	manager.registerHandlers(instance, "sales")
+	instance.mu.Unlock()
+
+	counts := make(map[string]int)
+	for _, h := range instance.Handlers() {
+		counts[h.Name]++
+	}
+	want := map[string]int{"custom": 1, historySyncHandlerName: 1, managerHandlerName: 1}
+	for name, n := range want {
+		if counts[name] != n {
+			t.Errorf("%d %q handlers after reconnecting, want %d (all: %v)", counts[name], name, n, instance.Handlers())
+		}
+	}
+
+	// The first connection's handlers are gone from the client itself
+	for _, h := range first {
+		if h.Name != "custom" && instance.Client.RemoveEventHandler(h.ID) {
+			t.Errorf("handler %d (%s) from the first connect is still registered", h.ID, h.Name)
+		}
+	}
+
+	if !instance.RemoveHandler(custom) {
+		t.Error("RemoveHandler didn't find the custom handler")
+	}
+	if instance.RemoveHandler(custom) {
+		t.Error("RemoveHandler removed the custom handler twice")
+	}
+}
+
+func TestQRLoginExpiry(t *testing.T) {
+	t.Setenv("AI_DRY_RUN", "true")
+	previous := DataDir()
+	t.Cleanup(func() { SetDataDir(previous) })
+
+	manager := NewWhatsAppManager(t.TempDir())
+	t.Cleanup(func() { manager.Shutdown(context.Background()) })
+	instance, err := manager.AddClient("sales")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expired := make(chan string, 2)
+	manager.OnQRExpired(func(phoneID string) { expired <- phoneID })
+
+	instance.mu.Lock()
+	manager.registerHandlers(instance, "sales")
+	instance.Connected = true
+	instance.mu.Unlock()
+
+	qrChan := make(chan whatsmeow.QRChannelItem, 3)
+	qrChan <- whatsmeow.QRChannelItem{Event: whatsmeow.QRChannelEventCode, Code: "code-1"}
+	qrChan <- whatsmeow.QRChannelItem{Event: whatsmeow.QRChannelEventCode, Code: "code-2"}
+	qrChan <- whatsmeow.QRChannelTimeout
+	close(qrChan)
+
+	var rendered []string
+	err = manager.waitForQRLogin(instance, "sales", qrChan, func(code string) { rendered = append(rendered, code) })
+	if !errors.Is(err, ErrQRExpired) {
+		t.Fatalf("waitForQRLogin error = %v, want ErrQRExpired", err)
+	}
+	if len(rendered) != 2 {
+		t.Errorf("rendered %v, want both codes", rendered)
+	}
+
+	select {
+	case phoneID := <-expired:
+		if phoneID != "sales" {
+			t.Errorf("callback got %q, want sales", phoneID)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("QR expired callback didn't fire")
+	}
+	select {
+	case <-expired:
+		t.Error("QR expired callback fired twice")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	connected, _, err := manager.GetClientStatus("sales")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if connected || instance.Client.IsConnected() {
+		t.Error("client still connected after the QR code expired")
+	}
+	if handlers := instance.Handlers(); len(handlers) != 0 {
+		t.Errorf("handlers left after the QR code expired: %v", handlers)
+	}
+}
+
+// TestClientAIConfigConcurrent changes a client's model and persona while replies copy
+// and read its AI tools; run it with -race
+func TestClientAIConfigConcurrent(t *testing.T) {
+	t.Setenv("AI_DRY_RUN", "true")
+	previous := DataDir()
+	t.Cleanup(func() { SetDataDir(previous) })
+
+	manager := NewWhatsAppManager(t.TempDir())
+	t.Cleanup(func() { manager.Shutdown(context.Background()) })
+	instance, err := manager.AddClient("sales")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := range 10 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if err := manager.SetClientModel("sales", fmt.Sprintf("model-%d", i)); err != nil {
+				t.Error(err)
+			}
+			if err := manager.SetClientSystemPrompt("sales", fmt.Sprintf("persona %d", i)); err != nil {
+				t.Error(err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			chat := instance.AITools.WithOverrides(AIOverrides{Language: "id"}).ForChat("628120000000@s.whatsapp.net")
+			chat.Model()
+			chat.CacheKey("halo")
+		}()
+	}
+	wg.Wait()
+
+	if model := instance.AITools.Model(); !strings.HasPrefix(model, "model-") {
+		t.Errorf("model = %q after concurrent updates", model)
+	}
+}
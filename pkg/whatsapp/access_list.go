@@ -0,0 +1,199 @@
+package whatsapp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"auto-lmk/pkg/tools"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+// accessList limits which contacts the bot answers. Denied contacts are always ignored;
+// when the allowlist is non-empty, only listed contacts are answered. Entries are phone
+// numbers (the user part of a JID).
+type accessList struct {
+	mu    sync.RWMutex
+	path  string
+	allow map[string]bool
+	deny  map[string]bool
+}
+
+type accessListFile struct {
+	Allow []string `json:"allow"`
+	Deny  []string `json:"deny"`
+}
+
+// loadAccessList reads AI_ACCESS_LIST_FILE (default data/access_list.json); a missing
+// file means everyone may use the bot
+func loadAccessList() *accessList {
+	al := &accessList{
+		path:  tools.EnvString("AI_ACCESS_LIST_FILE", tools.DataPath("access_list.json")),
+		allow: make(map[string]bool),
+		deny:  make(map[string]bool),
+	}
+
+	data, err := os.ReadFile(al.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return al
+	}
+	var file accessListFile
+	if err == nil {
+		err = json.Unmarshal(data, &file)
+	}
+	if err != nil {
+		fmt.Printf("Warning: ignoring access list %s: %v\n", al.path, err)
+		return al
+	}
+
+	for _, entry := range file.Allow {
+		if key := accessListKey(entry); key != "" {
+			al.allow[key] = true
+		}
+	}
+	for _, entry := range file.Deny {
+		if key := accessListKey(entry); key != "" {
+			al.deny[key] = true
+		}
+	}
+	return al
+}
+
+// accessListKey normalizes a phone number or JID to the phone number it identifies, or
+// "" if entry is invalid
+func accessListKey(entry string) string {
+	jid, err := tools.NormalizeJID(entry)
+	if err != nil {
+		return ""
+	}
+	return jid.User
+}
+
+// permits reports whether the bot may answer a sender; senders addressed by LID are
+// also checked under their phone number
+func (al *accessList) permits(info types.MessageInfo) bool {
+	al.mu.RLock()
+	defer al.mu.RUnlock()
+
+	keys := []string{info.Sender.ToNonAD().User}
+	if !info.SenderAlt.IsEmpty() {
+		keys = append(keys, info.SenderAlt.ToNonAD().User)
+	}
+
+	for _, key := range keys {
+		if al.deny[key] {
+			return false
+		}
+	}
+	if len(al.allow) == 0 {
+		return true
+	}
+	return slices.ContainsFunc(keys, func(key string) bool { return al.allow[key] })
+}
+
+// set puts a contact on the allow or deny list, removing it from the other; an empty
+// list name removes it from both
+func (al *accessList) set(entry, list string) (string, error) {
+	key := accessListKey(entry)
+	if key == "" {
+		return "", fmt.Errorf("invalid phone number or JID %q", entry)
+	}
+
+	al.mu.Lock()
+	defer al.mu.Unlock()
+
+	delete(al.allow, key)
+	delete(al.deny, key)
+	switch list {
+	case "allow":
+		al.allow[key] = true
+	case "deny":
+		al.deny[key] = true
+	}
+	return key, al.save()
+}
+
+// save writes the lists to disk; must be called with mu held
+func (al *accessList) save() error {
+	file := accessListFile{Allow: sortedKeys(al.allow), Deny: sortedKeys(al.deny)}
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal access list: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(al.path), 0755); err != nil {
+		return fmt.Errorf("failed to create access list directory: %w", err)
+	}
+	if err := os.WriteFile(al.path, data, 0644); err != nil {
+		return fmt.Errorf("failed to save access list to %s: %w", al.path, err)
+	}
+	return nil
+}
+
+// describe renders both lists for a command reply
+func (al *accessList) describe() string {
+	al.mu.RLock()
+	defer al.mu.RUnlock()
+
+	orNone := func(entries []string) string {
+		if len(entries) == 0 {
+			return "(none)"
+		}
+		return strings.Join(entries, ", ")
+	}
+	mode := "everyone except denied contacts"
+	if len(al.allow) > 0 {
+		mode = "only allowed contacts"
+	}
+	return fmt.Sprintf("🔐 AI answers %s\nAllowed: %s\nDenied: %s", mode, orNone(sortedKeys(al.allow)), orNone(sortedKeys(al.deny)))
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// isPermitted reports whether the bot may handle a message at all; operators always may
+func (ws *WhatsAppService) isPermitted(info types.MessageInfo) bool {
+	return ws.isAdmin(info.Sender) || ws.accessList.permits(info)
+}
+
+// handleAccessCommand handles "ai allow|deny|unlist <jid>"; without a JID it shows the lists
+func (ws *WhatsAppService) handleAccessCommand(to types.JID, name, arg string) {
+	if !ws.requireAdmin(to) {
+		return
+	}
+	if arg == "" {
+		ws.sendMessage(to, ws.accessList.describe())
+		return
+	}
+
+	list := name
+	if name == "unlist" {
+		list = ""
+	}
+	key, err := ws.accessList.set(arg, list)
+	if err != nil {
+		ws.sendMessage(to, fmt.Sprintf("❌ %v", err))
+		return
+	}
+
+	switch name {
+	case "allow":
+		ws.sendMessage(to, fmt.Sprintf("✅ %s may now use the AI.\n\n%s", key, ws.accessList.describe()))
+	case "deny":
+		ws.sendMessage(to, fmt.Sprintf("🚫 %s will be ignored.\n\n%s", key, ws.accessList.describe()))
+	default:
+		ws.sendMessage(to, fmt.Sprintf("🗑️ %s removed from the access lists.\n\n%s", key, ws.accessList.describe()))
+	}
+}
@@ -0,0 +1,149 @@
+package whatsapp
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"auto-lmk/pkg/tools"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+// ackConfig controls the automatic "seen" reaction sent in chats where AI is off
+type ackConfig struct {
+	emoji    string
+	cooldown time.Duration
+	offHours *timeWindow // nil means acknowledge at any time
+}
+
+// timeWindow is a daily time range in minutes since midnight; it may wrap past midnight
+type timeWindow struct {
+	start int
+	end   int
+}
+
+func loadAckConfig() ackConfig {
+	cfg := ackConfig{
+		emoji:    tools.EnvString("ACK_EMOJI", "✅"),
+		cooldown: tools.EnvDuration("ACK_COOLDOWN", 30*time.Minute),
+	}
+
+	if raw := os.Getenv("ACK_OFF_HOURS"); raw != "" {
+		window, err := parseTimeWindow(raw)
+		if err != nil {
+			fmt.Printf("Warning: ignoring ACK_OFF_HOURS: %v\n", err)
+		} else {
+			cfg.offHours = window
+		}
+	}
+
+	return cfg
+}
+
+// parseTimeWindow parses a range like "18:00-08:00"
+func parseTimeWindow(raw string) (*timeWindow, error) {
+	parts := strings.Split(strings.TrimSpace(raw), "-")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid time window %q, expected HH:MM-HH:MM", raw)
+	}
+
+	var minutes [2]int
+	for i, part := range parts {
+		t, err := time.Parse("15:04", strings.TrimSpace(part))
+		if err != nil {
+			return nil, fmt.Errorf("invalid time %q in window %q: %w", part, raw, err)
+		}
+		minutes[i] = t.Hour()*60 + t.Minute()
+	}
+
+	return &timeWindow{start: minutes[0], end: minutes[1]}, nil
+}
+
+func (tw *timeWindow) contains(t time.Time) bool {
+	m := t.Hour()*60 + t.Minute()
+	if tw.start <= tw.end {
+		return m >= tw.start && m < tw.end
+	}
+	// Window wraps past midnight
+	return m >= tw.start || m < tw.end
+}
+
+// maybeSendAck reacts to an inbound message in a chat with acknowledgements enabled,
+// respecting the off-hours schedule and the per-chat cooldown
+func (ws *WhatsAppService) maybeSendAck(info types.MessageInfo) {
+	chatKey := info.Chat.String()
+	if !ws.isAckEnabled(chatKey) || ws.IsAIEnabled(chatKey) {
+		return
+	}
+
+	now := time.Now()
+	if ws.ackConfig.offHours != nil && !ws.ackConfig.offHours.contains(now) {
+		return
+	}
+	if !ws.takeAckSlot(chatKey, now) {
+		return
+	}
+	go ws.sendReaction(info.Chat, info.Sender, info.ID, ws.ackConfig.emoji)
+}
+
+// takeAckSlot records an acknowledgement in a chat at now, returning false while the
+// chat's cooldown hasn't passed or acknowledgements were turned off meanwhile
+func (ws *WhatsAppService) takeAckSlot(chatKey string, now time.Time) bool {
+	ws.ackMu.Lock()
+	defer ws.ackMu.Unlock()
+	if !ws.ackEnabledChats[chatKey] {
+		return false
+	}
+	if last, ok := ws.lastAckTime[chatKey]; ok && now.Sub(last) < ws.ackConfig.cooldown {
+		return false
+	}
+	ws.lastAckTime[chatKey] = now
+	return true
+}
+
+// setAckEnabled turns acknowledgements on or off for a chat
+func (ws *WhatsAppService) setAckEnabled(chatKey string, enabled bool) {
+	ws.ackMu.Lock()
+	defer ws.ackMu.Unlock()
+	if enabled {
+		ws.ackEnabledChats[chatKey] = true
+		return
+	}
+	delete(ws.ackEnabledChats, chatKey)
+	delete(ws.lastAckTime, chatKey)
+}
+
+// isAckEnabled reports whether inbound messages in a chat get an acknowledgement
+func (ws *WhatsAppService) isAckEnabled(chatKey string) bool {
+	ws.ackMu.Lock()
+	defer ws.ackMu.Unlock()
+	return ws.ackEnabledChats[chatKey]
+}
+
+// progressReactions are the reactions put on a message while the AI answers it
+type progressReactions struct {
+	enabled bool
+	working string
+	done    string
+}
+
+// loadProgressReactions reads AI_PROGRESS_REACTIONS and the emoji to use
+func loadProgressReactions() progressReactions {
+	return progressReactions{
+		enabled: tools.EnvBool("AI_PROGRESS_REACTIONS", true),
+		working: tools.EnvString("AI_PROGRESS_EMOJI", "👀"),
+		done:    tools.EnvString("AI_DONE_EMOJI", "✅"),
+	}
+}
+
+// reactProgress sets the progress reaction on a message; a new reaction replaces the
+// previous one and an empty emoji removes it. It runs synchronously so reactions
+// arrive in order.
+func (ws *WhatsAppService) reactProgress(info types.MessageInfo, emoji string) {
+	if !ws.progressReactions.enabled {
+		return
+	}
+	ws.sendReaction(info.Chat, info.Sender, info.ID, emoji)
+}
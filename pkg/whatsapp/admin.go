@@ -0,0 +1,34 @@
+package whatsapp
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"auto-lmk/pkg/tools"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+// loadAdminUsers reads the operator phone numbers allowed to run admin commands
+// from AI_ADMIN_JIDS (comma-separated numbers or JIDs)
+func loadAdminUsers() map[string]bool {
+	admins := make(map[string]bool)
+	for _, entry := range strings.Split(os.Getenv("AI_ADMIN_JIDS"), ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		jid, err := tools.NormalizeJID(entry)
+		if err != nil {
+			fmt.Printf("Warning: ignoring AI_ADMIN_JIDS entry: %v\n", err)
+			continue
+		}
+		admins[jid.User] = true
+	}
+	return admins
+}
+
+// isAdmin reports whether the sender is a configured operator
+func (ws *WhatsAppService) isAdmin(sender types.JID) bool {
+	return ws.adminUsers[sender.ToNonAD().User]
+}
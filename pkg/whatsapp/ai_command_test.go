@@ -0,0 +1,211 @@
+package whatsapp
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"auto-lmk/pkg/tools"
+	"auto-lmk/pkg/whatsapptest"
+
+	"github.com/openai/openai-go"
+	waProto "go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+	"google.golang.org/protobuf/proto"
+)
+
+// newTestService creates a service that sends through a fake client, keeping its files
+// in a temporary data directory
+func newTestService(t *testing.T) (*WhatsAppService, *whatsapptest.FakeClient) {
+	t.Helper()
+	previous := tools.DataDir()
+	tools.SetDataDir(t.TempDir())
+	t.Cleanup(func() { tools.SetDataDir(previous) })
+
+	client := whatsapptest.NewFakeClient()
+	ws := newWhatsAppService()
+	ws.whatsappClient = client
+	return ws, client
+}
+
+// enableTestAI gives a test service the dry-run AI provider without rate limits, typing
+// delays or reactions, and starts its outbound queue so AI replies get sent
+func enableTestAI(t *testing.T, ws *WhatsAppService) {
+	t.Helper()
+	ws.aiConfigured = true
+	ws.aiTools = tools.NewAITools(tools.DryRunProvider{}, "dry-run")
+	ws.rateLimiter = newRateLimiter(6000, 0)
+	ws.typing = typingSimulation{}
+	ws.progressReactions = progressReactions{}
+	ws.streamChunkMode = tools.ChunkOnce
+	go ws.runOutboundQueue()
+	t.Cleanup(ws.outbound.close)
+}
+
+// incomingText builds a text message received now in a one-to-one chat
+func incomingText(chat types.JID, id types.MessageID, text string) *events.Message {
+	return &events.Message{
+		Info: types.MessageInfo{
+			MessageSource: types.MessageSource{Chat: chat, Sender: chat},
+			ID:            id,
+			Timestamp:     time.Now(),
+		},
+		Message: &waProto.Message{Conversation: proto.String(text)},
+	}
+}
+
+// waitForReply waits until a text containing want is sent to a chat and returns it
+func waitForReply(t *testing.T, client *whatsapptest.FakeClient, to types.JID, want string) string {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		for _, text := range client.SentTexts(to) {
+			if strings.Contains(text, want) {
+				return text
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("no reply containing %q sent to %s, got %q", want, to.User, client.SentTexts(to))
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// lastReply returns the last text sent to a chat
+func lastReply(t *testing.T, client *whatsapptest.FakeClient, to types.JID) string {
+	t.Helper()
+	texts := client.SentTexts(to)
+	if len(texts) == 0 {
+		t.Fatalf("no reply sent to %s", to)
+	}
+	return texts[len(texts)-1]
+}
+
+func TestHandleAICommand(t *testing.T) {
+	user := types.NewJID("628123456789", types.DefaultUserServer)
+	chatJID := user.String()
+
+	tests := []struct {
+		name         string
+		aiConfigured bool
+		enabled      bool // AI state of the chat before the command
+		command      string
+		wantReply    string
+		wantEnabled  bool
+	}{
+		{name: "on", aiConfigured: true, command: "on", wantReply: "AI mode enabled", wantEnabled: true},
+		{name: "on without provider", command: "on", wantReply: "not available"},
+		{name: "off", aiConfigured: true, enabled: true, command: "off", wantReply: "AI mode disabled"},
+		{name: "status enabled", aiConfigured: true, enabled: true, command: "status", wantReply: "currently enabled", wantEnabled: true},
+		{name: "status disabled", aiConfigured: true, command: "status", wantReply: "currently disabled"},
+		{name: "command name is case-insensitive", aiConfigured: true, command: "ON", wantReply: "AI mode enabled", wantEnabled: true},
+		{name: "admin command from non-admin", aiConfigured: true, enabled: true, command: "pauseall", wantReply: "restricted to operators", wantEnabled: true},
+		{name: "unknown command", aiConfigured: true, command: "frobnicate", wantReply: "Available AI commands"},
+		{name: "help lists snapshot", aiConfigured: true, command: "frobnicate", wantReply: "ai snapshot <name>"},
+		{name: "help lists restore", aiConfigured: true, command: "frobnicate", wantReply: "ai restore <name>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ws, client := newTestService(t)
+			ws.aiConfigured = tt.aiConfigured
+			if tt.enabled {
+				ws.aiEnabledChats[chatJID] = true
+			}
+
+			ws.handleAICommand(user, tt.command, chatJID)
+
+			if reply := lastReply(t, client, user); !strings.Contains(reply, tt.wantReply) {
+				t.Errorf("reply = %q, want it to contain %q", reply, tt.wantReply)
+			}
+			if got := ws.IsAIEnabled(chatJID); got != tt.wantEnabled {
+				t.Errorf("AI enabled = %t, want %t", got, tt.wantEnabled)
+			}
+		})
+	}
+}
+
+func TestHandleAICommandPauseAllRestoresChats(t *testing.T) {
+	admin := types.NewJID("628111111111", types.DefaultUserServer)
+	ws, client := newTestService(t)
+	ws.aiConfigured = true
+	ws.adminUsers = map[string]bool{admin.User: true}
+	ws.aiEnabledChats["a@s.whatsapp.net"] = true
+	ws.aiEnabledChats["b@s.whatsapp.net"] = true
+
+	ws.handleAICommand(admin, "pauseall", admin.String())
+	if ws.IsAIEnabled("a@s.whatsapp.net") || ws.IsAIEnabled("b@s.whatsapp.net") {
+		t.Fatal("AI still enabled after pauseall")
+	}
+
+	// A chat switched off while paused must stay off after resuming
+	ws.handleAICommand(admin, "off", "b@s.whatsapp.net")
+	ws.handleAICommand(admin, "resumeall", admin.String())
+
+	if !ws.IsAIEnabled("a@s.whatsapp.net") {
+		t.Error("chat a not restored by resumeall")
+	}
+	if ws.IsAIEnabled("b@s.whatsapp.net") {
+		t.Error("chat b switched off while paused was restored")
+	}
+	if reply := lastReply(t, client, admin); !strings.Contains(reply, "back on in 1 chats") {
+		t.Errorf("reply = %q", reply)
+	}
+}
+
+func TestHandleAICommandReset(t *testing.T) {
+	user := types.NewJID("628123456789", types.DefaultUserServer)
+	chatJID := user.String()
+
+	for _, tt := range []struct {
+		arg        string
+		wantImages bool
+	}{
+		{arg: "", wantImages: true},
+		{arg: "all", wantImages: false},
+	} {
+		t.Run("reset "+tt.arg, func(t *testing.T) {
+			ws, client := newTestService(t)
+			ws.aiEnabledChats[chatJID] = true
+			ws.chatHistory[chatJID] = []openai.ChatCompletionMessageParamUnion{openai.UserMessage("hi"), openai.AssistantMessage("hello")}
+			ws.imageHistory[chatJID] = map[string]string{"IMG1": "IMG1.jpg"}
+
+			ws.handleAICommand(user, strings.TrimSpace("reset "+tt.arg), chatJID)
+
+			if len(ws.chatHistory[chatJID]) != 0 {
+				t.Errorf("history not cleared: %d messages left", len(ws.chatHistory[chatJID]))
+			}
+			if got := len(ws.imageHistory[chatJID]) > 0; got != tt.wantImages {
+				t.Errorf("images kept = %t, want %t", got, tt.wantImages)
+			}
+			if !ws.IsAIEnabled(chatJID) {
+				t.Error("reset turned AI off")
+			}
+			if reply := lastReply(t, client, user); !strings.Contains(reply, "2 messages forgotten") {
+				t.Errorf("reply = %q", reply)
+			}
+		})
+	}
+}
+
+func TestHandleAICommandList(t *testing.T) {
+	admin := types.NewJID("628111111111", types.DefaultUserServer)
+	ws, client := newTestService(t)
+	ws.adminUsers = map[string]bool{admin.User: true}
+
+	ws.handleAICommand(admin, "list", admin.String())
+	if reply := lastReply(t, client, admin); !strings.Contains(reply, "not enabled in any chat") {
+		t.Errorf("reply = %q", reply)
+	}
+
+	for i := range maxListedChats + 2 {
+		ws.aiEnabledChats[fmt.Sprintf("62800000%04d@s.whatsapp.net", i)] = true
+	}
+	ws.handleAICommand(admin, "list", admin.String())
+	reply := lastReply(t, client, admin)
+	if !strings.Contains(reply, "enabled in 52 chats") || !strings.Contains(reply, "628000000000@s.whatsapp.net") || !strings.HasSuffix(reply, "and 2 more") {
+		t.Errorf("reply = %q", reply)
+	}
+}
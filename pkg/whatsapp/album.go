@@ -0,0 +1,85 @@
+package whatsapp
+
+import (
+	"context"
+	"fmt"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/proto/waCommon"
+	waProto "go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+	"google.golang.org/protobuf/proto"
+)
+
+// ImagePart is one image of an album
+type ImagePart struct {
+	Data    []byte
+	Caption string
+}
+
+// buildAlbumMessage builds the parent message announcing how many images the album holds
+func buildAlbumMessage(imageCount int) *waProto.Message {
+	return &waProto.Message{
+		AlbumMessage: &waProto.AlbumMessage{
+			ExpectedImageCount: proto.Uint32(uint32(imageCount)),
+			ExpectedVideoCount: proto.Uint32(0),
+		},
+	}
+}
+
+// buildAlbumItem wraps an image so WhatsApp renders it inside the album identified by parentKey
+func buildAlbumItem(imgMsg *waProto.ImageMessage, parentKey *waCommon.MessageKey, index int) *waProto.Message {
+	return &waProto.Message{
+		ImageMessage: imgMsg,
+		MessageContextInfo: &waProto.MessageContextInfo{
+			MessageAssociation: &waProto.MessageAssociation{
+				AssociationType:  waProto.MessageAssociation_MEDIA_ALBUM.Enum(),
+				ParentMessageKey: parentKey,
+				MessageIndex:     proto.Int32(int32(index)),
+			},
+		},
+	}
+}
+
+// sendAlbum sends several images grouped as a single album. All images are uploaded
+// before anything is sent so a failed upload never leaves a half-empty album behind.
+func (ws *WhatsAppService) sendAlbum(to types.JID, images []ImagePart) error {
+	if ws.whatsappClient == nil {
+		return fmt.Errorf("WhatsApp client not initialized")
+	}
+	switch len(images) {
+	case 0:
+		return fmt.Errorf("album has no images")
+	case 1:
+		// WhatsApp only groups two or more images
+		return ws.sendImage(to, images[0].Data, images[0].Caption)
+	}
+
+	ctx := context.Background()
+	uploaded := make([]*waProto.ImageMessage, 0, len(images))
+	for i, img := range images {
+		imgMsg, err := ws.uploadImage(ctx, img.Data, img.Caption)
+		if err != nil {
+			return fmt.Errorf("failed to upload album image %d: %w", i+1, err)
+		}
+		uploaded = append(uploaded, imgMsg)
+	}
+
+	albumID := ws.whatsappClient.GenerateMessageID()
+	_, err := ws.whatsappClient.SendMessage(ctx, to, buildAlbumMessage(len(uploaded)), whatsmeow.SendRequestExtra{ID: albumID})
+	if err != nil {
+		return fmt.Errorf("failed to send album: %w", err)
+	}
+
+	parentKey := &waCommon.MessageKey{
+		RemoteJID: proto.String(to.String()),
+		FromMe:    proto.Bool(true),
+		ID:        proto.String(albumID),
+	}
+	for i, imgMsg := range uploaded {
+		if _, err := ws.whatsappClient.SendMessage(ctx, to, buildAlbumItem(imgMsg, parentKey, i)); err != nil {
+			return fmt.Errorf("failed to send album image %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package whatsapp
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"auto-lmk/pkg/tools"
+
+	waProto "go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+)
+
+// incomingImage is a received image message waiting to be answered
+type incomingImage struct {
+	msg  *waProto.ImageMessage
+	info types.MessageInfo // the chat and sender are needed to request expired media again
+}
+
+// pendingAlbum collects the images of an album until all of them have arrived
+type pendingAlbum struct {
+	to, chat types.JID
+	images   []incomingImage
+	captions []string
+	expected int // image count announced by the album message, 0 if unknown
+	timer    *time.Timer
+}
+
+// albumBuffer holds the images of received albums briefly so they're answered together
+// instead of one reply per image. An album is dispatched once all its images arrived or
+// when no further image came within the window.
+type albumBuffer struct {
+	mu        sync.Mutex
+	window    time.Duration
+	maxImages int
+	pending   map[string]*pendingAlbum
+	dispatch  func(album *pendingAlbum)
+}
+
+// newAlbumBuffer reads AI_ALBUM_WINDOW (default 3s)
+func newAlbumBuffer(dispatch func(album *pendingAlbum)) *albumBuffer {
+	return &albumBuffer{
+		window:    tools.EnvDuration("AI_ALBUM_WINDOW", 3*time.Second),
+		maxImages: max(tools.EnvInt("AI_MAX_IMAGES", tools.DefaultMaxImages), 1),
+		pending:   make(map[string]*pendingAlbum),
+		dispatch:  dispatch,
+	}
+}
+
+// albumParentID returns the ID of the album an image belongs to, or "" for a lone image
+func albumParentID(message *waProto.Message) string {
+	assoc := message.GetMessageContextInfo().GetMessageAssociation()
+	if assoc.GetAssociationType() != waProto.MessageAssociation_MEDIA_ALBUM {
+		return ""
+	}
+	return assoc.GetParentMessageKey().GetID()
+}
+
+func albumKey(chat types.JID, albumID string) string {
+	return chat.String() + "/" + albumID
+}
+
+// getLocked returns the pending album for key, creating it; must be called with mu held
+func (ab *albumBuffer) getLocked(key string) *pendingAlbum {
+	album, ok := ab.pending[key]
+	if !ok {
+		album = &pendingAlbum{}
+		album.timer = time.AfterFunc(ab.window, func() { ab.flush(key) })
+		ab.pending[key] = album
+	}
+	return album
+}
+
+// expect records how many images an album announced, from its album message
+func (ab *albumBuffer) expect(chat types.JID, albumID string, count int) {
+	key := albumKey(chat, albumID)
+	ab.mu.Lock()
+	album := ab.getLocked(key)
+	album.expected = count
+	complete := len(album.images) > 0 && len(album.images) >= album.expected
+	ab.mu.Unlock()
+
+	if complete {
+		ab.flush(key)
+	}
+}
+
+// add buffers an image of an album, dispatching the album once it's complete
+func (ab *albumBuffer) add(to, chat types.JID, albumID string, img incomingImage, caption string) {
+	key := albumKey(chat, albumID)
+	ab.mu.Lock()
+	album := ab.getLocked(key)
+	album.to, album.chat = to, chat
+	if len(album.images) < ab.maxImages {
+		album.images = append(album.images, img)
+	}
+	if caption = strings.TrimSpace(caption); caption != "" {
+		album.captions = append(album.captions, caption)
+	}
+	album.timer.Reset(ab.window)
+	complete := album.expected > 0 && len(album.images) >= min(album.expected, ab.maxImages)
+	ab.mu.Unlock()
+
+	if complete {
+		ab.flush(key)
+	}
+}
+
+// flush dispatches a pending album; it's a no-op if the album was already dispatched
+func (ab *albumBuffer) flush(key string) {
+	ab.mu.Lock()
+	album, ok := ab.pending[key]
+	if ok {
+		delete(ab.pending, key)
+		album.timer.Stop()
+	}
+	ab.mu.Unlock()
+
+	if ok && len(album.images) > 0 {
+		go ab.dispatch(album) // answering downloads the images, keep it off the event loop
+	}
+}
+
+// handleAlbumWithAI answers the buffered images of an album with one AI request
+func (ws *WhatsAppService) handleAlbumWithAI(album *pendingAlbum) {
+	ws.handleImagesWithAI(album.to, album.chat, album.images, strings.Join(album.captions, "\n"))
+}
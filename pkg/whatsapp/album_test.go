@@ -0,0 +1,79 @@
+package whatsapp
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"testing"
+
+	waProto "go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+)
+
+func TestSendAlbum(t *testing.T) {
+	ws, client := newTestService(t)
+	chat := types.NewJID("628120000000", types.DefaultUserServer)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, image.NewGray(image.Rect(0, 0, 32, 32)), nil); err != nil {
+		t.Fatal(err)
+	}
+	images := []ImagePart{
+		{Data: buf.Bytes(), Caption: "first"},
+		{Data: buf.Bytes()},
+		{Data: buf.Bytes(), Caption: "third"},
+	}
+	if err := ws.sendAlbum(chat, images); err != nil {
+		t.Fatal(err)
+	}
+
+	sent := client.Sent()
+	if len(sent) != len(images)+1 {
+		t.Fatalf("sent %d messages, want the album and %d images", len(sent), len(images))
+	}
+
+	album := sent[0]
+	if got := album.Message.GetAlbumMessage().GetExpectedImageCount(); got != uint32(len(images)) {
+		t.Errorf("album expects %d images, want %d", got, len(images))
+	}
+
+	for i, item := range sent[1:] {
+		if item.To != chat {
+			t.Errorf("image %d sent to %s, want %s", i, item.To, chat)
+		}
+		if got := item.Message.GetImageMessage().GetCaption(); got != images[i].Caption {
+			t.Errorf("image %d caption = %q, want %q", i, got, images[i].Caption)
+		}
+
+		assoc := item.Message.GetMessageContextInfo().GetMessageAssociation()
+		if assoc.GetAssociationType() != waProto.MessageAssociation_MEDIA_ALBUM {
+			t.Errorf("image %d association = %v, want MEDIA_ALBUM", i, assoc.GetAssociationType())
+		}
+		if assoc.GetMessageIndex() != int32(i) {
+			t.Errorf("image %d index = %d", i, assoc.GetMessageIndex())
+		}
+		parent := assoc.GetParentMessageKey()
+		if parent.GetID() != album.ID || !parent.GetFromMe() || parent.GetRemoteJID() != chat.String() {
+			t.Errorf("image %d parent key = %v, want the album %s", i, parent, album.ID)
+		}
+	}
+}
+
+func TestSendAlbumSingleImage(t *testing.T) {
+	ws, client := newTestService(t)
+	chat := types.NewJID("628120000000", types.DefaultUserServer)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, image.NewGray(image.Rect(0, 0, 32, 32)), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := ws.sendAlbum(chat, []ImagePart{{Data: buf.Bytes()}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// WhatsApp only groups two or more images, so one image goes out on its own
+	sent := client.Sent()
+	if len(sent) != 1 || sent[0].Message.GetImageMessage() == nil || sent[0].Message.GetMessageContextInfo() != nil {
+		t.Errorf("sent %v, want a single plain image", sent)
+	}
+}
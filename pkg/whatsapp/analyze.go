@@ -0,0 +1,100 @@
+package whatsapp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"auto-lmk/pkg/tools"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+// lookupImage finds a stored image in a chat's history by ID. Command arguments arrive
+// lowercased, so IDs are matched case-insensitively.
+func (ws *WhatsAppService) lookupImage(chatKey string, imageID string) (string, string, bool) {
+	for id, filename := range ws.chatImages(chatKey) {
+		if strings.EqualFold(id, imageID) {
+			return id, filename, true
+		}
+	}
+	return "", "", false
+}
+
+// buildAnalyzeRequest resolves image IDs into referenced images for a multi-image request,
+// returning IDs that weren't found and IDs dropped because of the image cap
+func (ws *WhatsAppService) buildAnalyzeRequest(chatKey string, imageIDs []string, maxImages int) ([]map[string]string, []string, []string) {
+	var referenced []map[string]string
+	var missing, dropped []string
+
+	seen := make(map[string]bool)
+	for _, rawID := range imageIDs {
+		id, filename, ok := ws.lookupImage(chatKey, rawID)
+		if !ok {
+			missing = append(missing, rawID)
+			continue
+		}
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		if len(referenced) >= maxImages {
+			dropped = append(dropped, id)
+			continue
+		}
+		referenced = append(referenced, map[string]string{"id": id, "filename": filename})
+	}
+
+	return referenced, missing, dropped
+}
+
+// handleAnalyzeCommand handles "ai analyze <id1,id2,...> [question]"
+func (ws *WhatsAppService) handleAnalyzeCommand(to types.JID, arg string, chatJID string) {
+	aiTools := ws.aiToolsForChat(chatJID)
+	if aiTools == nil {
+		ws.sendMessage(to, tools.ErrorMessageAIToolsNotInit)
+		return
+	}
+
+	idList, question, _ := strings.Cut(arg, " ")
+	var imageIDs []string
+	for _, id := range strings.Split(idList, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			imageIDs = append(imageIDs, id)
+		}
+	}
+	if len(imageIDs) == 0 {
+		ws.sendMessage(to, "Usage: ai analyze <id1,id2,...> [question]")
+		return
+	}
+
+	maxImages := tools.EnvInt("AI_ANALYZE_MAX_IMAGES", 4)
+	referenced, missing, dropped := ws.buildAnalyzeRequest(chatJID, imageIDs, maxImages)
+	if len(referenced) == 0 {
+		ws.sendMessage(to, fmt.Sprintf("❌ None of the requested images were found: %s", strings.Join(missing, ", ")))
+		return
+	}
+
+	prompt := tools.AnalyzeImagesPrompt
+	if question = strings.TrimSpace(question); question != "" {
+		prompt = fmt.Sprintf("%s\n\n%s", prompt, question)
+	}
+
+	go func() {
+		response, err := aiTools.ProcessTextWithAI(context.Background(), prompt, referenced, ws.history(chatJID), nil)
+		if err != nil {
+			fmt.Printf("Failed to analyze images for chat %s: %v\n", chatJID, err)
+			ws.sendMessage(to, tools.ErrorMessageProcessingMessage)
+			return
+		}
+
+		if len(missing) > 0 {
+			response += fmt.Sprintf("\n\n⚠️ Not found: %s", strings.Join(missing, ", "))
+		}
+		if len(dropped) > 0 {
+			response += fmt.Sprintf("\n\n⚠️ Skipped (max %d images): %s", maxImages, strings.Join(dropped, ", "))
+		}
+		ws.sendAIMessage(to, chatJID, response)
+	}()
+}
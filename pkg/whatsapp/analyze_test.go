@@ -0,0 +1,32 @@
+package whatsapp
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBuildAnalyzeRequest(t *testing.T) {
+	ws, _ := newTestService(t)
+	chat := "628120000000@s.whatsapp.net"
+	for _, id := range []string{"IMGA", "IMGB", "IMGC"} {
+		ws.rememberImage(chat, id, id+".jpg")
+	}
+	ws.rememberImage("628129999999@s.whatsapp.net", "OTHER", "OTHER.jpg")
+
+	// Command arguments arrive lowercased; duplicates and other chats' images don't count
+	referenced, missing, dropped := ws.buildAnalyzeRequest(chat, []string{"imga", "imgb", "other", "IMGA", "nope", "imgc"}, 2)
+
+	want := []map[string]string{
+		{"id": "IMGA", "filename": "IMGA.jpg"},
+		{"id": "IMGB", "filename": "IMGB.jpg"},
+	}
+	if !reflect.DeepEqual(referenced, want) {
+		t.Errorf("referenced = %v, want %v", referenced, want)
+	}
+	if !reflect.DeepEqual(missing, []string{"other", "nope"}) {
+		t.Errorf("missing = %v, want [other nope]", missing)
+	}
+	if !reflect.DeepEqual(dropped, []string{"IMGC"}) {
+		t.Errorf("dropped = %v, want [IMGC]", dropped)
+	}
+}
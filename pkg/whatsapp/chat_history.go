@@ -0,0 +1,156 @@
+package whatsapp
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+
+	"github.com/openai/openai-go"
+	"go.mau.fi/whatsmeow/types"
+)
+
+// AI replies run in their own goroutines, so the per-chat conversation state is only
+// touched through these helpers, which hold historyMu.
+
+// defaultMaxChatImages is how many images a chat keeps when AI_MAX_CHAT_IMAGES is unset
+const defaultMaxChatImages = 100
+
+// history returns a copy of a chat's AI conversation
+func (ws *WhatsAppService) history(chatKey string) []openai.ChatCompletionMessageParamUnion {
+	ws.historyMu.RLock()
+	defer ws.historyMu.RUnlock()
+	return slices.Clone(ws.chatHistory[chatKey])
+}
+
+// appendHistory adds messages to the end of a chat's AI conversation
+func (ws *WhatsAppService) appendHistory(chatKey string, messages ...openai.ChatCompletionMessageParamUnion) {
+	ws.historyMu.Lock()
+	defer ws.historyMu.Unlock()
+	ws.chatHistory[chatKey] = append(ws.chatHistory[chatKey], messages...)
+}
+
+// historyLen returns the number of messages in a chat's AI conversation
+func (ws *WhatsAppService) historyLen(chatKey string) int {
+	ws.historyMu.RLock()
+	defer ws.historyMu.RUnlock()
+	return len(ws.chatHistory[chatKey])
+}
+
+// rememberLastMessage records the most recent message handled in its chat
+func (ws *WhatsAppService) rememberLastMessage(info types.MessageInfo) {
+	ws.historyMu.Lock()
+	defer ws.historyMu.Unlock()
+	ws.lastMessages[info.Chat.String()] = info
+}
+
+// lastMessage returns the most recent message handled in a chat
+func (ws *WhatsAppService) lastMessage(chat types.JID) (types.MessageInfo, bool) {
+	ws.historyMu.RLock()
+	defer ws.historyMu.RUnlock()
+	info, ok := ws.lastMessages[chat.String()]
+	return info, ok
+}
+
+// rememberImage records a stored image so it can be quoted or analyzed later. Once a
+// chat has more than maxChatImages images the oldest are forgotten and their files
+// deleted.
+func (ws *WhatsAppService) rememberImage(chatKey, imageID, filename string) {
+	ws.historyMu.Lock()
+	if ws.imageHistory[chatKey] == nil {
+		ws.imageHistory[chatKey] = make(map[string]string)
+	}
+	if _, known := ws.imageHistory[chatKey][imageID]; !known {
+		ws.imageOrder[chatKey] = append(ws.imageOrder[chatKey], imageID)
+	}
+	ws.imageHistory[chatKey][imageID] = filename
+	evicted := ws.evictImagesLocked(chatKey)
+	ws.historyMu.Unlock()
+
+	for _, name := range evicted {
+		if err := os.Remove(filepath.Join(ws.imageDir(), name)); err != nil && !os.IsNotExist(err) {
+			fmt.Printf("Failed to delete evicted image %s: %v\n", name, err)
+		}
+	}
+	if len(evicted) > 0 {
+		fmt.Printf("Evicted %d old images from chat %s\n", len(evicted), chatKey)
+	}
+}
+
+// evictImagesLocked forgets a chat's oldest images beyond maxChatImages and returns the
+// files no longer referenced by any chat; must be called with historyMu held
+func (ws *WhatsAppService) evictImagesLocked(chatKey string) []string {
+	order := ws.imageOrder[chatKey]
+	if ws.maxChatImages <= 0 || len(order) <= ws.maxChatImages {
+		return nil
+	}
+
+	var files []string
+	for _, id := range order[:len(order)-ws.maxChatImages] {
+		if filename, ok := ws.imageHistory[chatKey][id]; ok {
+			files = append(files, filename)
+		}
+		delete(ws.imageHistory[chatKey], id)
+		delete(ws.processedImages[chatKey], id)
+	}
+	ws.imageOrder[chatKey] = slices.Clone(order[len(order)-ws.maxChatImages:])
+
+	// Deduplicated images may share a file with another chat's or a newer image
+	var unused []string
+	for _, filename := range files {
+		if !ws.imageFileInUseLocked(filename) {
+			unused = append(unused, filename)
+		}
+	}
+	return unused
+}
+
+// imageFileInUseLocked reports whether any chat still references an image file; must be
+// called with historyMu held
+func (ws *WhatsAppService) imageFileInUseLocked(filename string) bool {
+	for _, images := range ws.imageHistory {
+		for _, name := range images {
+			if name == filename {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// setChatImagesLocked replaces a chat's stored images, e.g. from a snapshot; must be
+// called with historyMu held
+func (ws *WhatsAppService) setChatImagesLocked(chatKey string, images map[string]string) {
+	ws.imageHistory[chatKey] = images
+	order := make([]string, 0, len(images))
+	for id := range images {
+		order = append(order, id)
+	}
+	sort.Strings(order) // the original order isn't saved
+	ws.imageOrder[chatKey] = order
+}
+
+// chatImages returns a copy of a chat's stored images, by message ID
+func (ws *WhatsAppService) chatImages(chatKey string) map[string]string {
+	ws.historyMu.RLock()
+	defer ws.historyMu.RUnlock()
+	return maps.Clone(ws.imageHistory[chatKey])
+}
+
+func (ws *WhatsAppService) hasImageBeenProcessedByAI(chatKey string, imageID string) bool {
+	ws.historyMu.RLock()
+	defer ws.historyMu.RUnlock()
+	return ws.processedImages[chatKey][imageID]
+}
+
+func (ws *WhatsAppService) markImageAsProcessedByAI(chatKey string, imageID string) {
+	ws.historyMu.Lock()
+	defer ws.historyMu.Unlock()
+	if ws.processedImages[chatKey] == nil {
+		ws.processedImages[chatKey] = make(map[string]bool)
+	}
+	ws.processedImages[chatKey][imageID] = true
+	fmt.Printf("Marked image as processed: %s for chat %s\n", imageID, chatKey)
+}
@@ -0,0 +1,92 @@
+package whatsapp
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"auto-lmk/pkg/tools"
+
+	waProto "go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+	"google.golang.org/protobuf/proto"
+)
+
+// TestConcurrentAIReplies fires messages into several AI-enabled chats so their replies
+// update the conversation state from concurrent goroutines; run it with -race.
+func TestConcurrentAIReplies(t *testing.T) {
+	ws, _ := newTestService(t)
+	ws.aiConfigured = true
+	ws.aiTools = tools.NewAITools(tools.DryRunProvider{}, "dry-run")
+	ws.rateLimiter = newRateLimiter(6000, 0)
+	ws.typing = typingSimulation{}
+	ws.streamChunkMode = tools.ChunkOnce
+
+	const chats, perChat = 3, 10
+	var chatJIDs []types.JID
+	for i := range chats {
+		chat := types.NewJID(fmt.Sprintf("62812000000%d", i), types.DefaultUserServer)
+		chatJIDs = append(chatJIDs, chat)
+		ws.SetAIEnabled(chat.String(), true)
+	}
+
+	// Events arrive one at a time like on the client's event loop; the replies don't
+	for n := range perChat {
+		for _, chat := range chatJIDs {
+			ws.handleMessage(&events.Message{
+				Info: types.MessageInfo{
+					MessageSource: types.MessageSource{Chat: chat, Sender: chat},
+					ID:            fmt.Sprintf("MSG%s%d", chat.User, n),
+					Timestamp:     time.Now(),
+				},
+				Message: &waProto.Message{Conversation: proto.String(fmt.Sprintf("message %d", n))},
+			})
+			ws.history(chat.String())
+			ws.AIEnabledChats()
+		}
+	}
+
+	deadline := time.Now().Add(10 * time.Second)
+	for _, chat := range chatJIDs {
+		for ws.historyLen(chat.String()) < 2*perChat {
+			if time.Now().After(deadline) {
+				t.Fatalf("chat %s has %d history messages, want %d", chat, ws.historyLen(chat.String()), 2*perChat)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+}
+
+func TestRememberImageEvictsOldest(t *testing.T) {
+	ws, _ := newTestService(t)
+	ws.maxChatImages = 2
+	chat := "628120000000@s.whatsapp.net"
+
+	for i := range 3 {
+		name := fmt.Sprintf("IMG%d.jpg", i)
+		if err := os.WriteFile(tools.DataPath(name), []byte("image"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		ws.rememberImage(chat, fmt.Sprintf("IMG%d", i), name)
+		ws.markImageAsProcessedByAI(chat, fmt.Sprintf("IMG%d", i))
+	}
+	// Another chat sharing the evicted image's file keeps it on disk
+	ws.rememberImage("628129999999@s.whatsapp.net", "OTHER", "IMG1.jpg")
+	ws.rememberImage(chat, "IMG3", "IMG3.jpg")
+
+	images := ws.chatImages(chat)
+	if len(images) != 2 || images["IMG2"] == "" || images["IMG3"] == "" {
+		t.Errorf("images = %v, want IMG2 and IMG3", images)
+	}
+	if ws.hasImageBeenProcessedByAI(chat, "IMG0") {
+		t.Error("evicted image still marked as processed")
+	}
+	if _, err := os.Stat(tools.DataPath("IMG0.jpg")); !os.IsNotExist(err) {
+		t.Errorf("evicted image file not deleted: %v", err)
+	}
+	if _, err := os.Stat(tools.DataPath("IMG1.jpg")); err != nil {
+		t.Errorf("image file still used by another chat was deleted: %v", err)
+	}
+}
@@ -0,0 +1,81 @@
+package whatsapp
+
+import (
+	"sync"
+
+	"auto-lmk/pkg/tools"
+)
+
+// clientServiceHandlerName is the name the AI service's event handler is tracked under
+const clientServiceHandlerName = "ai-service"
+
+// NewClientService creates an AI service answering the chats of a client owned by the
+// WhatsAppManager. The manager keeps connecting the client and counting its messages;
+// the service only handles AI replies and commands. Close it when the client goes away.
+func NewClientService(instance *tools.WhatsAppInstance) *WhatsAppService {
+	service := newWhatsAppService()
+	service.managed = true
+	service.whatsappClient = instance.Client
+	service.device = instance.Client.Store
+	service.whatsappDownloader = instance.Downloader
+	service.aiTools = instance.AITools
+	service.aiConfigured = instance.AITools != nil
+	service.instance = instance
+	service.handlerID = instance.AddHandler(clientServiceHandlerName, service.eventHandler)
+	go service.runOutboundQueue()
+	return service
+}
+
+// Close stops a service created by NewClientService from handling the client's events
+// and sending queued messages
+func (ws *WhatsAppService) Close() error {
+	if ws.instance != nil {
+		ws.instance.RemoveHandler(ws.handlerID)
+	}
+	ws.outbound.close()
+	return nil
+}
+
+// ClientServices runs a NewClientService for every client of a WhatsAppManager: created
+// when the client is added or restored, closed when it is removed or logged out
+type ClientServices struct {
+	mu       sync.RWMutex
+	services map[string]*WhatsAppService
+}
+
+// NewClientServices starts services for the manager's clients and keeps them in sync
+func NewClientServices(manager *tools.WhatsAppManager) *ClientServices {
+	cs := &ClientServices{services: make(map[string]*WhatsAppService)}
+	manager.OnClientAdded(cs.add)
+	manager.OnClientRemoved(cs.remove)
+	return cs
+}
+
+// Get returns the service answering a client's chats
+func (cs *ClientServices) Get(phoneID string) (*WhatsAppService, bool) {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	service, ok := cs.services[phoneID]
+	return service, ok
+}
+
+func (cs *ClientServices) add(instance *tools.WhatsAppInstance) {
+	service := NewClientService(instance)
+	cs.mu.Lock()
+	previous := cs.services[instance.PhoneID]
+	cs.services[instance.PhoneID] = service
+	cs.mu.Unlock()
+	if previous != nil {
+		previous.Close()
+	}
+}
+
+func (cs *ClientServices) remove(phoneID string) {
+	cs.mu.Lock()
+	service := cs.services[phoneID]
+	delete(cs.services, phoneID)
+	cs.mu.Unlock()
+	if service != nil {
+		service.Close()
+	}
+}
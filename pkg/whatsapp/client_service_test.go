@@ -0,0 +1,69 @@
+package whatsapp
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"auto-lmk/pkg/tools"
+	"auto-lmk/pkg/whatsapptest"
+
+	waProto "go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestClientServiceImageReply(t *testing.T) {
+	t.Setenv("AI_DRY_RUN", "true")
+	previous := tools.DataDir()
+	t.Cleanup(func() { tools.SetDataDir(previous) })
+
+	manager := tools.NewWhatsAppManager(t.TempDir())
+	t.Cleanup(func() { manager.Shutdown(context.Background()) })
+	instance, err := manager.AddClient("sales")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Downloads and replies go through a fake client; images stay in the client's directory
+	client := whatsapptest.NewFakeClient()
+	instance.Downloader = tools.NewWhatsAppDownloader(client)
+	instance.Downloader.SetDataDir(manager.ClientDataDir("sales"))
+
+	ws := NewClientService(instance)
+	t.Cleanup(func() { ws.Close() })
+	ws.whatsappClient = client
+	ws.rateLimiter = newRateLimiter(6000, 0)
+	ws.typing = typingSimulation{}
+	ws.progressReactions = progressReactions{}
+	ws.streamChunkMode = tools.ChunkOnce
+
+	chat := types.NewJID("628120000000", types.DefaultUserServer)
+	ws.SetAIEnabled(chat.String(), true)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, image.NewGray(image.Rect(0, 0, 64, 64)), nil); err != nil {
+		t.Fatal(err)
+	}
+	client.Downloads["/image/1"] = buf.Bytes()
+
+	incoming := incomingText(chat, "IMG1", "")
+	incoming.Message = &waProto.Message{ImageMessage: &waProto.ImageMessage{
+		DirectPath: proto.String("/image/1"),
+		Mimetype:   proto.String("image/jpeg"),
+		Caption:    proto.String("apa ini?"),
+	}}
+	ws.handleMessage(incoming)
+
+	waitForReply(t, client, chat, "gambar dirujuk: 1")
+	if _, err := os.Stat(filepath.Join(manager.ClientDataDir("sales"), "IMG1.jpg")); err != nil {
+		t.Errorf("image not saved in the client's data directory: %v", err)
+	}
+	if _, err := os.Stat(tools.DataPath("IMG1.jpg")); !os.IsNotExist(err) {
+		t.Errorf("image saved in the shared data directory: %v", err)
+	}
+}
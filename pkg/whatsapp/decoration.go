@@ -0,0 +1,55 @@
+package whatsapp
+
+import (
+	"strings"
+
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// disabledDecoration turns off a globally configured prefix or suffix for one chat
+const disabledDecoration = "none"
+
+// decorateAIResponse wraps an AI reply with the disclaimer prefix and footer suffix
+func decorateAIResponse(response, prefix, suffix string) string {
+	if prefix != "" {
+		response = prefix + " " + response
+	}
+	if suffix != "" {
+		response = response + "\n\n" + suffix
+	}
+	return response
+}
+
+// responseDecoration returns the prefix and suffix for AI replies in a chat,
+// applying per-chat overrides on top of AI_RESPONSE_PREFIX / AI_RESPONSE_SUFFIX
+func (ws *WhatsAppService) responseDecoration(chatKey string) (string, string) {
+	prefix, suffix := ws.aiResponsePrefix, ws.aiResponseSuffix
+	settings := ws.chatSettingsFor(chatKey)
+	if settings.ResponsePrefix != "" {
+		prefix = settings.ResponsePrefix
+	}
+	if settings.ResponseSuffix != "" {
+		suffix = settings.ResponseSuffix
+	}
+	if strings.EqualFold(prefix, disabledDecoration) {
+		prefix = ""
+	}
+	if strings.EqualFold(suffix, disabledDecoration) {
+		suffix = ""
+	}
+	return prefix, suffix
+}
+
+// sendAIMessage queues an AI-generated reply with the chat's prefix/suffix applied. Only
+// the delivered text is decorated; chat history keeps the plain response so the
+// decoration never reaches the model's context.
+func (ws *WhatsAppService) sendAIMessage(to types.JID, chatKey string, response string) {
+	ws.sendAIReply(to, nil, chatKey, response)
+}
+
+// sendAIReply is like sendAIMessage but quotes original, the message being answered
+func (ws *WhatsAppService) sendAIReply(to types.JID, original *events.Message, chatKey string, response string) {
+	prefix, suffix := ws.responseDecoration(chatKey)
+	ws.enqueueReply(to, original, decorateAIResponse(response, prefix, suffix))
+}
@@ -0,0 +1,76 @@
+package whatsapp
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+func TestResponseDecorationOnlyOnAIReplies(t *testing.T) {
+	ws, client := newTestService(t)
+	enableTestAI(t, ws)
+	ws.aiResponsePrefix = "🤖 Auto-reply:"
+	ws.aiResponseSuffix = "Pesan ini dibuat oleh AI"
+	chat := types.NewJID("628120000000", types.DefaultUserServer)
+	ws.SetAIEnabled(chat.String(), true)
+
+	ws.handleMessage(incomingText(chat, "CMD", "ai status"))
+	if reply := lastReply(t, client, chat); strings.Contains(reply, "Auto-reply") || strings.Contains(reply, "dibuat oleh AI") {
+		t.Errorf("command reply was decorated: %q", reply)
+	}
+
+	ws.handleMessage(incomingText(chat, "Q1", "halo bot"))
+	reply := waitForReply(t, client, chat, "halo bot")
+	if !strings.HasPrefix(reply, "🤖 Auto-reply: [dry run]") || !strings.HasSuffix(reply, "\n\nPesan ini dibuat oleh AI") {
+		t.Errorf("AI reply = %q, want it wrapped in the prefix and suffix", reply)
+	}
+
+	// The model's context only has the plain reply
+	history, err := json.Marshal(ws.history(chat.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(history), "Auto-reply") || strings.Contains(string(history), "dibuat oleh AI") {
+		t.Errorf("decoration stored in history: %s", history)
+	}
+
+	// The decorated reply echoed back from another linked device isn't answered
+	sent := len(client.Sent())
+	echo := incomingText(chat, "ECHO", reply)
+	echo.Info.IsFromMe = true
+	ws.handleMessage(echo)
+	ws.handleMessage(incomingText(chat, "Q2", "lagi"))
+	waitForReply(t, client, chat, "lagi")
+	for _, text := range client.SentTexts(chat)[sent:] {
+		if strings.Contains(text, "[dry run] 🤖") {
+			t.Errorf("bot answered its own decorated reply: %q", text)
+		}
+	}
+}
+
+func TestResponseDecorationPerChat(t *testing.T) {
+	ws, _ := newTestService(t)
+	ws.aiResponsePrefix = "🤖"
+	ws.aiResponseSuffix = "footer"
+	custom := "628120000000@s.whatsapp.net"
+	plain := "628129999999@s.whatsapp.net"
+	ws.setChatSettings(custom, ChatSettings{ResponsePrefix: "[bot]"})
+	ws.setChatSettings(plain, ChatSettings{ResponsePrefix: "none", ResponseSuffix: "NONE"})
+
+	tests := []struct {
+		chat string
+		want string
+	}{
+		{"628121111111@s.whatsapp.net", "🤖 jawaban\n\nfooter"},
+		{custom, "[bot] jawaban\n\nfooter"},
+		{plain, "jawaban"},
+	}
+	for _, tt := range tests {
+		prefix, suffix := ws.responseDecoration(tt.chat)
+		if got := decorateAIResponse("jawaban", prefix, suffix); got != tt.want {
+			t.Errorf("decorated reply in %s = %q, want %q", tt.chat, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package whatsapp
+
+import (
+	"sync"
+	"time"
+
+	"auto-lmk/pkg/tools"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+// seenMessages remembers recently handled message IDs so a message delivered twice,
+// as whatsmeow sometimes does after a reconnect, is only answered once
+type seenMessages struct {
+	mu      sync.Mutex
+	seen    map[string]time.Time
+	order   []string // oldest first, for evicting beyond maxSize
+	ttl     time.Duration
+	maxSize int
+}
+
+// newSeenMessages reads MESSAGE_DEDUP_SIZE (default 1000, 0 disables) and MESSAGE_DEDUP_TTL
+func newSeenMessages() *seenMessages {
+	return &seenMessages{
+		seen:    make(map[string]time.Time),
+		ttl:     tools.EnvDuration("MESSAGE_DEDUP_TTL", 10*time.Minute),
+		maxSize: tools.EnvInt("MESSAGE_DEDUP_SIZE", 1000),
+	}
+}
+
+// duplicate records a message and reports whether it was already seen within the window
+func (sm *seenMessages) duplicate(info types.MessageInfo, now time.Time) bool {
+	if sm.maxSize <= 0 || info.ID == "" {
+		return false
+	}
+	key := info.Chat.String() + "/" + info.Sender.ToNonAD().String() + "/" + info.ID
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	// Expire from the front; entries are in the order they were seen
+	for len(sm.order) > 0 && now.Sub(sm.seen[sm.order[0]]) > sm.ttl {
+		sm.evictOldest()
+	}
+
+	if _, ok := sm.seen[key]; ok {
+		return true
+	}
+
+	// Only a new ID makes room, so a repeat of the oldest one is still caught
+	for len(sm.order) >= sm.maxSize {
+		sm.evictOldest()
+	}
+	sm.seen[key] = now
+	sm.order = append(sm.order, key)
+	return false
+}
+
+func (sm *seenMessages) evictOldest() {
+	delete(sm.seen, sm.order[0])
+	sm.order = sm.order[1:]
+}
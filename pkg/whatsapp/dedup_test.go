@@ -0,0 +1,41 @@
+package whatsapp
+
+import (
+	"testing"
+	"time"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+// TestSeenMessagesAtCapacity repeats the oldest ID once the window is full; it must
+// still be reported as a duplicate rather than evicted and accepted again
+func TestSeenMessagesAtCapacity(t *testing.T) {
+	sm := &seenMessages{seen: make(map[string]time.Time), ttl: time.Minute, maxSize: 2}
+	chat := types.NewJID("6281200000001", types.DefaultUserServer)
+	msg := func(id types.MessageID) types.MessageInfo {
+		return types.MessageInfo{MessageSource: types.MessageSource{Chat: chat, Sender: chat}, ID: id}
+	}
+	now := time.Now()
+
+	for _, id := range []types.MessageID{"A", "B"} {
+		if sm.duplicate(msg(id), now) {
+			t.Fatalf("%s reported as duplicate on first delivery", id)
+		}
+	}
+	if !sm.duplicate(msg("A"), now) {
+		t.Fatal("oldest ID accepted again at capacity")
+	}
+
+	// A new ID evicts the oldest one
+	if sm.duplicate(msg("C"), now) {
+		t.Fatal("C reported as duplicate on first delivery")
+	}
+	if sm.duplicate(msg("A"), now) {
+		t.Fatal("A still remembered after eviction")
+	}
+
+	// Entries past the TTL are forgotten
+	if sm.duplicate(msg("B"), now.Add(2*time.Minute)) {
+		t.Fatal("B remembered past the TTL")
+	}
+}
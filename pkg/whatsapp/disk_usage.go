@@ -0,0 +1,52 @@
+package whatsapp
+
+import (
+	"fmt"
+	"strings"
+
+	"auto-lmk/pkg/tools"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+// diskUsageTopChats caps how many chats the disk command lists
+const diskUsageTopChats = 15
+
+// imageOwners maps stored image file names to their chats, from the service's image
+// history and the downloader's historical image metadata
+func (ws *WhatsAppService) imageOwners() map[string]string {
+	owners := make(map[string]string)
+	if ws.whatsappDownloader != nil {
+		owners = ws.whatsappDownloader.ImageOwners()
+	}
+	ws.historyMu.RLock()
+	defer ws.historyMu.RUnlock()
+	for chat, images := range ws.imageHistory {
+		for _, filename := range images {
+			owners[filename] = chat
+		}
+	}
+	return owners
+}
+
+// handleDiskCommand reports image storage per chat, largest first
+func (ws *WhatsAppService) handleDiskCommand(to types.JID) {
+	files, err := tools.ImageFiles(ws.imageDir())
+	if err != nil {
+		ws.sendMessage(to, fmt.Sprintf("❌ Failed to scan storage: %v", err))
+		return
+	}
+
+	usage, total := tools.AggregateDiskUsage(files, ws.imageOwners())
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "💽 Image storage: %s in %d files\n", tools.FormatBytes(total), len(files))
+	for i, u := range usage {
+		if i == diskUsageTopChats {
+			fmt.Fprintf(&sb, "...and %d more chats", len(usage)-i)
+			break
+		}
+		fmt.Fprintf(&sb, "\n%s: %s (%d files)", u.Chat, tools.FormatBytes(u.Bytes), u.Files)
+	}
+	ws.sendMessage(to, sb.String())
+}
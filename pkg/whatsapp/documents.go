@@ -0,0 +1,103 @@
+package whatsapp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"auto-lmk/pkg/tools"
+
+	"github.com/openai/openai-go"
+	waProto "go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+)
+
+// documentMessage returns the document in a message, including captioned documents,
+// which WhatsApp wraps in a DocumentWithCaptionMessage
+func documentMessage(message *waProto.Message) *waProto.DocumentMessage {
+	if doc := message.GetDocumentMessage(); doc != nil {
+		return doc
+	}
+	return message.GetDocumentWithCaptionMessage().GetMessage().GetDocumentMessage()
+}
+
+// documentName returns a document's file name, falling back to its title
+func documentName(docMsg *waProto.DocumentMessage) string {
+	if name := docMsg.GetFileName(); name != "" {
+		return name
+	}
+	return docMsg.GetTitle()
+}
+
+// handleDocumentMessageWithAI extracts the text of a PDF or text document and answers the
+// caption about it, or summarizes it when there is no caption. Only a short note about
+// the document is kept in chat history so large files don't fill the context.
+func (ws *WhatsAppService) handleDocumentMessageWithAI(info types.MessageInfo, docMsg *waProto.DocumentMessage) {
+	chatKey := info.Chat.String()
+	aiTools := ws.aiToolsForChat(chatKey)
+	if aiTools == nil {
+		ws.sendMessage(info.Chat, tools.ErrorMessageAIToolsNotInit)
+		return
+	}
+	if !ws.allowAIReply(info.Chat) {
+		return
+	}
+
+	stopTyping := ws.startTyping(info.Chat)
+	defer stopTyping()
+
+	ctx := context.Background()
+	name := documentName(docMsg)
+	data, err := ws.whatsappDownloader.DownloadDocument(ctx, info, docMsg)
+	if err != nil {
+		fmt.Printf("Failed to download document from %s: %v\n", info.Sender.User, err)
+		stopTyping()
+		ws.sendMessage(info.Chat, tools.ErrorMessageDocumentProcessing)
+		return
+	}
+
+	text, err := tools.ExtractDocumentText(ctx, data, docMsg.GetMimetype(), name)
+	if err != nil {
+		fmt.Printf("Failed to extract text from document %s: %v\n", name, err)
+		stopTyping()
+		if errors.Is(err, tools.ErrUnsupportedDocument) {
+			ws.sendMessage(info.Chat, tools.ErrorMessageDocumentType)
+		} else {
+			ws.sendMessage(info.Chat, tools.ErrorMessageDocumentProcessing)
+		}
+		return
+	}
+	if text == "" {
+		stopTyping()
+		ws.sendMessage(info.Chat, tools.ErrorMessageDocumentEmpty)
+		return
+	}
+
+	note := ""
+	if truncated, ok := tools.TruncateText(text, ws.documentMaxChars); ok {
+		fmt.Printf("Document %s truncated to %d characters\n", name, ws.documentMaxChars)
+		text = truncated
+		note = fmt.Sprintf(tools.DocumentTruncatedNote, ws.documentMaxChars)
+	}
+
+	request := docMsg.GetCaption()
+	if request == "" {
+		request = tools.DefaultDocumentPrompt
+	}
+	prompt := fmt.Sprintf(tools.DocumentTemplate, name, note, text, request)
+
+	history := ws.history(chatKey)
+	response, err := aiTools.ProcessTextWithAI(ctx, prompt, nil, history, nil)
+	if err != nil {
+		fmt.Printf("Failed to process document %s with AI: %v\n", name, err)
+		stopTyping()
+		ws.sendMessage(info.Chat, tools.ErrorMessageDocumentProcessing)
+		return
+	}
+
+	summary := fmt.Sprintf("[Dokumen: %s] %s", name, request)
+	ws.appendHistory(chatKey, openai.UserMessage(summary), openai.AssistantMessage(response))
+
+	stopTyping()
+	ws.sendAIMessage(info.Chat, chatKey, response)
+}
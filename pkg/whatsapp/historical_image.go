@@ -0,0 +1,52 @@
+package whatsapp
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+// handleGetImageCommand handles "ai getimage <messageID>", downloading an image found in
+// the chat's history sync on demand and sending it back into the chat
+func (ws *WhatsAppService) handleGetImageCommand(to types.JID, arg string, chatJID string) {
+	messageID := strings.TrimSpace(arg)
+	if messageID == "" {
+		ws.sendMessage(to, "Usage: ai getimage <messageID>")
+		return
+	}
+	if ws.whatsappDownloader == nil {
+		ws.sendMessage(to, "❌ WhatsApp downloader is not initialized.")
+		return
+	}
+
+	// Images from other chats are reported as missing rather than leaked
+	info, ok := ws.whatsappDownloader.GetHistoricalImageInfo(types.MessageID(messageID))
+	if !ok || info.ChatJID.String() != chatJID {
+		ws.sendMessage(to, fmt.Sprintf("❌ No historical image with message ID %s in this chat.", messageID))
+		return
+	}
+
+	go func() {
+		path, err := ws.whatsappDownloader.DownloadHistoricalImage(context.Background(), info)
+		if err != nil {
+			fmt.Printf("Failed to download historical image %s: %v\n", messageID, err)
+			ws.sendMessage(to, fmt.Sprintf("❌ Failed to download image %s. It may have expired on WhatsApp's servers.", messageID))
+			return
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Printf("Failed to read historical image %s: %v\n", path, err)
+			ws.sendMessage(to, fmt.Sprintf("❌ Failed to read image %s.", messageID))
+			return
+		}
+
+		if err := ws.sendImage(to, data, info.ImageMsg.GetCaption()); err != nil {
+			fmt.Printf("Failed to send historical image %s: %v\n", messageID, err)
+			ws.sendMessage(to, fmt.Sprintf("❌ Failed to send image %s.", messageID))
+		}
+	}()
+}
@@ -0,0 +1,55 @@
+package whatsapp
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"auto-lmk/pkg/tools"
+)
+
+// ChatHistoryExport is the JSON document written by ExportChatHistory
+type ChatHistoryExport struct {
+	ChatJID    string    `json:"chatJID"`
+	ExportedAt time.Time `json:"exportedAt"`
+	Messages   []any     `json:"messages"`
+}
+
+// ExportChatHistory serializes a chat's AI conversation to JSON, with inline image data
+// replaced by a placeholder so the export stays readable
+func (ws *WhatsAppService) ExportChatHistory(chatJID string) ([]byte, error) {
+	messages, err := tools.RedactMessages(ws.history(chatJID))
+	if err != nil {
+		return nil, err
+	}
+	if messages == nil {
+		messages = []any{}
+	}
+
+	data, err := json.MarshalIndent(ChatHistoryExport{ChatJID: chatJID, ExportedAt: time.Now(), Messages: messages}, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal chat history: %w", err)
+	}
+	return data, nil
+}
+
+// exportChatHistoryToFile writes a chat's history export to data/exports and returns its path
+func (ws *WhatsAppService) exportChatHistoryToFile(chatJID string) (string, error) {
+	data, err := ws.ExportChatHistory(chatJID)
+	if err != nil {
+		return "", err
+	}
+
+	chatName := strings.NewReplacer("@", "_", ":", "_", ".", "_").Replace(chatJID)
+	path := tools.DataPath("exports", fmt.Sprintf("%s_%s.json", chatName, time.Now().Format("20060102_150405")))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("failed to create export directory: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return "", fmt.Errorf("failed to save chat history to %s: %w", path, err)
+	}
+	return path, nil
+}
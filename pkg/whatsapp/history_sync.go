@@ -0,0 +1,82 @@
+package whatsapp
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+// Limits for "ai synchistory"; the phone answers about 50 messages per request best
+const (
+	defaultHistorySyncCount = 50
+	maxHistorySyncCount     = 500
+	historySyncTimeout      = 2 * time.Minute
+)
+
+// historySyncAnchor returns the message to request older history before: the oldest
+// indexed image in the chat, so repeated requests page further back, or else the most
+// recent message handled in the chat
+func (ws *WhatsAppService) historySyncAnchor(chat types.JID) (types.MessageInfo, bool) {
+	if oldest, ok := ws.whatsappDownloader.OldestHistoricalImage(chat); ok {
+		var info types.MessageInfo
+		info.Chat = oldest.ChatJID
+		info.Sender = oldest.SenderJID
+		info.ID = oldest.MessageID
+		info.Timestamp = oldest.Timestamp
+		return info, true
+	}
+
+	return ws.lastMessage(chat)
+}
+
+// handleSyncHistoryCommand handles "ai synchistory [count]", asking the phone for older
+// messages of this chat so their images can be fetched with "ai getimage"
+func (ws *WhatsAppService) handleSyncHistoryCommand(to types.JID, arg string, chatJID string) {
+	if !ws.requireAdmin(to) {
+		return
+	}
+	if ws.whatsappDownloader == nil {
+		ws.sendMessage(to, "❌ WhatsApp downloader not initialized.")
+		return
+	}
+
+	count := defaultHistorySyncCount
+	if arg != "" {
+		n, err := strconv.Atoi(arg)
+		if err != nil || n <= 0 || n > maxHistorySyncCount {
+			ws.sendMessage(to, fmt.Sprintf("Usage: ai synchistory [count], count between 1 and %d", maxHistorySyncCount))
+			return
+		}
+		count = n
+	}
+
+	chat, err := types.ParseJID(chatJID)
+	if err != nil {
+		ws.sendMessage(to, "❌ Invalid chat.")
+		return
+	}
+	anchor, ok := ws.historySyncAnchor(chat)
+	if !ok {
+		ws.sendMessage(to, "❌ No known message in this chat to sync history from yet.")
+		return
+	}
+
+	ws.sendMessage(to, fmt.Sprintf("⏳ Requesting %d older messages from the phone...", count))
+
+	// The answer arrives as an event, so wait outside the event handler
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), historySyncTimeout)
+		defer cancel()
+
+		added, err := ws.whatsappDownloader.RequestHistorySyncAndWait(ctx, &anchor, count)
+		if err != nil {
+			fmt.Printf("History sync for chat %s failed: %v\n", chatJID, err)
+			ws.sendMessage(to, fmt.Sprintf("❌ History sync failed: %v", err))
+			return
+		}
+		ws.sendMessage(to, fmt.Sprintf("✅ History sync done, %d new images indexed.", added))
+	}()
+}
@@ -0,0 +1,114 @@
+package whatsapp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"auto-lmk/pkg/tools"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+// dailyCount tracks how many images a chat generated on a given day
+type dailyCount struct {
+	day   string
+	count int
+}
+
+// reserveImageGeneration counts an image generation against the chat's daily limit,
+// returning false when the limit has been reached
+func (ws *WhatsAppService) reserveImageGeneration(chatKey string) bool {
+	today := time.Now().Format("2006-01-02")
+
+	ws.imageGenMu.Lock()
+	defer ws.imageGenMu.Unlock()
+	usage, ok := ws.imageGenUsage[chatKey]
+	if !ok || usage.day != today {
+		usage = &dailyCount{day: today}
+		ws.imageGenUsage[chatKey] = usage
+	}
+
+	if ws.imageGenDailyLimit > 0 && usage.count >= ws.imageGenDailyLimit {
+		return false
+	}
+	usage.count++
+	return true
+}
+
+// setImageGenEnabled turns image generation on or off for a chat
+func (ws *WhatsAppService) setImageGenEnabled(chatKey string, enabled bool) {
+	ws.imageGenMu.Lock()
+	defer ws.imageGenMu.Unlock()
+	if enabled {
+		ws.imageGenEnabledChats[chatKey] = true
+		return
+	}
+	delete(ws.imageGenEnabledChats, chatKey)
+}
+
+// isImageGenEnabled reports whether messages in a chat may ask for generated images
+func (ws *WhatsAppService) isImageGenEnabled(chatKey string) bool {
+	ws.imageGenMu.Lock()
+	defer ws.imageGenMu.Unlock()
+	return ws.imageGenEnabledChats[chatKey]
+}
+
+// handleImageGenerationRequest generates and sends an image when the message asks for one.
+// It returns true when the message was handled as an image request.
+func (ws *WhatsAppService) handleImageGenerationRequest(to types.JID, chat types.JID, message string) bool {
+	chatKey := chat.String()
+	aiTools := ws.aiToolsForChat(chatKey)
+	if !ws.isImageGenEnabled(chatKey) || aiTools == nil {
+		return false
+	}
+
+	ctx := context.Background()
+	prompt, wantsImage, err := aiTools.DetectImageGenerationIntent(ctx, message)
+	if err != nil {
+		fmt.Printf("Failed to detect image generation intent for chat %s: %v\n", chatKey, err)
+		return false
+	}
+	if !wantsImage {
+		return false
+	}
+
+	if !ws.reserveImageGeneration(chatKey) {
+		ws.sendMessage(to, tools.ErrorMessageImageGenLimit)
+		return true
+	}
+
+	fmt.Printf("Generating image for chat %s: %s\n", chatKey, prompt)
+	imageData, err := aiTools.GenerateImage(ctx, prompt)
+	if errors.Is(err, tools.ErrImageContentRejected) {
+		fmt.Printf("Image prompt rejected for chat %s: %v\n", chatKey, err)
+		ws.sendMessage(to, tools.ErrorMessageImageRejected)
+		return true
+	}
+	if err != nil {
+		fmt.Printf("Failed to generate image for chat %s: %v\n", chatKey, err)
+		ws.sendMessage(to, tools.ErrorMessageImageGeneration)
+		return true
+	}
+
+	if err := ws.sendImage(chat, imageData, ""); err != nil {
+		fmt.Printf("Failed to send generated image to %s: %v\n", chatKey, err)
+		ws.sendMessage(to, tools.ErrorMessageSendingResponse)
+	}
+	return true
+}
+
+func (ws *WhatsAppService) handleImageGenCommand(to types.JID, arg string, chatJID string) {
+	switch strings.ToLower(arg) {
+	case "on":
+		ws.setImageGenEnabled(chatJID, true)
+		ws.sendMessage(to, fmt.Sprintf("🎨 Image generation enabled for this chat (limit %d per day).", ws.imageGenDailyLimit))
+	case "off":
+		ws.setImageGenEnabled(chatJID, false)
+		ws.sendMessage(to, "🎨 Image generation disabled for this chat.")
+	default:
+		ws.sendMessage(to, aiCommandHelp)
+	}
+}
@@ -0,0 +1,34 @@
+package whatsapp
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// TestReserveImageGenerationConcurrent reserves images from concurrent replies while the
+// command toggles the chat; run it with -race
+func TestReserveImageGenerationConcurrent(t *testing.T) {
+	ws, _ := newTestService(t)
+	ws.imageGenDailyLimit = 5
+	chat := "628120000000@s.whatsapp.net"
+
+	var reserved atomic.Int32
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ws.setImageGenEnabled(chat, i%2 == 0)
+			ws.isImageGenEnabled(chat)
+			if ws.reserveImageGeneration(chat) {
+				reserved.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := reserved.Load(); n != 5 {
+		t.Errorf("%d images reserved, want the daily limit of 5", n)
+	}
+}
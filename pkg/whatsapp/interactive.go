@@ -0,0 +1,56 @@
+package whatsapp
+
+import (
+	"fmt"
+
+	"auto-lmk/pkg/tools"
+
+	waProto "go.mau.fi/whatsmeow/proto/waE2E"
+)
+
+// InteractiveReply is a user's selection in response to a button, list or template message
+type InteractiveReply struct {
+	Kind        string // "button", "list" or "template"
+	SelectedID  string
+	DisplayText string
+}
+
+// extractInteractiveReply returns the selection carried by an interactive reply message, or nil
+func extractInteractiveReply(message *waProto.Message) *InteractiveReply {
+	switch {
+	case message.GetButtonsResponseMessage() != nil:
+		resp := message.GetButtonsResponseMessage()
+		return &InteractiveReply{
+			Kind:        "button",
+			SelectedID:  resp.GetSelectedButtonID(),
+			DisplayText: resp.GetSelectedDisplayText(),
+		}
+	case message.GetListResponseMessage() != nil:
+		resp := message.GetListResponseMessage()
+		return &InteractiveReply{
+			Kind:        "list",
+			SelectedID:  resp.GetSingleSelectReply().GetSelectedRowID(),
+			DisplayText: resp.GetTitle(),
+		}
+	case message.GetTemplateButtonReplyMessage() != nil:
+		resp := message.GetTemplateButtonReplyMessage()
+		return &InteractiveReply{
+			Kind:        "template",
+			SelectedID:  resp.GetSelectedID(),
+			DisplayText: resp.GetSelectedDisplayText(),
+		}
+	}
+	return nil
+}
+
+// Text renders the selection as message text for the normal pipeline
+func (r *InteractiveReply) Text() string {
+	display := r.DisplayText
+	if display == "" {
+		display = r.SelectedID
+	}
+	if r.SelectedID == "" || r.SelectedID == display {
+		return display
+	}
+	return fmt.Sprintf(tools.InteractiveReplyTemplate, display, r.SelectedID)
+}
@@ -0,0 +1,72 @@
+package whatsapp
+
+import (
+	"testing"
+
+	waProto "go.mau.fi/whatsmeow/proto/waE2E"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestExtractInteractiveReply(t *testing.T) {
+	tests := []struct {
+		name     string
+		message  *waProto.Message
+		wantKind string
+		wantText string
+	}{
+		{
+			name: "button",
+			message: &waProto.Message{ButtonsResponseMessage: &waProto.ButtonsResponseMessage{
+				SelectedButtonID: proto.String("btn_yes"),
+				Response:         &waProto.ButtonsResponseMessage_SelectedDisplayText{SelectedDisplayText: "Yes"},
+			}},
+			wantKind: "button",
+			wantText: "Yes\n\n[Pilihan ID: btn_yes]",
+		},
+		{
+			name: "list",
+			message: &waProto.Message{ListResponseMessage: &waProto.ListResponseMessage{
+				Title:             proto.String("Toyota Avanza"),
+				SingleSelectReply: &waProto.ListResponseMessage_SingleSelectReply{SelectedRowID: proto.String("row_avanza")},
+			}},
+			wantKind: "list",
+			wantText: "Toyota Avanza\n\n[Pilihan ID: row_avanza]",
+		},
+		{
+			name: "template",
+			message: &waProto.Message{TemplateButtonReplyMessage: &waProto.TemplateButtonReplyMessage{
+				SelectedID:          proto.String("tpl_1"),
+				SelectedDisplayText: proto.String("Book a test drive"),
+			}},
+			wantKind: "template",
+			wantText: "Book a test drive\n\n[Pilihan ID: tpl_1]",
+		},
+		{
+			name: "ID without display text",
+			message: &waProto.Message{TemplateButtonReplyMessage: &waProto.TemplateButtonReplyMessage{
+				SelectedID: proto.String("tpl_2"),
+			}},
+			wantKind: "template",
+			wantText: "tpl_2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reply := extractInteractiveReply(tt.message)
+			if reply == nil {
+				t.Fatal("extractInteractiveReply = nil")
+			}
+			if reply.Kind != tt.wantKind {
+				t.Errorf("Kind = %q, want %q", reply.Kind, tt.wantKind)
+			}
+			if got := reply.Text(); got != tt.wantText {
+				t.Errorf("Text() = %q, want %q", got, tt.wantText)
+			}
+		})
+	}
+
+	if reply := extractInteractiveReply(&waProto.Message{Conversation: proto.String("hi")}); reply != nil {
+		t.Errorf("extractInteractiveReply(text) = %+v, want nil", reply)
+	}
+}
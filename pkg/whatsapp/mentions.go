@@ -0,0 +1,89 @@
+package whatsapp
+
+import (
+	"fmt"
+	"strings"
+
+	waProto "go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+)
+
+// messageContextInfo returns the ContextInfo (mentions, quoted message) of common message types
+func messageContextInfo(message *waProto.Message) *waProto.ContextInfo {
+	switch {
+	case message.GetExtendedTextMessage() != nil:
+		return message.GetExtendedTextMessage().GetContextInfo()
+	case message.GetImageMessage() != nil:
+		return message.GetImageMessage().GetContextInfo()
+	case message.GetVideoMessage() != nil:
+		return message.GetVideoMessage().GetContextInfo()
+	case message.GetAudioMessage() != nil:
+		return message.GetAudioMessage().GetContextInfo()
+	case message.GetDocumentMessage() != nil:
+		return message.GetDocumentMessage().GetContextInfo()
+	case message.GetStickerMessage() != nil:
+		return message.GetStickerMessage().GetContextInfo()
+	}
+	return nil
+}
+
+// isAddressedTo reports whether a message @-mentions or quotes one of the given accounts
+func isAddressedTo(message *waProto.Message, own ...types.JID) bool {
+	ctxInfo := messageContextInfo(message)
+	if ctxInfo == nil {
+		return false
+	}
+
+	isOwn := func(raw string) bool {
+		jid, err := types.ParseJID(raw)
+		if err != nil {
+			return false
+		}
+		for _, o := range own {
+			if sameAccount(jid.ToNonAD(), o.ToNonAD()) {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, mentioned := range ctxInfo.GetMentionedJID() {
+		if isOwn(mentioned) {
+			return true
+		}
+	}
+	return ctxInfo.GetQuotedMessage() != nil && isOwn(ctxInfo.GetParticipant())
+}
+
+// shouldReply reports whether the AI should answer a message. In groups with mention-only
+// mode enabled, only messages that mention or quote the bot get a reply.
+func (ws *WhatsAppService) shouldReply(info types.MessageInfo, message *waProto.Message) bool {
+	if info.Chat.Server != types.GroupServer || !ws.groupMentionOnly[info.Chat.String()] {
+		return true
+	}
+	if ws.whatsappClient == nil {
+		return false
+	}
+	return isAddressedTo(message, ws.device.GetJID(), ws.device.GetLID())
+}
+
+func (ws *WhatsAppService) handleMentionCommand(to types.JID, arg string, chatJID string) {
+	chat, err := types.ParseJID(chatJID)
+	if err != nil || chat.Server != types.GroupServer {
+		ws.sendMessage(to, "❌ Mention-only mode is only available in group chats.")
+		return
+	}
+
+	switch strings.ToLower(arg) {
+	case "on":
+		ws.groupMentionOnly[chatJID] = true
+		ws.sendMessage(to, "🔔 Mention-only mode enabled. I'll only reply when mentioned or quoted.")
+	case "off":
+		delete(ws.groupMentionOnly, chatJID)
+		ws.sendMessage(to, "🔔 Mention-only mode disabled. I'll reply to every message.")
+	case "":
+		ws.sendMessage(to, fmt.Sprintf("🔔 Mention-only mode: %t", ws.groupMentionOnly[chatJID]))
+	default:
+		ws.sendMessage(to, aiCommandHelp)
+	}
+}
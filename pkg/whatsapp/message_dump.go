@@ -0,0 +1,165 @@
+package whatsapp
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"auto-lmk/pkg/tools"
+
+	waProto "go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Message dump modes for DEBUG_DUMP_MESSAGES
+const (
+	dumpModeOff         = ""
+	dumpModeUnsupported = "unsupported" // only messages no handler understood
+	dumpModeAll         = "all"
+)
+
+// messageDumper writes redacted raw message protos to disk for debugging parsing issues
+type messageDumper struct {
+	mode     string
+	dir      string
+	maxBytes int64
+	maxAge   time.Duration
+	mu       sync.Mutex
+}
+
+// newMessageDumper configures dumping from DEBUG_DUMP_MESSAGES ("unsupported", "all",
+// or "true" as an alias for "unsupported"); it returns nil when dumping is disabled
+func newMessageDumper() *messageDumper {
+	mode := strings.ToLower(strings.TrimSpace(os.Getenv("DEBUG_DUMP_MESSAGES")))
+	switch mode {
+	case dumpModeOff, "false", "0", "off":
+		return nil
+	case "true", "1", "on":
+		mode = dumpModeUnsupported
+	case dumpModeUnsupported, dumpModeAll:
+	default:
+		fmt.Printf("Invalid DEBUG_DUMP_MESSAGES value %q, message dumping disabled\n", mode)
+		return nil
+	}
+
+	return &messageDumper{
+		mode:     mode,
+		dir:      tools.EnvString("DEBUG_DUMP_DIR", tools.DataPath("message_dumps")),
+		maxBytes: int64(tools.EnvInt("DEBUG_DUMP_MAX_MB", 50)) * 1024 * 1024,
+		maxAge:   tools.EnvDuration("DEBUG_DUMP_MAX_AGE", 7*24*time.Hour),
+	}
+}
+
+// dumps reports whether dumping is enabled in the given mode
+func (md *messageDumper) dumps(mode string) bool {
+	return md != nil && md.mode == mode
+}
+
+// Dump writes the message as JSON with media payloads redacted, then enforces the
+// directory's size and age limits
+func (md *messageDumper) Dump(info types.MessageInfo, message *waProto.Message) {
+	data, err := marshalRedactedMessage(message)
+	if err != nil {
+		fmt.Printf("Failed to serialize message %s for dump: %v\n", info.ID, err)
+		return
+	}
+
+	md.mu.Lock()
+	defer md.mu.Unlock()
+
+	if err := os.MkdirAll(md.dir, 0755); err != nil {
+		fmt.Printf("Failed to create message dump directory: %v\n", err)
+		return
+	}
+
+	name := fmt.Sprintf("%s_%s.json", info.Timestamp.Format("20060102_150405"), info.ID)
+	header := fmt.Sprintf("// chat=%s sender=%s type=%s\n", info.Chat, info.Sender, messageTypeName(message))
+	if err := os.WriteFile(filepath.Join(md.dir, name), append([]byte(header), data...), 0644); err != nil {
+		fmt.Printf("Failed to write message dump %s: %v\n", name, err)
+		return
+	}
+	fmt.Printf("Dumped raw message %s to %s\n", info.ID, name)
+
+	md.cleanup()
+}
+
+// cleanup removes dumps older than maxAge and then the oldest dumps until the directory
+// is under maxBytes
+func (md *messageDumper) cleanup() {
+	entries, err := os.ReadDir(md.dir)
+	if err != nil {
+		return
+	}
+
+	type dumpFile struct {
+		path    string
+		size    int64
+		modTime time.Time
+	}
+	var files []dumpFile
+	var total int64
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || entry.IsDir() {
+			continue
+		}
+		path := filepath.Join(md.dir, entry.Name())
+		if md.maxAge > 0 && time.Since(info.ModTime()) > md.maxAge {
+			os.Remove(path)
+			continue
+		}
+		files = append(files, dumpFile{path: path, size: info.Size(), modTime: info.ModTime()})
+		total += info.Size()
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+	for _, f := range files {
+		if md.maxBytes <= 0 || total <= md.maxBytes {
+			break
+		}
+		if err := os.Remove(f.path); err == nil {
+			total -= f.size
+		}
+	}
+}
+
+// marshalRedactedMessage serializes a copy of the message to JSON with all binary
+// fields (thumbnails, media keys, hashes) cleared
+func marshalRedactedMessage(message *waProto.Message) ([]byte, error) {
+	clone := proto.Clone(message)
+	redactBytesFields(clone.ProtoReflect())
+	return protojson.MarshalOptions{Multiline: true, Indent: "  "}.Marshal(clone)
+}
+
+// redactBytesFields recursively clears bytes fields so media payloads never reach disk
+func redactBytesFields(m protoreflect.Message) {
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		switch {
+		case fd.Kind() == protoreflect.BytesKind:
+			m.Clear(fd)
+		case fd.Kind() != protoreflect.MessageKind && fd.Kind() != protoreflect.GroupKind:
+		case fd.IsList():
+			list := v.List()
+			for i := 0; i < list.Len(); i++ {
+				redactBytesFields(list.Get(i).Message())
+			}
+		case fd.IsMap():
+			v.Map().Range(func(_ protoreflect.MapKey, mv protoreflect.Value) bool {
+				if fd.MapValue().Kind() == protoreflect.MessageKind {
+					redactBytesFields(mv.Message())
+				}
+				return true
+			})
+		default:
+			redactBytesFields(v.Message())
+		}
+		return true
+	})
+}
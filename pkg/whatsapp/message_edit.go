@@ -0,0 +1,74 @@
+package whatsapp
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	waProto "go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+	"google.golang.org/protobuf/proto"
+)
+
+// MessageEditWindow is how long after sending a message it is still edited in place.
+// WhatsApp accepts edits a little longer, this leaves room for clock skew.
+const MessageEditWindow = 15 * time.Minute
+
+// sentMessages remembers when recent messages were sent so edits can respect the edit window
+type sentMessages struct {
+	mu     sync.Mutex
+	sentAt map[types.MessageID]time.Time
+}
+
+func newSentMessages() *sentMessages {
+	return &sentMessages{sentAt: make(map[types.MessageID]time.Time)}
+}
+
+// record stores a message's send time and forgets messages that can no longer be edited
+func (sm *sentMessages) record(id types.MessageID, at time.Time) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	for oldID, sent := range sm.sentAt {
+		if at.Sub(sent) > MessageEditWindow {
+			delete(sm.sentAt, oldID)
+		}
+	}
+	sm.sentAt[id] = at
+}
+
+// editable reports whether a message can still be edited; messages sent before a
+// restart aren't known and are assumed editable
+func (sm *sentMessages) editable(id types.MessageID, now time.Time) bool {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	sent, ok := sm.sentAt[id]
+	return !ok || now.Sub(sent) <= MessageEditWindow
+}
+
+// editMessage replaces the text of a message previously sent with sendMessage. Once the
+// edit window has passed the new text is sent as a new message instead, whose ID is returned.
+func (ws *WhatsAppService) editMessage(chat types.JID, messageID types.MessageID, newText string) (types.MessageID, error) {
+	if ws.whatsappClient == nil {
+		return "", fmt.Errorf("WhatsApp client not initialized")
+	}
+
+	if !ws.sentMessages.editable(messageID, time.Now()) {
+		fmt.Printf("Message %s is past the edit window, sending the update as a new message\n", messageID)
+		newID := ws.sendMessage(chat, newText)
+		if newID == "" {
+			return "", fmt.Errorf("failed to send message %s as a new message", messageID)
+		}
+		return newID, nil
+	}
+
+	edit := ws.whatsappClient.BuildEdit(chat, messageID, &waProto.Message{
+		Conversation: proto.String(newText),
+	})
+	if _, err := ws.whatsappClient.SendMessage(context.Background(), chat, edit); err != nil {
+		return "", fmt.Errorf("failed to edit message %s: %w", messageID, err)
+	}
+	return messageID, nil
+}
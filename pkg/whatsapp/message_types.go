@@ -0,0 +1,51 @@
+package whatsapp
+
+import (
+	"strings"
+
+	waProto "go.mau.fi/whatsmeow/proto/waE2E"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// envelopeFields are populated alongside the real content and say nothing about the message type
+var envelopeFields = map[string]bool{
+	"messageContextInfo":           true,
+	"senderKeyDistributionMessage": true,
+}
+
+// silentMessageTypes are protocol-level messages that should never trigger a user-facing reply
+var silentMessageTypes = map[string]bool{
+	"reactionMessage":         true,
+	"protocolMessage":         true,
+	"pollUpdateMessage":       true,
+	"keepInChatMessage":       true,
+	"encReactionMessage":      true,
+	"pinInChatMessage":        true,
+	"botInvokeMessage":        true,
+	"messageHistoryBundle":    true,
+	"placeholderMessage":      true,
+	"deviceSentMessage":       true,
+	"encEventResponseMessage": true,
+}
+
+// messageTypeName returns the names of the populated content fields of a message
+// (e.g. "pollCreationMessage"), discovered via protobuf reflection
+func messageTypeName(message *waProto.Message) string {
+	if message == nil {
+		return "nil"
+	}
+
+	var names []string
+	message.ProtoReflect().Range(func(fd protoreflect.FieldDescriptor, _ protoreflect.Value) bool {
+		name := fd.JSONName()
+		if !envelopeFields[name] {
+			names = append(names, name)
+		}
+		return true
+	})
+
+	if len(names) == 0 {
+		return "empty"
+	}
+	return strings.Join(names, ",")
+}
@@ -0,0 +1,65 @@
+package whatsapp
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	waProto "go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestMessageTypeName(t *testing.T) {
+	tests := []struct {
+		name    string
+		message *waProto.Message
+		want    string
+	}{
+		{"nil", nil, "nil"},
+		{"empty", &waProto.Message{}, "empty"},
+		{"poll", &waProto.Message{PollCreationMessage: &waProto.PollCreationMessage{Name: proto.String("Lunch?")}}, "pollCreationMessage"},
+		{"envelope fields ignored", &waProto.Message{
+			MessageContextInfo:  &waProto.MessageContextInfo{},
+			PollCreationMessage: &waProto.PollCreationMessage{},
+		}, "pollCreationMessage"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := messageTypeName(tt.message); got != tt.want {
+				t.Errorf("messageTypeName() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnsupportedMessageAcknowledgement(t *testing.T) {
+	ws, client := newTestService(t)
+	enableTestAI(t, ws)
+	ws.notifyUnsupported = true
+	chat := types.NewJID("628120000000", types.DefaultUserServer)
+	ws.SetAIEnabled(chat.String(), true)
+
+	incoming := func(id types.MessageID, message *waProto.Message) *events.Message {
+		return &events.Message{
+			Info: types.MessageInfo{
+				MessageSource: types.MessageSource{Chat: chat, Sender: chat},
+				ID:            id,
+				Timestamp:     time.Now(),
+			},
+			Message: message,
+		}
+	}
+
+	// Protocol messages never get a reply
+	ws.handleMessage(incoming("REACT", &waProto.Message{ReactionMessage: &waProto.ReactionMessage{Text: proto.String("👍")}}))
+	ws.handleMessage(incoming("POLL", &waProto.Message{PollCreationMessage: &waProto.PollCreationMessage{Name: proto.String("Lunch?")}}))
+
+	waitForReply(t, client, chat, "'pollCreationMessage'")
+	for _, text := range client.SentTexts(chat) {
+		if strings.Contains(text, "reactionMessage") {
+			t.Errorf("reaction was answered: %q", text)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package whatsapp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"auto-lmk/pkg/tools"
+
+	waProto "go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// deliveryTimeout is how long a sent message waits for a delivery receipt before it's
+// logged as unconfirmed and forgotten
+const deliveryTimeout = time.Hour
+
+// outboundMessage is a text message waiting in the outbound queue
+type outboundMessage struct {
+	To        types.JID            `json:"to"`
+	Text      string               `json:"text"`
+	QuotedID  types.MessageID      `json:"quoted_id,omitempty"`
+	Quote     *waProto.ContextInfo `json:"-"`
+	QueuedAt  time.Time            `json:"queued_at"`
+	Attempts  int                  `json:"attempts"`
+	LastError string               `json:"last_error,omitempty"`
+}
+
+// outboundQueue sends messages in order, retrying failed sends (e.g. during a brief
+// disconnect) and tracking delivery receipts for what was sent. A failed message holds
+// back later messages to the same recipient until its retry, but not other recipients.
+type outboundQueue struct {
+	messages       chan *outboundMessage
+	maxAttempts    int
+	retryDelay     time.Duration // doubles after every failed attempt
+	maxRetryDelay  time.Duration
+	deadLetterPath string
+
+	due  chan types.JID                   // recipients whose failed message may be retried
+	held map[types.JID][]*outboundMessage // a failed message and the ones queued behind it; only used by runOutboundQueue
+
+	mu              sync.Mutex
+	awaitingReceipt map[types.MessageID]time.Time
+
+	stop     chan struct{} // closed by close
+	stopOnce sync.Once
+}
+
+// newOutboundQueue reads SEND_QUEUE_SIZE, SEND_MAX_ATTEMPTS and SEND_RETRY_DELAY
+func newOutboundQueue() *outboundQueue {
+	return &outboundQueue{
+		messages:        make(chan *outboundMessage, max(tools.EnvInt("SEND_QUEUE_SIZE", 500), 1)),
+		maxAttempts:     max(tools.EnvInt("SEND_MAX_ATTEMPTS", 5), 1),
+		retryDelay:      tools.EnvDuration("SEND_RETRY_DELAY", 2*time.Second),
+		maxRetryDelay:   time.Minute,
+		deadLetterPath:  tools.DataPath("dead_letters.jsonl"),
+		awaitingReceipt: make(map[types.MessageID]time.Time),
+		due:             make(chan types.JID),
+		held:            make(map[types.JID][]*outboundMessage),
+		stop:            make(chan struct{}),
+	}
+}
+
+// close stops runOutboundQueue; messages still queued are not sent
+func (q *outboundQueue) close() {
+	q.stopOnce.Do(func() { close(q.stop) })
+}
+
+// enqueueMessage queues a text message for sending; it is retried until it is sent or
+// SEND_MAX_ATTEMPTS is reached, after which it goes to the dead-letter file
+func (ws *WhatsAppService) enqueueMessage(to types.JID, text string) {
+	ws.enqueueReply(to, nil, text)
+}
+
+// enqueueReply is like enqueueMessage but the message quotes original when set
+func (ws *WhatsAppService) enqueueReply(to types.JID, original *events.Message, text string) {
+	msg := &outboundMessage{To: to, Text: text, Quote: quoteContext(original), QueuedAt: time.Now()}
+	if original != nil {
+		msg.QuotedID = original.Info.ID
+	}
+	select {
+	case ws.outbound.messages <- msg:
+	default:
+		msg.LastError = "outbound queue full"
+		ws.outbound.deadLetter(msg)
+	}
+}
+
+// runOutboundQueue drains the outbound queue until the service is closed
+func (ws *WhatsAppService) runOutboundQueue() {
+	q := ws.outbound
+	for {
+		select {
+		case msg := <-q.messages:
+			if held, ok := q.held[msg.To]; ok {
+				q.held[msg.To] = append(held, msg) // keep the recipient's order
+				continue
+			}
+			ws.deliverQueued([]*outboundMessage{msg})
+		case to := <-q.due:
+			held := q.held[to]
+			delete(q.held, to)
+			ws.deliverQueued(held)
+		case <-q.stop:
+			return
+		}
+	}
+}
+
+// deliverQueued sends messages to one recipient in order. When a send fails, that message
+// and the ones after it are held until its retry is due, so the queue keeps moving.
+func (ws *WhatsAppService) deliverQueued(msgs []*outboundMessage) {
+	q := ws.outbound
+	for i, msg := range msgs {
+		msg.Attempts++
+		id, err := ws.sendQueuedMessage(msg)
+		if err == nil {
+			q.awaitReceipt(id, time.Now())
+			continue
+		}
+
+		msg.LastError = err.Error()
+		if msg.Attempts >= q.maxAttempts {
+			q.deadLetter(msg)
+			continue
+		}
+		delay := q.backoff(msg.Attempts)
+		fmt.Printf("Send to %s failed (attempt %d/%d), retrying in %s: %v\n", msg.To.User, msg.Attempts, q.maxAttempts, delay, err)
+		q.held[msg.To] = msgs[i:]
+		time.AfterFunc(delay, func() {
+			select {
+			case q.due <- msg.To:
+			case <-q.stop:
+			}
+		})
+		return
+	}
+}
+
+// backoff returns how long to wait before retrying a message that failed attempts times
+func (q *outboundQueue) backoff(attempts int) time.Duration {
+	delay := q.retryDelay
+	for i := 1; i < attempts && delay < q.maxRetryDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, q.maxRetryDelay)
+}
+
+// sendQueuedMessage makes one attempt at sending a queued message
+func (ws *WhatsAppService) sendQueuedMessage(msg *outboundMessage) (types.MessageID, error) {
+	if ws.whatsappClient == nil {
+		return "", fmt.Errorf("WhatsApp client not initialized")
+	}
+
+	resp, err := ws.whatsappClient.SendMessage(context.Background(), msg.To, textMessage(msg.Text, msg.Quote))
+	if err != nil {
+		return "", err
+	}
+	ws.sentMessages.record(resp.ID, resp.Timestamp)
+	return resp.ID, nil
+}
+
+// awaitReceipt starts waiting for a delivery receipt for a sent message, forgetting
+// messages whose receipt never came
+func (q *outboundQueue) awaitReceipt(id types.MessageID, now time.Time) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for oldID, sent := range q.awaitingReceipt {
+		if now.Sub(sent) > deliveryTimeout {
+			fmt.Printf("No delivery receipt for message %s after %s\n", oldID, deliveryTimeout)
+			delete(q.awaitingReceipt, oldID)
+		}
+	}
+	q.awaitingReceipt[id] = now
+}
+
+// handleReceipt marks queued messages as delivered when their receipt arrives
+func (ws *WhatsAppService) handleReceipt(receipt *events.Receipt) {
+	if receipt.Type != types.ReceiptTypeDelivered && receipt.Type != types.ReceiptTypeRead {
+		return
+	}
+
+	q := ws.outbound
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, id := range receipt.MessageIDs {
+		sent, ok := q.awaitingReceipt[id]
+		if !ok {
+			continue
+		}
+		delete(q.awaitingReceipt, id)
+		fmt.Printf("Message %s delivered to %s after %s\n", id, receipt.Chat.User, receipt.Timestamp.Sub(sent).Round(time.Millisecond))
+	}
+}
+
+// deadLetter appends a message that couldn't be sent to the dead-letter file
+func (q *outboundQueue) deadLetter(msg *outboundMessage) {
+	fmt.Printf("Giving up on message to %s after %d attempts: %s\n", msg.To.User, msg.Attempts, msg.LastError)
+
+	line, err := json.Marshal(msg)
+	if err != nil {
+		fmt.Printf("Failed to marshal dead letter: %v\n", err)
+		return
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	f, err := os.OpenFile(q.deadLetterPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		fmt.Printf("Failed to open dead-letter file: %v\n", err)
+		return
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		fmt.Printf("Failed to write dead letter: %v\n", err)
+	}
+}
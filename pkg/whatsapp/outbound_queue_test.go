@@ -0,0 +1,69 @@
+package whatsapp
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"auto-lmk/pkg/whatsapptest"
+
+	"go.mau.fi/whatsmeow"
+	waProto "go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+)
+
+// unreachableClient fails sends to one recipient while down is set
+type unreachableClient struct {
+	*whatsapptest.FakeClient
+	recipient types.JID
+	down      atomic.Bool
+}
+
+func (c *unreachableClient) SendMessage(ctx context.Context, to types.JID, message *waProto.Message, extra ...whatsmeow.SendRequestExtra) (whatsmeow.SendResponse, error) {
+	if to == c.recipient && c.down.Load() {
+		return whatsmeow.SendResponse{}, errors.New("recipient unreachable")
+	}
+	return c.FakeClient.SendMessage(ctx, to, message, extra...)
+}
+
+// waitForTexts waits until a chat received want, oldest first
+func waitForTexts(t *testing.T, client *whatsapptest.FakeClient, to types.JID, want []string) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !slices.Equal(client.SentTexts(to), want) {
+		if time.Now().After(deadline) {
+			t.Fatalf("sent to %s = %q, want %q", to.User, client.SentTexts(to), want)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestOutboundQueueRetryDoesNotBlockOtherChats(t *testing.T) {
+	ws, fake := newTestService(t)
+	failing := types.NewJID("628111111111", types.DefaultUserServer)
+	other := types.NewJID("628222222222", types.DefaultUserServer)
+	client := &unreachableClient{FakeClient: fake, recipient: failing}
+	client.down.Store(true)
+	ws.whatsappClient = client
+	ws.outbound.retryDelay = 50 * time.Millisecond
+	ws.outbound.maxRetryDelay = 50 * time.Millisecond
+	go ws.runOutboundQueue()
+	t.Cleanup(ws.outbound.close)
+
+	ws.enqueueMessage(failing, "first")
+	ws.enqueueMessage(other, "unaffected")
+	ws.enqueueMessage(failing, "second")
+
+	// The other chat's reply doesn't wait for the failing recipient's retries
+	waitForTexts(t, fake, other, []string{"unaffected"})
+	if sent := fake.SentTexts(failing); len(sent) != 0 {
+		t.Fatalf("sent to the failing recipient = %q while it was down", sent)
+	}
+
+	// Once the recipient is reachable its messages arrive in order
+	client.down.Store(false)
+	waitForTexts(t, fake, failing, []string{"first", "second"})
+}
@@ -0,0 +1,228 @@
+package whatsapp
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"auto-lmk/pkg/tools"
+
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// pipelineState tracks whether inbound message processing is paused for maintenance
+type pipelineState struct {
+	mu        sync.Mutex
+	paused    bool
+	pausedAt  time.Time
+	queue     bool // queue messages received while paused instead of ignoring them
+	maxQueued int
+	queued    []*events.Message
+	dropped   int
+	replaying map[types.MessageID]bool
+}
+
+// newPipelineState reads PAUSED_MESSAGES (queue|ignore) and PAUSED_QUEUE_SIZE
+func newPipelineState() *pipelineState {
+	mode := strings.ToLower(tools.EnvString("PAUSED_MESSAGES", "ignore"))
+	if mode != "queue" && mode != "ignore" {
+		fmt.Printf("Warning: unknown PAUSED_MESSAGES %q, ignoring messages while paused\n", mode)
+	}
+	return &pipelineState{
+		queue:     mode == "queue",
+		maxQueued: tools.EnvInt("PAUSED_QUEUE_SIZE", 500),
+		replaying: make(map[types.MessageID]bool),
+	}
+}
+
+// hold queues or drops a message received while paused; stale backlog is never queued.
+// It reports false when the pipeline is running and the message should be processed.
+func (p *pipelineState) hold(msg *events.Message, stale bool) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.paused {
+		return false
+	}
+
+	if !p.queue || stale {
+		p.dropped++
+		return true
+	}
+	if p.maxQueued > 0 && len(p.queued) >= p.maxQueued {
+		// Keep the most recent messages
+		p.queued = p.queued[1:]
+		p.dropped++
+	}
+	p.queued = append(p.queued, msg)
+	return true
+}
+
+// isReplaying reports whether a message is being processed from the pause queue
+func (p *pipelineState) isReplaying(id types.MessageID) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.replaying[id]
+}
+
+func (p *pipelineState) setReplaying(id types.MessageID, replaying bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if replaying {
+		p.replaying[id] = true
+	} else {
+		delete(p.replaying, id)
+	}
+}
+
+// Pause stops the bot from processing inbound messages while keeping the client online.
+// Messages received meanwhile are queued or ignored depending on PAUSED_MESSAGES.
+func (ws *WhatsAppService) Pause() {
+	p := ws.pipeline
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.paused {
+		return
+	}
+	p.paused = true
+	p.pausedAt = time.Now()
+	p.dropped = 0
+	fmt.Println("Message pipeline paused")
+}
+
+// Resume restarts message processing and replays any queued messages in arrival order.
+// It returns the number of messages that will be replayed.
+func (ws *WhatsAppService) Resume() int {
+	p := ws.pipeline
+	p.mu.Lock()
+	if !p.paused {
+		p.mu.Unlock()
+		return 0
+	}
+	p.paused = false
+	queued := p.queued
+	p.queued = nil
+	fmt.Printf("Message pipeline resumed after %s (%d queued, %d dropped)\n", time.Since(p.pausedAt).Round(time.Second), len(queued), p.dropped)
+	p.mu.Unlock()
+
+	go func() {
+		for _, msg := range queued {
+			// Queued messages were held on purpose, so they are not treated as stale
+			p.setReplaying(msg.Info.ID, true)
+			ws.processMessage(msg)
+			p.setReplaying(msg.Info.ID, false)
+		}
+	}()
+	return len(queued)
+}
+
+// IsPaused reports whether inbound message processing is paused
+func (ws *WhatsAppService) IsPaused() bool {
+	ws.pipeline.mu.Lock()
+	defer ws.pipeline.mu.Unlock()
+	return ws.pipeline.paused
+}
+
+// pauseStatus describes the paused state for status replies, or "" when running
+func (ws *WhatsAppService) pauseStatus() string {
+	p := ws.pipeline
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.paused {
+		return ""
+	}
+
+	status := fmt.Sprintf("⏸️ Bot is paused since %s", p.pausedAt.Format("2006-01-02 15:04"))
+	if p.queue {
+		status += fmt.Sprintf(" (%d messages queued", len(p.queued))
+	} else {
+		status += " (incoming messages are ignored"
+	}
+	if p.dropped > 0 {
+		status += fmt.Sprintf(", %d dropped", p.dropped)
+	}
+	return status + ")"
+}
+
+// isControlCommand reports whether a message is an "ai" command from an admin; these
+// are still handled while paused so the bot can be resumed from chat
+func (ws *WhatsAppService) isControlCommand(msg *events.Message) bool {
+	return isAICommand(msg) && ws.isAdmin(msg.Info.Sender)
+}
+
+// isAICommand reports whether a message is an "ai ..." command
+func isAICommand(msg *events.Message) bool {
+	text := msg.Message.GetConversation()
+	if text == "" {
+		text = msg.Message.GetExtendedTextMessage().GetText()
+	}
+	return strings.HasPrefix(strings.ToLower(text), "ai ")
+}
+
+// handlePauseCommand handles "ai pause" and "ai resume"
+func (ws *WhatsAppService) handlePauseCommand(to types.JID, name string) {
+	if !ws.requireAdmin(to) {
+		return
+	}
+
+	if name == "pause" {
+		ws.Pause()
+		ws.sendMessage(to, ws.pauseStatus())
+		return
+	}
+
+	if !ws.IsPaused() {
+		ws.sendMessage(to, "▶️ Bot is not paused.")
+		return
+	}
+	replayed := ws.Resume()
+	ws.sendMessage(to, fmt.Sprintf("▶️ Bot resumed, replaying %d queued messages.", replayed))
+}
+
+// handlePauseAllCommand handles "ai pauseall" and "ai resumeall". pauseall turns AI off in
+// every chat, remembering where it was on so resumeall restores exactly those chats.
+func (ws *WhatsAppService) handlePauseAllCommand(to types.JID, name string) {
+	if !ws.requireAdmin(to) {
+		return
+	}
+
+	ws.aiChatsMu.Lock()
+	if name == "pauseall" {
+		if ws.pausedAIChats != nil {
+			paused := len(ws.pausedAIChats)
+			ws.aiChatsMu.Unlock()
+			ws.sendMessage(to, fmt.Sprintf("⏸️ AI is already paused in all chats (%d to restore).", paused))
+			return
+		}
+		ws.pausedAIChats = ws.aiEnabledChats
+		ws.aiEnabledChats = make(map[string]bool)
+		paused := len(ws.pausedAIChats)
+		ws.aiChatsMu.Unlock()
+		fmt.Printf("AI paused in all chats by %s (%d chats)\n", to.User, paused)
+		ws.sendMessage(to, fmt.Sprintf("⏸️ AI turned off in %d chats. Send \"ai resumeall\" to turn it back on.", paused))
+		return
+	}
+
+	if ws.pausedAIChats == nil {
+		ws.aiChatsMu.Unlock()
+		ws.sendMessage(to, "▶️ AI is not paused in all chats.")
+		return
+	}
+	for chatJID := range ws.pausedAIChats {
+		ws.aiEnabledChats[chatJID] = true
+	}
+	restored := len(ws.pausedAIChats)
+	ws.pausedAIChats = nil
+	ws.aiChatsMu.Unlock()
+	fmt.Printf("AI resumed in all chats by %s (%d chats)\n", to.User, restored)
+	ws.sendMessage(to, fmt.Sprintf("▶️ AI turned back on in %d chats.", restored))
+}
+
+// pausedAIChatCount returns how many chats "ai resumeall" would restore and whether AI
+// is paused in all chats
+func (ws *WhatsAppService) pausedAIChatCount() (int, bool) {
+	ws.aiChatsMu.RLock()
+	defer ws.aiChatsMu.RUnlock()
+	return len(ws.pausedAIChats), ws.pausedAIChats != nil
+}
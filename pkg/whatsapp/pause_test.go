@@ -0,0 +1,54 @@
+package whatsapp
+
+import (
+	"strings"
+	"testing"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+func TestPausedPipeline(t *testing.T) {
+	tests := []struct {
+		mode       string // PAUSED_MESSAGES
+		wantReplay bool
+	}{
+		{mode: "ignore"},
+		{mode: "queue", wantReplay: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			t.Setenv("PAUSED_MESSAGES", tt.mode)
+			ws, client := newTestService(t)
+			enableTestAI(t, ws)
+			chat := types.NewJID("628120000000", types.DefaultUserServer)
+			ws.SetAIEnabled(chat.String(), true)
+
+			ws.Pause()
+			ws.handleMessage(incomingText(chat, "HELD", "sent while paused"))
+			if !ws.IsPaused() || !strings.Contains(ws.pauseStatus(), "paused") {
+				t.Fatalf("pause status = %q, want paused", ws.pauseStatus())
+			}
+			if sent := client.SentTexts(chat); len(sent) != 0 {
+				t.Fatalf("sent while paused: %q", sent)
+			}
+
+			replayed := ws.Resume()
+			if want := map[bool]int{true: 1}[tt.wantReplay]; replayed != want {
+				t.Errorf("Resume replayed %d messages, want %d", replayed, want)
+			}
+			ws.handleMessage(incomingText(chat, "AFTER", "sent after resume"))
+			waitForReply(t, client, chat, "sent after resume")
+
+			if tt.wantReplay {
+				waitForReply(t, client, chat, "sent while paused")
+				return
+			}
+			for _, text := range client.SentTexts(chat) {
+				if strings.Contains(text, "sent while paused") {
+					t.Errorf("ignored message was answered: %q", text)
+				}
+			}
+		})
+	}
+}
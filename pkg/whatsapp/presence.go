@@ -0,0 +1,210 @@
+package whatsapp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"auto-lmk/pkg/tools"
+
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// presenceTracker remembers which contacts are online, from presence subscriptions
+type presenceTracker struct {
+	mu         sync.Mutex
+	online     map[string]bool
+	lastSeen   map[string]time.Time
+	subscribed map[string]bool
+	waiters    map[string][]chan struct{} // closed when the contact comes online
+}
+
+func newPresenceTracker() *presenceTracker {
+	return &presenceTracker{
+		online:     make(map[string]bool),
+		lastSeen:   make(map[string]time.Time),
+		subscribed: make(map[string]bool),
+		waiters:    make(map[string][]chan struct{}),
+	}
+}
+
+// presenceKey identifies a contact regardless of the device a presence came from
+func presenceKey(jid types.JID) string {
+	return jid.ToNonAD().String()
+}
+
+// handlePresence records a contact going online or offline
+func (ws *WhatsAppService) handlePresence(evt *events.Presence) {
+	p := ws.presence
+	key := presenceKey(evt.From)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.online[key] = !evt.Unavailable
+	if evt.Unavailable {
+		if !evt.LastSeen.IsZero() {
+			p.lastSeen[key] = evt.LastSeen
+		}
+		return
+	}
+
+	p.lastSeen[key] = time.Now()
+	for _, ch := range p.waiters[key] {
+		close(ch)
+	}
+	delete(p.waiters, key)
+}
+
+// isOnline reports whether a contact was last seen online. Contacts whose presence isn't
+// subscribed are reported offline.
+func (ws *WhatsAppService) isOnline(jid types.JID) bool {
+	ws.presence.mu.Lock()
+	defer ws.presence.mu.Unlock()
+	return ws.presence.online[presenceKey(jid)]
+}
+
+// lastSeen returns when a contact was last online, if known
+func (ws *WhatsAppService) lastSeen(jid types.JID) (time.Time, bool) {
+	ws.presence.mu.Lock()
+	defer ws.presence.mu.Unlock()
+	t, ok := ws.presence.lastSeen[presenceKey(jid)]
+	return t, ok
+}
+
+// subscribePresence asks WhatsApp for a contact's presence updates. WhatsApp only sends
+// them while we're marked available ourselves.
+func (ws *WhatsAppService) subscribePresence(jid types.JID) error {
+	if ws.whatsappClient == nil {
+		return fmt.Errorf("WhatsApp client not initialized")
+	}
+
+	ctx := context.Background()
+	if err := ws.whatsappClient.SendPresence(ctx, types.PresenceAvailable); err != nil {
+		return fmt.Errorf("failed to mark ourselves available: %w", err)
+	}
+	if err := ws.whatsappClient.SubscribePresence(ctx, jid); err != nil {
+		return fmt.Errorf("failed to subscribe to presence of %s: %w", jid.User, err)
+	}
+
+	ws.presence.mu.Lock()
+	ws.presence.subscribed[presenceKey(jid)] = true
+	ws.presence.mu.Unlock()
+	return nil
+}
+
+// resubscribePresence renews presence subscriptions, which don't survive a reconnect
+func (ws *WhatsAppService) resubscribePresence() {
+	ws.presence.mu.Lock()
+	keys := make([]string, 0, len(ws.presence.subscribed))
+	for key := range ws.presence.subscribed {
+		keys = append(keys, key)
+	}
+	ws.presence.mu.Unlock()
+
+	for _, key := range keys {
+		jid, err := types.ParseJID(key)
+		if err != nil {
+			continue
+		}
+		if err := ws.subscribePresence(jid); err != nil {
+			fmt.Printf("Failed to renew presence subscription: %v\n", err)
+		}
+	}
+}
+
+// waitUntilOnline blocks until a contact comes online or timeout passes, reporting
+// whether they came online
+func (ws *WhatsAppService) waitUntilOnline(jid types.JID, timeout time.Duration) bool {
+	p := ws.presence
+	key := presenceKey(jid)
+
+	p.mu.Lock()
+	if p.online[key] {
+		p.mu.Unlock()
+		return true
+	}
+	ch := make(chan struct{})
+	p.waiters[key] = append(p.waiters[key], ch)
+	p.mu.Unlock()
+
+	select {
+	case <-ch:
+		return true
+	case <-time.After(timeout):
+		p.mu.Lock()
+		waiters := p.waiters[key]
+		for i, w := range waiters {
+			if w == ch {
+				p.waiters[key] = append(waiters[:i], waiters[i+1:]...)
+				break
+			}
+		}
+		p.mu.Unlock()
+		return false
+	}
+}
+
+// deferUntilOnline holds an AI reply in a chat with "ai online on" until the contact is
+// online, at most AI_ONLINE_MAX_WAIT
+func (ws *WhatsAppService) deferUntilOnline(chat types.JID) {
+	if !ws.isOnlineOnly(chat.String()) || chat.Server == types.GroupServer {
+		return
+	}
+
+	maxWait := tools.EnvDuration("AI_ONLINE_MAX_WAIT", 10*time.Minute)
+	if !ws.waitUntilOnline(chat, maxWait) {
+		fmt.Printf("%s didn't come online within %s, replying anyway\n", chat.User, maxWait)
+	}
+}
+
+// setOnlineOnly turns waiting until the contact is online on or off for a chat
+func (ws *WhatsAppService) setOnlineOnly(chatKey string, enabled bool) {
+	ws.onlineOnlyMu.Lock()
+	defer ws.onlineOnlyMu.Unlock()
+	if enabled {
+		ws.onlineOnlyChats[chatKey] = true
+		return
+	}
+	delete(ws.onlineOnlyChats, chatKey)
+}
+
+// isOnlineOnly reports whether AI replies in a chat wait until the contact is online
+func (ws *WhatsAppService) isOnlineOnly(chatKey string) bool {
+	ws.onlineOnlyMu.RLock()
+	defer ws.onlineOnlyMu.RUnlock()
+	return ws.onlineOnlyChats[chatKey]
+}
+
+// handleOnlineCommand handles "ai online on|off"
+func (ws *WhatsAppService) handleOnlineCommand(to types.JID, arg string, chatJID string) {
+	chat, err := types.ParseJID(chatJID)
+	if err != nil || chat.Server == types.GroupServer {
+		ws.sendMessage(to, "❌ Waiting until online is only available in private chats.")
+		return
+	}
+
+	switch strings.ToLower(arg) {
+	case "on":
+		if err := ws.subscribePresence(chat); err != nil {
+			fmt.Printf("Failed to enable online-only replies for %s: %v\n", chatJID, err)
+			ws.sendMessage(to, "❌ Could not subscribe to this contact's online status.")
+			return
+		}
+		ws.setOnlineOnly(chatJID, true)
+		ws.sendMessage(to, "🟢 I'll wait until you're online before replying.")
+	case "off":
+		ws.setOnlineOnly(chatJID, false)
+		ws.sendMessage(to, "🟢 I'll reply right away again.")
+	case "":
+		status := fmt.Sprintf("🟢 Wait until online: %t (online now: %t", ws.isOnlineOnly(chatJID), ws.isOnline(chat))
+		if seen, ok := ws.lastSeen(chat); ok {
+			status += ", last seen " + seen.Format("2006-01-02 15:04")
+		}
+		ws.sendMessage(to, status+")")
+	default:
+		ws.sendMessage(to, aiCommandHelp)
+	}
+}
@@ -0,0 +1,242 @@
+package whatsapp
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"auto-lmk/pkg/tools"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+// ChatSettings are per-chat AI overrides; empty fields fall back to the defaults
+type ChatSettings struct {
+	SystemPrompt   string `json:"systemPrompt,omitempty"`
+	Model          string `json:"model,omitempty"`
+	Language       string `json:"language,omitempty"`
+	MaxTokens      int    `json:"maxTokens,omitempty"`
+	ResponsePrefix string `json:"responsePrefix,omitempty"`
+	ResponseSuffix string `json:"responseSuffix,omitempty"`
+	StreamChunk    string `json:"streamChunk,omitempty"`
+	Notes          string `json:"notes,omitempty"`
+}
+
+// overrides converts the settings into AITools overrides
+func (cs ChatSettings) overrides() tools.AIOverrides {
+	return tools.AIOverrides{
+		Model:        cs.Model,
+		SystemPrompt: cs.SystemPrompt,
+		Language:     cs.Language,
+		MaxTokens:    cs.MaxTokens,
+	}
+}
+
+// chatSettingsFor returns a chat's AI overrides, empty when it has none
+func (ws *WhatsAppService) chatSettingsFor(chatKey string) ChatSettings {
+	ws.chatSettingsMu.RLock()
+	defer ws.chatSettingsMu.RUnlock()
+	return ws.chatSettings[chatKey]
+}
+
+// setChatSettings replaces a chat's AI overrides; empty settings remove them
+func (ws *WhatsAppService) setChatSettings(chatKey string, settings ChatSettings) {
+	ws.chatSettingsMu.Lock()
+	defer ws.chatSettingsMu.Unlock()
+	if settings == (ChatSettings{}) {
+		delete(ws.chatSettings, chatKey)
+		return
+	}
+	ws.chatSettings[chatKey] = settings
+}
+
+// aiToolsForChat returns the AI tools configured with the chat's overrides, recording
+// token usage under the chat
+func (ws *WhatsAppService) aiToolsForChat(chatKey string) *tools.AITools {
+	if ws.aiTools == nil {
+		return nil
+	}
+	settings := ws.chatSettingsFor(chatKey)
+	if settings == (ChatSettings{}) {
+		return ws.aiTools.ForChat(chatKey)
+	}
+	return ws.aiTools.WithOverrides(settings.overrides()).ForChat(chatKey)
+}
+
+// profilePath returns the file a named profile is stored in; profiles are shared by all chats
+func profilePath(name string) (string, error) {
+	if !snapshotNamePattern.MatchString(name) {
+		return "", fmt.Errorf("invalid profile name %q: use letters, digits, '-' or '_'", name)
+	}
+	return tools.DataPath("profiles", name+".json"), nil
+}
+
+// SaveProfile exports a chat's AI settings as a named profile
+func (ws *WhatsAppService) SaveProfile(chatJID, name string) error {
+	path, err := profilePath(name)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(ws.chatSettingsFor(chatJID), "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal profile: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create profile directory: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to save profile to %s: %w", path, err)
+	}
+	return nil
+}
+
+// ApplyProfile replaces a chat's AI settings with a named profile
+func (ws *WhatsAppService) ApplyProfile(chatJID, name string) error {
+	path, err := profilePath(name)
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read profile %s: %w", name, err)
+	}
+
+	var settings ChatSettings
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return fmt.Errorf("failed to parse profile %s: %w", name, err)
+	}
+
+	ws.setChatSettings(chatJID, settings)
+	return nil
+}
+
+// ListProfiles returns the names of all saved profiles
+func ListProfiles() ([]string, error) {
+	files, err := filepath.Glob(tools.DataPath("profiles", "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list profiles: %w", err)
+	}
+
+	names := make([]string, 0, len(files))
+	for _, file := range files {
+		names = append(names, strings.TrimSuffix(filepath.Base(file), ".json"))
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// setChatSetting updates a single field of a chat's settings; an empty value clears it
+func (ws *WhatsAppService) setChatSetting(chatJID, field, value string) error {
+	settings := ws.chatSettingsFor(chatJID)
+
+	switch strings.ToLower(field) {
+	case "prompt":
+		settings.SystemPrompt = value
+	case "model":
+		settings.Model = value
+	case "language":
+		settings.Language = value
+	case "maxtokens":
+		if value == "" {
+			settings.MaxTokens = 0
+			break
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			return fmt.Errorf("maxtokens must be a positive number")
+		}
+		settings.MaxTokens = n
+	case "prefix":
+		settings.ResponsePrefix = value
+	case "suffix":
+		settings.ResponseSuffix = value
+	case "chunk":
+		if value != "" {
+			if _, err := tools.ParseChunkMode(value); err != nil {
+				return err
+			}
+		}
+		settings.StreamChunk = strings.ToLower(value)
+	case "notes":
+		settings.Notes = value
+	default:
+		return fmt.Errorf("unknown setting %q (use prompt, model, language, maxtokens, prefix, suffix, chunk or notes)", field)
+	}
+
+	ws.setChatSettings(chatJID, settings)
+	return nil
+}
+
+// describeChatSettings renders a chat's settings for a command reply
+func describeChatSettings(settings ChatSettings) string {
+	orDefault := func(v string) string {
+		if v == "" {
+			return "(default)"
+		}
+		return v
+	}
+	maxTokens := "(default)"
+	if settings.MaxTokens > 0 {
+		maxTokens = strconv.Itoa(settings.MaxTokens)
+	}
+
+	return fmt.Sprintf("⚙️ Chat AI settings\nPrompt: %s\nModel: %s\nLanguage: %s\nMax tokens: %s\nPrefix: %s\nSuffix: %s\nChunking: %s\nNotes: %s",
+		orDefault(settings.SystemPrompt), orDefault(settings.Model), orDefault(settings.Language), maxTokens,
+		orDefault(settings.ResponsePrefix), orDefault(settings.ResponseSuffix), orDefault(settings.StreamChunk), orDefault(settings.Notes))
+}
+
+// handleSettingsCommand handles "ai set <field> <value>"
+func (ws *WhatsAppService) handleSettingsCommand(to types.JID, arg string, chatJID string) {
+	field, value, _ := strings.Cut(arg, " ")
+	if field == "" {
+		ws.sendMessage(to, describeChatSettings(ws.chatSettingsFor(chatJID)))
+		return
+	}
+
+	if err := ws.setChatSetting(chatJID, field, strings.TrimSpace(value)); err != nil {
+		ws.sendMessage(to, fmt.Sprintf("❌ %v", err))
+		return
+	}
+	ws.sendMessage(to, describeChatSettings(ws.chatSettingsFor(chatJID)))
+}
+
+// handleProfileCommand handles "ai profile save|apply <name>" and "ai profile list"
+func (ws *WhatsAppService) handleProfileCommand(to types.JID, arg string, chatJID string) {
+	action, name, _ := strings.Cut(arg, " ")
+	name = strings.TrimSpace(name)
+
+	switch strings.ToLower(action) {
+	case "save":
+		if err := ws.SaveProfile(chatJID, name); err != nil {
+			ws.sendMessage(to, fmt.Sprintf("❌ Failed to save profile: %v", err))
+			return
+		}
+		ws.sendMessage(to, fmt.Sprintf("💾 Profile '%s' saved from this chat's settings.", name))
+	case "apply":
+		if err := ws.ApplyProfile(chatJID, name); err != nil {
+			ws.sendMessage(to, fmt.Sprintf("❌ Failed to apply profile: %v", err))
+			return
+		}
+		ws.sendMessage(to, fmt.Sprintf("✅ Profile '%s' applied.\n\n%s", name, describeChatSettings(ws.chatSettingsFor(chatJID))))
+	case "list":
+		names, err := ListProfiles()
+		if err != nil {
+			ws.sendMessage(to, fmt.Sprintf("❌ %v", err))
+			return
+		}
+		if len(names) == 0 {
+			ws.sendMessage(to, "No profiles saved yet.")
+			return
+		}
+		ws.sendMessage(to, "Saved profiles:\n"+strings.Join(names, "\n"))
+	default:
+		ws.sendMessage(to, "Usage: ai profile save|apply <name>, ai profile list")
+	}
+}
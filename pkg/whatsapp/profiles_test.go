@@ -0,0 +1,84 @@
+package whatsapp
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"auto-lmk/pkg/tools"
+)
+
+func TestProfileRoundTrip(t *testing.T) {
+	ws, _ := newTestService(t)
+	source := "628120000000@s.whatsapp.net"
+	target := "120363000000000000@g.us"
+
+	settings := ChatSettings{
+		SystemPrompt:   "Kamu asisten toko.",
+		Model:          "gpt-4o",
+		Language:       "id",
+		MaxTokens:      500,
+		ResponsePrefix: "🤖 ",
+		ResponseSuffix: "\n— Bot",
+		StreamChunk:    "paragraph",
+		Notes:          "store support",
+	}
+	ws.setChatSettings(source, settings)
+
+	if err := ws.SaveProfile(source, "store"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ws.ApplyProfile(target, "store"); err != nil {
+		t.Fatal(err)
+	}
+	if got := ws.chatSettingsFor(target); !reflect.DeepEqual(got, settings) {
+		t.Errorf("applied settings = %+v, want %+v", got, settings)
+	}
+
+	names, err := ListProfiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(names, []string{"store"}) {
+		t.Errorf("profiles = %v, want [store]", names)
+	}
+
+	if err := ws.SaveProfile(source, "../escape"); err == nil {
+		t.Error("profile name with a path separator was accepted")
+	}
+	if err := ws.ApplyProfile(target, "missing"); err == nil {
+		t.Error("applying a missing profile succeeded")
+	}
+}
+
+// TestChatSettingsConcurrent reads a chat's settings from reply goroutines while commands
+// change them; run it with -race
+func TestChatSettingsConcurrent(t *testing.T) {
+	ws, _ := newTestService(t)
+	ws.aiTools = tools.NewAITools(tools.DryRunProvider{}, "dry-run")
+	chat := "628120000000@s.whatsapp.net"
+
+	var wg sync.WaitGroup
+	for i := range 10 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			ws.aiToolsForChat(chat)
+			ws.responseDecoration(chat)
+			ws.chunkModeForChat(chat)
+		}()
+		go func() {
+			defer wg.Done()
+			if err := ws.setChatSetting(chat, "model", fmt.Sprintf("model-%d", i)); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if model := ws.chatSettingsFor(chat).Model; !strings.HasPrefix(model, "model-") {
+		t.Errorf("model = %q after concurrent updates", model)
+	}
+}
@@ -0,0 +1,80 @@
+package whatsapp
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"auto-lmk/pkg/tools"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+// tokenBucket tracks the AI replies left for one chat
+type tokenBucket struct {
+	tokens   float64
+	last     time.Time
+	notified bool // the chat was already told to slow down since it ran out
+}
+
+// rateLimiter is a per-chat token bucket limiting how often the AI is called
+type rateLimiter struct {
+	mu      sync.Mutex
+	rate    float64 // tokens added per second
+	burst   float64
+	buckets map[string]*tokenBucket
+}
+
+// newRateLimiter allows perMinute replies per chat on average with bursts of up to
+// burst; perMinute <= 0 disables limiting
+func newRateLimiter(perMinute, burst int) *rateLimiter {
+	if burst <= 0 {
+		burst = perMinute
+	}
+	return &rateLimiter{
+		rate:    float64(perMinute) / 60,
+		burst:   float64(burst),
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+// allow takes a token for the chat. When none is left it reports false, and notify is
+// true the first time so the chat is told once rather than on every message.
+func (rl *rateLimiter) allow(chatKey string, now time.Time) (allowed bool, notify bool) {
+	if rl.rate <= 0 {
+		return true, false
+	}
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	b, ok := rl.buckets[chatKey]
+	if !ok {
+		b = &tokenBucket{tokens: rl.burst, last: now}
+		rl.buckets[chatKey] = b
+	}
+	b.tokens = min(rl.burst, b.tokens+now.Sub(b.last).Seconds()*rl.rate)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		b.notified = false
+		return true, false
+	}
+	notify = !b.notified
+	b.notified = true
+	return false, notify
+}
+
+// allowAIReply applies AI_RATE_LIMIT_PER_MINUTE to a chat, asking it to slow down when
+// the limit is hit
+func (ws *WhatsAppService) allowAIReply(chat types.JID) bool {
+	allowed, notify := ws.rateLimiter.allow(chat.String(), time.Now())
+	if !allowed {
+		fmt.Printf("Rate limit reached for chat %s, skipping AI reply\n", chat)
+		if notify {
+			ws.sendMessage(chat, tools.ErrorMessageRateLimited)
+		}
+	}
+	return allowed
+}
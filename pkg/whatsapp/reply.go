@@ -0,0 +1,86 @@
+package whatsapp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"auto-lmk/pkg/tools"
+
+	waProto "go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+	"google.golang.org/protobuf/proto"
+)
+
+// When AI replies quote the message they answer, set with AI_QUOTE_REPLIES
+const (
+	quoteRepliesOff    = "off"
+	quoteRepliesGroups = "groups"
+	quoteRepliesAll    = "all"
+)
+
+// loadQuoteReplies reads AI_QUOTE_REPLIES (off|groups|all, default off)
+func loadQuoteReplies() string {
+	mode := strings.ToLower(tools.EnvString("AI_QUOTE_REPLIES", quoteRepliesOff))
+	switch mode {
+	case quoteRepliesOff, quoteRepliesGroups, quoteRepliesAll:
+		return mode
+	default:
+		fmt.Printf("Warning: unknown AI_QUOTE_REPLIES %q, not quoting replies\n", mode)
+		return quoteRepliesOff
+	}
+}
+
+// replyTarget returns the message an AI reply should quote, or nil to send it standalone
+func (ws *WhatsAppService) replyTarget(info types.MessageInfo, message *waProto.Message) *events.Message {
+	if message == nil || ws.quoteReplies == quoteRepliesOff {
+		return nil
+	}
+	if ws.quoteReplies == quoteRepliesGroups && info.Chat.Server != types.GroupServer {
+		return nil
+	}
+	return &events.Message{Info: info, Message: message}
+}
+
+// quoteContext builds the context that makes a message quote original
+func quoteContext(original *events.Message) *waProto.ContextInfo {
+	if original == nil {
+		return nil
+	}
+	return &waProto.ContextInfo{
+		StanzaID:      proto.String(original.Info.ID),
+		Participant:   proto.String(original.Info.Sender.ToNonAD().String()),
+		QuotedMessage: original.Message,
+	}
+}
+
+// textMessage builds a text message, quoting the message described by quote if set
+func textMessage(text string, quote *waProto.ContextInfo) *waProto.Message {
+	if quote == nil {
+		return &waProto.Message{Conversation: proto.String(text)}
+	}
+	return &waProto.Message{
+		ExtendedTextMessage: &waProto.ExtendedTextMessage{
+			Text:        proto.String(text),
+			ContextInfo: quote,
+		},
+	}
+}
+
+// sendReply sends a text message quoting original, the message that triggered it, and
+// returns its ID, or "" if sending failed. A nil original sends a standalone message.
+func (ws *WhatsAppService) sendReply(to types.JID, original *events.Message, text string) types.MessageID {
+	if ws.whatsappClient == nil {
+		fmt.Printf("Cannot send message: WhatsApp client not initialized\n")
+		return ""
+	}
+
+	resp, err := ws.whatsappClient.SendMessage(context.Background(), to, textMessage(text, quoteContext(original)))
+	if err != nil {
+		fmt.Printf("Failed to send message to %s: %v\n", to.User, err)
+		return ""
+	}
+	ws.sentMessages.record(resp.ID, resp.Timestamp)
+	return resp.ID
+}
@@ -0,0 +1,37 @@
+package whatsapp
+
+import (
+	"go.mau.fi/whatsmeow/types"
+)
+
+// sameAccount reports whether two JIDs belong to the same user, ignoring the device part
+func sameAccount(a, b types.JID) bool {
+	return !a.IsEmpty() && !b.IsEmpty() && a.User == b.User && a.Server == b.Server
+}
+
+// isSelfSender decides whether a message came from the bot's own account. Messages from
+// this exact device are always treated as self; messages from the account's other linked
+// devices (e.g. the phone) only when ignoreOtherDevices is set.
+func isSelfSender(sender types.JID, isFromMe bool, ownJID, ownLID types.JID, ignoreOtherDevices bool) bool {
+	fromOwnAccount := isFromMe || sameAccount(sender, ownJID) || sameAccount(sender, ownLID)
+	if !fromOwnAccount {
+		return false
+	}
+
+	fromThisDevice := (sameAccount(sender, ownJID) && sender.Device == ownJID.Device) ||
+		(sameAccount(sender, ownLID) && sender.Device == ownLID.Device)
+	if fromThisDevice {
+		return true
+	}
+	return ignoreOtherDevices
+}
+
+// isOwnMessage reports whether an incoming message was sent by this account and should be ignored
+func (ws *WhatsAppService) isOwnMessage(info types.MessageInfo) bool {
+	var ownJID, ownLID types.JID
+	if ws.device != nil {
+		ownJID = ws.device.GetJID()
+		ownLID = ws.device.GetLID()
+	}
+	return isSelfSender(info.Sender, info.IsFromMe, ownJID, ownLID, ws.ignoreOwnDevices)
+}
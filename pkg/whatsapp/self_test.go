@@ -0,0 +1,40 @@
+package whatsapp
+
+import (
+	"testing"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+func TestIsSelfSender(t *testing.T) {
+	ownJID := types.JID{User: "628120000000", Server: types.DefaultUserServer, Device: 5}
+	ownLID := types.JID{User: "123456789", Server: types.HiddenUserServer, Device: 5}
+	phone := types.JID{User: "628120000000", Server: types.DefaultUserServer}
+	phoneLID := types.JID{User: "123456789", Server: types.HiddenUserServer}
+	other := types.NewJID("628129999999", types.DefaultUserServer)
+
+	tests := []struct {
+		name               string
+		sender             types.JID
+		isFromMe           bool
+		ignoreOtherDevices bool
+		want               bool
+	}{
+		{"this device", ownJID, true, false, true},
+		{"this device without IsFromMe", ownJID, false, false, true},
+		{"this device via LID", ownLID, false, false, true},
+		{"other device ignored", phone, true, true, true},
+		{"other device without IsFromMe ignored", phone, false, true, true},
+		{"other device via LID ignored", phoneLID, false, true, true},
+		{"other device answered", phone, true, false, false},
+		{"other device via LID answered", phoneLID, false, false, false},
+		{"someone else", other, false, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isSelfSender(tt.sender, tt.isFromMe, ownJID, ownLID, tt.ignoreOtherDevices); got != tt.want {
+				t.Errorf("isSelfSender(%s, %v, ignoreOtherDevices=%v) = %v, want %v", tt.sender, tt.isFromMe, tt.ignoreOtherDevices, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package whatsapp
+
+import (
+	"fmt"
+	"strings"
+
+	"auto-lmk/pkg/tools"
+
+	waProto "go.mau.fi/whatsmeow/proto/waE2E"
+)
+
+// sharedContentText describes a shared location or contact card as text for the AI, or
+// returns "" when the message is neither
+func sharedContentText(message *waProto.Message) string {
+	switch {
+	case message.LocationMessage != nil:
+		return locationText(message.LocationMessage)
+	case message.LiveLocationMessage != nil:
+		loc := message.LiveLocationMessage
+		return fmt.Sprintf(tools.LiveLocationTemplate, loc.GetDegreesLatitude(), loc.GetDegreesLongitude())
+	case message.ContactMessage != nil:
+		return contactText(message.ContactMessage)
+	case message.ContactsArrayMessage != nil:
+		var lines []string
+		for _, contact := range message.ContactsArrayMessage.GetContacts() {
+			if text := contactText(contact); text != "" {
+				lines = append(lines, text)
+			}
+		}
+		if len(lines) == 0 {
+			fmt.Printf("Received contacts array %q without readable contacts\n", message.ContactsArrayMessage.GetDisplayName())
+		}
+		return strings.Join(lines, "\n")
+	}
+	return ""
+}
+
+// locationText describes a pinned location, including the place name and address when set
+func locationText(loc *waProto.LocationMessage) string {
+	var details []string
+	for _, detail := range []string{loc.GetName(), loc.GetAddress(), loc.GetComment()} {
+		if detail = strings.TrimSpace(detail); detail != "" {
+			details = append(details, detail)
+		}
+	}
+
+	extra := ""
+	if len(details) > 0 {
+		extra = " (" + strings.Join(details, ", ") + ")"
+	}
+	return fmt.Sprintf(tools.LocationTemplate, loc.GetDegreesLatitude(), loc.GetDegreesLongitude(), extra)
+}
+
+// contactText describes a shared contact card by name and phone numbers
+func contactText(contact *waProto.ContactMessage) string {
+	name, numbers := parseVCard(contact.GetVcard())
+	if name == "" {
+		name = contact.GetDisplayName()
+	}
+	if name == "" && len(numbers) == 0 {
+		fmt.Printf("Received contact card without name or number: %q\n", contact.GetVcard())
+		return ""
+	}
+	if len(numbers) == 0 {
+		fmt.Printf("Received contact card %q without a phone number\n", name)
+		numbers = []string{"-"}
+	}
+	return fmt.Sprintf(tools.ContactTemplate, name, strings.Join(numbers, ", "))
+}
+
+// parseVCard returns the formatted name and phone numbers from a vCard, e.g.
+// "FN:Budi" and "TEL;type=CELL;waid=628123:+62 812-3"
+func parseVCard(vcard string) (string, []string) {
+	var name string
+	var numbers []string
+	for _, line := range strings.Split(vcard, "\n") {
+		line = strings.TrimSpace(line)
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		// Properties may be grouped ("item1.TEL") and carry parameters ("TEL;type=CELL")
+		key, _, _ = strings.Cut(key, ";")
+		if i := strings.LastIndex(key, "."); i >= 0 {
+			key = key[i+1:]
+		}
+
+		switch strings.ToUpper(key) {
+		case "FN":
+			name = strings.TrimSpace(value)
+		case "TEL":
+			if number := strings.TrimSpace(value); number != "" {
+				numbers = append(numbers, number)
+			}
+		}
+	}
+	return name, numbers
+}
@@ -0,0 +1,68 @@
+package whatsapp
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"auto-lmk/pkg/tools"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+// similarImageThreshold is how many of the 64 perceptual hash bits may differ for two
+// images to count as the same picture
+const similarImageThreshold = 10
+
+// maxSimilarImages caps how many matches "ai similar" lists
+const maxSimilarImages = 10
+
+// handleSimilarCommand handles "ai similar <imageID>", listing older images of this chat
+// that look like a stored image, so they can be fetched with "ai getimage"
+func (ws *WhatsAppService) handleSimilarCommand(to types.JID, arg string, chatJID string) {
+	imageID := strings.TrimSpace(arg)
+	if imageID == "" {
+		ws.sendMessage(to, "Usage: ai similar <imageID>")
+		return
+	}
+	if ws.whatsappDownloader == nil {
+		ws.sendMessage(to, "❌ WhatsApp downloader is not initialized.")
+		return
+	}
+
+	id, filename, ok := ws.lookupImage(chatJID, imageID)
+	if !ok {
+		ws.sendMessage(to, fmt.Sprintf("❌ No stored image with ID %s in this chat.", imageID))
+		return
+	}
+	data, err := os.ReadFile(filepath.Join(ws.imageDir(), filename))
+	if err != nil {
+		fmt.Printf("Failed to read image %s: %v\n", filename, err)
+		ws.sendMessage(to, fmt.Sprintf("❌ Failed to read image %s.", id))
+		return
+	}
+
+	// Only this chat's images are listed, so other chats' images aren't leaked
+	var matches []tools.HistoryImageInfo
+	for _, info := range ws.whatsappDownloader.FindSimilarImages(data, similarImageThreshold) {
+		if info.ChatJID.String() == chatJID && !strings.EqualFold(string(info.MessageID), id) {
+			matches = append(matches, info)
+		}
+	}
+	if len(matches) == 0 {
+		ws.sendMessage(to, fmt.Sprintf("🔍 No earlier image in this chat looks like %s.", id))
+		return
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "🔍 Images in this chat that look like %s:\n", id)
+	for _, info := range matches[:min(len(matches), maxSimilarImages)] {
+		fmt.Fprintf(&sb, "• %s (%s)\n", info.MessageID, info.Timestamp.Format("2006-01-02 15:04"))
+	}
+	if len(matches) > maxSimilarImages {
+		fmt.Fprintf(&sb, "…and %d more\n", len(matches)-maxSimilarImages)
+	}
+	sb.WriteString("Send \"ai getimage <messageID>\" to see one again.")
+	ws.sendMessage(to, sb.String())
+}
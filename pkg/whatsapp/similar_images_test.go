@@ -0,0 +1,70 @@
+package whatsapp
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"auto-lmk/pkg/tools"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+func TestHandleSimilarCommand(t *testing.T) {
+	ws, client := newTestService(t)
+	ws.whatsappDownloader = tools.NewWhatsAppDownloader(client)
+	chat := types.NewJID("628120000000", types.DefaultUserServer)
+	otherChat := types.NewJID("628129999999", types.DefaultUserServer)
+
+	img := image.NewGray(image.Rect(0, 0, 64, 64))
+	for i := range img.Pix {
+		img.Pix[i] = uint8(i % 64 * 4)
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(tools.DataPath("IMG1.jpg"), buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ws.rememberImage(chat.String(), "IMG1", "IMG1.jpg")
+	hash, err := tools.PerceptualHash(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	history := map[string]tools.HistoryImageInfo{
+		"SEEN_BEFORE": {MessageID: "SEEN_BEFORE", ChatJID: chat, Timestamp: time.Now(), PHash: hash ^ 1, HasPHash: true},
+		"UNRELATED":   {MessageID: "UNRELATED", ChatJID: chat, Timestamp: time.Now(), PHash: ^hash, HasPHash: true},
+		"OTHER_CHAT":  {MessageID: "OTHER_CHAT", ChatJID: otherChat, Timestamp: time.Now(), PHash: hash, HasPHash: true},
+	}
+	metadata, err := json.Marshal(history)
+	if err != nil {
+		t.Fatal(err)
+	}
+	metadataPath := filepath.Join(t.TempDir(), "history.json")
+	if err := os.WriteFile(metadataPath, metadata, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ws.whatsappDownloader.LoadHistoryMetadata(metadataPath); err != nil {
+		t.Fatal(err)
+	}
+
+	ws.handleAICommand(chat, "similar img1", chat.String())
+
+	reply := lastReply(t, client, chat)
+	if !strings.Contains(reply, "SEEN_BEFORE") {
+		t.Errorf("reply = %q, want the similar image listed", reply)
+	}
+	for _, id := range []string{"UNRELATED", "OTHER_CHAT"} {
+		if strings.Contains(reply, id) {
+			t.Errorf("reply = %q, want %s left out", reply, id)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package whatsapp
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"auto-lmk/pkg/tools"
+
+	"github.com/openai/openai-go"
+)
+
+// ChatSnapshot is the serialized AI state of a single chat
+type ChatSnapshot struct {
+	ChatJID         string                                   `json:"chatJID"`
+	CreatedAt       time.Time                                `json:"createdAt"`
+	AIEnabled       bool                                     `json:"aiEnabled"`
+	AckEnabled      bool                                     `json:"ackEnabled"`
+	CacheEnabled    bool                                     `json:"cacheEnabled"`
+	Settings        ChatSettings                             `json:"settings"`
+	History         []openai.ChatCompletionMessageParamUnion `json:"history"`
+	ImageHistory    map[string]string                        `json:"imageHistory,omitempty"`
+	ProcessedImages map[string]bool                          `json:"processedImages,omitempty"`
+}
+
+var snapshotNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// snapshotPath returns the file a named snapshot of a chat is stored in
+func snapshotPath(chatJID, name string) (string, error) {
+	if !snapshotNamePattern.MatchString(name) {
+		return "", fmt.Errorf("invalid snapshot name %q: use letters, digits, '-' or '_'", name)
+	}
+	chatName := strings.NewReplacer("@", "_", ":", "_", ".", "_").Replace(chatJID)
+	return tools.DataPath("snapshots", fmt.Sprintf("%s_%s.json", chatName, name)), nil
+}
+
+// SnapshotChat saves a chat's full AI state (history, image references, settings) under a name
+func (ws *WhatsAppService) SnapshotChat(chatJID, name string) error {
+	path, err := snapshotPath(chatJID, name)
+	if err != nil {
+		return err
+	}
+
+	snapshot := ChatSnapshot{
+		ChatJID:      chatJID,
+		CreatedAt:    time.Now(),
+		AIEnabled:    ws.IsAIEnabled(chatJID),
+		AckEnabled:   ws.isAckEnabled(chatJID),
+		CacheEnabled: ws.isCacheEnabled(chatJID),
+		Settings:     ws.chatSettingsFor(chatJID),
+	}
+	ws.historyMu.RLock()
+	snapshot.History = slices.Clone(ws.chatHistory[chatJID])
+	snapshot.ImageHistory = maps.Clone(ws.imageHistory[chatJID])
+	snapshot.ProcessedImages = maps.Clone(ws.processedImages[chatJID])
+	ws.historyMu.RUnlock()
+
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal snapshot: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to save snapshot to %s: %w", path, err)
+	}
+
+	fmt.Printf("Saved snapshot %s for chat %s (%d messages)\n", name, chatJID, len(snapshot.History))
+	return nil
+}
+
+// RestoreChat replaces a chat's AI state with a previously saved snapshot
+func (ws *WhatsAppService) RestoreChat(chatJID, name string) error {
+	path, err := snapshotPath(chatJID, name)
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read snapshot %s: %w", path, err)
+	}
+
+	var snapshot ChatSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return fmt.Errorf("failed to unmarshal snapshot: %w", err)
+	}
+
+	ws.historyMu.Lock()
+	ws.chatHistory[chatJID] = snapshot.History
+	ws.setChatImagesLocked(chatJID, snapshot.ImageHistory)
+	ws.processedImages[chatJID] = snapshot.ProcessedImages
+	ws.historyMu.Unlock()
+	ws.aiChatsMu.Lock()
+	if snapshot.AIEnabled {
+		ws.aiEnabledChats[chatJID] = true
+	} else {
+		delete(ws.aiEnabledChats, chatJID)
+	}
+	ws.aiChatsMu.Unlock()
+	ws.setAckEnabled(chatJID, snapshot.AckEnabled)
+	ws.setChatSettings(chatJID, snapshot.Settings)
+	ws.setCacheEnabled(chatJID, snapshot.CacheEnabled)
+
+	fmt.Printf("Restored snapshot %s for chat %s (%d messages)\n", name, chatJID, len(snapshot.History))
+	return nil
+}
@@ -0,0 +1,63 @@
+package whatsapp
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/openai/openai-go"
+)
+
+func TestSnapshotRestoreRoundTrip(t *testing.T) {
+	ws, _ := newTestService(t)
+	ws.aiConfigured = true
+	chat := "628120000000@s.whatsapp.net"
+	settings := ChatSettings{Model: "gpt-4o-mini", Language: "id", ResponsePrefix: "🤖"}
+
+	ws.SetAIEnabled(chat, true)
+	ws.setAckEnabled(chat, true)
+	ws.setCacheEnabled(chat, true)
+	ws.setChatSettings(chat, settings)
+	ws.appendHistory(chat, openai.UserMessage("Berapa harga Avanza?"), openai.AssistantMessage("Mulai 240 juta."))
+	ws.rememberImage(chat, "IMG1", "IMG1.jpg")
+	ws.markImageAsProcessedByAI(chat, "IMG1")
+	wantHistory, err := json.Marshal(ws.history(chat))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ws.SnapshotChat(chat, "before-reset"); err != nil {
+		t.Fatalf("SnapshotChat: %v", err)
+	}
+
+	// Wipe the chat's state, then bring it back
+	ws.ResetChat(chat, true)
+	ws.SetAIEnabled(chat, false)
+	ws.setAckEnabled(chat, false)
+	ws.setCacheEnabled(chat, false)
+	delete(ws.chatSettings, chat)
+
+	if err := ws.RestoreChat(chat, "before-reset"); err != nil {
+		t.Fatalf("RestoreChat: %v", err)
+	}
+
+	gotHistory, err := json.Marshal(ws.history(chat))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(gotHistory) != string(wantHistory) {
+		t.Errorf("history = %s, want %s", gotHistory, wantHistory)
+	}
+	if !ws.IsAIEnabled(chat) || !ws.isAckEnabled(chat) || !ws.isCacheEnabled(chat) {
+		t.Errorf("AI, ack, cache enabled = %t, %t, %t; want all restored", ws.IsAIEnabled(chat), ws.isAckEnabled(chat), ws.isCacheEnabled(chat))
+	}
+	if ws.chatSettingsFor(chat) != settings {
+		t.Errorf("settings = %+v, want %+v", ws.chatSettingsFor(chat), settings)
+	}
+	if ws.chatImages(chat)["IMG1"] != "IMG1.jpg" || !ws.hasImageBeenProcessedByAI(chat, "IMG1") {
+		t.Errorf("images = %v, want IMG1 restored and marked processed", ws.chatImages(chat))
+	}
+
+	if err := ws.RestoreChat(chat, "missing"); err == nil {
+		t.Error("RestoreChat of a missing snapshot succeeded")
+	}
+}
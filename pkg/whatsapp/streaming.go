@@ -0,0 +1,62 @@
+package whatsapp
+
+import (
+	"fmt"
+	"os"
+
+	"auto-lmk/pkg/tools"
+
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// loadStreamChunkMode reads STREAM_CHUNK_MODE, falling back to a single message
+func loadStreamChunkMode() tools.ChunkMode {
+	mode, err := tools.ParseChunkMode(os.Getenv("STREAM_CHUNK_MODE"))
+	if err != nil {
+		fmt.Printf("Warning: %v, sending AI replies in one message\n", err)
+		return tools.ChunkOnce
+	}
+	return mode
+}
+
+// chunkModeForChat returns the streaming chunk mode for a chat, honouring its override
+func (ws *WhatsAppService) chunkModeForChat(chatKey string) tools.ChunkMode {
+	if chunk := ws.chatSettingsFor(chatKey).StreamChunk; chunk != "" {
+		if mode, err := tools.ParseChunkMode(chunk); err == nil {
+			return mode
+		}
+	}
+	return ws.streamChunkMode
+}
+
+// streamAIResponse runs a streaming completion and sends the reply in chunks as they
+// complete. The prefix goes on the first message and the suffix on the last; the last
+// chunk is held back until the stream ends so it can carry the suffix. The first message
+// quotes original when set.
+func (ws *WhatsAppService) streamAIResponse(to types.JID, original *events.Message, chatKey string, mode tools.ChunkMode, generate func(onChunk func(string)) (string, error)) (string, error) {
+	prefix, suffix := ws.responseDecoration(chatKey)
+	pending := ""
+	deliver := func(text, suffix string) {
+		ws.enqueueReply(to, original, decorateAIResponse(text, prefix, suffix))
+		prefix = ""
+		original = nil
+	}
+
+	chunker := tools.NewChunker(mode, ws.streamChunkChars, func(chunk string) {
+		if pending != "" {
+			deliver(pending, "")
+		}
+		pending = chunk
+	})
+	response, err := generate(chunker.Write)
+	chunker.Flush()
+
+	if pending != "" {
+		if err != nil {
+			suffix = ""
+		}
+		deliver(pending, suffix)
+	}
+	return response, err
+}
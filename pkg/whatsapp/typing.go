@@ -0,0 +1,36 @@
+package whatsapp
+
+import (
+	"time"
+	"unicode/utf8"
+
+	"auto-lmk/pkg/tools"
+)
+
+// typingSimulation sizes the pause before an AI reply so it looks typed at a human pace
+type typingSimulation struct {
+	charsPerSecond float64 // 0 disables the delay
+	minDelay       time.Duration
+	maxDelay       time.Duration
+}
+
+// newTypingSimulation reads AI_TYPING_CPS, AI_TYPING_MIN_DELAY and AI_TYPING_MAX_DELAY
+func newTypingSimulation() typingSimulation {
+	ts := typingSimulation{
+		charsPerSecond: tools.EnvFloat("AI_TYPING_CPS", 25),
+		minDelay:       tools.EnvDuration("AI_TYPING_MIN_DELAY", time.Second),
+		maxDelay:       tools.EnvDuration("AI_TYPING_MAX_DELAY", 8*time.Second),
+	}
+	ts.maxDelay = max(ts.maxDelay, ts.minDelay)
+	return ts
+}
+
+// delay returns how long typing a reply takes, less the time already spent generating it
+func (ts typingSimulation) delay(reply string, elapsed time.Duration) time.Duration {
+	if ts.charsPerSecond <= 0 {
+		return 0
+	}
+	typing := time.Duration(float64(utf8.RuneCountInString(reply)) / ts.charsPerSecond * float64(time.Second))
+	typing = min(max(typing, ts.minDelay), ts.maxDelay)
+	return max(typing-elapsed, 0)
+}
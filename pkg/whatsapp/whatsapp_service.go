@@ -3,10 +3,14 @@ package whatsapp
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -14,9 +18,7 @@ import (
 
 	"github.com/joho/godotenv"
 	_ "github.com/mattn/go-sqlite3"
-	"github.com/mdp/qrterminal"
 	"github.com/openai/openai-go"
-	"github.com/openai/openai-go/option"
 	"go.mau.fi/whatsmeow"
 	"go.mau.fi/whatsmeow/proto/waCompanionReg"
 	waProto "go.mau.fi/whatsmeow/proto/waE2E"
@@ -28,16 +30,68 @@ import (
 	"google.golang.org/protobuf/proto"
 )
 
+// ErrAINotConfigured is returned when AI is requested but no AI provider is available
+var ErrAINotConfigured = errors.New("AI functionality is not available: the AI provider's API key is not configured")
+
 type WhatsAppService struct {
-	aiEnabledChats     map[string]bool
-	chatHistory        map[string][]openai.ChatCompletionMessageParamUnion
-	imageHistory       map[string]map[string]string
-	processedImages    map[string]map[string]bool
-	openaiClient       openai.Client
-	openaiConfigured   bool
-	whatsappClient     *whatsmeow.Client
-	whatsappDownloader *tools.WhatsAppDownloader
-	aiTools            *tools.AITools
+	aiEnabledChats       map[string]bool
+	ackEnabledChats      map[string]bool
+	cacheEnabledChats    map[string]bool
+	cacheChatsMu         sync.RWMutex // guards cacheEnabledChats
+	chatSettings         map[string]ChatSettings
+	chatSettingsMu       sync.RWMutex // guards chatSettings
+	imageGenEnabledChats map[string]bool
+	imageGenUsage        map[string]*dailyCount
+	imageGenMu           sync.Mutex // guards imageGenEnabledChats and imageGenUsage
+	imageGenDailyLimit   int
+	messageDumper        *messageDumper
+	pipeline             *pipelineState
+	groupMentionOnly     map[string]bool
+	onlineOnlyChats      map[string]bool
+	onlineOnlyMu         sync.RWMutex    // guards onlineOnlyChats
+	pausedAIChats        map[string]bool // chats "ai pauseall" turned off, nil unless paused
+	aiChatsMu            sync.RWMutex    // guards aiEnabledChats and pausedAIChats
+	presence             *presenceTracker
+	albums               *albumBuffer
+	webhooks             *tools.WebhookDispatcher
+	aiResponsePrefix     string
+	aiResponseSuffix     string
+	streamChunkMode      tools.ChunkMode
+	streamChunkChars     int
+	documentMaxChars     int
+	responseCache        *tools.ResponseCache
+	lastAckTime          map[string]time.Time
+	ackMu                sync.Mutex // guards ackEnabledChats and lastAckTime
+	ackConfig            ackConfig
+	progressReactions    progressReactions
+	staleThreshold       time.Duration
+	adminUsers           map[string]bool
+	accessList           *accessList
+	rateLimiter          *rateLimiter
+	sentMessages         *sentMessages
+	outbound             *outboundQueue
+	seenMessages         *seenMessages
+	lastMessages         map[string]types.MessageInfo
+	notifyUnsupported    bool
+	quoteReplies         string
+	typing               typingSimulation
+	stripImageMetadata   bool
+	toolCalling          bool
+	ignoreOwnDevices     bool
+	chatHistory          map[string][]openai.ChatCompletionMessageParamUnion
+	imageHistory         map[string]map[string]string
+	processedImages      map[string]map[string]bool
+	imageOrder           map[string][]string // image IDs per chat, oldest first
+	maxChatImages        int
+	historyMu            sync.RWMutex // guards chatHistory, imageHistory, processedImages, imageOrder and lastMessages
+	aiConfigured         bool
+	whatsappClient       tools.WhatsAppClient
+	device               *store.Device // login state and own JIDs of whatsappClient
+	whatsappDownloader   *tools.WhatsAppDownloader
+	aiTools              *tools.AITools
+	managed              bool                    // the client belongs to a WhatsAppManager, see NewClientService
+	instance             *tools.WhatsAppInstance // the managed client, nil otherwise
+	handlerID            uint32
 }
 
 func NewWhatsAppService() (*WhatsAppService, error) {
@@ -48,19 +102,16 @@ func NewWhatsAppService() (*WhatsAppService, error) {
 	}
 
 	// Create data directory if it doesn't exist
-	if err := os.MkdirAll("data", 0755); err != nil {
+	tools.SetDataDir(tools.EnvString("DATA_DIR", "data"))
+	if err := os.MkdirAll(tools.DataDir(), 0755); err != nil {
 		return nil, fmt.Errorf("failed to create data directory: %w", err)
 	}
 
-	service := &WhatsAppService{
-		aiEnabledChats:  make(map[string]bool),
-		chatHistory:     make(map[string][]openai.ChatCompletionMessageParamUnion),
-		imageHistory:    make(map[string]map[string]string),
-		processedImages: make(map[string]map[string]bool),
-	}
+	service := newWhatsAppService()
+	go service.runOutboundQueue()
 
-	// Initialize OpenAI client
-	if err := service.initializeOpenAI(); err != nil {
+	// Initialize the AI provider
+	if err := service.initializeAI(); err != nil {
 		fmt.Printf("Warning: %v\n", err)
 	}
 
@@ -72,27 +123,82 @@ func NewWhatsAppService() (*WhatsAppService, error) {
 	return service, nil
 }
 
-func (ws *WhatsAppService) initializeOpenAI() error {
-	apiKey := os.Getenv("OPENAI_API_KEY")
-	baseURL := os.Getenv("OPENAI_BASE_URL")
-	if apiKey == "" {
-		ws.openaiConfigured = false
-		return fmt.Errorf("OPENAI_API_KEY environment variable not set. AI functionality will be disabled")
+// newWhatsAppService creates the service state from the environment, without a WhatsApp
+// client or AI provider
+func newWhatsAppService() *WhatsAppService {
+	service := &WhatsAppService{
+		aiEnabledChats:       make(map[string]bool),
+		ackEnabledChats:      make(map[string]bool),
+		cacheEnabledChats:    make(map[string]bool),
+		chatSettings:         make(map[string]ChatSettings),
+		imageGenEnabledChats: make(map[string]bool),
+		imageGenUsage:        make(map[string]*dailyCount),
+		imageGenDailyLimit:   tools.EnvInt("IMAGE_GEN_DAILY_LIMIT", 5),
+		messageDumper:        newMessageDumper(),
+		pipeline:             newPipelineState(),
+		groupMentionOnly:     make(map[string]bool),
+		onlineOnlyChats:      make(map[string]bool),
+		presence:             newPresenceTracker(),
+		webhooks:             tools.NewWebhookDispatcher(os.Getenv("WEBHOOK_URL"), tools.EnvDuration("WEBHOOK_TIMEOUT", 10*time.Second)),
+		aiResponsePrefix:     os.Getenv("AI_RESPONSE_PREFIX"),
+		aiResponseSuffix:     os.Getenv("AI_RESPONSE_SUFFIX"),
+		streamChunkMode:      loadStreamChunkMode(),
+		streamChunkChars:     tools.EnvInt("STREAM_CHUNK_CHARS", tools.DefaultChunkChars),
+		documentMaxChars:     tools.EnvInt("DOCUMENT_MAX_CHARS", tools.DefaultDocumentMaxChars),
+		responseCache:        tools.NewResponseCache(tools.EnvDuration("RESPONSE_CACHE_TTL", time.Hour), tools.EnvInt("RESPONSE_CACHE_SIZE", 500)),
+		lastAckTime:          make(map[string]time.Time),
+		ackConfig:            loadAckConfig(),
+		progressReactions:    loadProgressReactions(),
+		staleThreshold:       tools.EnvDuration("AI_STALE_MESSAGE_THRESHOLD", 5*time.Minute),
+		adminUsers:           loadAdminUsers(),
+		accessList:           loadAccessList(),
+		rateLimiter:          newRateLimiter(tools.EnvInt("AI_RATE_LIMIT_PER_MINUTE", 10), tools.EnvInt("AI_RATE_LIMIT_BURST", 0)),
+		sentMessages:         newSentMessages(),
+		outbound:             newOutboundQueue(),
+		seenMessages:         newSeenMessages(),
+		lastMessages:         make(map[string]types.MessageInfo),
+		notifyUnsupported:    tools.EnvBool("AI_NOTIFY_UNSUPPORTED_MESSAGES", false),
+		quoteReplies:         loadQuoteReplies(),
+		typing:               newTypingSimulation(),
+		stripImageMetadata:   tools.EnvBool("STRIP_IMAGE_METADATA", false),
+		toolCalling:          tools.EnvBool("AI_TOOL_CALLING", false),
+		ignoreOwnDevices:     tools.EnvBool("IGNORE_OWN_DEVICES", true),
+		chatHistory:          make(map[string][]openai.ChatCompletionMessageParamUnion),
+		imageHistory:         make(map[string]map[string]string),
+		processedImages:      make(map[string]map[string]bool),
+		imageOrder:           make(map[string][]string),
+		maxChatImages:        tools.EnvInt("AI_MAX_CHAT_IMAGES", defaultMaxChatImages),
 	}
+	service.albums = newAlbumBuffer(service.handleAlbumWithAI)
+	return service
+}
 
-	clientOpts := []option.RequestOption{
-		option.WithAPIKey(apiKey),
-	}
-	if baseURL != "" {
-		clientOpts = append(clientOpts, option.WithBaseURL(baseURL))
+func (ws *WhatsAppService) initializeAI() error {
+	provider, model, err := tools.NewAIProviderFromEnv()
+	if err != nil {
+		ws.aiConfigured = false
+		return err
 	}
-
-	ws.openaiClient = openai.NewClient(clientOpts...)
-	ws.openaiConfigured = true
+	ws.aiConfigured = true
 
 	// Initialize AI tools
-	model := os.Getenv("OPENAI_MODEL")
-	ws.aiTools = tools.NewAITools(ws.openaiClient, model)
+	ws.aiTools = tools.NewAITools(provider, model)
+	ws.aiTools.SetVisionModel(os.Getenv("VISION_MODEL"))
+	ws.aiTools.SetTranscriptionModel(os.Getenv("TRANSCRIPTION_MODEL"))
+	ws.aiTools.SetImageGenerationModel(os.Getenv("IMAGE_GEN_MODEL"))
+	if err := ws.aiTools.DetectVisionSupport(context.Background()); err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	}
+	if !ws.aiTools.VisionSupported() {
+		fmt.Printf("Model %s does not accept images, image messages get a text-only reply\n", ws.aiTools.Model())
+	}
+
+	// Optional persona replacing the built-in system prompts
+	systemPrompt, err := tools.LoadSystemPrompt(tools.EnvString("SYSTEM_PROMPT_FILE", tools.DataPath("system_prompt.txt")))
+	if err != nil {
+		fmt.Printf("Using default system prompt: %v\n", err)
+	}
+	ws.aiTools.SetSystemPrompt(systemPrompt)
 
 	return nil
 }
@@ -100,7 +206,7 @@ func (ws *WhatsAppService) initializeOpenAI() error {
 func (ws *WhatsAppService) initializeWhatsApp() error {
 	// Create database connection
 	dbLog := waLog.Stdout("DB", "INFO", true)
-	db, err := sql.Open("sqlite3", "file:data/auto-lmk.db?_foreign_keys=on")
+	db, err := sql.Open("sqlite3", "file:"+tools.DataPath("auto-lmk.db")+"?_foreign_keys=on")
 	if err != nil {
 		return fmt.Errorf("failed to open database: %w", err)
 	}
@@ -133,10 +239,18 @@ func (ws *WhatsAppService) initializeWhatsApp() error {
 	clientLog := waLog.Stdout("WA", "INFO", true)
 	client := whatsmeow.NewClient(deviceStore, clientLog)
 	ws.whatsappClient = client
+	ws.device = deviceStore
 	client.AddEventHandler(ws.eventHandler)
 
 	// Initialize WhatsApp downloader
 	ws.whatsappDownloader = tools.NewWhatsAppDownloader(client)
+	ws.whatsappDownloader.SetDataDir(tools.DataDir())
+	if err := ws.whatsappDownloader.SetHistoryMetadataPath(tools.DataPath("auto-lmk_history.json")); err != nil {
+		fmt.Printf("Failed to load history metadata: %v\n", err)
+	}
+	if ws.aiTools != nil {
+		ws.whatsappDownloader.RegisterAITools(ws.aiTools)
+	}
 
 	// Add history sync handlers
 	ctx := context.Background()
@@ -168,7 +282,18 @@ func (ws *WhatsAppService) Start() error {
 }
 
 func (ws *WhatsAppService) connectToWhatsApp() error {
-	if ws.whatsappClient.Store.ID == nil {
+	if phone := os.Getenv("WHATSAPP_PAIR_PHONE"); ws.device.ID == nil && phone != "" {
+		// No ID stored, log in with a pairing code instead of a QR code
+		jid, err := tools.NormalizeJID(phone)
+		if err != nil {
+			return fmt.Errorf("invalid WHATSAPP_PAIR_PHONE: %w", err)
+		}
+		code, err := tools.PairWithPhone(context.Background(), ws.whatsappClient, jid.User)
+		if err != nil {
+			return fmt.Errorf("failed to request pairing code: %w", err)
+		}
+		fmt.Printf("Enter this pairing code in WhatsApp (Linked devices > Link with phone number): %s\n", code)
+	} else if ws.device.ID == nil {
 		// No ID stored, new login
 		qrChan, _ := ws.whatsappClient.GetQRChannel(context.Background())
 		err := ws.whatsappClient.Connect()
@@ -179,7 +304,7 @@ func (ws *WhatsAppService) connectToWhatsApp() error {
 		for evt := range qrChan {
 			if evt.Event == "code" {
 				fmt.Println("Scan this QR code with WhatsApp for PrimaMobil:")
-				qrterminal.GenerateHalfBlock(evt.Code, qrterminal.L, os.Stdout)
+				tools.PrintQRCode(evt.Code, os.Stdout)
 			}
 		}
 	} else {
@@ -197,8 +322,13 @@ func (ws *WhatsAppService) eventHandler(evt interface{}) {
 	switch v := evt.(type) {
 	case *events.Message:
 		ws.handleMessage(v)
+	case *events.Receipt:
+		ws.handleReceipt(v)
+	case *events.Presence:
+		ws.handlePresence(v)
 	case *events.Connected:
 		fmt.Println("PrimaMobil connected to WhatsApp!")
+		go ws.resubscribePresence()
 	case *events.Disconnected:
 		fmt.Println("PrimaMobil disconnected from WhatsApp")
 	case *events.PairSuccess:
@@ -207,19 +337,62 @@ func (ws *WhatsAppService) eventHandler(evt interface{}) {
 }
 
 func (ws *WhatsAppService) handleMessage(msg *events.Message) {
-	if msg.Info.IsFromMe {
+	if ws.isOwnMessage(msg.Info) {
 		return // Ignore own messages
 	}
+	if ws.seenMessages.duplicate(msg.Info, time.Now()) {
+		fmt.Printf("Skipping duplicate delivery of message %s in chat %s\n", msg.Info.ID, msg.Info.Chat.String())
+		return
+	}
+	if !ws.managed { // the manager counts and dispatches its clients' messages
+		tools.CountMessageReceived()
+		ws.webhooks.Dispatch(tools.NewWebhookEvent("", msg.Info, msg.Message))
+	}
+	ws.rememberLastMessage(msg.Info)
+
+	// Denied or unlisted contacts are ignored silently, including their "ai" commands
+	if !ws.isPermitted(msg.Info) {
+		return
+	}
 
+	// While paused only admin commands get through; the rest is queued or ignored
+	if !ws.isControlCommand(msg) && ws.pipeline.hold(msg, ws.isStaleMessage(msg.Info)) {
+		return
+	}
+
+	// Backlog delivered in a burst after a reconnect is ignored, except commands such as
+	// "ai off" which still take effect
+	if ws.isStaleMessage(msg.Info) && !isAICommand(msg) {
+		fmt.Printf("Skipping stale message %s in chat %s (sent %s)\n", msg.Info.ID, msg.Info.Chat.String(), msg.Info.Timestamp.Format(time.RFC3339))
+		return
+	}
+
+	ws.processMessage(msg)
+}
+
+// processMessage handles an inbound message once it has passed the pause gate
+func (ws *WhatsAppService) processMessage(msg *events.Message) {
 	info := msg.Info
 	message := msg.Message
 	var messageText string
 
+	if ws.messageDumper.dumps(dumpModeAll) {
+		go ws.messageDumper.Dump(info, message)
+	}
+
 	// Extract message text from different message types
 	if message.Conversation != nil && *message.Conversation != "" {
 		messageText = *message.Conversation
 	} else if message.ExtendedTextMessage != nil && message.ExtendedTextMessage.Text != nil {
 		messageText = *message.ExtendedTextMessage.Text
+	} else if reply := extractInteractiveReply(message); reply != nil {
+		// Button, list and template selections flow through as regular text
+		fmt.Printf("Received %s reply from %s: id=%s text=%s\n", reply.Kind, info.Sender.User, reply.SelectedID, reply.DisplayText)
+		messageText = reply.Text()
+	} else if shared := sharedContentText(message); shared != "" {
+		// Locations and contact cards reach the AI as a text description
+		fmt.Printf("Received shared %s from %s\n", messageTypeName(message), info.Sender.User)
+		messageText = shared
 	}
 
 	// Check for quoted messages in ExtendedTextMessage
@@ -290,93 +463,522 @@ func (ws *WhatsAppService) handleMessage(msg *events.Message) {
 			go ws.storeImageInHistory(info.Sender, info.Chat, message.ImageMessage, caption, info.ID)
 
 			// If AI is enabled, process the image
-			if ws.aiEnabledChats[info.Chat.String()] {
+			if ws.IsAIEnabled(info.Chat.String()) && !ws.shouldReply(info, message) {
+				fmt.Printf("Group %s is in mention-only mode, storing image without reply\n", info.Chat.String())
+			} else if albumID := albumParentID(message); albumID != "" && ws.IsAIEnabled(info.Chat.String()) {
+				// Answer the whole album at once when its other images have arrived
+				ws.albums.add(info.Sender, info.Chat, albumID, incomingImage{msg: message.ImageMessage, info: info}, caption)
+			} else if ws.IsAIEnabled(info.Chat.String()) {
 				fmt.Printf("AI enabled for chat %s, processing image...\n", info.Chat.String())
-				go ws.handleImageMessageWithAI(info.Sender, info.Chat, message.ImageMessage, caption, info.ID)
+				go ws.handleImageMessageWithAI(info, message.ImageMessage, caption)
 			} else {
 				fmt.Printf("AI not enabled for chat %s, storing image for future reference\n", info.Chat.String())
 			}
+		} else if message.AlbumMessage != nil {
+			// Announces how many images follow, so the album can be answered as soon as they're in
+			if ws.IsAIEnabled(info.Chat.String()) {
+				ws.albums.expect(info.Chat, info.ID, int(message.AlbumMessage.GetExpectedImageCount()))
+			}
 		} else if message.AudioMessage != nil {
 			fmt.Printf("Received audio from %s\n", info.Sender.User)
+
+			if ws.IsAIEnabled(info.Chat.String()) && ws.shouldReply(info, message) {
+				go ws.markMessageAsRead(info)
+				go ws.handleAudioMessageWithAI(info, message)
+			}
 		} else if message.VideoMessage != nil {
 			caption := ""
 			if message.VideoMessage.Caption != nil {
 				caption = *message.VideoMessage.Caption
 			}
 			fmt.Printf("Received video from %s: %s\n", info.Sender.User, caption)
-		} else if message.DocumentMessage != nil {
-			title := ""
-			if message.DocumentMessage.Title != nil {
-				title = *message.DocumentMessage.Title
+
+			if caption != "" && ws.IsAIEnabled(info.Chat.String()) && ws.shouldReply(info, message) {
+				go ws.markMessageAsRead(info)
+				go ws.handleVideoMessageWithAI(info, message, caption)
+			}
+		} else if message.StickerMessage != nil {
+			fmt.Printf("Received sticker from %s (animated: %t)\n", info.Sender.User, message.StickerMessage.GetIsAnimated())
+
+			if ws.IsAIEnabled(info.Chat.String()) && ws.shouldReply(info, message) {
+				go ws.markMessageAsRead(info)
+				go ws.handleStickerMessageWithAI(info, message.StickerMessage)
+			}
+		} else if docMsg := documentMessage(message); docMsg != nil {
+			fmt.Printf("Received document from %s: %s (%s)\n", info.Sender.User, documentName(docMsg), docMsg.GetMimetype())
+
+			if ws.IsAIEnabled(info.Chat.String()) && ws.shouldReply(info, message) {
+				go ws.markMessageAsRead(info)
+				go ws.handleDocumentMessageWithAI(info, docMsg)
+			}
+		} else {
+			// Log anything we don't handle yet so missing message types can be discovered
+			typeName := messageTypeName(message)
+			fmt.Printf("Received unsupported message type %s from %s\n", typeName, info.Sender.User)
+
+			if ws.messageDumper.dumps(dumpModeUnsupported) {
+				go ws.messageDumper.Dump(info, message)
+			}
+
+			if ws.notifyUnsupported && !silentMessageTypes[typeName] && ws.IsAIEnabled(info.Chat.String()) {
+				messageText = fmt.Sprintf(tools.UnsupportedMessageTemplate, typeName)
 			}
-			fmt.Printf("Received document from %s: %s\n", info.Sender.User, title)
 		}
-		return
+
+		if messageText == "" {
+			ws.maybeSendAck(info)
+			return
+		}
 	}
 
 	fmt.Printf("Received message from %s: %s\n", info.Sender.User, messageText)
 
 	// Handle AI commands
 	if strings.HasPrefix(strings.ToLower(messageText), "ai ") {
-		ws.handleAICommand(info.Sender, strings.TrimSpace(strings.ToLower(messageText[3:])), info.Chat.String())
+		ws.handleAICommand(info.Sender, strings.TrimSpace(messageText[3:]), info.Chat.String())
 		return
 	}
 
 	// Handle AI responses when enabled for this chat
-	if ws.aiEnabledChats[info.Chat.String()] {
+	if ws.IsAIEnabled(info.Chat.String()) {
 		// Mark message as read when AI is enabled
 		go ws.markMessageAsRead(info)
 
+		// In mention-only groups, stay quiet unless the bot is mentioned or quoted
+		if !ws.shouldReply(info, message) {
+			return
+		}
+
 		if messageText != "" {
-			go ws.handleAIResponseWithTyping(info.Sender, info.Chat, messageText, message)
+			go func() {
+				if !ws.allowAIReply(info.Chat) {
+					return
+				}
+				if ws.handleImageGenerationRequest(info.Sender, info.Chat, messageText) {
+					return
+				}
+				ws.handleAIResponseWithTyping(info, messageText, message)
+			}()
 		} else if message.ImageMessage != nil {
 			// Handle image-only messages - save image and let AI decide
 			caption := ""
 			if message.ImageMessage.Caption != nil {
 				caption = *message.ImageMessage.Caption
 			}
-			go ws.handleImageMessageWithAI(info.Sender, info.Chat, message.ImageMessage, caption, info.ID)
+			go ws.handleImageMessageWithAI(info, message.ImageMessage, caption)
 		}
+	} else {
+		ws.maybeSendAck(info)
+	}
+}
+
+// SetAIEnabled turns AI responses on or off for a chat
+func (ws *WhatsAppService) SetAIEnabled(chatJID string, enabled bool) error {
+	ws.aiChatsMu.Lock()
+	defer ws.aiChatsMu.Unlock()
+	if !enabled {
+		delete(ws.aiEnabledChats, chatJID)
+		delete(ws.pausedAIChats, chatJID) // stay off after "ai resumeall"
+		return nil
+	}
+	if !ws.aiConfigured {
+		return ErrAINotConfigured
+	}
+	ws.aiEnabledChats[chatJID] = true
+	return nil
+}
+
+// IsAIEnabled reports whether AI responses are enabled for a chat
+func (ws *WhatsAppService) IsAIEnabled(chatJID string) bool {
+	ws.aiChatsMu.RLock()
+	defer ws.aiChatsMu.RUnlock()
+	return ws.aiEnabledChats[chatJID]
+}
+
+// AIEnabledChats returns the JIDs of the chats AI is enabled in, sorted
+func (ws *WhatsAppService) AIEnabledChats() []string {
+	ws.aiChatsMu.RLock()
+	defer ws.aiChatsMu.RUnlock()
+	chats := make([]string, 0, len(ws.aiEnabledChats))
+	for chatJID := range ws.aiEnabledChats {
+		chats = append(chats, chatJID)
+	}
+	sort.Strings(chats)
+	return chats
+}
+
+// ResetChat clears a chat's AI conversation history so the next message starts fresh,
+// leaving AI enabled. With forgetImages the chat's stored image references are dropped
+// too; the image files stay on disk. It returns the number of history messages cleared.
+func (ws *WhatsAppService) ResetChat(chatJID string, forgetImages bool) int {
+	ws.historyMu.Lock()
+	defer ws.historyMu.Unlock()
+	cleared := len(ws.chatHistory[chatJID])
+	delete(ws.chatHistory, chatJID)
+	if forgetImages {
+		delete(ws.imageHistory, chatJID)
+		delete(ws.processedImages, chatJID)
+		delete(ws.imageOrder, chatJID)
+	}
+	return cleared
+}
+
+// isStaleMessage reports whether a message is older than the configured staleness threshold
+func (ws *WhatsAppService) isStaleMessage(info types.MessageInfo) bool {
+	if ws.staleThreshold <= 0 || info.Timestamp.IsZero() || ws.pipeline.isReplaying(info.ID) {
+		return false
+	}
+	return time.Since(info.Timestamp) > ws.staleThreshold
+}
+
+const aiCommandHelp = "Available AI commands:\n" +
+	"ai on - Enable AI responses\n" +
+	"ai off - Disable AI responses\n" +
+	"ai status - Check AI status\n" +
+	"ai list - Show every chat AI is enabled in (admin)\n" +
+	"ai ack on - React to messages while AI is off\n" +
+	"ai ack off - Stop auto-acknowledge reactions\n" +
+	"ai usage - Show the AI tokens this chat has used\n" +
+	"ai reset [all] - Start the AI conversation over; \"all\" also forgets this chat's images\n" +
+	"ai export - Save this chat's AI conversation to the data directory (operators)\n" +
+	"ai cache on|off|clear|stats - Manage cached AI answers for this chat\n" +
+	"ai analyze <id1,id2,...> [question] - Compare several stored images\n" +
+	"ai getimage <messageID> - Download an older image from this chat's history and resend it\n" +
+	"ai similar <imageID> - List older images of this chat that look like a stored image\n" +
+	"ai synchistory [count] - Ask the phone for older messages so their images can be fetched (admin)\n" +
+	"ai imagegen on|off - Allow generating images on request\n" +
+	"ai mention on|off - In groups, only reply when mentioned or quoted\n" +
+	"ai online on|off - Wait until you're online before replying\n" +
+	"ai disk - Show image storage per chat (admin)\n" +
+	"ai pause|resume - Stop or restart replying in all chats during maintenance (admin)\n" +
+	"ai pauseall|resumeall - Turn AI off in every chat and later back on where it was on (admin)\n" +
+	"ai allow|deny|unlist [number] - Manage which contacts the AI answers (admin)\n" +
+	"ai set [prompt|model|language|maxtokens|prefix|suffix|chunk|notes] <value> - Show or change this chat's AI settings (operators)\n" +
+	"ai profile save|apply|list <name> - Share chat AI settings as profiles (operators)\n" +
+	"ai snapshot <name> - Save this chat's AI conversation and settings under a name (admin)\n" +
+	"ai restore <name> - Bring back a saved snapshot of this chat (admin)"
+
+// requireAdmin reports whether the sender may run an operator command, replying if not
+func (ws *WhatsAppService) requireAdmin(to types.JID) bool {
+	if ws.isAdmin(to) {
+		return true
+	}
+	ws.sendMessage(to, "⛔ This command is restricted to operators.")
+	return false
+}
+
+// aiNotConfiguredMessage tells an operator which setting enables AI
+func aiNotConfiguredMessage() string {
+	if key := tools.AIProviderKeyEnv(); key != "" {
+		return fmt.Sprintf("AI functionality is not available. %s not configured.", key)
 	}
+	return "AI functionality is not available. Check the AI_PROVIDER settings."
 }
 
 func (ws *WhatsAppService) handleAICommand(to types.JID, command string, chatJID string) {
-	switch command {
+	// Only the command name is case-insensitive; arguments such as prompts keep their case
+	name, arg, _ := strings.Cut(command, " ")
+	name = strings.ToLower(name)
+	arg = strings.TrimSpace(arg)
+
+	switch name {
 	case "on":
-		if !ws.openaiConfigured {
-			ws.sendMessage(to, "AI functionality is not available. OPENAI_API_KEY not configured.")
+		if err := ws.SetAIEnabled(chatJID, true); err != nil {
+			ws.sendMessage(to, aiNotConfiguredMessage())
 			return
 		}
-		ws.aiEnabledChats[chatJID] = true
 		ws.sendMessage(to, "🤖 AI mode enabled for this chat. I will now respond to your messages using AI.\n\n💡 **Note:** I can only reference images sent after AI was enabled. For older images, please resend them so I can analyze them.")
 	case "off":
-		delete(ws.aiEnabledChats, chatJID)
+		ws.SetAIEnabled(chatJID, false)
 		ws.sendMessage(to, "🤖 AI mode disabled for this chat.")
 	case "status":
-		if ws.aiEnabledChats[chatJID] {
-			ws.sendMessage(to, "🤖 AI mode is currently enabled for this chat.")
-		} else {
-			ws.sendMessage(to, "🤖 AI mode is currently disabled for this chat.")
+		status := "🤖 AI mode is currently disabled for this chat."
+		if ws.IsAIEnabled(chatJID) {
+			status = "🤖 AI mode is currently enabled for this chat."
 		}
+		if paused := ws.pauseStatus(); paused != "" {
+			status += "\n" + paused
+		}
+		if restore, paused := ws.pausedAIChatCount(); paused {
+			status += fmt.Sprintf("\n⏸️ AI is paused in all chats (%d to restore with \"ai resumeall\")", restore)
+		}
+		ws.sendMessage(to, status)
+	case "usage":
+		ws.handleUsageCommand(to, chatJID)
+	case "list":
+		if ws.requireAdmin(to) {
+			ws.handleListCommand(to)
+		}
+	case "reset":
+		switch strings.ToLower(arg) {
+		case "":
+			cleared := ws.ResetChat(chatJID, false)
+			ws.sendMessage(to, fmt.Sprintf("🧹 Conversation reset, %d messages forgotten. AI is still on.", cleared))
+		case "all":
+			cleared := ws.ResetChat(chatJID, true)
+			ws.sendMessage(to, fmt.Sprintf("🧹 Conversation and image references reset, %d messages forgotten. AI is still on.", cleared))
+		default:
+			ws.sendMessage(to, aiCommandHelp)
+		}
+	case "pause", "resume":
+		ws.handlePauseCommand(to, name)
+	case "pauseall", "resumeall":
+		ws.handlePauseAllCommand(to, name)
+	case "allow", "deny", "unlist":
+		ws.handleAccessCommand(to, name, arg)
+	case "showprompt":
+		if !ws.requireAdmin(to) {
+			return
+		}
+		aiTools := ws.aiToolsForChat(chatJID)
+		if aiTools == nil {
+			ws.sendMessage(to, tools.ErrorMessageAIToolsNotInit)
+			return
+		}
+		prompt, err := aiTools.PreviewTextPrompt("<next message>", nil, ws.history(chatJID))
+		if err != nil {
+			ws.sendMessage(to, fmt.Sprintf("❌ Failed to render prompt: %v", err))
+			return
+		}
+		ws.sendMessage(to, prompt)
+	case "ack":
+		switch strings.ToLower(arg) {
+		case "on":
+			ws.setAckEnabled(chatJID, true)
+			ws.sendMessage(to, "✅ Auto-acknowledge enabled for this chat. Incoming messages will get a reaction while AI is off.")
+		case "off":
+			ws.setAckEnabled(chatJID, false)
+			ws.sendMessage(to, "✅ Auto-acknowledge disabled for this chat.")
+		default:
+			ws.sendMessage(to, aiCommandHelp)
+		}
+	case "cache":
+		ws.handleCacheCommand(to, arg, chatJID)
+	case "analyze":
+		ws.handleAnalyzeCommand(to, arg, chatJID)
+	case "getimage":
+		ws.handleGetImageCommand(to, arg, chatJID)
+	case "similar":
+		ws.handleSimilarCommand(to, arg, chatJID)
+	case "synchistory":
+		ws.handleSyncHistoryCommand(to, arg, chatJID)
+	case "imagegen":
+		ws.handleImageGenCommand(to, arg, chatJID)
+	case "mention":
+		ws.handleMentionCommand(to, arg, chatJID)
+	case "online":
+		ws.handleOnlineCommand(to, arg, chatJID)
+	case "disk":
+		if ws.requireAdmin(to) {
+			ws.handleDiskCommand(to)
+		}
+	case "set":
+		if ws.requireAdmin(to) {
+			ws.handleSettingsCommand(to, arg, chatJID)
+		}
+	case "profile":
+		if ws.requireAdmin(to) {
+			ws.handleProfileCommand(to, arg, chatJID)
+		}
+	case "export":
+		if !ws.requireAdmin(to) {
+			return
+		}
+		path, err := ws.exportChatHistoryToFile(chatJID)
+		if err != nil {
+			ws.sendMessage(to, fmt.Sprintf("❌ Failed to export chat history: %v", err))
+			return
+		}
+		ws.sendMessage(to, fmt.Sprintf("📤 Exported %d messages to %s", ws.historyLen(chatJID), path))
+	case "snapshot":
+		if !ws.requireAdmin(to) {
+			return
+		}
+		if err := ws.SnapshotChat(chatJID, arg); err != nil {
+			ws.sendMessage(to, fmt.Sprintf("❌ Failed to save snapshot: %v", err))
+			return
+		}
+		ws.sendMessage(to, fmt.Sprintf("💾 Snapshot '%s' saved for this chat.", arg))
+	case "restore":
+		if !ws.requireAdmin(to) {
+			return
+		}
+		if err := ws.RestoreChat(chatJID, arg); err != nil {
+			ws.sendMessage(to, fmt.Sprintf("❌ Failed to restore snapshot: %v", err))
+			return
+		}
+		ws.sendMessage(to, fmt.Sprintf("♻️ Snapshot '%s' restored for this chat.", arg))
 	default:
-		ws.sendMessage(to, "Available AI commands:\nai on - Enable AI responses\nai off - Disable AI responses\nai status - Check AI status")
+		ws.sendMessage(to, aiCommandHelp)
 	}
 }
 
-func (ws *WhatsAppService) sendMessage(to types.JID, text string) {
-	if ws.whatsappClient == nil {
-		fmt.Printf("Cannot send message: WhatsApp client not initialized\n")
+// handleUsageCommand replies with the tokens the chat has used since startup
+func (ws *WhatsAppService) handleUsageCommand(to types.JID, chatJID string) {
+	aiTools := ws.aiToolsForChat(chatJID)
+	if aiTools == nil {
+		ws.sendMessage(to, tools.ErrorMessageAIToolsNotInit)
 		return
 	}
+	usage := aiTools.GetUsageStats()[chatJID]
+	ws.sendMessage(to, fmt.Sprintf("📊 AI usage for this chat since startup:\nRequests: %d\nPrompt tokens: %d\nCompletion tokens: %d\nTotal tokens: %d",
+		usage.Requests, usage.PromptTokens, usage.CompletionTokens, usage.TotalTokens))
+}
+
+// maxListedChats caps the chats "ai list" names so the reply stays readable
+const maxListedChats = 50
+
+// handleListCommand replies with the chats AI is enabled in
+func (ws *WhatsAppService) handleListCommand(to types.JID) {
+	chats := ws.AIEnabledChats()
+	if len(chats) == 0 {
+		ws.sendMessage(to, "🤖 AI is not enabled in any chat.")
+		return
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "🤖 AI is enabled in %d chats:", len(chats))
+	for _, chatJID := range chats[:min(len(chats), maxListedChats)] {
+		sb.WriteString("\n" + chatJID)
+	}
+	if len(chats) > maxListedChats {
+		fmt.Fprintf(&sb, "\n…and %d more", len(chats)-maxListedChats)
+	}
+	ws.sendMessage(to, sb.String())
+}
+
+func (ws *WhatsAppService) handleCacheCommand(to types.JID, arg string, chatJID string) {
+	switch strings.ToLower(arg) {
+	case "on":
+		ws.setCacheEnabled(chatJID, true)
+		ws.sendMessage(to, "🗃️ Response caching enabled for this chat. Repeated questions will be answered from cache.")
+	case "off":
+		ws.setCacheEnabled(chatJID, false)
+		ws.sendMessage(to, "🗃️ Response caching disabled for this chat. Answers will always be fresh.")
+	case "clear":
+		removed := ws.responseCache.ClearChat(chatJID)
+		ws.sendMessage(to, fmt.Sprintf("🗃️ Cleared %d cached answers for this chat.", removed))
+	case "stats":
+		stats := ws.responseCache.Stats(chatJID)
+		state := "disabled"
+		if ws.isCacheEnabled(chatJID) {
+			state = "enabled"
+		}
+		ws.sendMessage(to, fmt.Sprintf("🗃️ Response cache (%s)\nEntries: %d\nHits: %d\nMisses: %d", state, stats.Entries, stats.Hits, stats.Misses))
+	default:
+		ws.sendMessage(to, aiCommandHelp)
+	}
+}
+
+// setCacheEnabled turns response caching on or off for a chat
+func (ws *WhatsAppService) setCacheEnabled(chatJID string, enabled bool) {
+	ws.cacheChatsMu.Lock()
+	defer ws.cacheChatsMu.Unlock()
+	if enabled {
+		ws.cacheEnabledChats[chatJID] = true
+	} else {
+		delete(ws.cacheEnabledChats, chatJID)
+	}
+}
+
+// isCacheEnabled reports whether AI responses are cached for a chat
+func (ws *WhatsAppService) isCacheEnabled(chatJID string) bool {
+	ws.cacheChatsMu.RLock()
+	defer ws.cacheChatsMu.RUnlock()
+	return ws.cacheEnabledChats[chatJID]
+}
+
+// cachedAIResponse answers from the response cache when caching is enabled for the chat,
+// otherwise calls generate and stores its result
+func (ws *WhatsAppService) cachedAIResponse(chatKey string, message string, generate func() (string, error)) (string, error) {
+	aiTools := ws.aiToolsForChat(chatKey)
+	if !ws.isCacheEnabled(chatKey) || aiTools == nil {
+		return generate()
+	}
+
+	key := aiTools.CacheKey(message)
+	if response, ok := ws.responseCache.Get(chatKey, key); ok {
+		fmt.Printf("Serving cached AI response for chat %s\n", chatKey)
+		return response, nil
+	}
+
+	response, err := generate()
+	if err != nil {
+		return "", err
+	}
+	ws.responseCache.Put(chatKey, key, response)
+	return response, nil
+}
+
+// sendMessage sends a text message and returns its ID, or "" if sending failed
+func (ws *WhatsAppService) sendMessage(to types.JID, text string) types.MessageID {
+	return ws.sendReply(to, nil, text)
+}
+
+// sendImage sends image data (JPEG, PNG, WebP or GIF) with an optional caption, e.g. an
+// image the AI produced in response to a message
+func (ws *WhatsAppService) sendImage(to types.JID, data []byte, caption string) error {
+	if ws.whatsappClient == nil {
+		return fmt.Errorf("WhatsApp client not initialized")
+	}
 
 	ctx := context.Background()
-	msg := &waProto.Message{
-		Conversation: proto.String(text),
+	imgMsg, err := ws.uploadImage(ctx, data, caption)
+	if err != nil {
+		return err
 	}
 
-	_, err := ws.whatsappClient.SendMessage(ctx, to, msg)
+	_, err = ws.whatsappClient.SendMessage(ctx, to, &waProto.Message{ImageMessage: imgMsg})
 	if err != nil {
-		fmt.Printf("Failed to send message to %s: %v\n", to.User, err)
+		return fmt.Errorf("failed to send image: %w", err)
+	}
+	return nil
+}
+
+// uploadImage uploads image data to WhatsApp's media servers and returns the message referencing it
+func (ws *WhatsAppService) uploadImage(ctx context.Context, data []byte, caption string) (*waProto.ImageMessage, error) {
+	uploaded, err := ws.whatsappClient.Upload(ctx, data, whatsmeow.MediaImage)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload image: %w", err)
+	}
+
+	imgMsg := &waProto.ImageMessage{
+		Mimetype:      proto.String(tools.DetectImageType("", data)),
+		URL:           proto.String(uploaded.URL),
+		DirectPath:    proto.String(uploaded.DirectPath),
+		MediaKey:      uploaded.MediaKey,
+		FileEncSHA256: uploaded.FileEncSHA256,
+		FileSHA256:    uploaded.FileSHA256,
+		FileLength:    proto.Uint64(uploaded.FileLength),
+	}
+	if caption != "" {
+		imgMsg.Caption = proto.String(caption)
+	}
+
+	// Without a thumbnail and dimensions the chat shows an empty placeholder until the
+	// image is downloaded; the image is still sent if they can't be computed
+	if thumbnail, width, height, err := tools.ImageThumbnail(data); err != nil {
+		fmt.Printf("Failed to create image thumbnail: %v\n", err)
+	} else {
+		imgMsg.JPEGThumbnail = thumbnail
+		imgMsg.Width = proto.Uint32(uint32(width))
+		imgMsg.Height = proto.Uint32(uint32(height))
+	}
+	return imgMsg, nil
+}
+
+func (ws *WhatsAppService) sendReaction(chat, sender types.JID, messageID types.MessageID, emoji string) {
+	if ws.whatsappClient == nil {
+		fmt.Printf("Cannot send reaction: WhatsApp client not initialized\n")
+		return
+	}
+
+	ctx := context.Background()
+	msg := ws.whatsappClient.BuildReaction(chat, sender, messageID, emoji)
+
+	_, err := ws.whatsappClient.SendMessage(ctx, chat, msg)
+	if err != nil {
+		fmt.Printf("Failed to send reaction to %s: %v\n", chat.User, err)
 	}
 }
 
@@ -396,32 +998,360 @@ func (ws *WhatsAppService) markMessageAsRead(info types.MessageInfo) {
 // For brevity, I'm showing the main structure. The remaining methods from main.go
 // would be moved here as well.
 
-func (ws *WhatsAppService) handleAIResponseWithTyping(to types.JID, chat types.JID, message string, msg *waProto.Message) {
-	// Implementation would be moved here...
+// handleAIResponseWithTyping answers a text message with the AI while showing the typing
+// indicator and, when enabled, progress reactions on the message being answered
+func (ws *WhatsAppService) handleAIResponseWithTyping(info types.MessageInfo, message string, msg *waProto.Message) {
+	to, chat := info.Sender, info.Chat
+	chatKey := chat.String()
+	aiTools := ws.aiToolsForChat(chatKey)
+	if aiTools == nil {
+		ws.sendMessage(chat, tools.ErrorMessageAIToolsNotInit)
+		return
+	}
+	ws.deferUntilOnline(chat)
+
+	ws.reactProgress(info, ws.progressReactions.working)
+	typingSince := time.Now()
+	stopTyping := ws.startTyping(chat)
+	defer stopTyping()
+
+	quotedMessageID := ""
+	if msg != nil && msg.ExtendedTextMessage != nil && msg.ExtendedTextMessage.ContextInfo != nil {
+		quotedMessageID = msg.ExtendedTextMessage.ContextInfo.GetStanzaID()
+	}
+	referencedImages := ws.findReferencedImages(message, chatKey, quotedMessageID)
+	history := ws.history(chatKey)
+
+	original := ws.replyTarget(info, msg)
+	mode := ws.chunkModeForChat(chatKey)
+	streamed := false
+	response, err := ws.cachedAIResponse(chatKey, message, func() (string, error) {
+		// Tool calls need the full response before anything is sent, so they don't stream
+		if ws.toolCalling {
+			return aiTools.ProcessWithTools(context.Background(), message, referencedImages, history)
+		}
+		if mode == tools.ChunkOnce {
+			return aiTools.ProcessTextWithAI(context.Background(), message, referencedImages, history, nil)
+		}
+		streamed = true
+		return ws.streamAIResponse(chat, original, chatKey, mode, func(onChunk func(string)) (string, error) {
+			return aiTools.ProcessTextWithAIStream(context.Background(), message, referencedImages, history, onChunk)
+		})
+	})
+	if err != nil {
+		fmt.Printf("Failed to process message from %s with AI: %v\n", to.User, err)
+		stopTyping()
+		ws.reactProgress(info, "") // clear the in-progress reaction
+		ws.sendMessage(chat, tools.ErrorMessageProcessingMessage)
+		return
+	}
+
+	ws.appendHistory(chatKey, openai.UserMessage(message), openai.AssistantMessage(response))
+
+	if !streamed {
+		// Keep composing for as long as typing the reply would take
+		time.Sleep(ws.typing.delay(response, time.Since(typingSince)))
+		stopTyping()
+		ws.sendAIReply(chat, original, chatKey, response)
+	}
+	stopTyping()
+	ws.reactProgress(info, ws.progressReactions.done)
 }
 
-func (ws *WhatsAppService) handleImageMessageWithAI(to types.JID, chat types.JID, imgMsg *waProto.ImageMessage, caption string, messageID string) {
-	// Implementation would be moved here...
+// startTyping shows the composing indicator in a chat, refreshing it until the returned
+// stop function is called; stop clears the indicator and is safe to call more than once
+func (ws *WhatsAppService) startTyping(chat types.JID) func() {
+	if ws.whatsappClient == nil {
+		return func() {}
+	}
+
+	ctx := context.Background()
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(10 * time.Second)
+		defer ticker.Stop()
+		for {
+			if err := ws.whatsappClient.SendChatPresence(ctx, chat, types.ChatPresenceComposing, types.ChatPresenceMediaText); err != nil {
+				fmt.Printf("Failed to send typing indicator to %s: %v\n", chat, err)
+			}
+			select {
+			case <-ticker.C:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			<-stopped // don't let a late composing update override the pause
+			if err := ws.whatsappClient.SendChatPresence(ctx, chat, types.ChatPresencePaused, types.ChatPresenceMediaText); err != nil {
+				fmt.Printf("Failed to clear typing indicator in %s: %v\n", chat, err)
+			}
+		})
+	}
 }
 
-func (ws *WhatsAppService) findReferencedImages(message string, chatKey string, quotedMessageID string) []map[string]string {
-	// Implementation would be moved here...
-	return nil
+// handleImageMessageWithAI downloads an image, stores it for later reference and answers it with the AI
+func (ws *WhatsAppService) handleImageMessageWithAI(info types.MessageInfo, imgMsg *waProto.ImageMessage, caption string) {
+	ws.handleImagesWithAI(info.Sender, info.Chat, []incomingImage{{msg: imgMsg, info: info}}, caption)
 }
 
-func (ws *WhatsAppService) hasImageBeenProcessedByAI(chatKey string, imageID string) bool {
-	if chatProcessed, exists := ws.processedImages[chatKey]; exists {
-		return chatProcessed[imageID]
+// handleImagesWithAI downloads and stores several images, e.g. an album, and answers them
+// together with one AI request. Images that fail to download are left out.
+func (ws *WhatsAppService) handleImagesWithAI(to types.JID, chat types.JID, images []incomingImage, caption string) {
+	chatKey := chat.String()
+	aiTools := ws.aiToolsForChat(chatKey)
+	if aiTools == nil {
+		ws.sendMessage(chat, tools.ErrorMessageAIToolsNotInit)
+		return
 	}
-	return false
+	if !ws.allowAIReply(chat) {
+		return
+	}
+
+	stopTyping := ws.startTyping(chat)
+	defer stopTyping()
+
+	ctx := context.Background()
+	var filenames, imageIDs []string
+	errorMessage := ""
+	for _, img := range images {
+		filename, errMsg := ws.saveIncomingImage(ctx, chatKey, img)
+		if errMsg != "" {
+			errorMessage = errMsg
+			continue
+		}
+		filenames = append(filenames, filename)
+		imageIDs = append(imageIDs, img.info.ID)
+	}
+	if len(filenames) == 0 {
+		ws.sendMessage(chat, errorMessage)
+		return
+	}
+
+	prompt := caption
+	if prompt == "" {
+		prompt = tools.DefaultImagePrompt
+	}
+
+	response, err := aiTools.ProcessImageWithAI(ctx, prompt, filenames, imageIDs, ws.history(chatKey), nil)
+	if err != nil {
+		fmt.Printf("Failed to process images %v with AI: %v\n", imageIDs, err)
+		stopTyping()
+		ws.sendMessage(chat, imageErrorMessage(err, tools.ErrorMessageImageProcessing))
+		return
+	}
+
+	userMessage := prompt + "\n"
+	for _, id := range imageIDs[:min(len(imageIDs), aiTools.MaxImages())] {
+		ws.markImageAsProcessedByAI(chatKey, id)
+		userMessage += fmt.Sprintf("\n[Image ID: %s]", id)
+	}
+	ws.appendHistory(chatKey, openai.UserMessage(userMessage), openai.AssistantMessage(response))
+
+	stopTyping()
+	ws.sendAIMessage(chat, chatKey, response)
 }
 
-func (ws *WhatsAppService) markImageAsProcessedByAI(chatKey string, imageID string) {
-	if ws.processedImages[chatKey] == nil {
-		ws.processedImages[chatKey] = make(map[string]bool)
+// imageErrorMessage returns the reply for a failed ProcessImageWithAI call, explaining
+// unreadable formats and moderated content instead of the generic fallback
+func imageErrorMessage(err error, fallback string) string {
+	switch {
+	case errors.Is(err, tools.ErrImageFormatUnsupported):
+		return tools.ErrorMessageImageFormat
+	case errors.Is(err, tools.ErrContentFlagged):
+		return tools.ErrorMessageContentFlagged
+	default:
+		return fallback
+	}
+}
+
+// saveIncomingImage downloads an image and stores it for later reference, returning its
+// filename or the error message to reply with
+func (ws *WhatsAppService) saveIncomingImage(ctx context.Context, chatKey string, img incomingImage) (string, string) {
+	imageData, err := ws.whatsappDownloader.DownloadImage(ctx, img.info, img.msg)
+	if err != nil {
+		fmt.Printf("Failed to download image %s: %v\n", img.info.ID, err)
+		return "", tools.ErrorMessageImageProcessing
+	}
+	if err := tools.ValidateImage(imageData); err != nil {
+		return "", fmt.Sprintf(tools.ErrorMessageImageValidation, err)
+	}
+
+	imagePath, err := ws.storeIncomingImage(imageData, img.info.ID, ws.whatsappDownloader.GetImageType(img.msg))
+	if err != nil {
+		fmt.Printf("Failed to save image %s: %v\n", img.info.ID, err)
+		return "", tools.ErrorMessageImageSave
 	}
-	ws.processedImages[chatKey][imageID] = true
-	fmt.Printf("Marked image as processed: %s for chat %s\n", imageID, chatKey)
+	filename := filepath.Base(imagePath)
+
+	// Remember the image so it can be quoted or analyzed later
+	ws.rememberImage(chatKey, img.info.ID, filename)
+	return filename, ""
+}
+
+// storeIncomingImage saves a received image, or a frame taken from a video or sticker,
+// stripping its metadata when configured. Every incoming image is saved through here.
+func (ws *WhatsAppService) storeIncomingImage(data []byte, filename string, mimeType string) (string, error) {
+	if ws.stripImageMetadata {
+		return tools.SaveImageToDirStripped(data, ws.imageDir(), filename, mimeType)
+	}
+	return tools.SaveImageToDir(data, ws.imageDir(), filename, mimeType)
+}
+
+// imageDir is where the service's images are stored: the downloader's directory, which
+// for a managed client is its own data/<phoneID>, so its AI tools find them
+func (ws *WhatsAppService) imageDir() string {
+	if ws.whatsappDownloader != nil {
+		if dir := ws.whatsappDownloader.DataDir(); dir != "" {
+			return dir
+		}
+	}
+	return tools.DataDir()
+}
+
+// handleAudioMessageWithAI transcribes a voice note and answers it like a text message
+func (ws *WhatsAppService) handleAudioMessageWithAI(info types.MessageInfo, message *waProto.Message) {
+	aiTools := ws.aiToolsForChat(info.Chat.String())
+	if aiTools == nil {
+		ws.sendMessage(info.Chat, tools.ErrorMessageAIToolsNotInit)
+		return
+	}
+	if !ws.allowAIReply(info.Chat) {
+		return
+	}
+
+	ctx := context.Background()
+	audioMsg := message.AudioMessage
+
+	audioData, err := ws.whatsappDownloader.DownloadAudio(ctx, info, audioMsg)
+	if err != nil {
+		fmt.Printf("Failed to download audio from %s: %v\n", info.Sender.User, err)
+		ws.sendMessage(info.Chat, tools.ErrorMessageAudioProcessing)
+		return
+	}
+
+	transcript, err := aiTools.TranscribeAudio(ctx, audioData, ws.whatsappDownloader.GetAudioType(audioMsg))
+	if err != nil {
+		fmt.Printf("Failed to transcribe audio from %s: %v\n", info.Sender.User, err)
+		ws.sendMessage(info.Chat, tools.ErrorMessageAudioProcessing)
+		return
+	}
+	if transcript == "" {
+		ws.sendMessage(info.Chat, tools.ErrorMessageAudioEmpty)
+		return
+	}
+
+	fmt.Printf("Transcribed audio from %s: %s\n", info.Sender.User, transcript)
+	ws.handleAIResponseWithTyping(info, transcript, message)
+}
+
+// handleVideoMessageWithAI answers a captioned video using a still frame, falling back
+// to the thumbnail embedded in the message when the video cannot be decoded
+func (ws *WhatsAppService) handleVideoMessageWithAI(info types.MessageInfo, message *waProto.Message, caption string) {
+	chatKey := info.Chat.String()
+	videoMsg := message.VideoMessage
+	original := ws.replyTarget(info, message)
+	aiTools := ws.aiToolsForChat(chatKey)
+	if aiTools == nil {
+		ws.sendReply(info.Chat, original, tools.ErrorMessageAIToolsNotInit)
+		return
+	}
+	if !ws.allowAIReply(info.Chat) {
+		return
+	}
+
+	ctx := context.Background()
+	var frame []byte
+	videoData, err := ws.whatsappDownloader.DownloadVideo(ctx, info, videoMsg)
+	if err != nil {
+		fmt.Printf("Failed to download video from %s: %v\n", info.Sender.User, err)
+	} else if frame, err = tools.ExtractVideoThumbnail(videoData); err != nil {
+		fmt.Printf("Failed to extract video frame, using embedded thumbnail: %v\n", err)
+	}
+	if len(frame) == 0 {
+		frame = videoMsg.GetJPEGThumbnail()
+	}
+	if len(frame) == 0 {
+		ws.sendReply(info.Chat, original, tools.ErrorMessageVideoProcessing)
+		return
+	}
+
+	filename, err := ws.storeIncomingImage(frame, fmt.Sprintf("video_%s.jpg", info.ID), "image/jpeg")
+	if err != nil {
+		fmt.Printf("Failed to save video frame: %v\n", err)
+		ws.sendReply(info.Chat, original, tools.ErrorMessageImageSave)
+		return
+	}
+
+	prompt := fmt.Sprintf(tools.VideoFrameTemplate, caption)
+	response, err := aiTools.ProcessImageWithAI(ctx, prompt, []string{filepath.Base(filename)}, []string{info.ID}, ws.history(chatKey), nil)
+	if err != nil {
+		fmt.Printf("Failed to process video frame with AI: %v\n", err)
+		ws.sendReply(info.Chat, original, imageErrorMessage(err, tools.ErrorMessageVideoProcessing))
+		return
+	}
+
+	ws.sendAIReply(info.Chat, original, chatKey, response)
+}
+
+// handleStickerMessageWithAI converts a sticker to a JPEG and lets the AI comment on it;
+// animated stickers use their first frame, falling back to the embedded PNG thumbnail
+func (ws *WhatsAppService) handleStickerMessageWithAI(info types.MessageInfo, stickerMsg *waProto.StickerMessage) {
+	chatKey := info.Chat.String()
+	aiTools := ws.aiToolsForChat(chatKey)
+	if aiTools == nil {
+		ws.sendMessage(info.Chat, tools.ErrorMessageAIToolsNotInit)
+		return
+	}
+	if !ws.allowAIReply(info.Chat) {
+		return
+	}
+
+	ctx := context.Background()
+	var frame []byte
+	stickerData, err := ws.whatsappDownloader.DownloadSticker(ctx, info, stickerMsg)
+	if err != nil {
+		fmt.Printf("Failed to download sticker from %s: %v\n", info.Sender.User, err)
+	} else if frame, err = tools.StickerToJPEG(stickerData); err != nil {
+		fmt.Printf("Failed to convert sticker, using embedded thumbnail: %v\n", err)
+	}
+	mimeType := "image/jpeg"
+	if len(frame) == 0 && len(stickerMsg.GetPngThumbnail()) > 0 {
+		frame, mimeType = stickerMsg.GetPngThumbnail(), "image/png"
+	}
+	if len(frame) == 0 {
+		ws.sendMessage(info.Chat, tools.ErrorMessageStickerProcessing)
+		return
+	}
+
+	filename, err := ws.storeIncomingImage(frame, fmt.Sprintf("sticker_%s", info.ID), mimeType)
+	if err != nil {
+		fmt.Printf("Failed to save sticker image: %v\n", err)
+		ws.sendMessage(info.Chat, tools.ErrorMessageImageSave)
+		return
+	}
+
+	// Stickers carry no caption
+	prompt := fmt.Sprintf(tools.StickerTemplate, tools.DefaultImagePrompt)
+	response, err := aiTools.ProcessImageWithAI(ctx, prompt, []string{filepath.Base(filename)}, []string{info.ID}, ws.history(chatKey), nil)
+	if err != nil {
+		fmt.Printf("Failed to process sticker with AI: %v\n", err)
+		ws.sendMessage(info.Chat, imageErrorMessage(err, tools.ErrorMessageStickerProcessing))
+		return
+	}
+
+	ws.sendAIMessage(info.Chat, chatKey, response)
+}
+
+func (ws *WhatsAppService) findReferencedImages(message string, chatKey string, quotedMessageID string) []map[string]string {
+	// Implementation would be moved here...
+	return nil
 }
 
 func (ws *WhatsAppService) storeImageInHistory(to types.JID, chat types.JID, imgMsg *waProto.ImageMessage, caption string, messageID string) {
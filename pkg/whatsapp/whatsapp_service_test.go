@@ -0,0 +1,30 @@
+package whatsapp
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+func TestHandleMessageSkipsStaleMessages(t *testing.T) {
+	ws, client := newTestService(t)
+	enableTestAI(t, ws)
+	ws.staleThreshold = 5 * time.Minute
+	chat := types.NewJID("628120000000", types.DefaultUserServer)
+	ws.SetAIEnabled(chat.String(), true)
+
+	// Backlog delivered after a reconnect
+	stale := incomingText(chat, "OLD", "sent an hour ago")
+	stale.Info.Timestamp = time.Now().Add(-time.Hour)
+	ws.handleMessage(stale)
+	ws.handleMessage(incomingText(chat, "NEW", "sent just now"))
+
+	waitForReply(t, client, chat, "sent just now")
+	for _, text := range client.SentTexts(chat) {
+		if strings.Contains(text, "sent an hour ago") {
+			t.Errorf("stale message was answered: %q", text)
+		}
+	}
+}
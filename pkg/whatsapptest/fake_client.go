@@ -0,0 +1,289 @@
+// Package whatsapptest provides a fake WhatsApp client for testing code that talks to
+// WhatsApp through tools.WhatsAppClient.
+package whatsapptest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"auto-lmk/pkg/tools"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/proto/waCommon"
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/proto/waHistorySync"
+	"go.mau.fi/whatsmeow/types"
+	"google.golang.org/protobuf/proto"
+)
+
+// SentMessage is a message sent through a FakeClient
+type SentMessage struct {
+	To      types.JID
+	ID      types.MessageID
+	Message *waE2E.Message
+}
+
+// Text returns the text of a sent text message, or "" for other messages
+func (m SentMessage) Text() string {
+	if text := m.Message.GetConversation(); text != "" {
+		return text
+	}
+	return m.Message.GetExtendedTextMessage().GetText()
+}
+
+// ChatPresence is a typing indicator update sent through a FakeClient
+type ChatPresence struct {
+	Chat  types.JID
+	State types.ChatPresence
+}
+
+// FakeClient is an in-memory tools.WhatsAppClient. It records what is sent instead of
+// talking to WhatsApp; set the exported error fields to make calls fail. It is safe for
+// concurrent use.
+type FakeClient struct {
+	// SendErr, when set, is returned by SendMessage and nothing is recorded
+	SendErr error
+	// Downloads maps a media's direct path to the data Download returns for it; media
+	// that isn't in the map fails to download
+	Downloads map[string][]byte
+	// ConnectErr, when set, is returned by Connect
+	ConnectErr error
+
+	mu            sync.Mutex
+	connected     bool
+	nextID        int
+	handlers      []whatsmeow.EventHandler
+	sent          []SentMessage
+	read          []types.MessageID
+	chatPresences []ChatPresence
+	subscribed    []types.JID
+	uploads       [][]byte
+	mediaRetries  []types.MessageID
+}
+
+var _ tools.WhatsAppClient = (*FakeClient)(nil)
+
+// NewFakeClient creates a disconnected fake client
+func NewFakeClient() *FakeClient {
+	return &FakeClient{Downloads: make(map[string][]byte)}
+}
+
+// Sent returns the messages sent so far, oldest first
+func (c *FakeClient) Sent() []SentMessage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]SentMessage(nil), c.sent...)
+}
+
+// SentTexts returns the text of every message sent to a chat, oldest first
+func (c *FakeClient) SentTexts(to types.JID) []string {
+	var texts []string
+	for _, msg := range c.Sent() {
+		if msg.To == to {
+			texts = append(texts, msg.Text())
+		}
+	}
+	return texts
+}
+
+// Read returns the IDs of messages marked as read
+func (c *FakeClient) Read() []types.MessageID {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]types.MessageID(nil), c.read...)
+}
+
+// ChatPresences returns the typing indicator updates sent so far
+func (c *FakeClient) ChatPresences() []ChatPresence {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]ChatPresence(nil), c.chatPresences...)
+}
+
+// Subscribed returns the contacts whose presence was subscribed to
+func (c *FakeClient) Subscribed() []types.JID {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]types.JID(nil), c.subscribed...)
+}
+
+// MediaRetries returns the IDs of messages whose media was requested again; answer
+// them by dispatching an *events.MediaRetry
+func (c *FakeClient) MediaRetries() []types.MessageID {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]types.MessageID(nil), c.mediaRetries...)
+}
+
+// IsConnected reports whether Connect was called without a later Disconnect
+func (c *FakeClient) IsConnected() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.connected
+}
+
+// Dispatch delivers an event, e.g. an *events.Message, to the registered handlers
+func (c *FakeClient) Dispatch(evt any) {
+	c.mu.Lock()
+	handlers := append([]whatsmeow.EventHandler(nil), c.handlers...)
+	c.mu.Unlock()
+
+	for _, handler := range handlers {
+		handler(evt)
+	}
+}
+
+func (c *FakeClient) Connect() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.ConnectErr != nil {
+		return c.ConnectErr
+	}
+	c.connected = true
+	return nil
+}
+
+func (c *FakeClient) Disconnect() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.connected = false
+}
+
+// GetQRChannel returns a channel that is closed right away, as if login timed out
+func (c *FakeClient) GetQRChannel(ctx context.Context) (<-chan whatsmeow.QRChannelItem, error) {
+	ch := make(chan whatsmeow.QRChannelItem)
+	close(ch)
+	return ch, nil
+}
+
+// PairPhone returns a fixed pairing code
+func (c *FakeClient) PairPhone(ctx context.Context, phone string, showPushNotification bool, clientType whatsmeow.PairClientType, clientDisplayName string) (string, error) {
+	return "FAKE-CODE", nil
+}
+
+func (c *FakeClient) AddEventHandler(handler whatsmeow.EventHandler) uint32 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.handlers = append(c.handlers, handler)
+	return uint32(len(c.handlers))
+}
+
+func (c *FakeClient) SendMessage(ctx context.Context, to types.JID, message *waE2E.Message, extra ...whatsmeow.SendRequestExtra) (whatsmeow.SendResponse, error) {
+	if c.SendErr != nil {
+		return whatsmeow.SendResponse{}, c.SendErr
+	}
+
+	id := c.GenerateMessageID()
+	if len(extra) > 0 && extra[0].ID != "" {
+		id = extra[0].ID
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sent = append(c.sent, SentMessage{To: to, ID: id, Message: message})
+	return whatsmeow.SendResponse{ID: id, Timestamp: time.Now()}, nil
+}
+
+// GenerateMessageID returns sequential IDs: FAKE1, FAKE2, ...
+func (c *FakeClient) GenerateMessageID() types.MessageID {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.nextID++
+	return fmt.Sprintf("FAKE%d", c.nextID)
+}
+
+func (c *FakeClient) BuildEdit(chat types.JID, id types.MessageID, newContent *waE2E.Message) *waE2E.Message {
+	return &waE2E.Message{
+		ProtocolMessage: &waE2E.ProtocolMessage{
+			Key:           &waCommon.MessageKey{FromMe: proto.Bool(true), ID: proto.String(id), RemoteJID: proto.String(chat.String())},
+			Type:          waE2E.ProtocolMessage_MESSAGE_EDIT.Enum(),
+			EditedMessage: newContent,
+		},
+	}
+}
+
+func (c *FakeClient) BuildReaction(chat, sender types.JID, id types.MessageID, reaction string) *waE2E.Message {
+	return &waE2E.Message{
+		ReactionMessage: &waE2E.ReactionMessage{
+			Key:  &waCommon.MessageKey{ID: proto.String(id), RemoteJID: proto.String(chat.String()), Participant: proto.String(sender.String())},
+			Text: proto.String(reaction),
+		},
+	}
+}
+
+func (c *FakeClient) BuildHistorySyncRequest(lastKnownMessageInfo *types.MessageInfo, count int) *waE2E.Message {
+	return &waE2E.Message{
+		ProtocolMessage: &waE2E.ProtocolMessage{
+			Type: waE2E.ProtocolMessage_PEER_DATA_OPERATION_REQUEST_MESSAGE.Enum(),
+			PeerDataOperationRequestMessage: &waE2E.PeerDataOperationRequestMessage{
+				PeerDataOperationRequestType: waE2E.PeerDataOperationRequestType_HISTORY_SYNC_ON_DEMAND.Enum(),
+				HistorySyncOnDemandRequest: &waE2E.PeerDataOperationRequestMessage_HistorySyncOnDemandRequest{
+					ChatJID:          proto.String(lastKnownMessageInfo.Chat.String()),
+					OldestMsgID:      proto.String(lastKnownMessageInfo.ID),
+					OnDemandMsgCount: proto.Int32(int32(count)),
+				},
+			},
+		},
+	}
+}
+
+// Upload records the data and returns a response pointing at a fake URL
+func (c *FakeClient) Upload(ctx context.Context, plaintext []byte, appInfo whatsmeow.MediaType) (whatsmeow.UploadResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.uploads = append(c.uploads, plaintext)
+	path := fmt.Sprintf("/fake/%d", len(c.uploads))
+	return whatsmeow.UploadResponse{URL: "https://example.invalid" + path, DirectPath: path, FileLength: uint64(len(plaintext))}, nil
+}
+
+// Download returns the data registered in Downloads for the media's direct path
+func (c *FakeClient) Download(ctx context.Context, msg whatsmeow.DownloadableMessage) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	data, ok := c.Downloads[msg.GetDirectPath()]
+	if !ok {
+		return nil, whatsmeow.ErrMediaDownloadFailedWith404
+	}
+	return data, nil
+}
+
+// DownloadHistorySync returns an empty history sync of the notification's type
+func (c *FakeClient) DownloadHistorySync(ctx context.Context, notif *waE2E.HistorySyncNotification, synchronousStorage bool) (*waHistorySync.HistorySync, error) {
+	syncType := waHistorySync.HistorySync_HistorySyncType(notif.GetSyncType())
+	return &waHistorySync.HistorySync{SyncType: &syncType}, nil
+}
+
+// SendMediaRetryReceipt records the request; no answer is sent
+func (c *FakeClient) SendMediaRetryReceipt(ctx context.Context, message *types.MessageInfo, mediaKey []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.mediaRetries = append(c.mediaRetries, message.ID)
+	return nil
+}
+
+func (c *FakeClient) MarkRead(ctx context.Context, ids []types.MessageID, timestamp time.Time, chat, sender types.JID, receiptTypeExtra ...types.ReceiptType) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.read = append(c.read, ids...)
+	return nil
+}
+
+func (c *FakeClient) SendPresence(ctx context.Context, state types.Presence) error {
+	return nil
+}
+
+func (c *FakeClient) SendChatPresence(ctx context.Context, jid types.JID, state types.ChatPresence, media types.ChatPresenceMedia) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.chatPresences = append(c.chatPresences, ChatPresence{Chat: jid, State: state})
+	return nil
+}
+
+func (c *FakeClient) SubscribePresence(ctx context.Context, jid types.JID) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.subscribed = append(c.subscribed, jid)
+	return nil
+}